@@ -0,0 +1,94 @@
+package audit
+
+import (
+	"github.com/blang/semver/v4"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+)
+
+const (
+	EventChannelArchived         = "channel.archived"
+	EventChannelWarned           = "channel.warned"
+	EventChannelPurged           = "channel.purged"
+	EventRunCompleted            = "run.completed"
+	EventUserRemovedFromAllTeams = "user.removed_from_all_teams"
+	EventUserMembershipsRestored = "user.memberships_restored"
+	EventUserOffboarded          = "user.offboarded"
+
+	// minAuditServerVersion is the first server version supporting LogAuditRec for plugins.
+	minAuditServerVersion = "10.10.0"
+)
+
+// Record is an audit entry for an action taken by the plugin.
+type Record struct {
+	Event   string
+	Status  string // model.AuditStatusSuccess or model.AuditStatusFail
+	ActorID string // user that triggered the action; empty for scheduled jobs
+	Data    map[string]any
+	Error   string
+}
+
+// Sink receives a copy of every audit record, such as for forwarding records to external systems.
+// Send must not block.
+type Sink interface {
+	Send(rec Record)
+}
+
+// Logger writes audit records to the server audit log. Servers that don't support audit
+// logging for plugins get the records in the regular server log instead.
+type Logger struct {
+	papi      plugin.API
+	supported bool
+	sinks     []Sink
+}
+
+func NewLogger(papi plugin.API, sinks ...Sink) *Logger {
+	supported := false
+	if current, err := semver.ParseTolerant(papi.GetServerVersion()); err == nil {
+		supported = current.GTE(semver.MustParse(minAuditServerVersion))
+	}
+
+	return &Logger{
+		papi:      papi,
+		supported: supported,
+		sinks:     sinks,
+	}
+}
+
+// Log writes the record and sends it to the sinks. A nil Logger ignores all records.
+func (l *Logger) Log(rec Record) {
+	if l == nil {
+		return
+	}
+
+	for _, sink := range l.sinks {
+		sink.Send(rec)
+	}
+
+	if !l.supported {
+		keyValuePairs := []any{"event", rec.Event, "status", rec.Status, "actor_id", rec.ActorID}
+		for k, v := range rec.Data {
+			keyValuePairs = append(keyValuePairs, k, v)
+		}
+		if rec.Error != "" {
+			keyValuePairs = append(keyValuePairs, "error", rec.Error)
+		}
+		l.papi.LogInfo("Audit record", keyValuePairs...)
+		return
+	}
+
+	l.papi.LogAuditRec(&model.AuditRecord{
+		EventName: rec.Event,
+		Status:    rec.Status,
+		EventData: model.AuditEventData{
+			Parameters: rec.Data,
+		},
+		Actor: model.AuditEventActor{
+			UserId: rec.ActorID,
+		},
+		Error: model.AuditEventError{
+			Description: rec.Error,
+		},
+	})
+}
@@ -57,7 +57,8 @@ func (b *Bot) SendPostWithAttachment(channelID string, msg string, file *model.F
 	return b.client.Post.CreatePost(post)
 }
 
-func (b *Bot) SendDirectPost(userID string, msg string) error {
+// SendDirectPost sends a direct message from the bot to a user, with optional message attachments.
+func (b *Bot) SendDirectPost(userID string, msg string, attachments ...*model.SlackAttachment) error {
 	channel, err := b.client.Channel.GetDirect(userID, b.botID)
 	if err != nil {
 		return fmt.Errorf("bot cannot send direct message: %w", err)
@@ -68,6 +69,9 @@ func (b *Bot) SendDirectPost(userID string, msg string) error {
 		ChannelId: channel.Id,
 		Message:   msg,
 	}
+	if len(attachments) > 0 {
+		model.ParseSlackAttachment(post, attachments)
+	}
 	return b.client.Post.CreatePost(post)
 }
 
@@ -83,3 +87,8 @@ func (b *Bot) SendPost(channelID string, msg string) error {
 func (b *Bot) UploadFile(content *bytes.Buffer, fileName, adminChannel string) (*model.FileInfo, error) {
 	return b.client.File.Upload(content, fileName, adminChannel)
 }
+
+// UserID returns the user ID of the bot.
+func (b *Bot) UserID() string {
+	return b.botID
+}
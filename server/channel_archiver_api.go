@@ -0,0 +1,625 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/kvstore"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	defaultStaleChannelsPerPage = 100
+
+	archiverRunKeyPrefix        = "arrun_"
+	archiverRunLockKey          = "archiver_run" // held while any run is active, so that one runs at a time across the cluster
+	archiverRunRetention        = 7 * 24 * time.Hour
+	archiverRunInterruptTimeout = 30 * time.Second // longer than the lock expiry, for locks left by a previous plugin instance
+	maxFinishedArchiverRuns     = 100              // finished runs kept in memory without a KV store
+
+	ArchiverRunStatusRunning   = "running"
+	ArchiverRunStatusCompleted = "completed"
+	ArchiverRunStatusCanceled  = "canceled"
+	ArchiverRunStatusFailed    = "failed"
+)
+
+var errArchiverRunInProgress = errors.New("is already in progress")
+
+type StaleChannel struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	TeamID      string `json:"team_id"`
+	Type        string `json:"type"`
+}
+
+type StaleChannelsResponse struct {
+	Channels []StaleChannel `json:"channels"`
+	Page     int            `json:"page"`
+	PerPage  int            `json:"per_page"`
+	HasMore  bool           `json:"has_more"`
+}
+
+type ArchiverRunPayload struct {
+	Days      int      `json:"days"`
+	Exclude   []string `json:"exclude"`
+	BatchSize int      `json:"batch_size"`
+	DryRun    bool     `json:"dry_run"`
+}
+
+type CancelArchiverRunPayload struct {
+	RunID string `json:"run_id"`
+}
+
+// ArchiverRun is the status of a Channel Archiver run started via the REST API.
+type ArchiverRun struct {
+	ID            string   `json:"id"`
+	RequesterID   string   `json:"requester_id"`
+	DryRun        bool     `json:"dry_run"`
+	Status        string   `json:"status"`
+	ExitReason    string   `json:"exit_reason,omitempty"`
+	Error         string   `json:"error,omitempty"`
+	StartAt       int64    `json:"start_at"`
+	EndAt         int64    `json:"end_at,omitempty"`
+	ChannelCount  int      `json:"channel_count"`
+	ChannelIDs    []string `json:"channel_ids"`
+	AgeInDays     int      `json:"age_in_days"`
+	BatchSize     int      `json:"batch_size"`
+	ExcludeFilter []string `json:"exclude"`
+
+	cancel context.CancelFunc
+}
+
+// archiverRunRegistry tracks the Channel Archiver runs started on this server. Only one run may be
+// active at a time; the cluster lock archiverRunLockKey extends this to the cluster. Runs are stored in the KV store, so that every server of the cluster can report
+// their status.
+type archiverRunRegistry struct {
+	mux    sync.Mutex
+	kv     *pluginapi.KVService // optional, runs are only kept in memory without it
+	runs   map[string]*ArchiverRun
+	active string
+}
+
+func newArchiverRunRegistry(kv *pluginapi.KVService) *archiverRunRegistry {
+	return &archiverRunRegistry{
+		kv:   kv,
+		runs: make(map[string]*ArchiverRun),
+	}
+}
+
+func archiverRunKey(runID string) string {
+	return archiverRunKeyPrefix + runID
+}
+
+// start registers and stores a new run, returning an error if another run is still active.
+func (reg *archiverRunRegistry) start(run *ArchiverRun) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if reg.active != "" {
+		return fmt.Errorf("run %s %w", reg.active, errArchiverRunInProgress)
+	}
+
+	if err := reg.save(run, 0); err != nil {
+		return err
+	}
+
+	reg.runs[run.ID] = run
+	reg.active = run.ID
+	return nil
+}
+
+// update applies fn to the run under lock and stores the run. Finished runs are kept for
+// archiverRunRetention, or in memory up to maxFinishedArchiverRuns without a KV store.
+func (reg *archiverRunRegistry) update(runID string, fn func(run *ArchiverRun)) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	run, ok := reg.runs[runID]
+	if !ok {
+		return nil
+	}
+	fn(run)
+	if run.Status == ArchiverRunStatusRunning {
+		return reg.save(run, 0)
+	}
+
+	if reg.active == runID {
+		reg.active = ""
+	}
+	if reg.kv == nil {
+		reg.prune()
+		return nil
+	}
+	delete(reg.runs, runID)
+	return reg.save(run, archiverRunRetention)
+}
+
+// prune drops the oldest finished runs kept in memory beyond maxFinishedArchiverRuns.
+func (reg *archiverRunRegistry) prune() {
+	finished := make([]*ArchiverRun, 0, len(reg.runs))
+	for _, run := range reg.runs {
+		if run.Status != ArchiverRunStatusRunning {
+			finished = append(finished, run)
+		}
+	}
+	if len(finished) <= maxFinishedArchiverRuns {
+		return
+	}
+
+	slices.SortFunc(finished, func(a, b *ArchiverRun) int {
+		return cmp.Compare(a.EndAt, b.EndAt)
+	})
+	for _, run := range finished[:len(finished)-maxFinishedArchiverRuns] {
+		delete(reg.runs, run.ID)
+	}
+}
+
+// get returns a copy of the run, or nil if the run does not exist. Runs not running on this server
+// are read from the KV store.
+func (reg *archiverRunRegistry) get(runID string) *ArchiverRun {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	run, ok := reg.runs[runID]
+	if !ok {
+		if reg.kv == nil {
+			return nil
+		}
+		run, _ = reg.load(runID)
+		return run
+	}
+
+	runCopy := *run
+	runCopy.ChannelIDs = append([]string{}, run.ChannelIDs...)
+	runCopy.ExcludeFilter = append([]string{}, run.ExcludeFilter...)
+	runCopy.cancel = nil
+	return &runCopy
+}
+
+// cancel requests cancellation of a run on this server, returning false if the run is not running
+// here.
+func (reg *archiverRunRegistry) cancel(runID string) bool {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	run, ok := reg.runs[runID]
+	if !ok {
+		return false
+	}
+	if run.cancel != nil {
+		run.cancel()
+	}
+	return true
+}
+
+func (reg *archiverRunRegistry) cancelAll() {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	for _, run := range reg.runs {
+		if run.cancel != nil {
+			run.cancel()
+		}
+	}
+}
+
+// interrupted returns the IDs of the stored runs that are still running but not on this server.
+func (reg *archiverRunRegistry) interrupted() ([]string, error) {
+	if reg.kv == nil {
+		return nil, nil
+	}
+
+	keys, err := kvstore.ListKeysWithPrefix(reg.kv, archiverRunKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list Channel Archiver runs: %w", err)
+	}
+
+	runIDs := make([]string, 0)
+	for _, key := range keys {
+		runID := strings.TrimPrefix(key, archiverRunKeyPrefix)
+		run, err := reg.load(runID)
+		if err != nil {
+			return nil, err
+		}
+		if run != nil && run.Status == ArchiverRunStatusRunning && !reg.isRunningHere(runID) {
+			runIDs = append(runIDs, runID)
+		}
+	}
+	return runIDs, nil
+}
+
+func (reg *archiverRunRegistry) isRunningHere(runID string) bool {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	_, ok := reg.runs[runID]
+	return ok
+}
+
+// fail marks a stored run that no server is running anymore as failed.
+func (reg *archiverRunRegistry) fail(runID string, reason string) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if _, ok := reg.runs[runID]; ok || reg.kv == nil {
+		return nil
+	}
+
+	run, err := reg.load(runID)
+	if err != nil || run == nil || run.Status != ArchiverRunStatusRunning {
+		return err
+	}
+
+	run.Status = ArchiverRunStatusFailed
+	run.Error = reason
+	run.EndAt = model.GetMillis()
+	return reg.save(run, archiverRunRetention)
+}
+
+// save stores the run, expiring after expiry unless it is 0.
+func (reg *archiverRunRegistry) save(run *ArchiverRun, expiry time.Duration) error {
+	if reg.kv == nil {
+		return nil
+	}
+
+	var opts []pluginapi.KVSetOption
+	if expiry > 0 {
+		opts = append(opts, pluginapi.SetExpiry(expiry))
+	}
+	if _, err := reg.kv.Set(archiverRunKey(run.ID), run, opts...); err != nil {
+		return fmt.Errorf("cannot save Channel Archiver run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (reg *archiverRunRegistry) load(runID string) (*ArchiverRun, error) {
+	var run *ArchiverRun
+	if err := reg.kv.Get(archiverRunKey(runID), &run); err != nil {
+		return nil, fmt.Errorf("cannot get Channel Archiver run %s: %w", runID, err)
+	}
+	return run, nil
+}
+
+func (p *Plugin) handleGetStaleChannels(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	cfg := p.getConfiguration()
+
+	days := cfg.AgeInDays
+	if d := query.Get("days"); d != "" {
+		var err error
+		if days, err = config.ParseInt(d, config.MinAgeInDays, config.MaxAgeInDays); err != nil {
+			writeError(w, fmt.Sprintf("invalid days parameter: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	page := 0
+	if pg := query.Get("page"); pg != "" {
+		var err error
+		if page, err = strconv.Atoi(pg); err != nil || page < 0 {
+			writeError(w, "invalid page parameter: must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+	}
+
+	perPage := defaultStaleChannelsPerPage
+	if pp := query.Get("per_page"); pp != "" {
+		var err error
+		if perPage, err = config.ParseInt(pp, 1, config.DefaultListBatchSize); err != nil {
+			writeError(w, fmt.Sprintf("invalid per_page parameter: %s", err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	includePrivate := true
+	if ip := query.Get("include_private"); ip != "" {
+		var err error
+		if includePrivate, err = strconv.ParseBool(ip); err != nil {
+			writeError(w, "invalid include_private parameter: must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	opts := store.StaleChannelOpts{
+		AgeInDays:                 days,
+		ExcludeChannels:           append(config.SplitList(query.Get("exclude")), cfg.GetExcludeChannels()...),
+		IncludeChannelTypeOpen:    true,
+		IncludeChannelTypePrivate: includePrivate,
+		AdminChannel:              cfg.AdminChannel,
+		TeamID:                    query.Get("team_id"),
+	}
+
+	staleChannels, hasMore, err := channels.GetStaleChannelsPage(p.SQLStore, p.Client, opts, channels.NewPolicyOpts(cfg), page, perPage)
+	if err != nil {
+		err = errors.Wrap(err, "error fetching stale channels")
+		p.API.LogError(err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	response := StaleChannelsResponse{
+		Channels: make([]StaleChannel, 0, len(staleChannels)),
+		Page:     page,
+		PerPage:  perPage,
+		HasMore:  hasMore,
+	}
+	for _, ch := range staleChannels {
+		response.Channels = append(response.Channels, StaleChannel{
+			ID:          ch.Id,
+			Name:        ch.Name,
+			DisplayName: ch.DisplayName,
+			TeamID:      ch.TeamId,
+			Type:        string(ch.Type),
+		})
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (p *Plugin) handleStartArchiverRun(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	requesterID, ok := p.requireSystemAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	var payload ArchiverRunPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, fmt.Sprintf("error decoding run payload: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	cfg := p.getConfiguration()
+
+	if payload.Days == 0 {
+		payload.Days = cfg.AgeInDays
+	}
+	if payload.Days < config.MinAgeInDays || payload.Days > config.MaxAgeInDays {
+		writeError(w, fmt.Sprintf("days must be between %d and %d", config.MinAgeInDays, config.MaxAgeInDays), http.StatusBadRequest)
+		return
+	}
+
+	if payload.BatchSize == 0 {
+		payload.BatchSize = config.DefaultArchiveBatchSize
+	}
+	if payload.BatchSize < config.MinBatchSize || payload.BatchSize > config.MaxBatchSize {
+		writeError(w, fmt.Sprintf("batch_size must be between %d and %d", config.MinBatchSize, config.MaxBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	messages, err := channels.NewMessageTemplates(cfg)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &ArchiverRun{
+		ID:            model.NewId(),
+		RequesterID:   requesterID,
+		DryRun:        payload.DryRun,
+		Status:        ArchiverRunStatusRunning,
+		StartAt:       model.GetMillis(),
+		ChannelIDs:    make([]string, 0),
+		AgeInDays:     payload.Days,
+		BatchSize:     payload.BatchSize,
+		ExcludeFilter: append(append([]string{}, payload.Exclude...), cfg.GetExcludeChannels()...),
+		cancel:        cancel,
+	}
+
+	clusterLock, locked, err := p.tryClusterLock(archiverRunLockKey)
+	if err != nil {
+		cancel()
+		writeError(w, fmt.Sprintf("cannot create Channel Archiver lock: %s", err.Error()), http.StatusInternalServerError)
+		return
+	}
+	if !locked {
+		cancel()
+		writeError(w, fmt.Sprintf("a Channel Archiver run %s", errArchiverRunInProgress), http.StatusConflict)
+		return
+	}
+
+	if err := p.archiverRuns.start(run); err != nil {
+		cancel()
+		clusterLock.Unlock()
+		status := http.StatusInternalServerError
+		if errors.Is(err, errArchiverRunInProgress) {
+			status = http.StatusConflict
+		}
+		writeError(w, err.Error(), status)
+		return
+	}
+
+	opts := channels.ArchiverOpts{
+		StaleChannelOpts: store.StaleChannelOpts{
+			AgeInDays:                 run.AgeInDays,
+			ExcludeChannels:           run.ExcludeFilter,
+			IncludeChannelTypeOpen:    true,
+			IncludeChannelTypePrivate: true,
+			AdminChannel:              cfg.AdminChannel,
+		},
+		BatchSize:   run.BatchSize,
+		ListOnly:    run.DryRun,
+		ArchiveTeam: cfg.ArchiveTeam,
+		Messages:    messages,
+		I18n:        p.i18n,
+		Locale:      cfg.ChannelPostLocale,
+		ProgressFn: func(results *channels.ArchiverResults) {
+			err := p.archiverRuns.update(run.ID, func(r *ArchiverRun) {
+				r.ChannelIDs = append([]string{}, results.ChannelIDs...)
+				r.ChannelCount = len(r.ChannelIDs)
+			})
+			if err != nil {
+				p.API.LogWarn("Cannot save Channel Archiver run progress.", "run_id", run.ID, "err", err.Error())
+			}
+		},
+		Bot:     p.bot,
+		Audit:   p.audit,
+		ActorID: requesterID,
+
+		Policies: channels.NewPolicyOpts(cfg),
+	}
+
+	go p.runArchiver(ctx, run.ID, clusterLock, opts)
+
+	writeJSON(w, http.StatusAccepted, p.archiverRuns.get(run.ID))
+}
+
+// runArchiver runs the Channel Archiver while holding a cluster lock, so that the run is not marked as
+// interrupted by another server while it runs. clusterLock is released once the run is finished.
+func (p *Plugin) runArchiver(ctx context.Context, runID string, clusterLock *cluster.Mutex, opts channels.ArchiverOpts) {
+	defer clusterLock.Unlock()
+
+	var results *channels.ArchiverResults
+	mutex, err := cluster.NewMutex(p.API, archiverRunKey(runID))
+	if err == nil {
+		mutex.Lock()
+		defer mutex.Unlock()
+		results, err = channels.ArchiveStaleChannels(ctx, p.SQLStore, p.Client, opts)
+	} else {
+		err = fmt.Errorf("cannot create Channel Archiver run lock: %w", err)
+	}
+
+	saveErr := p.archiverRuns.update(runID, func(run *ArchiverRun) {
+		run.EndAt = model.GetMillis()
+		run.cancel = nil
+		if results != nil {
+			run.ExitReason = string(results.ExitReason)
+			run.ChannelIDs = append([]string{}, results.ChannelIDs...)
+			run.ChannelCount = len(run.ChannelIDs)
+		}
+
+		switch {
+		case err != nil:
+			run.Status = ArchiverRunStatusFailed
+			run.Error = err.Error()
+		case results.ExitReason == channels.ReasonCancelled:
+			run.Status = ArchiverRunStatusCanceled
+		default:
+			run.Status = ArchiverRunStatusCompleted
+		}
+	})
+
+	if err != nil {
+		p.API.LogError("Error running Channel Archiver via API", "run_id", runID, "err", err.Error())
+	}
+	if saveErr != nil {
+		p.API.LogError("Cannot save finished Channel Archiver run.", "run_id", runID, "err", saveErr.Error())
+	}
+}
+
+// failInterruptedArchiverRuns marks the runs interrupted by a plugin restart as failed. Runs still
+// running on another server of the cluster are left alone.
+func (p *Plugin) failInterruptedArchiverRuns() {
+	runIDs, err := p.archiverRuns.interrupted()
+	if err != nil {
+		p.API.LogError("Cannot list interrupted Channel Archiver runs.", "err", err.Error())
+		return
+	}
+
+	for _, runID := range runIDs {
+		go func(runID string) {
+			ctx, cancel := context.WithTimeout(context.Background(), archiverRunInterruptTimeout)
+			defer cancel()
+
+			mutex, err := cluster.NewMutex(p.API, archiverRunKey(runID))
+			if err != nil {
+				p.API.LogError("Cannot create Channel Archiver run lock.", "run_id", runID, "err", err.Error())
+				return
+			}
+			if err := mutex.LockWithContext(ctx); err != nil {
+				// the run is running on another server
+				return
+			}
+			defer mutex.Unlock()
+
+			if err := p.archiverRuns.fail(runID, "interrupted by a plugin restart"); err != nil {
+				p.API.LogError("Cannot save interrupted Channel Archiver run.", "run_id", runID, "err", err.Error())
+			}
+		}(runID)
+	}
+}
+
+func (p *Plugin) handleGetArchiverRunStatus(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	runID := r.URL.Query().Get("run_id")
+	if runID == "" {
+		writeError(w, "missing run_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	run := p.archiverRuns.get(runID)
+	if run == nil {
+		writeError(w, fmt.Sprintf("run %s not found", runID), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, run)
+}
+
+func (p *Plugin) handleCancelArchiverRun(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	var payload CancelArchiverRunPayload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		writeError(w, fmt.Sprintf("error decoding cancel payload: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if payload.RunID == "" {
+		writeError(w, "missing run_id in the request payload", http.StatusBadRequest)
+		return
+	}
+
+	canceled := p.archiverRuns.cancel(payload.RunID)
+	run := p.archiverRuns.get(payload.RunID)
+	switch {
+	case run == nil:
+		writeError(w, fmt.Sprintf("run %s not found", payload.RunID), http.StatusNotFound)
+	case !canceled && run.Status == ArchiverRunStatusRunning:
+		writeError(w, fmt.Sprintf("run %s is running on another server, send the request to that server", payload.RunID), http.StatusConflict)
+	default:
+		writeJSON(w, http.StatusOK, run)
+	}
+}
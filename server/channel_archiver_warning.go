@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	root "github.com/mattermost/mattermost-plugin-retention-tooling"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const warningKeepReason = "kept from an archive warning"
+
+var msgWarnedChannelArchived = &i18n.Message{
+	ID:    "archiver.warning_action.already_archived",
+	Other: "**{{.ChannelName}}** has already been archived.",
+}
+
+// pluginURL returns the server relative URL of a plugin route, as used by message attachment actions.
+func (p *Plugin) pluginURL(route string) string {
+	return fmt.Sprintf("/plugins/%s%s", root.Manifest.Id, route)
+}
+
+// handleWarningAction handles the keep and archive now buttons of archive warning messages.
+func (p *Plugin) handleWarningAction(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	userID := r.Header.Get("Mattermost-User-Id")
+	if userID == "" {
+		writeError(w, "request is not from an authenticated user", http.StatusUnauthorized)
+		return
+	}
+
+	var req model.PostActionIntegrationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, fmt.Sprintf("unable to decode request: %s", err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	channelID, _ := req.Context["channel_id"].(string)
+	action, _ := req.Context["action"].(string)
+	if !model.IsValidId(channelID) {
+		writeError(w, "invalid channel_id", http.StatusBadRequest)
+		return
+	}
+
+	loc := p.i18n.UserLocalizer(userID)
+
+	var msg string
+	var err error
+	switch action {
+	case channels.WarningActionKeep:
+		msg, err = p.keepWarnedChannel(userID, channelID, loc)
+	case channels.WarningActionArchive:
+		msg, err = p.archiveWarnedChannel(userID, channelID, loc)
+	default:
+		writeError(w, fmt.Sprintf("invalid action %q", action), http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		p.API.LogError("Error handling archive warning action", "action", action, "channel_id", channelID, "user_id", userID, "err", err.Error())
+		msg = loc.T(&i18n.Message{
+			ID:    "archiver.warning_action.error",
+			Other: "Something went wrong. Please try again or contact your system administrator.",
+		}, nil)
+	}
+
+	writeJSON(w, http.StatusOK, &model.PostActionIntegrationResponse{EphemeralText: msg})
+}
+
+func (p *Plugin) keepWarnedChannel(userID, channelID string, loc *i18n.Localizer) (string, error) {
+	channel, err := p.Client.Channel.Get(channelID)
+	if err != nil {
+		return "", fmt.Errorf("cannot get channel: %w", err)
+	}
+	if channel.DeleteAt != 0 {
+		return loc.T(msgWarnedChannelArchived, map[string]any{"ChannelName": channel.DisplayName}), nil
+	}
+	if !channels.CanActOnWarning(p.Client, userID, channel) {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.warning_action.keep_no_permission",
+			Other: "You must be a channel admin of **{{.ChannelName}}** to keep it.",
+		}, map[string]any{"ChannelName": channel.DisplayName}), nil
+	}
+
+	now := time.Now()
+	marker := &channels.KeepMarker{
+		ChannelID: channel.Id,
+		UserID:    userID,
+		Reason:    warningKeepReason,
+		CreateAt:  model.GetMillisForTime(now),
+	}
+	if maxKeepDays := p.getConfiguration().MaxKeepDays; maxKeepDays > 0 {
+		marker.ExpireAt = model.GetMillisForTime(now.AddDate(0, 0, maxKeepDays))
+	}
+
+	if err = channels.SaveKeepMarker(p.Client, marker); err != nil {
+		return "", err
+	}
+	if err = channels.DeleteChannelWarning(p.Client, channel.Id); err != nil {
+		return "", err
+	}
+
+	if marker.ExpireAt == 0 {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.warning_action.kept",
+			Other: "**{{.ChannelName}}** will be kept indefinitely and won't be archived.",
+		}, map[string]any{"ChannelName": channel.DisplayName}), nil
+	}
+	return loc.T(&i18n.Message{
+		ID:    "archiver.warning_action.kept_until",
+		Other: "**{{.ChannelName}}** will be kept until {{.Until}} and won't be archived.",
+	}, map[string]any{
+		"ChannelName": channel.DisplayName,
+		"Until":       model.GetTimeForMillis(marker.ExpireAt).UTC().Format("Jan 2, 2006"),
+	}), nil
+}
+
+func (p *Plugin) archiveWarnedChannel(userID, channelID string, loc *i18n.Localizer) (string, error) {
+	channel, err := p.Client.Channel.Get(channelID)
+	if err != nil {
+		return "", fmt.Errorf("cannot get channel: %w", err)
+	}
+	if channel.DeleteAt != 0 {
+		return loc.T(msgWarnedChannelArchived, map[string]any{"ChannelName": channel.DisplayName}), nil
+	}
+	if !channels.CanActOnWarning(p.Client, userID, channel) {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.warning_action.archive_no_permission",
+			Other: "You must be a channel admin of **{{.ChannelName}}** to archive it.",
+		}, map[string]any{"ChannelName": channel.DisplayName}), nil
+	}
+
+	cfg := p.getConfiguration()
+	messages, err := channels.NewMessageTemplates(cfg)
+	if err != nil {
+		return "", err
+	}
+
+	opts := channels.ArchiverOpts{
+		StaleChannelOpts: store.StaleChannelOpts{
+			AgeInDays: cfg.AgeInDays,
+		},
+		ArchiveTeam: cfg.ArchiveTeam,
+		Messages:    messages,
+		I18n:        p.i18n,
+		Locale:      cfg.ChannelPostLocale,
+		Bot:         p.bot,
+		Audit:       p.audit,
+		ActorID:     userID,
+	}
+	if _, err = channels.ArchiveChannel(p.Client, opts, channel); err != nil {
+		return "", err
+	}
+
+	return loc.T(&i18n.Message{
+		ID:    "archiver.warning_action.archived",
+		Other: "**{{.ChannelName}}** has been archived.",
+	}, map[string]any{"ChannelName": channel.DisplayName}), nil
+}
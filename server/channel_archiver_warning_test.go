@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+)
+
+func TestHandleWarningActionCreatorFallback(t *testing.T) {
+	const channelID = "abcdefghijklmnopqrstuvwxyz"
+	channel := &model.Channel{Id: channelID, Name: "stale", DisplayName: "Stale", CreatorId: "creator_id"}
+
+	// the channel has no admins, so its creator was warned
+	warning, err := json.Marshal(&channels.ChannelWarning{ChannelID: channelID, WarnedAt: 1, ArchiveAt: 2, RecipientIDs: []string{"creator_id"}})
+	require.NoError(t, err)
+
+	setup := func(userID string) (*Plugin, *plugintest.API) {
+		p := &Plugin{archiverRuns: newArchiverRunRegistry(nil)}
+		api := &plugintest.API{}
+		p.SetAPI(api)
+		p.Client = pluginapi.NewClient(api, nil)
+
+		api.On("GetChannel", channelID).Return(channel, nil)
+		api.On("HasPermissionTo", userID, model.PermissionManageSystem).Return(false)
+		api.On("GetChannelMember", channelID, userID).Return(&model.ChannelMember{ChannelId: channelID, UserId: userID}, nil)
+		api.On("KVGet", "warn_"+channelID).Return(warning, nil)
+		return p, api
+	}
+
+	click := func(t *testing.T, p *Plugin, userID string, action string) string {
+		body, err := json.Marshal(&model.PostActionIntegrationRequest{Context: map[string]any{"action": action, "channel_id": channelID}})
+		require.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPost, channels.WarningActionRoute, bytes.NewReader(body))
+		r.Header.Set("Mattermost-User-Id", userID)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(nil, w, r)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var resp model.PostActionIntegrationResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&resp))
+		return resp.EphemeralText
+	}
+
+	t.Run("the warned creator can keep the channel", func(t *testing.T) {
+		p, api := setup("creator_id")
+		api.On("KVSetWithOptions", "keep_"+channelID, mock.Anything, mock.Anything).Return(true, nil)
+		api.On("KVSetWithOptions", "warn_"+channelID, []byte(nil), mock.Anything).Return(true, nil)
+
+		assert.Equal(t, "**Stale** will be kept indefinitely and won't be archived.", click(t, p, "creator_id", channels.WarningActionKeep))
+		api.AssertCalled(t, "KVSetWithOptions", "keep_"+channelID, mock.Anything, mock.Anything)
+	})
+
+	t.Run("the warned creator can archive the channel", func(t *testing.T) {
+		p, api := setup("creator_id")
+		api.On("DeleteChannel", channelID).Return(nil)
+		api.On("KVSetWithOptions", "warn_"+channelID, []byte(nil), mock.Anything).Return(true, nil)
+
+		assert.Equal(t, "**Stale** has been archived.", click(t, p, "creator_id", channels.WarningActionArchive))
+		api.AssertCalled(t, "DeleteChannel", channelID)
+	})
+
+	t.Run("other members cannot act on the warning", func(t *testing.T) {
+		p, api := setup("member_id")
+
+		assert.Equal(t, "You must be a channel admin of **Stale** to keep it.", click(t, p, "member_id", channels.WarningActionKeep))
+		assert.Equal(t, "You must be a channel admin of **Stale** to archive it.", click(t, p, "member_id", channels.WarningActionArchive))
+		api.AssertNotCalled(t, "DeleteChannel", channelID)
+	})
+}
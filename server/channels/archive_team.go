@@ -0,0 +1,148 @@
+package channels
+
+import (
+	"fmt"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const (
+	originKeyPrefix = "origin_"
+
+	// archiveMembersPerPage is the number of channel members added to the archive team at a time.
+	archiveMembersPerPage = 200
+)
+
+// ChannelOrigin records where a channel lived before it was moved to the archive team, so it
+// can be moved back when restored.
+type ChannelOrigin struct {
+	ChannelID string `json:"channel_id"`
+	TeamID    string `json:"team_id"`
+	Name      string `json:"name"`
+	MovedAt   int64  `json:"moved_at"`
+}
+
+func originKey(channelID string) string {
+	return originKeyPrefix + channelID
+}
+
+// GetChannelOrigin returns the original team of a channel moved to the archive team, or nil
+// if the channel was never moved.
+func GetChannelOrigin(client *pluginapi.Client, channelID string) (*ChannelOrigin, error) {
+	var origin *ChannelOrigin
+	if err := client.KV.Get(originKey(channelID), &origin); err != nil {
+		return nil, fmt.Errorf("cannot get original team for channel %s: %w", channelID, err)
+	}
+	return origin, nil
+}
+
+// ResolveTeam finds a team by name or ID.
+func ResolveTeam(client *pluginapi.Client, teamRef string) (*model.Team, error) {
+	team, err := client.Team.GetByName(teamRef)
+	if err == nil {
+		return team, nil
+	}
+
+	if model.IsValidId(teamRef) {
+		return client.Team.Get(teamRef)
+	}
+	return nil, err
+}
+
+// moveChannelToTeam moves a channel to another team, recording its original team and name.
+// The channel is renamed if its name is already taken in the target team. Channel memberships
+// are left untouched so the channel can be moved back without losing members.
+func moveChannelToTeam(client *pluginapi.Client, channelID string, teamID string) (*ChannelOrigin, error) {
+	channel, err := client.Channel.Get(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get channel: %w", err)
+	}
+
+	origin := &ChannelOrigin{
+		ChannelID: channel.Id,
+		TeamID:    channel.TeamId,
+		Name:      channel.Name,
+		MovedAt:   model.GetMillis(),
+	}
+
+	if _, err = client.Channel.GetByName(teamID, channel.Name, true); err == nil {
+		channel.Name = uniqueChannelName(channel.Name, channel.Id)
+	}
+
+	// store the origin first so it is never lost, even if the move fails half way
+	if _, err = client.KV.Set(originKey(channel.Id), origin); err != nil {
+		return nil, fmt.Errorf("cannot save original team: %w", err)
+	}
+
+	channel.TeamId = teamID
+	if err = client.Channel.Update(channel); err != nil {
+		_ = client.KV.Delete(originKey(channel.Id))
+		return nil, fmt.Errorf("cannot move channel to team %s: %w", teamID, err)
+	}
+
+	return origin, nil
+}
+
+// MoveChannelBack moves a channel that was moved to the archive team back to its original team,
+// restoring its original name unless that name was taken in the meantime, and deletes the origin
+// record. It returns nil if the channel was never moved.
+func MoveChannelBack(client *pluginapi.Client, channelID string) (*ChannelOrigin, error) {
+	origin, err := GetChannelOrigin(client, channelID)
+	if err != nil || origin == nil {
+		return nil, err
+	}
+
+	channel, err := client.Channel.Get(channelID)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get channel: %w", err)
+	}
+
+	channel.Name = origin.Name
+	if other, err := client.Channel.GetByName(origin.TeamID, origin.Name, true); err == nil && other.Id != channel.Id {
+		channel.Name = uniqueChannelName(origin.Name, channel.Id)
+	}
+	channel.TeamId = origin.TeamID
+	if err = client.Channel.Update(channel); err != nil {
+		return nil, fmt.Errorf("cannot move channel back to team %s: %w", origin.TeamID, err)
+	}
+
+	if err = client.KV.Delete(originKey(channel.Id)); err != nil {
+		return nil, fmt.Errorf("cannot delete original team for channel %s: %w", channel.Id, err)
+	}
+	return origin, nil
+}
+
+// grantArchiveTeamAccess adds the members of a channel moved to the archive team to that team, so
+// they can still reach the channel. Members who cannot be added, such as deactivated users, are
+// skipped. It returns the number of members added.
+func grantArchiveTeamAccess(client *pluginapi.Client, channelID string, teamID string) int {
+	added := 0
+	for page := 0; ; page++ {
+		members, err := client.Channel.ListMembers(channelID, page, archiveMembersPerPage)
+		if err != nil {
+			client.Log.Warn("Cannot list channel members to add to the archive team", "channel_id", channelID, "err", err)
+			return added
+		}
+		for _, member := range members {
+			if _, err := client.Team.CreateMember(teamID, member.UserId); err != nil {
+				client.Log.Debug("Cannot add channel member to the archive team", "channel_id", channelID, "user_id", member.UserId, "err", err)
+				continue
+			}
+			added++
+		}
+		if len(members) < archiveMembersPerPage {
+			return added
+		}
+	}
+}
+
+// uniqueChannelName suffixes the channel name with part of the channel ID, keeping it within
+// the maximum channel name length.
+func uniqueChannelName(name string, channelID string) string {
+	suffix := "-" + channelID[:8]
+	if len(name)+len(suffix) > model.ChannelNameMaxLength {
+		name = name[:model.ChannelNameMaxLength-len(suffix)]
+	}
+	return name + suffix
+}
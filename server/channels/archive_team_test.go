@@ -0,0 +1,83 @@
+package channels
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+func TestUniqueChannelName(t *testing.T) {
+	channelID := model.NewId()
+
+	name := uniqueChannelName("town-hall", channelID)
+	assert.Equal(t, "town-hall-"+channelID[:8], name)
+
+	long := strings.Repeat("a", model.ChannelNameMaxLength)
+	name = uniqueChannelName(long, channelID)
+	assert.Len(t, name, model.ChannelNameMaxLength)
+	assert.True(t, strings.HasSuffix(name, "-"+channelID[:8]))
+}
+
+func TestGrantArchiveTeamAccess(t *testing.T) {
+	api := &plugintest.API{}
+	client := pluginapi.NewClient(api, nil)
+
+	api.On("GetChannelMembers", "channel_id", 0, archiveMembersPerPage).Return(model.ChannelMembers{
+		{ChannelId: "channel_id", UserId: "user1"},
+		{ChannelId: "channel_id", UserId: "deactivated"},
+	}, nil)
+	api.On("CreateTeamMember", "archive_team", "user1").Return(&model.TeamMember{TeamId: "archive_team", UserId: "user1"}, nil)
+	api.On("CreateTeamMember", "archive_team", "deactivated").Return(nil, &model.AppError{Message: "user is deactivated"})
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	assert.Equal(t, 1, grantArchiveTeamAccess(client, "channel_id", "archive_team"))
+	api.AssertExpectations(t)
+}
+
+func TestArchiveChannelMovedBackOnFailure(t *testing.T) {
+	api := &plugintest.API{}
+	client := pluginapi.NewClient(api, nil)
+
+	kv := map[string][]byte{}
+	api.On("KVGet", mock.Anything).Return(func(key string) ([]byte, *model.AppError) {
+		return kv[key], nil
+	})
+	api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, value []byte, _ model.PluginKVSetOptions) (bool, *model.AppError) {
+		if value == nil {
+			delete(kv, key)
+		} else {
+			kv[key] = value
+		}
+		return true, nil
+	})
+
+	var teamIDs []string
+	channel := &model.Channel{Id: "channel_id", Name: "stale", TeamId: "origin_team"}
+	api.On("GetTeamByName", "archive").Return(&model.Team{Id: "archive_team", Name: "archive"}, nil)
+	api.On("GetTeam", "origin_team").Return(&model.Team{Id: "origin_team", Name: "engineering"}, nil)
+	api.On("GetChannel", "channel_id").Return(func(string) (*model.Channel, *model.AppError) {
+		return channel.DeepCopy(), nil
+	})
+	api.On("GetChannelByName", mock.Anything, "stale", true).Return(nil, &model.AppError{Message: "not found"})
+	api.On("UpdateChannel", mock.Anything).Return(func(updated *model.Channel) (*model.Channel, *model.AppError) {
+		teamIDs = append(teamIDs, updated.TeamId)
+		channel = updated.DeepCopy()
+		return updated, nil
+	})
+	api.On("DeleteChannel", "channel_id").Return(&model.AppError{Message: "some database error"})
+	api.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	_, err := ArchiveChannel(client, ArchiverOpts{ArchiveTeam: "archive"}, channel.DeepCopy())
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"archive_team", "origin_team"}, teamIDs)
+	assert.Equal(t, "stale", channel.Name)
+	assert.Empty(t, kv, "the origin record is deleted once the channel is back")
+}
@@ -4,11 +4,15 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/mattermost/mattermost/server/public/model"
 	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
@@ -26,13 +30,29 @@ type ArchiverOpts struct {
 	BatchSize   int
 	ListOnly    bool // don't archive channels, just list results
 	MaxWarnings int
+	ArchiveTeam string // optional team name or ID that stale channels are moved to before archiving
+	WarningDays int    // if > 0, channel admins are warned this many days before their channels are archived
+
+	Policies PolicyOpts // optional policies archiving some channels sooner, without warning
+
+	WarningActionURL string            // optional URL handling the keep and archive now buttons of warning messages
+	Messages         *MessageTemplates // optional templates for the posts made by the archiver
+
+	I18n   *i18n.Bundle // optional translations; posts are in English without it
+	Locale string       // locale of channel and admin channel posts, the server locale if empty
 
 	ProgressFn func(results *ArchiverResults) // optional callback to receive results per batch
 	Bot        *bot.Bot                       // optional bot for posting channel archived notification posts
+	Audit      *audit.Logger                  // optional audit logger
+	ActorID    string                         // user that started the run; empty for scheduled jobs
 }
 
 type ArchiverResults struct {
 	ChannelsArchived []string
+	ChannelIDs       []string       // IDs of the archived (or listed) channels
+	ChannelsWarned   []string       // channels whose admins were warned instead of archiving the channel
+	PolicyCounts     map[string]int // number of channels archived (or listed) by each policy other than the inactive policy
+	StaleCount       int            // number of stale channels found, including channels left in place
 	ExitReason       Reason
 	Duration         time.Duration
 	start            time.Time
@@ -41,6 +61,9 @@ type ArchiverResults struct {
 func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts) (results *ArchiverResults, retErr error) {
 	results = &ArchiverResults{
 		ChannelsArchived: make([]string, 0),
+		ChannelIDs:       make([]string, 0),
+		ChannelsWarned:   make([]string, 0),
+		PolicyCounts:     make(map[string]int),
 		ExitReason:       ReasonDone,
 		start:            time.Now(),
 	}
@@ -53,8 +76,16 @@ func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 			results.ExitReason = ReasonError
 		}
 		results.Duration = time.Since(results.start)
+		logArchiverRunCompleted(opts, results, retErr)
 	}()
 
+	// channels marked as keep are excluded from both listing and archiving
+	staleOpts, err := excludeKeptChannels(client, opts.StaleChannelOpts)
+	if err != nil {
+		return results, err
+	}
+	opts.StaleChannelOpts = staleOpts
+
 	if opts.ListOnly {
 		return results, listStaleChannels(ctx, sqlstore, opts, results)
 	}
@@ -62,6 +93,7 @@ func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 	client.Log.Debug(
 		"Archiving stale channels.",
 		"AgeInDays", opts.StaleChannelOpts.AgeInDays,
+		"policies", opts.Policies,
 		"exclude", opts.StaleChannelOpts.ExcludeChannels,
 		"open", opts.StaleChannelOpts.IncludeChannelTypeOpen,
 		"private", opts.StaleChannelOpts.IncludeChannelTypePrivate,
@@ -72,29 +104,129 @@ func ArchiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 	return results, archiveStaleChannels(ctx, sqlstore, client, opts, results)
 }
 
+// GetStaleChannelsPage returns a single page of the channels a run would archive, excluding channels
+// marked as keep. As in a dry run, the channels selected by the enabled policies come first, each
+// listed once, followed by the inactive channels.
+func GetStaleChannelsPage(sqlstore *store.SQLStore, client *pluginapi.Client, opts store.StaleChannelOpts, policies PolicyOpts, page int, pageSize int) ([]*model.Channel, bool, error) {
+	opts, err := excludeKeptChannels(client, opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	selected := make([]*model.Channel, 0)
+	listed := make([]string, 0)
+	for _, policy := range extraPolicies(ArchiverOpts{StaleChannelOpts: opts, Policies: policies}) {
+		staleOpts := policy.staleOpts
+		staleOpts.ExcludeChannels = append(slices.Clone(staleOpts.ExcludeChannels), listed...)
+		policyChannels, _, err := sqlstore.GetStaleChannels(staleOpts, 0, 0)
+		if err != nil {
+			return nil, false, fmt.Errorf("cannot fetch %s: %w", policy.name, err)
+		}
+		for _, ch := range policyChannels {
+			selected = append(selected, ch)
+			listed = append(listed, ch.Id)
+		}
+	}
+
+	offset := page * pageSize
+	pageChannels := selected[min(offset, len(selected)):min(offset+pageSize, len(selected))]
+	if offset+pageSize < len(selected) {
+		return pageChannels, true, nil
+	}
+
+	staleOpts := opts
+	staleOpts.ExcludeChannels = append(slices.Clone(staleOpts.ExcludeChannels), listed...)
+	inactiveOffset := max(offset-len(selected), 0)
+	if len(pageChannels) == pageSize {
+		// the page is full, so the inactive channels only tell whether more follow
+		inactive, _, err := sqlstore.GetStaleChannelsWithOffset(staleOpts, inactiveOffset, 1)
+		return pageChannels, len(inactive) > 0, err
+	}
+
+	inactive, more, err := sqlstore.GetStaleChannelsWithOffset(staleOpts, inactiveOffset, pageSize-len(pageChannels))
+	if err != nil {
+		return nil, false, err
+	}
+	return append(slices.Clone(pageChannels), inactive...), more, nil
+}
+
+// excludeKeptChannels returns a copy of opts with all channels marked as keep added to the exclude list.
+func excludeKeptChannels(client *pluginapi.Client, opts store.StaleChannelOpts) (store.StaleChannelOpts, error) {
+	kept, err := GetKeptChannelIDs(client)
+	if err != nil {
+		return opts, err
+	}
+
+	exclude := make([]string, 0, len(opts.ExcludeChannels)+len(kept))
+	exclude = append(exclude, opts.ExcludeChannels...)
+	opts.ExcludeChannels = append(exclude, kept...)
+	return opts, nil
+}
+
 func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, results *ArchiverResults) error {
 	var buffer bytes.Buffer
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+
+	var archiveTeam *model.Team
+	if opts.ArchiveTeam != "" {
+		var err error
+		if archiveTeam, err = ResolveTeam(client, opts.ArchiveTeam); err != nil {
+			return fmt.Errorf("cannot find archive team %s: %w", opts.ArchiveTeam, err)
+		}
+	}
+	teams := make(map[string]*model.Team)
+
+	var warnings *warningDigest
+	if opts.WarningDays > 0 {
+		warnings = newWarningDigest(sqlstore, client, opts)
+		defer func() {
+			warnings.send()
+			results.ChannelsWarned = warnings.warned
+		}()
+	}
 
-	buffer.WriteString("Archived Channels:\n")
+	// the extra policies go first; once archived, their channels are no longer stale
+	policies := extraPolicies(opts)
+	for _, policy := range policies {
+		cancelled, err := archivePolicyChannels(ctx, sqlstore, client, opts, policy, loc, archiveTeam, teams, results)
+		if err != nil || cancelled {
+			return err
+		}
+	}
+
+	// archived channels are no longer stale, so only channels left in place are skipped when paging
+	offset := 0
+
+	buffer.WriteString(loc.T(&i18n.Message{ID: "archiver.report.archived_header", Other: "Archived Channels:"}, nil) + "\n")
 	for {
-		staleChannels, more, err := sqlstore.GetStaleChannels(opts.StaleChannelOpts, 0, opts.BatchSize)
+		staleChannels, more, err := sqlstore.GetStaleChannelsWithOffset(opts.StaleChannelOpts, offset, opts.BatchSize)
 		if err != nil {
 			results.ExitReason = ReasonError
 			return fmt.Errorf("cannot fetch stale channels: %w", err)
 		}
 
+		results.StaleCount += len(staleChannels)
 		for _, ch := range staleChannels {
-			// archive the channel after posting notice.
-			if opts.Bot != nil {
-				msg := fmt.Sprintf("This channel has been archived due to inactivity for more than %d days.", opts.StaleChannelOpts.AgeInDays)
-				_ = opts.Bot.SendPost(ch.Id, msg)
+			if warnings != nil {
+				archive, err := warnings.shouldArchive(ch)
+				if err != nil {
+					return err
+				}
+				if !archive {
+					offset++
+					continue
+				}
 			}
-			appErr := client.Channel.Delete(ch.Id)
-			if appErr != nil {
-				return fmt.Errorf("cannot archive channel %s (%s): %w", ch.Name, ch.Id, err)
+
+			archivedChannelStr, err := archiveChannel(client, opts, inactivePolicy(opts), loc, archiveTeam, teams, ch)
+			if err != nil {
+				return err
+			}
+			if warnings != nil {
+				_ = DeleteChannelWarning(client, ch.Id)
 			}
-			archivedChannelStr := fmt.Sprintf("%s (%s)\n", ch.Name, ch.Id)
 			results.ChannelsArchived = append(results.ChannelsArchived, archivedChannelStr)
+			results.ChannelIDs = append(results.ChannelIDs, ch.Id)
 			if opts.StaleChannelOpts.AdminChannel != "" {
 				buffer.WriteString(archivedChannelStr)
 			}
@@ -113,7 +245,19 @@ func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 		}
 
 		if !more {
-			return handleAdminChannelPost(opts.Bot, &buffer, "archived", opts.StaleChannelOpts.AdminChannel, "The following channels have been archived:")
+			data := MessageData{
+				DaysIdle:     opts.StaleChannelOpts.AgeInDays,
+				ChannelCount: len(results.ChannelIDs),
+			}
+			if warnings != nil && len(warnings.warned) > 0 {
+				data.WarnedCount = len(warnings.warned)
+				buffer.WriteString("\n" + loc.T(&i18n.Message{ID: "archiver.report.warned_header", Other: "Warned Channels:"}, nil) + "\n")
+				for _, line := range warnings.warned {
+					buffer.WriteString(line)
+				}
+			}
+			writePolicySections(&buffer, policies, loc)
+			return handleAdminChannelPost(opts.Bot, &buffer, "archived", opts.StaleChannelOpts.AdminChannel, opts.Messages.AdminReport(loc, data))
 		}
 
 		// sleep so we don't peg the cpu; longer here to allow websocket events to flush
@@ -126,22 +270,185 @@ func archiveStaleChannels(ctx context.Context, sqlstore *store.SQLStore, client
 	}
 }
 
+// ArchiveChannel archives a single stale channel right away, posting the archive notice and
+// moving it to the archive team first if one is configured.
+func ArchiveChannel(client *pluginapi.Client, opts ArchiverOpts, ch *model.Channel) (string, error) {
+	var archiveTeam *model.Team
+	if opts.ArchiveTeam != "" {
+		var err error
+		if archiveTeam, err = ResolveTeam(client, opts.ArchiveTeam); err != nil {
+			return "", fmt.Errorf("cannot find archive team %s: %w", opts.ArchiveTeam, err)
+		}
+	}
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+	line, err := archiveChannel(client, opts, inactivePolicy(opts), loc, archiveTeam, make(map[string]*model.Team), ch)
+	if err != nil {
+		return "", err
+	}
+	return line, DeleteChannelWarning(client, ch.Id)
+}
+
+// archiveChannel archives a channel after posting notice, returning the line for the admin report.
+func archiveChannel(client *pluginapi.Client, opts ArchiverOpts, policy archivePolicy, loc *i18n.Localizer, archiveTeam *model.Team, teams map[string]*model.Team, ch *model.Channel) (string, error) {
+	if opts.Bot != nil {
+		msg := opts.Messages.ArchiveNotice(loc, MessageData{
+			ChannelName: ch.DisplayName,
+			TeamName:    teamDisplayName(client, teams, ch.TeamId),
+			DaysIdle:    policy.ageInDays,
+			PolicyName:  policy.name,
+		})
+		_ = opts.Bot.SendPost(ch.Id, msg)
+	}
+	var moved string
+	var origin *ChannelOrigin
+	if archiveTeam != nil && ch.TeamId != archiveTeam.Id {
+		var err error
+		origin, err = moveChannelToTeam(client, ch.Id, archiveTeam.Id)
+		if err != nil {
+			client.Log.Warn("Cannot move stale channel to archive team; archiving in place", "channel_id", ch.Id, "err", err)
+			moved = " - " + loc.T(&i18n.Message{ID: "archiver.report.move_failed", Other: "could not be moved to the archive team"}, nil)
+		} else {
+			moved = " - " + loc.T(&i18n.Message{ID: "archiver.report.moved", Other: "moved from team {{.TeamName}} ({{.TeamID}})"}, map[string]any{
+				"TeamName": teamName(client, teams, origin.TeamID),
+				"TeamID":   origin.TeamID,
+			})
+		}
+	}
+	if appErr := client.Channel.Delete(ch.Id); appErr != nil {
+		// a channel that cannot be archived goes back to where it was
+		if origin != nil {
+			if _, err := MoveChannelBack(client, ch.Id); err != nil {
+				client.Log.Error("Cannot move channel back from the archive team", "channel_id", ch.Id, "team_id", origin.TeamID, "err", err)
+			}
+		}
+		opts.Audit.Log(audit.Record{
+			Event:   audit.EventChannelArchived,
+			Status:  model.AuditStatusFail,
+			ActorID: opts.ActorID,
+			Data:    archiveAuditData(policy, ch, origin, archiveTeam),
+			Error:   appErr.Error(),
+		})
+		return "", fmt.Errorf("cannot archive channel %s (%s): %w", ch.Name, ch.Id, appErr)
+	}
+
+	if origin != nil {
+		grantArchiveTeamAccess(client, ch.Id, archiveTeam.Id)
+	}
+
+	opts.Audit.Log(audit.Record{
+		Event:   audit.EventChannelArchived,
+		Status:  model.AuditStatusSuccess,
+		ActorID: opts.ActorID,
+		Data:    archiveAuditData(policy, ch, origin, archiveTeam),
+	})
+	return fmt.Sprintf("%s (%s)%s\n", ch.Name, ch.Id, moved), nil
+}
+
+func archiveAuditData(policy archivePolicy, ch *model.Channel, origin *ChannelOrigin, archiveTeam *model.Team) map[string]any {
+	data := map[string]any{
+		"channel_id":   ch.Id,
+		"channel_name": ch.Name,
+		"display_name": ch.DisplayName,
+		"team_id":      ch.TeamId,
+		"policy":       policy.name,
+		"age_in_days":  policy.ageInDays,
+	}
+	if origin != nil {
+		data["moved_to_team_id"] = archiveTeam.Id
+	}
+	return data
+}
+
+// logArchiverRunCompleted records the outcome of an archiver run.
+func logArchiverRunCompleted(opts ArchiverOpts, results *ArchiverResults, err error) {
+	rec := audit.Record{
+		Event:   audit.EventRunCompleted,
+		Status:  model.AuditStatusSuccess,
+		ActorID: opts.ActorID,
+		Data: map[string]any{
+			"run":           "archive",
+			"dry_run":       opts.ListOnly,
+			"age_in_days":   opts.StaleChannelOpts.AgeInDays,
+			"channel_count": len(results.ChannelIDs),
+			"warned_count":  len(results.ChannelsWarned),
+			"policy_counts": results.PolicyCounts,
+			"exit_reason":   string(results.ExitReason),
+			"duration_ms":   results.Duration.Milliseconds(),
+		},
+	}
+	// canceled runs only scanned part of the channels
+	if results.ExitReason == ReasonDone {
+		rec.Data["stale_count"] = results.StaleCount
+	}
+	if err != nil {
+		rec.Status = model.AuditStatusFail
+		rec.Error = err.Error()
+	}
+	opts.Audit.Log(rec)
+}
+
+// getTeam returns a team, caching lookups. Nil is returned if the team cannot be found.
+func getTeam(client *pluginapi.Client, cache map[string]*model.Team, teamID string) *model.Team {
+	if team, ok := cache[teamID]; ok {
+		return team
+	}
+
+	team, err := client.Team.Get(teamID)
+	if err != nil {
+		team = nil
+	}
+	cache[teamID] = team
+	return team
+}
+
+// teamName returns the name of a team, or its ID if the team cannot be found.
+func teamName(client *pluginapi.Client, cache map[string]*model.Team, teamID string) string {
+	if team := getTeam(client, cache, teamID); team != nil {
+		return team.Name
+	}
+	return teamID
+}
+
+// teamDisplayName returns the display name of a team, or its ID if the team cannot be found.
+func teamDisplayName(client *pluginapi.Client, cache map[string]*model.Team, teamID string) string {
+	if team := getTeam(client, cache, teamID); team != nil {
+		return team.DisplayName
+	}
+	return teamID
+}
+
 func listStaleChannels(ctx context.Context, sqlstore *store.SQLStore, opts ArchiverOpts, results *ArchiverResults) error {
 	page := 0
 	var buffer bytes.Buffer
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
 
-	buffer.WriteString("Stale Channels:\n")
+	// channels are only listed once, under the first policy selecting them
+	listed := make([]string, 0)
+	policies := extraPolicies(opts)
+	for _, policy := range policies {
+		ids, cancelled, err := listPolicyChannels(ctx, sqlstore, opts, policy, listed, results)
+		if err != nil || cancelled {
+			return err
+		}
+		listed = append(listed, ids...)
+	}
+	staleOpts := opts.StaleChannelOpts
+	staleOpts.ExcludeChannels = append(slices.Clone(staleOpts.ExcludeChannels), listed...)
+
+	buffer.WriteString(loc.T(&i18n.Message{ID: "archiver.report.stale_header", Other: "Stale Channels:"}, nil) + "\n")
 	for {
-		staleChannels, more, err := sqlstore.GetStaleChannels(opts.StaleChannelOpts, page, opts.BatchSize)
+		staleChannels, more, err := sqlstore.GetStaleChannels(staleOpts, page, opts.BatchSize)
 		if err != nil {
 			results.ExitReason = ReasonError
 			return fmt.Errorf("cannot fetch stale channels: %w", err)
 		}
 		page++
+		results.StaleCount += len(staleChannels)
 
 		for _, ch := range staleChannels {
 			buffer.WriteString(fmt.Sprintf("%s (%s)\n", ch.Name, ch.Id))
 			results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("**%s** (%s)", ch.Name, ch.Id))
+			results.ChannelIDs = append(results.ChannelIDs, ch.Id)
 		}
 
 		if !more {
@@ -157,7 +464,10 @@ func listStaleChannels(ctx context.Context, sqlstore *store.SQLStore, opts Archi
 		}
 	}
 
-	return handleAdminChannelPost(opts.Bot, &buffer, "stale", opts.StaleChannelOpts.AdminChannel, "The following channels have been identified as stale:")
+	writePolicySections(&buffer, policies, loc)
+
+	msg := loc.T(&i18n.Message{ID: "archiver.report.stale", Other: "The following channels have been identified as stale:"}, nil)
+	return handleAdminChannelPost(opts.Bot, &buffer, "stale", opts.StaleChannelOpts.AdminChannel, msg)
 }
 
 func handleAdminChannelPost(bot *bot.Bot, buffer *bytes.Buffer, fileType string, adminChannel, msg string) error {
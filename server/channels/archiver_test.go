@@ -2,6 +2,7 @@ package channels
 
 import (
 	"context"
+	"slices"
 	"testing"
 	"time"
 
@@ -28,6 +29,7 @@ func TestArchiveStaleChannelsListMode(t *testing.T) {
 	mockAPI := &plugintest.API{}
 	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	mockAPI.On("KVList", 0, 1000).Return([]string{}, nil)
 	client := pluginapi.NewClient(mockAPI, nil)
 
 	// Create test channels
@@ -62,6 +64,7 @@ func TestArchiveStaleChannelsArchiveMode(t *testing.T) {
 	mockAPI := &plugintest.API{}
 	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	mockAPI.On("KVList", 0, 1000).Return([]string{}, nil)
 	client := pluginapi.NewClient(mockAPI, nil)
 
 	// Create test channels
@@ -173,6 +176,7 @@ func setupStaleChannelsTest(t *testing.T) (*store.TestHelper, *pluginapi.Client,
 
 	mockAPI.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything,
 		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	mockAPI.On("KVList", 0, 1000).Return([]string{}, nil)
 	mockAPI.On("UploadFile", mockBytes, mockString, mockString).Return(&model.FileInfo{Id: "test-file-id"}, nil)
 	mockAPI.On("CreatePost", mockPost).Return(&model.Post{}, nil)
 	mockAPI.On("GetBot", mockString).Return(&model.Bot{}, nil)
@@ -204,3 +208,70 @@ func setupStaleChannelsTest(t *testing.T) (*store.TestHelper, *pluginapi.Client,
 
 	return th, client, testBot, adminChannel, channels, mockAPI
 }
+
+func TestGetStaleChannelsPage(t *testing.T) {
+	th := store.SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	mockAPI := &plugintest.API{}
+	mockAPI.On("KVList", 0, 1000).Return([]string{}, nil)
+	client := pluginapi.NewClient(mockAPI, nil)
+
+	channels, err := th.CreateChannels(3, "page-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// channel 0 - created a month ago and never used (abandoned, but recently updated)
+	weekAgo := model.GetMillisForTime(time.Now().AddDate(0, 0, -7))
+	store.SetTimestamps(t, th, "Channels", channels[0].Id, monthAgo, weekAgo, 0)
+
+	// channels 1 and 2 - inactive
+	inactive := make([]string, 0, 2)
+	for _, ch := range channels[1:] {
+		_, err = th.CreatePosts(1, th.User1.Id, ch.Id)
+		require.NoError(t, err)
+		store.SetTimestamps(t, th, "Posts", ch.Id, monthAgo, monthAgo, 0)
+		store.SetTimestamps(t, th, "Channels", ch.Id, -1, monthAgo, 0)
+		inactive = append(inactive, ch.Id)
+	}
+	slices.Sort(inactive)
+
+	opts := store.StaleChannelOpts{
+		AgeInDays:              30,
+		IncludeChannelTypeOpen: true,
+		TeamID:                 th.Team1.Id,
+	}
+
+	t.Run("without policies", func(t *testing.T) {
+		page, more, err := GetStaleChannelsPage(th.Store, client, opts, PolicyOpts{}, 0, 2)
+		require.NoError(t, err)
+		assert.False(t, more)
+		assert.Equal(t, inactive, channelIDs(page))
+	})
+
+	t.Run("policy channels come first", func(t *testing.T) {
+		policies := PolicyOpts{AbandonedAgeInDays: 14}
+
+		page, more, err := GetStaleChannelsPage(th.Store, client, opts, policies, 0, 2)
+		require.NoError(t, err)
+		assert.True(t, more)
+		assert.Equal(t, []string{channels[0].Id, inactive[0]}, channelIDs(page))
+
+		page, more, err = GetStaleChannelsPage(th.Store, client, opts, policies, 1, 2)
+		require.NoError(t, err)
+		assert.False(t, more)
+		assert.Equal(t, []string{inactive[1]}, channelIDs(page))
+
+		page, more, err = GetStaleChannelsPage(th.Store, client, opts, policies, 0, 1)
+		require.NoError(t, err)
+		assert.True(t, more)
+		assert.Equal(t, []string{channels[0].Id}, channelIDs(page))
+	})
+}
+
+func channelIDs(channels []*model.Channel) []string {
+	ids := make([]string, 0, len(channels))
+	for _, ch := range channels {
+		ids = append(ids, ch.Id)
+	}
+	return ids
+}
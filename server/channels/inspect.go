@@ -0,0 +1,138 @@
+package channels
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// InspectResults explains how the archiver treats a single channel.
+type InspectResults struct {
+	Channel      *model.Channel
+	Activity     *store.ChannelActivity
+	Keep         *KeepMarker     // active keep marker, if any
+	Origin       *ChannelOrigin  // original team, if the channel was moved to the archive team
+	Warning      *ChannelWarning // archive warning sent for the current stale period, if any
+	OlderThan    int64           // channels with no activity since this timestamp are stale under the inactive policy
+	Exclusions   []string        // reasons the channel is excluded from archiving, if any
+	Stale        bool            // stale under the inactive policy
+	Orphaned     bool            // channel has no active members
+	Abandoned    bool            // channel was never used and is old enough for the abandoned policy
+	Policy       string          // policy the channel is stale under, empty if it is not stale
+	WouldArchive bool
+}
+
+// InspectChannel evaluates a channel against the stale channel options, returning the channel
+// activity and every rule that prevents it from being archived, localized with loc. The enabled
+// policies are applied in the same order as the archiver.
+func InspectChannel(sqlstore *store.SQLStore, client *pluginapi.Client, channel *model.Channel, opts store.StaleChannelOpts, policies PolicyOpts, loc *i18n.Localizer) (*InspectResults, error) {
+	activity, err := sqlstore.GetChannelActivity(channel.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch channel activity: %w", err)
+	}
+
+	results := &InspectResults{
+		Channel:    channel,
+		Activity:   activity,
+		OlderThan:  model.GetMillisForTime(time.Now().AddDate(0, 0, -opts.AgeInDays)),
+		Exclusions: make([]string, 0),
+	}
+	results.Stale = activity.IsStale(results.OlderThan)
+
+	results.Orphaned, err = sqlstore.IsChannelOrphaned(channel.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check channel members: %w", err)
+	}
+
+	if policies.AbandonedAgeInDays > 0 {
+		createdBefore := model.GetMillisForTime(time.Now().AddDate(0, 0, -policies.AbandonedAgeInDays))
+		if results.Abandoned, err = sqlstore.IsChannelAbandoned(channel.Id, createdBefore); err != nil {
+			return nil, fmt.Errorf("cannot check channel posts: %w", err)
+		}
+	}
+
+	switch {
+	case results.Orphaned && policies.OrphanedAgeInDays > 0 &&
+		activity.IsStale(model.GetMillisForTime(time.Now().AddDate(0, 0, -policies.OrphanedAgeInDays))):
+		results.Policy = PolicyOrphaned
+	case results.Abandoned:
+		results.Policy = PolicyAbandoned
+	case results.Stale:
+		results.Policy = PolicyInactive
+	}
+
+	results.Keep, err = GetKeepMarker(client, channel.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	results.Origin, err = GetChannelOrigin(client, channel.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	warning, err := GetChannelWarning(client, channel.Id)
+	if err != nil {
+		return nil, err
+	}
+	if warning.IsValid(results.OlderThan) {
+		results.Warning = warning
+	}
+
+	if channel.DeleteAt != 0 {
+		results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{ID: "archiver.inspect.excluded_archived", Other: "channel is already archived"}, nil))
+	}
+
+	if store.IsDefaultChannel(channel.Name) {
+		results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{ID: "archiver.inspect.excluded_default", Other: "default channels are never archived"}, nil))
+	}
+
+	if opts.AdminChannel != "" && (channel.Id == opts.AdminChannel || channel.Name == opts.AdminChannel) {
+		results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{ID: "archiver.inspect.excluded_admin_channel", Other: "channel is the archiver admin channel"}, nil))
+	}
+
+	for _, ex := range opts.ExcludeChannels {
+		if ex == channel.Id || ex == channel.Name {
+			results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{
+				ID:    "archiver.inspect.excluded_list",
+				Other: "channel is in the exclude list (`{{.Entry}}`)",
+			}, map[string]any{"Entry": ex}))
+			break
+		}
+	}
+
+	if results.Keep != nil {
+		results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{ID: "archiver.inspect.excluded_keep", Other: "channel is marked as keep"}, nil))
+	}
+
+	if !isChannelTypeIncluded(channel.Type, opts) {
+		results.Exclusions = append(results.Exclusions, loc.T(&i18n.Message{
+			ID:    "archiver.inspect.excluded_type",
+			Other: "channel type `{{.Type}}` is not archived",
+		}, map[string]any{"Type": channel.Type}))
+	}
+
+	results.WouldArchive = results.Policy != "" && len(results.Exclusions) == 0
+
+	return results, nil
+}
+
+func isChannelTypeIncluded(channelType model.ChannelType, opts store.StaleChannelOpts) bool {
+	switch channelType {
+	case model.ChannelTypeOpen:
+		return opts.IncludeChannelTypeOpen
+	case model.ChannelTypePrivate:
+		return opts.IncludeChannelTypePrivate
+	case model.ChannelTypeDirect:
+		return opts.IncludeChannelTypeDirect
+	case model.ChannelTypeGroup:
+		return opts.IncludeChannelTypeGroup
+	default:
+		return false
+	}
+}
@@ -0,0 +1,118 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/kvstore"
+)
+
+const keepKeyPrefix = "keep_"
+
+// KeepMarker protects a channel from being archived, optionally until a specific time.
+type KeepMarker struct {
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+	Reason    string `json:"reason"`
+	CreateAt  int64  `json:"create_at"`
+	ExpireAt  int64  `json:"expire_at"` // zero means the marker never expires
+}
+
+// IsActive returns true if the marker has not expired at the given time (millis).
+func (km *KeepMarker) IsActive(now int64) bool {
+	return km.ExpireAt == 0 || km.ExpireAt > now
+}
+
+func keepKey(channelID string) string {
+	return keepKeyPrefix + channelID
+}
+
+// SaveKeepMarker stores a keep marker, replacing any existing marker for the channel.
+// Markers with an expiry are removed from the KV store automatically once expired.
+func SaveKeepMarker(client *pluginapi.Client, marker *KeepMarker) error {
+	var opts []pluginapi.KVSetOption
+	if marker.ExpireAt != 0 {
+		ttl := time.Until(model.GetTimeForMillis(marker.ExpireAt))
+		if ttl <= 0 {
+			return fmt.Errorf("keep marker for channel %s has already expired", marker.ChannelID)
+		}
+		opts = append(opts, pluginapi.SetExpiry(ttl))
+	}
+
+	if _, err := client.KV.Set(keepKey(marker.ChannelID), marker, opts...); err != nil {
+		return fmt.Errorf("cannot save keep marker for channel %s: %w", marker.ChannelID, err)
+	}
+	return nil
+}
+
+// GetKeepMarker returns the active keep marker for a channel, or nil if there is none.
+func GetKeepMarker(client *pluginapi.Client, channelID string) (*KeepMarker, error) {
+	var marker *KeepMarker
+	if err := client.KV.Get(keepKey(channelID), &marker); err != nil {
+		return nil, fmt.Errorf("cannot get keep marker for channel %s: %w", channelID, err)
+	}
+
+	if marker == nil || !marker.IsActive(model.GetMillis()) {
+		return nil, nil
+	}
+	return marker, nil
+}
+
+// DeleteKeepMarker removes the keep marker for a channel, if any.
+func DeleteKeepMarker(client *pluginapi.Client, channelID string) error {
+	if err := client.KV.Delete(keepKey(channelID)); err != nil {
+		return fmt.Errorf("cannot delete keep marker for channel %s: %w", channelID, err)
+	}
+	return nil
+}
+
+// ListKeepMarkers returns all active keep markers.
+func ListKeepMarkers(client *pluginapi.Client) ([]*KeepMarker, error) {
+	keys, err := kvstore.ListKeysWithPrefix(&client.KV, keepKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list keep markers: %w", err)
+	}
+
+	markers := make([]*KeepMarker, 0, len(keys))
+	for _, key := range keys {
+		marker, err := GetKeepMarker(client, strings.TrimPrefix(key, keepKeyPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if marker != nil {
+			markers = append(markers, marker)
+		}
+	}
+	return markers, nil
+}
+
+// GetKeptChannelIDs returns the IDs of all channels with an active keep marker.
+func GetKeptChannelIDs(client *pluginapi.Client) ([]string, error) {
+	markers, err := ListKeepMarkers(client)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(markers))
+	for _, marker := range markers {
+		ids = append(ids, marker.ChannelID)
+	}
+	return ids, nil
+}
+
+// CanManageChannel returns true if the user is a system admin or an admin of the channel.
+func CanManageChannel(client *pluginapi.Client, userID, channelID string) bool {
+	if client.User.HasPermissionTo(userID, model.PermissionManageSystem) {
+		return true
+	}
+
+	member, err := client.Channel.GetMember(channelID, userID)
+	if err != nil {
+		return false
+	}
+	return member.SchemeAdmin
+}
@@ -0,0 +1,189 @@
+package channels
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+)
+
+const (
+	// PolicyInactive is the name of the policy archiving channels without activity.
+	PolicyInactive = "inactive channels"
+	// PolicyOrphaned is the name of the policy archiving channels whose members are all deactivated,
+	// usually after fewer days than inactive channels.
+	PolicyOrphaned = "orphaned channels"
+	// PolicyAbandoned is the name of the policy archiving channels that were never used, some days
+	// after they were created.
+	PolicyAbandoned = "abandoned channels"
+)
+
+// The default messages are localized templates, used when no custom template is configured.
+var (
+	defaultArchiveNotice = &i18n.Message{
+		ID: "archiver.notice.archived",
+		Other: "This channel has been archived due to inactivity for more than {{.DaysIdle}} days." +
+			"{{if .RestoreInstructions}} {{.RestoreInstructions}}{{end}}" +
+			"{{if .ContactLink}} Questions? Contact {{.ContactLink}}.{{end}}",
+	}
+	defaultOrphanedNotice = &i18n.Message{
+		ID: "archiver.notice.orphaned",
+		Other: "This channel has been archived because it has no active members and no activity for more than {{.DaysIdle}} days." +
+			"{{if .RestoreInstructions}} {{.RestoreInstructions}}{{end}}" +
+			"{{if .ContactLink}} Questions? Contact {{.ContactLink}}.{{end}}",
+	}
+	defaultAbandonedNotice = &i18n.Message{
+		ID: "archiver.notice.abandoned",
+		Other: "This channel was never used, so it has been archived {{.DaysIdle}} days after it was created." +
+			"{{if .RestoreInstructions}} {{.RestoreInstructions}}{{end}}" +
+			"{{if .ContactLink}} Questions? Contact {{.ContactLink}}.{{end}}",
+	}
+	defaultWarning = &i18n.Message{
+		ID: "archiver.warning.scheduled",
+		Other: "Scheduled to be archived on or after {{.ArchiveDate}}." +
+			"{{if .ContactLink}} Questions? Contact {{.ContactLink}}.{{end}}",
+	}
+	defaultAdminReport = &i18n.Message{
+		ID: "archiver.report.archived",
+		Other: "The following channels have been archived" +
+			"{{if .WarnedCount}}. The admins of {{.WarnedCount}} more channels were warned that their channels will be archived.{{else}}:{{end}}",
+	}
+)
+
+// MessageData is available to the message templates. Channel fields are empty in the admin report,
+// and the counts are only set in the admin report.
+type MessageData struct {
+	ChannelName         string // display name of the channel
+	TeamName            string // display name of the channel's team
+	DaysIdle            int    // number of days without activity (or since creation, for abandoned channels) after which channels are archived
+	PolicyName          string
+	RestoreInstructions string
+	ContactLink         string
+	ArchiveDate         string // date the channel will be archived, warnings only
+	ChannelCount        int    // number of channels archived, admin report only
+	WarnedCount         int    // number of channels whose admins were warned, admin report only
+}
+
+// MessageTemplates renders the posts made by the archiver. Messages without a custom template,
+// and all messages of a nil *MessageTemplates, use the localized default message.
+type MessageTemplates struct {
+	archiveNotice       *template.Template
+	warning             *template.Template
+	adminReport         *template.Template
+	restoreInstructions string
+	contactLink         string
+}
+
+var sampleMessageData = MessageData{
+	ChannelName:         "Town Hall",
+	TeamName:            "Engineering",
+	DaysIdle:            config.DefaultAgeInDays,
+	PolicyName:          PolicyInactive,
+	RestoreInstructions: "Ask a system admin to unarchive the channel.",
+	ContactLink:         "~it-help",
+	ArchiveDate:         "Jan 2, 2006",
+	ChannelCount:        3,
+	WarnedCount:         2,
+}
+
+// NewMessageTemplates parses the custom message templates from the configuration. Templates are
+// test-rendered so mistakes such as unknown fields are reported right away rather than when
+// channels are archived.
+func NewMessageTemplates(cfg *config.Configuration) (*MessageTemplates, error) {
+	mt := &MessageTemplates{
+		restoreInstructions: strings.TrimSpace(cfg.RestoreInstructions),
+		contactLink:         strings.TrimSpace(cfg.ContactLink),
+	}
+
+	var err error
+	if mt.archiveNotice, err = parseMessageTemplate("Archive notice template", cfg.ArchiveNoticeTemplate); err != nil {
+		return nil, err
+	}
+	if mt.warning, err = parseMessageTemplate("Archive warning template", cfg.ArchiveWarningTemplate); err != nil {
+		return nil, err
+	}
+	if mt.adminReport, err = parseMessageTemplate("Admin report template", cfg.AdminReportTemplate); err != nil {
+		return nil, err
+	}
+	return mt, nil
+}
+
+// parseMessageTemplate parses a custom template, returning nil if the template is empty.
+func parseMessageTemplate(name, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid `%s`: %w", name, err)
+	}
+	if _, err = execute(tmpl, sampleMessageData); err != nil {
+		return nil, fmt.Errorf("invalid `%s`: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// ArchiveNotice renders the notice posted in a channel before it is archived. The default notice
+// depends on the policy the channel is archived under.
+func (mt *MessageTemplates) ArchiveNotice(loc *i18n.Localizer, data MessageData) string {
+	var tmpl *template.Template
+	if mt != nil {
+		tmpl = mt.archiveNotice
+	}
+	fallback := defaultArchiveNotice
+	switch data.PolicyName {
+	case PolicyOrphaned:
+		fallback = defaultOrphanedNotice
+	case PolicyAbandoned:
+		fallback = defaultAbandonedNotice
+	}
+	return mt.render(loc, tmpl, fallback, data)
+}
+
+// Warning renders the text shown for each channel in the warning sent to channel admins.
+func (mt *MessageTemplates) Warning(loc *i18n.Localizer, data MessageData) string {
+	var tmpl *template.Template
+	if mt != nil {
+		tmpl = mt.warning
+	}
+	return mt.render(loc, tmpl, defaultWarning, data)
+}
+
+// AdminReport renders the message posted to the admin channel along with the archived channels report.
+func (mt *MessageTemplates) AdminReport(loc *i18n.Localizer, data MessageData) string {
+	var tmpl *template.Template
+	if mt != nil {
+		tmpl = mt.adminReport
+	}
+	return mt.render(loc, tmpl, defaultAdminReport, data)
+}
+
+// render executes the custom template with the configured restore instructions and contact link,
+// falling back to the localized default message if there is no custom template or it fails.
+func (mt *MessageTemplates) render(loc *i18n.Localizer, tmpl *template.Template, fallback *i18n.Message, data MessageData) string {
+	if data.PolicyName == "" {
+		data.PolicyName = PolicyInactive
+	}
+	if mt != nil {
+		data.RestoreInstructions = mt.restoreInstructions
+		data.ContactLink = mt.contactLink
+	}
+
+	if tmpl != nil {
+		if msg, err := execute(tmpl, data); err == nil {
+			return msg
+		}
+	}
+	return loc.T(fallback, data)
+}
+
+func execute(tmpl *template.Template, data MessageData) (string, error) {
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
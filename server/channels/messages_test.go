@@ -0,0 +1,79 @@
+package channels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestMessageTemplates(t *testing.T) {
+	data := MessageData{
+		ChannelName: "Town Hall",
+		TeamName:    "Engineering",
+		DaysIdle:    90,
+		ArchiveDate: "Mar 1, 2025",
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		var mt *MessageTemplates
+		assert.Equal(t, "This channel has been archived due to inactivity for more than 90 days.", mt.ArchiveNotice(nil, data))
+		assert.Equal(t, "Scheduled to be archived on or after Mar 1, 2025.", mt.Warning(nil, data))
+
+		orphaned := data
+		orphaned.PolicyName = PolicyOrphaned
+		assert.Equal(t, "This channel has been archived because it has no active members and no activity for more than 90 days.", mt.ArchiveNotice(nil, orphaned))
+
+		abandoned := data
+		abandoned.PolicyName = PolicyAbandoned
+		abandoned.DaysIdle = 14
+		assert.Equal(t, "This channel was never used, so it has been archived 14 days after it was created.", mt.ArchiveNotice(nil, abandoned))
+
+		assert.Equal(t, "The following channels have been archived:", mt.AdminReport(nil, MessageData{ChannelCount: 2}))
+		assert.Equal(t, "The following channels have been archived. The admins of 3 more channels were warned that their channels will be archived.",
+			mt.AdminReport(nil, MessageData{ChannelCount: 2, WarnedCount: 3}))
+	})
+
+	t.Run("defaults with restore instructions and contact link", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.RestoreInstructions = "Ask in ~it-help to restore it."
+		cfg.ContactLink = "@helpdesk"
+
+		mt, err := NewMessageTemplates(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "This channel has been archived due to inactivity for more than 90 days. Ask in ~it-help to restore it. Questions? Contact @helpdesk.",
+			mt.ArchiveNotice(nil, data))
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.ArchiveNoticeTemplate = "{{.ChannelName}} in {{.TeamName}} was idle for {{.DaysIdle}} days ({{.PolicyName}})."
+
+		mt, err := NewMessageTemplates(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, "Town Hall in Engineering was idle for 90 days (inactive channels).", mt.ArchiveNotice(nil, data))
+
+		orphaned := data
+		orphaned.PolicyName = PolicyOrphaned
+		assert.Equal(t, "Town Hall in Engineering was idle for 90 days (orphaned channels).", mt.ArchiveNotice(nil, orphaned))
+		assert.Equal(t, "Scheduled to be archived on or after Mar 1, 2025.", mt.Warning(nil, data))
+	})
+
+	t.Run("invalid syntax", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.ArchiveWarningTemplate = "{{.ChannelName"
+
+		_, err := NewMessageTemplates(cfg)
+		require.ErrorContains(t, err, "Archive warning template")
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.AdminReportTemplate = "{{.Channels}}"
+
+		_, err := NewMessageTemplates(cfg)
+		require.ErrorContains(t, err, "Admin report template")
+	})
+}
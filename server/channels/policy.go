@@ -0,0 +1,170 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// PolicyOpts holds the thresholds of the policies archiving some channels sooner than inactive
+// channels. A policy is disabled when its threshold is 0.
+type PolicyOpts struct {
+	OrphanedAgeInDays  int // days without activity for channels without active members, see PolicyOrphaned
+	AbandonedAgeInDays int // days since creation for channels without user posts, see PolicyAbandoned
+}
+
+func NewPolicyOpts(cfg *config.Configuration) PolicyOpts {
+	return PolicyOpts{
+		OrphanedAgeInDays:  cfg.OrphanedAgeInDays,
+		AbandonedAgeInDays: cfg.AbandonedAgeInDays,
+	}
+}
+
+// archivePolicy is the rule a channel is archived under.
+type archivePolicy struct {
+	name      string
+	ageInDays int
+
+	// the following are only set for the policies applied before the inactive policy
+	staleOpts store.StaleChannelOpts
+	header    *i18n.Message // admin report section listing the channels
+	report    *bytes.Buffer
+}
+
+func inactivePolicy(opts ArchiverOpts) archivePolicy {
+	return archivePolicy{name: PolicyInactive, ageInDays: opts.StaleChannelOpts.AgeInDays}
+}
+
+// extraPolicies returns the enabled policies applied before the inactive policy, in order. Their
+// channels are archived without warning, and listed in their own section of the admin report.
+func extraPolicies(opts ArchiverOpts) []archivePolicy {
+	policies := make([]archivePolicy, 0, 2)
+
+	if opts.Policies.OrphanedAgeInDays > 0 {
+		staleOpts := opts.StaleChannelOpts
+		staleOpts.AgeInDays = opts.Policies.OrphanedAgeInDays
+		staleOpts.OrphanedOnly = true
+		policies = append(policies, archivePolicy{
+			name:      PolicyOrphaned,
+			ageInDays: opts.Policies.OrphanedAgeInDays,
+			staleOpts: staleOpts,
+			header:    &i18n.Message{ID: "archiver.report.orphaned_header", Other: "Orphaned Channels:"},
+			report:    &bytes.Buffer{},
+		})
+	}
+
+	if opts.Policies.AbandonedAgeInDays > 0 {
+		staleOpts := opts.StaleChannelOpts
+		staleOpts.AgeInDays = opts.Policies.AbandonedAgeInDays
+		staleOpts.AbandonedOnly = true
+		policies = append(policies, archivePolicy{
+			name:      PolicyAbandoned,
+			ageInDays: opts.Policies.AbandonedAgeInDays,
+			staleOpts: staleOpts,
+			header:    &i18n.Message{ID: "archiver.report.abandoned_header", Other: "Abandoned Channels:"},
+			report:    &bytes.Buffer{},
+		})
+	}
+
+	return policies
+}
+
+// archivePolicyChannels archives the channels selected by one of the extra policies, adding them to
+// the policy's report. It returns true if the run was cancelled.
+func archivePolicyChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts, policy archivePolicy, loc *i18n.Localizer,
+	archiveTeam *model.Team, teams map[string]*model.Team, results *ArchiverResults) (bool, error) {
+	for {
+		// archived channels drop out of the results, so the first page is always fetched
+		staleChannels, more, err := sqlstore.GetStaleChannelsWithOffset(policy.staleOpts, 0, opts.BatchSize)
+		if err != nil {
+			results.ExitReason = ReasonError
+			return false, fmt.Errorf("cannot fetch %s: %w", policy.name, err)
+		}
+
+		results.StaleCount += len(staleChannels)
+		for _, ch := range staleChannels {
+			archivedChannelStr, err := archiveChannel(client, opts, policy, loc, archiveTeam, teams, ch)
+			if err != nil {
+				return false, err
+			}
+			_ = DeleteChannelWarning(client, ch.Id)
+			results.ChannelsArchived = append(results.ChannelsArchived, archivedChannelStr)
+			results.ChannelIDs = append(results.ChannelIDs, ch.Id)
+			results.PolicyCounts[policy.name]++
+			policy.report.WriteString(archivedChannelStr)
+
+			// sleep a short time so we don't peg the cpu
+			select {
+			case <-time.After(time.Millisecond * 10):
+			case <-ctx.Done():
+				results.ExitReason = ReasonCancelled
+				return true, nil
+			}
+		}
+
+		if opts.ProgressFn != nil {
+			opts.ProgressFn(results)
+		}
+
+		if !more {
+			return false, nil
+		}
+	}
+}
+
+// listPolicyChannels lists the channels one of the extra policies would archive, skipping the
+// channels in exclude, and returns their IDs. The returned bool is true if the run was cancelled.
+func listPolicyChannels(ctx context.Context, sqlstore *store.SQLStore, opts ArchiverOpts, policy archivePolicy, exclude []string, results *ArchiverResults) ([]string, bool, error) {
+	staleOpts := policy.staleOpts
+	staleOpts.ExcludeChannels = append(slices.Clone(staleOpts.ExcludeChannels), exclude...)
+	ids := make([]string, 0)
+
+	for page := 0; ; page++ {
+		staleChannels, more, err := sqlstore.GetStaleChannels(staleOpts, page, opts.BatchSize)
+		if err != nil {
+			results.ExitReason = ReasonError
+			return nil, false, fmt.Errorf("cannot fetch %s: %w", policy.name, err)
+		}
+		results.StaleCount += len(staleChannels)
+
+		for _, ch := range staleChannels {
+			policy.report.WriteString(fmt.Sprintf("%s (%s)\n", ch.Name, ch.Id))
+			results.ChannelsArchived = append(results.ChannelsArchived, fmt.Sprintf("**%s** (%s)", ch.Name, ch.Id))
+			results.ChannelIDs = append(results.ChannelIDs, ch.Id)
+			results.PolicyCounts[policy.name]++
+			ids = append(ids, ch.Id)
+		}
+
+		if !more {
+			return ids, false, nil
+		}
+
+		// sleep a short time so we don't peg the cpu
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-ctx.Done():
+			results.ExitReason = ReasonCancelled
+			return nil, true, nil
+		}
+	}
+}
+
+// writePolicySections appends a section for each extra policy that found channels to the admin report.
+func writePolicySections(buffer *bytes.Buffer, policies []archivePolicy, loc *i18n.Localizer) {
+	for _, policy := range policies {
+		if policy.report.Len() == 0 {
+			continue
+		}
+		buffer.WriteString("\n" + loc.T(policy.header, nil) + "\n")
+		buffer.Write(policy.report.Bytes())
+	}
+}
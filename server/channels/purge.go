@@ -0,0 +1,208 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+type PurgeOpts struct {
+	AgeInDays    int  // channels archived for more than this many days are purged
+	MaxChannels  int  // safety cap on the number of channels purged per run
+	DryRun       bool // don't delete channels, just list results
+	AdminChannel string
+
+	Bot    *bot.Bot      // optional bot for posting the report to the admin channel
+	Audit  *audit.Logger // optional audit logger
+	I18n   *i18n.Bundle  // optional translations; the report is in English without it
+	Locale string        // locale of the admin channel report, the server locale if empty
+}
+
+type PurgeResults struct {
+	ChannelsPurged []string
+	ChannelIDs     []string
+	Counts         store.PurgeCounts
+	CapReached     bool // more channels are eligible than MaxChannels
+	ExitReason     Reason
+	Duration       time.Duration
+}
+
+// PurgeArchivedChannels permanently deletes channels that have been archived for more than
+// opts.AgeInDays, along with their posts, reactions, file info, drafts and bookmarks. Channels marked as keep are skipped.
+func PurgeArchivedChannels(ctx context.Context, sqlstore *store.SQLStore, client *pluginapi.Client, opts PurgeOpts) (results *PurgeResults, retErr error) {
+	start := time.Now()
+	results = &PurgeResults{
+		ChannelsPurged: make([]string, 0),
+		ChannelIDs:     make([]string, 0),
+		ExitReason:     ReasonDone,
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			retErr = fmt.Errorf("panic recovered: %v", p)
+		}
+		if retErr != nil {
+			results.ExitReason = ReasonError
+		}
+		results.Duration = time.Since(start)
+		logPurgeRunCompleted(opts, results, retErr)
+	}()
+
+	kept, err := GetKeptChannelIDs(client)
+	if err != nil {
+		return results, err
+	}
+
+	archivedBefore := model.GetMillisForTime(time.Now().AddDate(0, 0, -opts.AgeInDays))
+
+	// fetch one more than the cap to detect whether the cap was reached
+	archived, err := sqlstore.GetArchivedChannels(archivedBefore, kept, opts.MaxChannels+1)
+	if err != nil {
+		return results, fmt.Errorf("cannot fetch archived channels: %w", err)
+	}
+	if len(archived) > opts.MaxChannels {
+		results.CapReached = true
+		archived = archived[:opts.MaxChannels]
+	}
+
+	client.Log.Debug("Purging archived channels.", "AgeInDays", opts.AgeInDays, "count", len(archived), "dry_run", opts.DryRun)
+
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+
+	var buffer bytes.Buffer
+	if opts.DryRun {
+		buffer.WriteString(loc.T(&i18n.Message{ID: "archiver.purge.candidates_header", Other: "Channels to be permanently deleted:"}, nil) + "\n")
+	} else {
+		buffer.WriteString(loc.T(&i18n.Message{ID: "archiver.purge.purged_header", Other: "Permanently deleted channels:"}, nil) + "\n")
+	}
+
+	for _, ch := range archived {
+		line := loc.T(&i18n.Message{ID: "archiver.purge.channel", Other: "{{.ChannelName}} ({{.ChannelID}}) archived {{.ArchivedAt}}"}, map[string]any{
+			"ChannelName": ch.Name,
+			"ChannelID":   ch.Id,
+			"ArchivedAt":  model.GetTimeForMillis(ch.DeleteAt).UTC().Format(time.RFC3339),
+		})
+
+		if !opts.DryRun {
+			counts, err := sqlstore.PurgeChannel(ch.Id)
+			if err != nil {
+				opts.Audit.Log(audit.Record{
+					Event:  audit.EventChannelPurged,
+					Status: model.AuditStatusFail,
+					Data:   purgeAuditData(ch, nil),
+					Error:  err.Error(),
+				})
+				return results, fmt.Errorf("cannot purge channel %s (%s): %w", ch.Name, ch.Id, err)
+			}
+
+			opts.Audit.Log(audit.Record{
+				Event:  audit.EventChannelPurged,
+				Status: model.AuditStatusSuccess,
+				Data:   purgeAuditData(ch, counts),
+			})
+
+			results.Counts.Add(counts)
+			line += ", " + loc.T(&i18n.Message{ID: "archiver.purge.counts", Other: "{{.Posts}} posts, {{.Reactions}} reactions, {{.FileInfos}} files"}, counts)
+		}
+
+		results.ChannelsPurged = append(results.ChannelsPurged, line)
+		results.ChannelIDs = append(results.ChannelIDs, ch.Id)
+		buffer.WriteString(line + "\n")
+
+		// sleep a short time so we don't peg the cpu
+		select {
+		case <-time.After(time.Millisecond * 10):
+		case <-ctx.Done():
+			results.ExitReason = ReasonCancelled
+			return results, nil
+		}
+	}
+
+	if len(archived) == 0 {
+		return results, nil
+	}
+
+	msg := loc.T(&i18n.Message{ID: "archiver.purge.purged", Other: "The following archived channels have been permanently deleted:"}, nil)
+	if !opts.DryRun {
+		// the plugin API can neither delete files nor invalidate the server caches
+		msg += "\n" + loc.T(&i18n.Message{
+			ID:    "archiver.purge.cache_notice",
+			Other: "Clients may show the deleted channels until the server caches are purged in **System Console > Web Server > Purge All Caches**.",
+		}, nil)
+		if paths := results.Counts.FilePaths; len(paths) > 0 {
+			msg += "\n" + loc.T(&i18n.Message{
+				ID:    "archiver.purge.files_left",
+				Other: "{{.Count}} files were left in the file store and are listed at the end of the attached report, to be removed from the storage.",
+			}, map[string]any{"Count": len(paths)})
+			buffer.WriteString("\n" + loc.T(&i18n.Message{ID: "archiver.purge.files_header", Other: "Files left in the file store:"}, nil) + "\n")
+			for _, path := range paths {
+				buffer.WriteString(path + "\n")
+			}
+		}
+	}
+	fileType := "purged"
+	if opts.DryRun {
+		msg = loc.T(&i18n.Message{ID: "archiver.purge.candidates", Other: "The following archived channels are eligible for permanent deletion (dry run):"}, nil)
+		fileType = "purge-candidates"
+	}
+	if results.CapReached {
+		msg += "\n" + loc.T(&i18n.Message{
+			ID:    "archiver.purge.cap_reached",
+			Other: "The limit of {{.MaxChannels}} channels per run was reached; remaining channels will be processed on the next run.",
+		}, map[string]any{"MaxChannels": opts.MaxChannels})
+	}
+
+	return results, handleAdminChannelPost(opts.Bot, &buffer, fileType, opts.AdminChannel, msg)
+}
+
+func purgeAuditData(ch *model.Channel, counts *store.PurgeCounts) map[string]any {
+	data := map[string]any{
+		"channel_id":   ch.Id,
+		"channel_name": ch.Name,
+		"team_id":      ch.TeamId,
+		"archived_at":  ch.DeleteAt,
+	}
+	if counts != nil {
+		data["posts"] = counts.Posts
+		data["reactions"] = counts.Reactions
+		data["file_infos"] = counts.FileInfos
+		data["threads"] = counts.Threads
+		data["drafts"] = counts.Drafts
+		data["bookmarks"] = counts.Bookmarks
+		data["channel_members"] = counts.ChannelMembers
+		data["file_paths"] = counts.FilePaths
+	}
+	return data
+}
+
+// logPurgeRunCompleted records the outcome of a purge run.
+func logPurgeRunCompleted(opts PurgeOpts, results *PurgeResults, err error) {
+	rec := audit.Record{
+		Event:  audit.EventRunCompleted,
+		Status: model.AuditStatusSuccess,
+		Data: map[string]any{
+			"run":           "purge",
+			"dry_run":       opts.DryRun,
+			"age_in_days":   opts.AgeInDays,
+			"channel_count": len(results.ChannelIDs),
+			"cap_reached":   results.CapReached,
+			"posts":         results.Counts.Posts,
+			"exit_reason":   string(results.ExitReason),
+			"duration_ms":   results.Duration.Milliseconds(),
+		},
+	}
+	if err != nil {
+		rec.Status = model.AuditStatusFail
+		rec.Error = err.Error()
+	}
+	opts.Audit.Log(rec)
+}
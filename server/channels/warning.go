@@ -0,0 +1,262 @@
+package channels
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	warningKeyPrefix = "warn_"
+
+	WarningActionKeep    = "keep"
+	WarningActionArchive = "archive"
+
+	// WarningActionRoute is the plugin HTTP route handling the buttons of the warning messages.
+	WarningActionRoute = "/channel_archiver/warning_action"
+
+	warningDateLayout = "Jan 2, 2006"
+)
+
+// ChannelWarning records that the admins of a stale channel were told it will be archived.
+type ChannelWarning struct {
+	ChannelID string `json:"channel_id"`
+	WarnedAt  int64  `json:"warned_at"`
+	ArchiveAt int64  `json:"archive_at"` // the channel is not archived before this time
+
+	RecipientIDs []string `json:"recipient_ids,omitempty"` // users warned, who may keep or archive the channel
+}
+
+// IsValid returns true if the warning was sent after the channel became stale. Warnings sent before
+// that belong to an earlier stale period that ended with new activity.
+func (w *ChannelWarning) IsValid(olderThan int64) bool {
+	return w != nil && w.WarnedAt >= olderThan
+}
+
+func warningKey(channelID string) string {
+	return warningKeyPrefix + channelID
+}
+
+// GetChannelWarning returns the archive warning of a channel, or nil if its admins were never warned.
+func GetChannelWarning(client *pluginapi.Client, channelID string) (*ChannelWarning, error) {
+	var warning *ChannelWarning
+	if err := client.KV.Get(warningKey(channelID), &warning); err != nil {
+		return nil, fmt.Errorf("cannot get archive warning for channel %s: %w", channelID, err)
+	}
+	return warning, nil
+}
+
+// DeleteChannelWarning removes the archive warning of a channel.
+func DeleteChannelWarning(client *pluginapi.Client, channelID string) error {
+	if err := client.KV.Delete(warningKey(channelID)); err != nil {
+		return fmt.Errorf("cannot delete archive warning for channel %s: %w", channelID, err)
+	}
+	return nil
+}
+
+// CanActOnWarning returns true if a user may keep or archive a warned channel: channel admins and
+// system admins, and the users the warning was sent to, such as the creator of a channel without
+// admins. For warnings saved without recipients, the channel creator is allowed.
+func CanActOnWarning(client *pluginapi.Client, userID string, channel *model.Channel) bool {
+	if CanManageChannel(client, userID, channel.Id) {
+		return true
+	}
+
+	warning, err := GetChannelWarning(client, channel.Id)
+	if err != nil || warning == nil {
+		return false
+	}
+	if len(warning.RecipientIDs) == 0 {
+		return channel.CreatorId != "" && channel.CreatorId == userID
+	}
+	return slices.Contains(warning.RecipientIDs, userID)
+}
+
+// warnedChannel is a channel listed in a warning digest.
+type warnedChannel struct {
+	channel   *model.Channel
+	archiveAt int64
+}
+
+// warningDigest collects the stale channels whose admins must be warned during an archiver run,
+// so each admin gets a single message listing all of their channels.
+type warningDigest struct {
+	sqlstore  *store.SQLStore
+	client    *pluginapi.Client
+	opts      ArchiverOpts
+	olderThan int64
+	loc       *i18n.Localizer // localizer of the admin channel report
+	byUser    map[string][]*warnedChannel
+	warned    []string
+}
+
+func newWarningDigest(sqlstore *store.SQLStore, client *pluginapi.Client, opts ArchiverOpts) *warningDigest {
+	return &warningDigest{
+		sqlstore:  sqlstore,
+		client:    client,
+		opts:      opts,
+		olderThan: model.GetMillisForTime(time.Now().AddDate(0, 0, -opts.StaleChannelOpts.AgeInDays)),
+		loc:       opts.I18n.LocaleLocalizer(opts.Locale),
+		byUser:    make(map[string][]*warnedChannel),
+		warned:    make([]string, 0),
+	}
+}
+
+// shouldArchive returns true if the admins of the channel were warned and the warning period is over.
+// Channels without a valid warning are added to the digest and a warning is recorded for them.
+func (wd *warningDigest) shouldArchive(ch *model.Channel) (bool, error) {
+	warning, err := GetChannelWarning(wd.client, ch.Id)
+	if err != nil {
+		return false, err
+	}
+
+	now := model.GetMillis()
+	if warning.IsValid(wd.olderThan) {
+		return warning.ArchiveAt <= now, nil
+	}
+
+	warning = &ChannelWarning{
+		ChannelID: ch.Id,
+		WarnedAt:  now,
+		ArchiveAt: model.GetMillisForTime(time.Now().AddDate(0, 0, wd.opts.WarningDays)),
+	}
+
+	recipients, err := wd.recipients(ch)
+	if err != nil {
+		return false, err
+	}
+	warning.RecipientIDs = recipients
+	for _, userID := range recipients {
+		wd.byUser[userID] = append(wd.byUser[userID], &warnedChannel{channel: ch, archiveAt: warning.ArchiveAt})
+	}
+
+	if _, err = wd.client.KV.Set(warningKey(ch.Id), warning); err != nil {
+		return false, fmt.Errorf("cannot save archive warning for channel %s: %w", ch.Id, err)
+	}
+	wd.opts.Audit.Log(audit.Record{
+		Event:   audit.EventChannelWarned,
+		Status:  model.AuditStatusSuccess,
+		ActorID: wd.opts.ActorID,
+		Data: map[string]any{
+			"channel_id":    ch.Id,
+			"channel_name":  ch.Name,
+			"display_name":  ch.DisplayName,
+			"team_id":       ch.TeamId,
+			"warned_at":     warning.WarnedAt,
+			"archive_at":    warning.ArchiveAt,
+			"recipient_ids": recipients,
+		},
+	})
+	recipientCount := wd.loc.Plural(&i18n.Message{
+		ID:    "archiver.report.warning_recipients",
+		One:   "{{.Count}} recipient",
+		Other: "{{.Count}} recipients",
+	}, len(recipients), nil)
+	wd.warned = append(wd.warned, fmt.Sprintf("%s (%s) - %s\n", ch.Name, ch.Id, recipientCount))
+	return false, nil
+}
+
+// recipients returns the channel admins, or the channel creator if the channel has no admins.
+func (wd *warningDigest) recipients(ch *model.Channel) ([]string, error) {
+	adminIDs, err := wd.sqlstore.GetChannelAdminIDs(ch.Id)
+	if err != nil {
+		return nil, fmt.Errorf("cannot fetch admins of channel %s: %w", ch.Id, err)
+	}
+	if len(adminIDs) > 0 || ch.CreatorId == "" {
+		return adminIDs, nil
+	}
+
+	creator, err := wd.client.User.Get(ch.CreatorId)
+	if err != nil || creator.DeleteAt != 0 || creator.IsBot {
+		return adminIDs, nil
+	}
+	return []string{creator.Id}, nil
+}
+
+// send delivers one direct message per user, listing all of their channels scheduled to be archived.
+func (wd *warningDigest) send() {
+	if wd.opts.Bot == nil {
+		return
+	}
+
+	teams := make(map[string]*model.Team)
+	for userID, channels := range wd.byUser {
+		sort.Slice(channels, func(i, j int) bool {
+			return channels[i].channel.Name < channels[j].channel.Name
+		})
+
+		loc := wd.opts.I18n.UserLocalizer(userID)
+
+		var sb strings.Builder
+		sb.WriteString("#### " + loc.T(&i18n.Message{ID: "archiver.warning.title", Other: "Channels scheduled to be archived"}, nil) + "\n")
+		sb.WriteString(loc.T(&i18n.Message{
+			ID: "archiver.warning.intro",
+			Other: "The following channels you manage have had no activity for more than {{.DaysIdle}} days and will be archived. " +
+				"Any new activity in a channel also cancels its archival.",
+		}, map[string]any{"DaysIdle": wd.opts.StaleChannelOpts.AgeInDays}) + "\n")
+
+		attachments := make([]*model.SlackAttachment, 0, len(channels))
+		for _, wc := range channels {
+			ch := wc.channel
+			archiveDate := model.GetTimeForMillis(wc.archiveAt).UTC().Format(warningDateLayout)
+			sb.WriteString(loc.T(&i18n.Message{
+				ID:    "archiver.warning.channel",
+				Other: "- **{{.ChannelName}}** ({{.TeamName}}) on or after {{.ArchiveDate}}",
+			}, map[string]any{
+				"ChannelName": ch.DisplayName,
+				"TeamName":    teamDisplayName(wd.client, teams, ch.TeamId),
+				"ArchiveDate": archiveDate,
+			}) + "\n")
+			attachments = append(attachments, wd.attachment(loc, ch, MessageData{
+				ChannelName: ch.DisplayName,
+				TeamName:    teamDisplayName(wd.client, teams, ch.TeamId),
+				DaysIdle:    wd.opts.StaleChannelOpts.AgeInDays,
+				ArchiveDate: archiveDate,
+			}))
+		}
+
+		if err := wd.opts.Bot.SendDirectPost(userID, sb.String(), attachments...); err != nil {
+			wd.client.Log.Warn("Cannot send archive warning", "user_id", userID, "err", err)
+		}
+	}
+}
+
+func (wd *warningDigest) attachment(loc *i18n.Localizer, ch *model.Channel, data MessageData) *model.SlackAttachment {
+	attachment := &model.SlackAttachment{
+		Title: ch.DisplayName,
+		Text:  wd.opts.Messages.Warning(loc, data),
+	}
+	if wd.opts.WarningActionURL == "" {
+		return attachment
+	}
+
+	action := func(id, name, style, action string) *model.PostAction {
+		return &model.PostAction{
+			Id:    id,
+			Name:  name,
+			Style: style,
+			Type:  model.PostActionTypeButton,
+			Integration: &model.PostActionIntegration{
+				URL: wd.opts.WarningActionURL,
+				Context: map[string]any{
+					"channel_id": ch.Id,
+					"action":     action,
+				},
+			},
+		}
+	}
+	attachment.Actions = []*model.PostAction{
+		action("keep", loc.T(&i18n.Message{ID: "archiver.warning.keep_button", Other: "Keep"}, nil), "primary", WarningActionKeep),
+		action("archivenow", loc.T(&i18n.Message{ID: "archiver.warning.archive_button", Other: "Archive now"}, nil), "danger", WarningActionArchive),
+	}
+	return attachment
+}
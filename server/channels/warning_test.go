@@ -0,0 +1,17 @@
+package channels
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelWarningIsValid(t *testing.T) {
+	var warning *ChannelWarning
+	assert.False(t, warning.IsValid(1000), "missing warning")
+
+	warning = &ChannelWarning{WarnedAt: 2000, ArchiveAt: 3000}
+	assert.True(t, warning.IsValid(1000), "warned after the channel became stale")
+	assert.True(t, warning.IsValid(2000))
+	assert.False(t, warning.IsValid(2500), "warned during an earlier stale period")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -11,9 +12,12 @@ import (
 	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
 	"github.com/mattermost/mattermost/server/public/pluginapi/experimental/command"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 )
 
@@ -22,6 +26,33 @@ const (
 	paramNameDays      = "days"
 	paramNameBatchSize = "batch-size"
 	paramNameExclude   = "exclude"
+	paramNameUntil     = "until"
+	paramNameReason    = "reason"
+
+	keepDateLayout = "2006-01-02"
+)
+
+var (
+	msgRequirePermission = &i18n.Message{
+		ID:    "archiver.command.require_permission",
+		Other: "You require {{.Permission}} permissions to execute this command.",
+	}
+	msgChannelNotFound = &i18n.Message{
+		ID:    "archiver.command.channel_not_found",
+		Other: "Cannot find channel `{{.Channel}}`.",
+	}
+
+	// subcommand descriptions, shown in English by the autocomplete and localized by help
+	subCommandHelp = map[string]*i18n.Message{
+		"archive":     {ID: "archiver.command.help_archive", Other: "Archive stale channels"},
+		"list":        {ID: "archiver.command.help_list", Other: "List stale channels that would be archived"},
+		"inspect":     {ID: "archiver.command.help_inspect", Other: "Explain why a channel would or would not be archived"},
+		"keep":        {ID: "archiver.command.help_keep", Other: "Protect the current channel from being archived"},
+		"keep-list":   {ID: "archiver.command.help_keep_list", Other: "List all channels marked as keep"},
+		"keep-revoke": {ID: "archiver.command.help_keep_revoke", Other: "Remove the keep marker from a channel"},
+		"move-back":   {ID: "archiver.command.help_move_back", Other: "Move a channel from the archive team back to its original team"},
+		"help":        {ID: "archiver.command.help_help", Other: "Display help text"},
+	}
 )
 
 type ErrInvalidSubCommand struct {
@@ -38,6 +69,8 @@ type ChannelArchiverCmd struct {
 	commands []*model.AutocompleteData
 	bot      *bot.Bot
 	config   *config.Configuration
+	i18n     *i18n.Bundle
+	audit    *audit.Logger
 }
 
 func getDefaultBatchSize(list bool) int {
@@ -48,11 +81,23 @@ func getDefaultBatchSize(list bool) int {
 }
 
 // RegisterChannelArchiver is called by the plugin to register all necessary commands
-func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, configuration *config.Configuration) (*ChannelArchiverCmd, error) {
-	cmdArchive := model.NewAutocompleteData("archive", "", "Archive stale channels")
-	cmdList := model.NewAutocompleteData("list", "", "List stale channels that would be archived")
-	cmdHelp := model.NewAutocompleteData("help", "", "Display help text")
-	commands := []*model.AutocompleteData{cmdArchive, cmdList, cmdHelp}
+func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, configuration *config.Configuration, bundle *i18n.Bundle, auditLogger *audit.Logger) (*ChannelArchiverCmd, error) {
+	cmdArchive := model.NewAutocompleteData("archive", "", subCommandHelp["archive"].Other)
+	cmdList := model.NewAutocompleteData("list", "", subCommandHelp["list"].Other)
+	cmdInspect := model.NewAutocompleteData("inspect", "[~channel]", subCommandHelp["inspect"].Other)
+	cmdKeep := model.NewAutocompleteData("keep", "", subCommandHelp["keep"].Other)
+	cmdKeepList := model.NewAutocompleteData("keep-list", "", subCommandHelp["keep-list"].Other)
+	cmdKeepRevoke := model.NewAutocompleteData("keep-revoke", "[~channel]", subCommandHelp["keep-revoke"].Other)
+	cmdMoveBack := model.NewAutocompleteData("move-back", "[~channel]", subCommandHelp["move-back"].Other)
+	cmdHelp := model.NewAutocompleteData("help", "", subCommandHelp["help"].Other)
+	commands := []*model.AutocompleteData{cmdArchive, cmdList, cmdInspect, cmdKeep, cmdKeepList, cmdKeepRevoke, cmdMoveBack, cmdHelp}
+
+	// Channel admins may mark their own channels as keep; everything else requires a system admin.
+	for _, c := range commands {
+		if c != cmdKeep && c != cmdHelp {
+			c.RoleID = model.SystemAdminRoleId
+		}
+	}
 
 	cmdArchive.AddNamedTextArgument(paramNameDays, "Number of days of inactivity for a channel to be considered stale", fmt.Sprintf("[int - min %d days]", config.MinAgeInDays), "[0-9]*", true)
 	cmdArchive.AddNamedTextArgument(paramNameBatchSize, fmt.Sprintf("Channels will be archived in batches of this size. (default=%d)", config.DefaultArchiveBatchSize), "[int]", "[0-9]*", false)
@@ -61,6 +106,15 @@ func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, co
 	cmdList.AddNamedTextArgument(paramNameDays, "Number of days of inactivity for a channel to be considered stale", fmt.Sprintf("[int - min %d days]", config.MinAgeInDays), "[0-9]*", true)
 	cmdList.AddNamedTextArgument(paramNameExclude, "Comma separated list of channel names/IDs to exclude. No Spaces.", "", "", false)
 
+	cmdInspect.AddTextArgument("Channel to inspect. Defaults to the current channel.", "[~channel]", "")
+
+	cmdKeep.AddNamedTextArgument(paramNameReason, "Why the channel must be kept", "[text]", "", true)
+	cmdKeep.AddNamedTextArgument(paramNameUntil, "Keep the channel through this date, in UTC, or until an RFC 3339 timestamp", "[YYYY-MM-DD or YYYY-MM-DDThh:mm:ssZ]", "", false)
+
+	cmdKeepRevoke.AddTextArgument("Channel to remove the keep marker from. Defaults to the current channel.", "[~channel]", "")
+
+	cmdMoveBack.AddTextArgument("Channel to move back. Defaults to the current channel.", "[~channel]", "")
+
 	names := []string{}
 	for _, c := range commands {
 		names = append(names, c.Trigger)
@@ -69,7 +123,7 @@ func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, co
 
 	cmd := model.NewAutocompleteData(ArchiverTrigger, hint, "Manage stale channels.")
 	cmd.SubCommands = commands
-	cmd.RoleID = model.SystemAdminRoleId
+	cmd.RoleID = model.SystemUserRoleId
 
 	iconData, err := command.GetIconData(&client.System, "assets/archiver.svg")
 	if err != nil {
@@ -101,9 +155,17 @@ func RegisterChannelArchiver(client *pluginapi.Client, store *store.SQLStore, co
 		commands: commands,
 		bot:      bot,
 		config:   configuration,
+		i18n:     bundle,
+		audit:    auditLogger,
 	}, nil
 }
 
+// IsChannelAdminCommand returns true if the command is a channel-archiver subcommand that
+// channel admins may run without being system admins. Such subcommands check permissions themselves.
+func IsChannelAdminCommand(cmd string) bool {
+	return parseNamedArgs(cmd)[SubCommandKey] == "keep"
+}
+
 func (ca *ChannelArchiverCmd) OnConfigurationChange(newConfig *config.Configuration) {
 	ca.config = newConfig
 }
@@ -115,13 +177,26 @@ func (ca *ChannelArchiverCmd) Execute(args *model.CommandArgs) (*model.CommandRe
 	var err error
 	var msg string
 
+	// command output is only seen by the user running the command
+	loc := ca.i18n.UserLocalizer(args.UserId)
+
 	switch subCommand {
 	case "archive":
-		msg, err = ca.handleArchive(args, params, false)
+		msg, err = ca.handleArchive(args, params, false, loc)
 	case "list":
-		msg, err = ca.handleArchive(args, params, true)
+		msg, err = ca.handleArchive(args, params, true, loc)
+	case "inspect":
+		msg, err = ca.handleInspect(args, loc)
+	case "keep":
+		msg, err = ca.handleKeep(args, params, loc)
+	case "keep-list":
+		msg, err = ca.handleKeepList(args, loc)
+	case "keep-revoke":
+		msg, err = ca.handleKeepRevoke(args, loc)
+	case "move-back":
+		msg, err = ca.handleMoveBack(args, loc)
 	case "help":
-		msg, err = ca.handleHelp()
+		msg, err = ca.handleHelp(loc)
 	default:
 		err = ErrInvalidSubCommand{subCommand: subCommand}
 	}
@@ -133,21 +208,27 @@ func (ca *ChannelArchiverCmd) Execute(args *model.CommandArgs) (*model.CommandRe
 	return &model.CommandResponse{}, err
 }
 
-func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[string]string, list bool) (string, error) {
+func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[string]string, list bool, loc *i18n.Localizer) (string, error) {
 	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
-		return fmt.Sprintf("You require %s permissions to execute this command.", model.PermissionManageSystem.Id), nil
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
 	}
 
 	days, err := config.ParseInt(params[paramNameDays], config.MinAgeInDays, config.MaxAgeInDays)
 	if err != nil {
-		return fmt.Sprintf("Missing or invalid '%s' parameter: %s", paramNameDays, err.Error()), nil
+		return loc.T(&i18n.Message{
+			ID:    "archiver.command.invalid_days",
+			Other: "Missing or invalid '{{.Param}}' parameter: {{.Error}}",
+		}, map[string]any{"Param": paramNameDays, "Error": err.Error()}), nil
 	}
 
 	batchSize := getDefaultBatchSize(list)
 	if bs, ok := params[paramNameBatchSize]; ok {
 		batchSize, err = config.ParseInt(bs, config.MinBatchSize, config.MaxBatchSize)
 		if err != nil {
-			return fmt.Sprintf("Invalid '%s' parameter: %s", paramNameBatchSize, err.Error()), nil
+			return loc.T(&i18n.Message{
+				ID:    "archiver.command.invalid_batch_size",
+				Other: "Invalid '{{.Param}}' parameter: {{.Error}}",
+			}, map[string]any{"Param": paramNameBatchSize, "Error": err.Error()}), nil
 		}
 	}
 
@@ -157,14 +238,14 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 	}
 
 	// Include the configured excluded channels
-	if ca.config.ExcludeChannels != "" {
-		excludedChannelsNoSpaces := strings.ReplaceAll(ca.config.ExcludeChannels, " ", ",")
-		excludedChannelsSlice := strings.Split(excludedChannelsNoSpaces, ",")
-		if len(exclude) > 0 {
-			exclude = append(exclude, excludedChannelsSlice...)
-		} else {
-			exclude = excludedChannelsSlice
-		}
+	exclude = append(exclude, ca.config.GetExcludeChannels()...)
+
+	messages, err := channels.NewMessageTemplates(ca.config)
+	if err != nil {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.command.invalid_templates",
+			Other: "Cannot archive channels: {{.Error}}",
+		}, map[string]any{"Error": err.Error()}), nil
 	}
 
 	opts := channels.ArchiverOpts{
@@ -175,22 +256,35 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 			IncludeChannelTypePrivate: true,
 			AdminChannel:              ca.config.AdminChannel,
 		},
-		BatchSize: batchSize,
-		ListOnly:  list,
+		BatchSize:   batchSize,
+		ListOnly:    list,
+		ArchiveTeam: ca.config.ArchiveTeam,
+		Messages:    messages,
+		I18n:        ca.i18n,
+		Locale:      ca.config.ChannelPostLocale,
+		Audit:       ca.audit,
+		ActorID:     args.UserId,
 		ProgressFn: func(results *channels.ArchiverResults) {
 			if list {
 				return
 			}
 			ca.client.Log.Debug("Channel Archiver", "archived_count", len(results.ChannelsArchived))
-			msg := fmt.Sprintf("Channel-archiver progress -- %d channels archived.", len(results.ChannelsArchived))
+			msg := loc.T(&i18n.Message{
+				ID:    "archiver.command.progress",
+				Other: "Channel-archiver progress -- {{.Count}} channels archived.",
+			}, map[string]any{"Count": len(results.ChannelsArchived)})
 			_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
 		},
-		Bot: ca.bot,
+		Bot:      ca.bot,
+		Policies: channels.NewPolicyOpts(ca.config),
 	}
 
 	results, err := channels.ArchiveStaleChannels(context.TODO(), ca.sqlStore, ca.client, opts)
 	if err != nil {
-		return fmt.Sprintf("Error archiving channels: %s", err.Error()), nil
+		return loc.T(&i18n.Message{
+			ID:    "archiver.command.archive_error",
+			Other: "Error archiving channels: {{.Error}}",
+		}, map[string]any{"Error": err.Error()}), nil
 	}
 
 	if list {
@@ -201,10 +295,16 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 			if err != nil {
 				return "", err
 			}
-			msg = fmt.Sprintf("Channel list uploaded to %s.", channel.Name)
+			msg = loc.T(&i18n.Message{
+				ID:    "archiver.command.list_uploaded",
+				Other: "Channel list uploaded to {{.ChannelName}}.",
+			}, map[string]any{"ChannelName": channel.Name})
 		} else {
-			ca.reportChannelList(args, results.ChannelsArchived)
-			msg = fmt.Sprintf("count: %d\n%s", len(results.ChannelsArchived), results.ExitReason)
+			ca.reportChannelList(args, results.ChannelsArchived, loc)
+			msg = loc.T(&i18n.Message{
+				ID:    "archiver.command.list_count",
+				Other: "count: {{.Count}}\n{{.ExitReason}}",
+			}, map[string]any{"Count": len(results.ChannelsArchived), "ExitReason": exitReason(loc, results.ExitReason)})
 		}
 		return msg, nil
 	}
@@ -215,18 +315,407 @@ func (ca *ChannelArchiverCmd) handleArchive(args *model.CommandArgs, params map[
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("%d channels archived in %v. Archived channel list uploaded to %s.\n%s", len(results.ChannelsArchived), results.Duration, channel.Name, results.ExitReason), nil
+		return loc.T(&i18n.Message{
+			ID:    "archiver.command.archived_uploaded",
+			Other: "{{.Count}} channels archived in {{.Duration}}. Archived channel list uploaded to {{.ChannelName}}.\n{{.ExitReason}}",
+		}, map[string]any{
+			"Count":       len(results.ChannelsArchived),
+			"Duration":    results.Duration.String(),
+			"ChannelName": channel.Name,
+			"ExitReason":  exitReason(loc, results.ExitReason),
+		}), nil
+	}
+
+	return loc.T(&i18n.Message{
+		ID:    "archiver.command.archived",
+		Other: "{{.Count}} channels archived in {{.Duration}}.\n{{.ExitReason}}",
+	}, map[string]any{
+		"Count":      len(results.ChannelsArchived),
+		"Duration":   results.Duration.String(),
+		"ExitReason": exitReason(loc, results.ExitReason),
+	}), nil
+}
+
+func (ca *ChannelArchiverCmd) handleInspect(args *model.CommandArgs, loc *i18n.Localizer) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
+	}
+
+	var channelRef string
+	if positional := parsePositionalArgs(args.Command); len(positional) > 0 {
+		channelRef = positional[0]
+	}
+
+	channel, err := ca.resolveChannel(args, channelRef)
+	if err != nil {
+		return loc.T(msgChannelNotFound, map[string]any{"Channel": channelRef}), nil
+	}
+
+	opts := store.StaleChannelOpts{
+		AgeInDays:                 ca.config.AgeInDays,
+		ExcludeChannels:           ca.config.GetExcludeChannels(),
+		IncludeChannelTypeOpen:    true,
+		IncludeChannelTypePrivate: true,
+		AdminChannel:              ca.config.AdminChannel,
+	}
+
+	results, err := channels.InspectChannel(ca.sqlStore, ca.client, channel, opts, channels.NewPolicyOpts(ca.config), loc)
+	if err != nil {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.inspect.error",
+			Other: "Error inspecting channel: {{.Error}}",
+		}, map[string]any{"Error": err.Error()}), nil
+	}
+
+	var sb strings.Builder
+	writeLine := func(msg *i18n.Message, data any) {
+		sb.WriteString(loc.T(msg, data) + "\n")
+	}
+
+	writeLine(&i18n.Message{
+		ID:    "archiver.inspect.title",
+		Other: "#### Channel Archiver inspection for ~{{.ChannelName}} (`{{.ChannelID}}`)",
+	}, map[string]any{"ChannelName": channel.Name, "ChannelID": channel.Id})
+	writeLine(&i18n.Message{ID: "archiver.inspect.last_post", Other: "- **Last post:** {{.Time}}"},
+		map[string]any{"Time": formatActivityTime(loc, results.Activity.LastPostAt)})
+	writeLine(&i18n.Message{ID: "archiver.inspect.last_reaction", Other: "- **Last reaction:** {{.Time}}"},
+		map[string]any{"Time": formatActivityTime(loc, results.Activity.LastReactionAt)})
+	writeLine(&i18n.Message{ID: "archiver.inspect.channel_updated", Other: "- **Channel updated (`UpdateAt`):** {{.Time}}"},
+		map[string]any{"Time": formatActivityTime(loc, results.Activity.ChannelUpdateAt)})
+	writeLine(&i18n.Message{
+		ID:    "archiver.inspect.stale",
+		Other: "- **Stale:** {{.Stale}} (no activity for {{.Days}} days means stale; cutoff is {{.Cutoff}})",
+	}, map[string]any{
+		"Stale":  yesNo(loc, results.Stale),
+		"Days":   opts.AgeInDays,
+		"Cutoff": formatActivityTime(loc, results.OlderThan),
+	})
+	if results.Orphaned {
+		if ca.config.OrphanedAgeInDays > 0 {
+			writeLine(&i18n.Message{
+				ID:    "archiver.inspect.orphaned",
+				Other: "- **Orphaned:** yes, no active members (archived after {{.Days}} days without activity)",
+			}, map[string]any{"Days": ca.config.OrphanedAgeInDays})
+		} else {
+			writeLine(&i18n.Message{
+				ID:    "archiver.inspect.orphaned_disabled",
+				Other: "- **Orphaned:** yes, no active members (orphaned channels policy disabled)",
+			}, nil)
+		}
+	}
+	if results.Abandoned {
+		writeLine(&i18n.Message{
+			ID:    "archiver.inspect.abandoned",
+			Other: "- **Abandoned:** yes, never used since it was created more than {{.Days}} days ago",
+		}, map[string]any{"Days": ca.config.AbandonedAgeInDays})
+	}
+
+	if len(results.Exclusions) == 0 {
+		writeLine(&i18n.Message{ID: "archiver.inspect.no_exclusions", Other: "- **Exclusions:** none"}, nil)
+	} else {
+		writeLine(&i18n.Message{ID: "archiver.inspect.exclusions", Other: "- **Exclusions:**"}, nil)
+		for _, ex := range results.Exclusions {
+			sb.WriteString(fmt.Sprintf("  - %s\n", ex))
+		}
+	}
+
+	switch {
+	case !ca.config.EnableChannelArchiver:
+		writeLine(&i18n.Message{ID: "archiver.inspect.job_disabled", Other: "- **Scheduled job:** disabled"}, nil)
+	case ca.config.EnableChannelArchiverDryRunMode:
+		writeLine(&i18n.Message{ID: "archiver.inspect.job_dry_run", Other: "- **Scheduled job:** enabled (dry run mode, channels are only listed)"}, nil)
+	default:
+		writeLine(&i18n.Message{ID: "archiver.inspect.job_enabled", Other: "- **Scheduled job:** enabled"}, nil)
+	}
+
+	if results.Keep != nil {
+		writeLine(&i18n.Message{ID: "archiver.inspect.keep", Other: "- **Keep:** {{.Keep}}"},
+			map[string]any{"Keep": ca.formatKeepMarker(loc, results.Keep)})
+	}
+
+	if results.Origin != nil {
+		teamName := results.Origin.TeamID
+		if team, err := ca.client.Team.Get(results.Origin.TeamID); err == nil {
+			teamName = team.Name
+		}
+		writeLine(&i18n.Message{
+			ID:    "archiver.inspect.origin",
+			Other: "- **Moved to the archive team:** originally `{{.ChannelName}}` in team {{.TeamName}} (`{{.TeamID}}`)",
+		}, map[string]any{"ChannelName": results.Origin.Name, "TeamName": teamName, "TeamID": results.Origin.TeamID})
+	}
+
+	wouldArchive := yesNo(loc, results.WouldArchive)
+	if results.Warning != nil {
+		writeLine(&i18n.Message{
+			ID:    "archiver.inspect.warning",
+			Other: "- **Archive warning:** sent {{.WarnedAt}}, archiving on or after {{.ArchiveAt}}",
+		}, map[string]any{
+			"WarnedAt":  formatActivityTime(loc, results.Warning.WarnedAt),
+			"ArchiveAt": formatActivityTime(loc, results.Warning.ArchiveAt),
+		})
+	}
+	// only inactive channels are warned about
+	if results.WouldArchive && results.Policy == channels.PolicyInactive && ca.config.WarningDays > 0 {
+		switch {
+		case results.Warning == nil:
+			wouldArchive = loc.T(&i18n.Message{ID: "archiver.inspect.would_warn", Other: "no, the channel admins would be warned first"}, nil)
+		case results.Warning.ArchiveAt > model.GetMillis():
+			wouldArchive = loc.T(&i18n.Message{ID: "archiver.inspect.warning_pending", Other: "no, the warning period is not over yet"}, nil)
+		}
+	}
+	writeLine(&i18n.Message{ID: "archiver.inspect.would_archive", Other: "- **Next run would archive this channel:** {{.WouldArchive}}"},
+		map[string]any{"WouldArchive": wouldArchive})
+
+	return sb.String(), nil
+}
+
+func (ca *ChannelArchiverCmd) handleKeep(args *model.CommandArgs, params map[string]string, loc *i18n.Localizer) (string, error) {
+	channel, err := ca.client.Channel.Get(args.ChannelId)
+	if err != nil {
+		return "", err
+	}
+
+	if channel.Type != model.ChannelTypeOpen && channel.Type != model.ChannelTypePrivate {
+		return loc.T(&i18n.Message{ID: "archiver.keep.invalid_type", Other: "Only public and private channels can be marked as keep."}, nil), nil
+	}
+
+	if !channels.CanManageChannel(ca.client, args.UserId, channel.Id) {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.keep.no_permission",
+			Other: "You must be a channel admin or system admin to mark this channel as keep.",
+		}, nil), nil
+	}
+
+	reason := params[paramNameReason]
+	if reason == "" {
+		return loc.T(&i18n.Message{ID: "archiver.keep.missing_param", Other: "Missing '{{.Param}}' parameter."}, map[string]any{"Param": paramNameReason}), nil
+	}
+
+	now := time.Now()
+	var until time.Time
+	if u := params[paramNameUntil]; u != "" {
+		until, err = parseKeepUntil(u)
+		if err != nil {
+			return loc.T(&i18n.Message{
+				ID:    "archiver.keep.invalid_until",
+				Other: "Invalid '{{.Param}}' parameter: expected a date formatted as YYYY-MM-DD or a timestamp such as 2027-06-30T17:00:00Z.",
+			}, map[string]any{"Param": paramNameUntil}), nil
+		}
+		if !until.After(now) {
+			return loc.T(&i18n.Message{
+				ID:    "archiver.keep.until_in_past",
+				Other: "Invalid '{{.Param}}' parameter: the date must be in the future.",
+			}, map[string]any{"Param": paramNameUntil}), nil
+		}
+	}
+
+	if ca.config.MaxKeepDays > 0 {
+		maxUntil := now.AddDate(0, 0, ca.config.MaxKeepDays)
+		switch {
+		case until.IsZero():
+			until = maxUntil
+		case until.After(maxUntil) && formatKeepUntil(until) == maxUntil.UTC().Format(keepDateLayout):
+			// the last allowed day was given as a date
+			until = maxUntil
+		case until.After(maxUntil):
+			return loc.T(&i18n.Message{
+				ID:    "archiver.keep.max_days",
+				Other: "Channels can be kept for at most {{.MaxKeepDays}} days (until {{.Until}}).",
+			}, map[string]any{"MaxKeepDays": ca.config.MaxKeepDays, "Until": maxUntil.UTC().Format(keepDateLayout)}), nil
+		}
+	}
+
+	marker := &channels.KeepMarker{
+		ChannelID: channel.Id,
+		UserID:    args.UserId,
+		Reason:    reason,
+		CreateAt:  model.GetMillisForTime(now),
+	}
+	if !until.IsZero() {
+		marker.ExpireAt = model.GetMillisForTime(until)
+	}
+
+	if err = channels.SaveKeepMarker(ca.client, marker); err != nil {
+		return "", err
+	}
+
+	channelLoc := ca.i18n.LocaleLocalizer(ca.config.ChannelPostLocale)
+	_ = ca.bot.SendPost(channel.Id, channelLoc.T(&i18n.Message{
+		ID:    "archiver.keep.channel_notice",
+		Other: "This channel has been marked as keep and will not be archived: {{.Keep}}",
+	}, map[string]any{"Keep": ca.formatKeepMarker(channelLoc, marker)}))
+
+	return loc.T(&i18n.Message{
+		ID:    "archiver.keep.marked",
+		Other: "~{{.ChannelName}} marked as keep: {{.Keep}}",
+	}, map[string]any{"ChannelName": channel.Name, "Keep": ca.formatKeepMarker(loc, marker)}), nil
+}
+
+func (ca *ChannelArchiverCmd) handleKeepList(args *model.CommandArgs, loc *i18n.Localizer) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
+	}
+
+	markers, err := channels.ListKeepMarkers(ca.client)
+	if err != nil {
+		return "", err
+	}
+
+	if len(markers) == 0 {
+		return loc.T(&i18n.Message{ID: "archiver.keep_list.empty", Other: "No channels are marked as keep."}, nil), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(loc.Plural(&i18n.Message{
+		ID:    "archiver.keep_list.title",
+		One:   "#### {{.Count}} channel marked as keep",
+		Other: "#### {{.Count}} channels marked as keep",
+	}, len(markers), nil) + "\n")
+	for _, marker := range markers {
+		name := marker.ChannelID
+		if channel, err := ca.client.Channel.Get(marker.ChannelID); err == nil {
+			name = "~" + channel.Name
+		}
+		sb.WriteString(fmt.Sprintf("- %s (`%s`): %s\n", name, marker.ChannelID, ca.formatKeepMarker(loc, marker)))
+	}
+	return sb.String(), nil
+}
+
+func (ca *ChannelArchiverCmd) handleKeepRevoke(args *model.CommandArgs, loc *i18n.Localizer) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
+	}
+
+	var channelRef string
+	if positional := parsePositionalArgs(args.Command); len(positional) > 0 {
+		channelRef = positional[0]
+	}
+
+	channel, err := ca.resolveChannel(args, channelRef)
+	if err != nil {
+		return loc.T(msgChannelNotFound, map[string]any{"Channel": channelRef}), nil
+	}
+
+	marker, err := channels.GetKeepMarker(ca.client, channel.Id)
+	if err != nil {
+		return "", err
+	}
+	if marker == nil {
+		return loc.T(&i18n.Message{ID: "archiver.keep_revoke.not_kept", Other: "~{{.ChannelName}} is not marked as keep."}, map[string]any{"ChannelName": channel.Name}), nil
+	}
+
+	if err = channels.DeleteKeepMarker(ca.client, channel.Id); err != nil {
+		return "", err
+	}
+
+	return loc.T(&i18n.Message{ID: "archiver.keep_revoke.removed", Other: "Keep marker removed from ~{{.ChannelName}}."}, map[string]any{"ChannelName": channel.Name}), nil
+}
+
+// handleMoveBack moves a channel from the archive team back to the team it was moved from, typically
+// after it was unarchived.
+func (ca *ChannelArchiverCmd) handleMoveBack(args *model.CommandArgs, loc *i18n.Localizer) (string, error) {
+	if !ca.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
+	}
+
+	var channelRef string
+	if positional := parsePositionalArgs(args.Command); len(positional) > 0 {
+		channelRef = positional[0]
+	}
+
+	channel, err := ca.resolveChannel(args, channelRef)
+	if err != nil {
+		return loc.T(msgChannelNotFound, map[string]any{"Channel": channelRef}), nil
+	}
+
+	origin, err := channels.MoveChannelBack(ca.client, channel.Id)
+	if err != nil {
+		return "", err
+	}
+	if origin == nil {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.move_back.not_moved",
+			Other: "~{{.ChannelName}} was not moved to the archive team.",
+		}, map[string]any{"ChannelName": channel.Name}), nil
+	}
+
+	teamName := origin.TeamID
+	if team, err := ca.client.Team.Get(origin.TeamID); err == nil {
+		teamName = team.DisplayName
+	}
+	return loc.T(&i18n.Message{
+		ID:    "archiver.move_back.moved",
+		Other: "~{{.ChannelName}} was moved back to team {{.TeamName}}.",
+	}, map[string]any{"ChannelName": origin.Name, "TeamName": teamName}), nil
+}
+
+func (ca *ChannelArchiverCmd) formatKeepMarker(loc *i18n.Localizer, marker *channels.KeepMarker) string {
+	by := marker.UserID
+	if user, err := ca.client.User.Get(marker.UserID); err == nil {
+		by = "@" + user.Username
+	}
+
+	if marker.ExpireAt == 0 {
+		return loc.T(&i18n.Message{
+			ID:    "archiver.keep.marker_indefinitely",
+			Other: "kept indefinitely by {{.By}}, reason: {{.Reason}}",
+		}, map[string]any{"By": by, "Reason": marker.Reason})
+	}
+	return loc.T(&i18n.Message{
+		ID:    "archiver.keep.marker_until",
+		Other: "kept until {{.Until}} by {{.By}}, reason: {{.Reason}}",
+	}, map[string]any{
+		"Until":  formatKeepUntil(model.GetTimeForMillis(marker.ExpireAt)),
+		"By":     by,
+		"Reason": marker.Reason,
+	})
+}
+
+// parseKeepUntil parses the end of a keep. A date keeps the channel through the end of that day, in
+// UTC, while an RFC 3339 timestamp is used as is.
+func parseKeepUntil(value string) (time.Time, error) {
+	if date, err := time.Parse(keepDateLayout, value); err == nil {
+		return date.AddDate(0, 0, 1).Add(-time.Millisecond), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// formatKeepUntil formats the end of a keep as a date when it is the end of a day, in UTC, and as a
+// timestamp otherwise.
+func formatKeepUntil(until time.Time) string {
+	until = until.UTC()
+	if next := until.Add(time.Millisecond); next.Equal(next.Truncate(24 * time.Hour)) {
+		return until.Format(keepDateLayout)
+	}
+	return until.Format(time.RFC3339)
+}
+
+// resolveChannel finds a channel by `~name` (in the current team) or ID. An empty reference
+// resolves to the channel the command was executed in.
+func (ca *ChannelArchiverCmd) resolveChannel(args *model.CommandArgs, channelRef string) (*model.Channel, error) {
+	if channelRef == "" {
+		return ca.client.Channel.Get(args.ChannelId)
 	}
 
-	return fmt.Sprintf("%d channels archived in %v.\n%s",
-		len(results.ChannelsArchived), results.Duration, results.ExitReason), nil
+	name := strings.TrimPrefix(channelRef, "~")
+	channel, err := ca.client.Channel.GetByName(args.TeamId, name, true)
+	if err == nil {
+		return channel, nil
+	}
+
+	if model.IsValidId(name) {
+		return ca.client.Channel.Get(name)
+	}
+	return nil, err
 }
 
-func (ca *ChannelArchiverCmd) handleHelp() (string, error) {
+func (ca *ChannelArchiverCmd) handleHelp(loc *i18n.Localizer) (string, error) {
 	resp := ""
 	for _, cmd := range ca.commands {
 		desc := cmd.Trigger
-		if cmd.HelpText != "" {
+		if msg, ok := subCommandHelp[cmd.Trigger]; ok {
+			desc += " - " + loc.T(msg, nil)
+		} else if cmd.HelpText != "" {
 			desc += " - " + cmd.HelpText
 		}
 		resp += fmt.Sprintf("/%s %s\n", ArchiverTrigger, desc)
@@ -235,7 +724,7 @@ func (ca *ChannelArchiverCmd) handleHelp() (string, error) {
 	return resp, nil
 }
 
-func (ca *ChannelArchiverCmd) reportChannelList(args *model.CommandArgs, channelIDs []string) {
+func (ca *ChannelArchiverCmd) reportChannelList(args *model.CommandArgs, channelIDs []string, loc *i18n.Localizer) {
 	total := len(channelIDs)
 	const itemsPerPost = 500
 	var sb strings.Builder
@@ -247,7 +736,7 @@ func (ca *ChannelArchiverCmd) reportChannelList(args *model.CommandArgs, channel
 		itemsInPage++
 
 		if itemsInPage >= itemsPerPost {
-			msg := fmt.Sprintf("Stale channels %d to %d of %d\n%s", start+1, idx+1, total, sb.String())
+			msg := staleChannelsPage(loc, start+1, idx+1, total) + "\n" + sb.String()
 			_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
 			start = idx + 1
 			itemsInPage = 0
@@ -257,7 +746,48 @@ func (ca *ChannelArchiverCmd) reportChannelList(args *model.CommandArgs, channel
 	}
 
 	if itemsInPage > 0 {
-		msg := fmt.Sprintf("Stale channels %d to %d of %d\n%s", start+1, idx, total, sb.String())
+		msg := staleChannelsPage(loc, start+1, idx, total) + "\n" + sb.String()
 		_ = ca.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
 	}
 }
+
+// exitReason localizes the reason an archiver run ended.
+func exitReason(loc *i18n.Localizer, reason channels.Reason) string {
+	switch reason {
+	case channels.ReasonDone:
+		return loc.T(&i18n.Message{ID: "archiver.command.reason_done", Other: "completed normally"}, nil)
+	case channels.ReasonCancelled:
+		return loc.T(&i18n.Message{ID: "archiver.command.reason_canceled", Other: "canceled"}, nil)
+	case channels.ReasonError:
+		return loc.T(&i18n.Message{ID: "archiver.command.reason_error", Other: "error"}, nil)
+	default:
+		return string(reason)
+	}
+}
+
+func staleChannelsPage(loc *i18n.Localizer, from, to, total int) string {
+	return loc.T(&i18n.Message{
+		ID:    "archiver.command.list_page",
+		Other: "Stale channels {{.From}} to {{.To}} of {{.Total}}",
+	}, map[string]any{"From": from, "To": to, "Total": total})
+}
+
+func formatActivityTime(loc *i18n.Localizer, millis int64) string {
+	if millis == 0 {
+		return loc.T(&i18n.Message{ID: "archiver.inspect.never", Other: "never"}, nil)
+	}
+	t := model.GetTimeForMillis(millis)
+	days := int(time.Since(t).Hours() / 24)
+	return loc.Plural(&i18n.Message{
+		ID:    "archiver.inspect.time",
+		One:   "{{.Time}} ({{.Count}} day ago)",
+		Other: "{{.Time}} ({{.Count}} days ago)",
+	}, days, map[string]any{"Time": t.UTC().Format(jobs.FullLayout), "Count": days})
+}
+
+func yesNo(loc *i18n.Localizer, b bool) string {
+	if b {
+		return loc.T(&i18n.Message{ID: "archiver.inspect.yes", Other: "yes"}, nil)
+	}
+	return loc.T(&i18n.Message{ID: "archiver.inspect.no", Other: "no"}, nil)
+}
@@ -0,0 +1,34 @@
+package command
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeepUntil(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		value     string
+		expected  time.Time
+		formatted string
+	}{
+		{"date keeps the whole day", "2030-01-01", time.Date(2030, 1, 1, 23, 59, 59, int(999*time.Millisecond), time.UTC), "2030-01-01"},
+		{"timestamp", "2030-01-01T17:30:00Z", time.Date(2030, 1, 1, 17, 30, 0, 0, time.UTC), "2030-01-01T17:30:00Z"},
+		{"timestamp with offset", "2030-01-01T17:30:00+02:00", time.Date(2030, 1, 1, 15, 30, 0, 0, time.UTC), "2030-01-01T15:30:00Z"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			until, err := parseKeepUntil(tc.value)
+			require.NoError(t, err)
+			assert.True(t, tc.expected.Equal(until), until)
+			assert.Equal(t, tc.formatted, formatKeepUntil(until))
+		})
+	}
+
+	for _, value := range []string{"2030-13-01", "01/01/2030", "2030-01-01 17:30"} {
+		_, err := parseKeepUntil(value)
+		assert.Error(t, err, value)
+	}
+}
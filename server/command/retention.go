@@ -0,0 +1,624 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/experimental/command"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+const (
+	RetentionTrigger  = "retention"
+	paramNameDryRun   = "dry-run"
+	paramNameKeepTeam = "keep-team"
+	paramNameManager  = "manager"
+
+	paramNameKeepChannel = "keep-channel"
+	paramNameCleanupData = "cleanup-data"
+
+	// DeactivatedUsersAutocompleteRoute lists the deactivated users matching the user input.
+	DeactivatedUsersAutocompleteRoute = "/autocomplete/deactivated_users"
+
+	// removeProgressChannels is the number of channel removals between progress posts
+	removeProgressChannels = 100
+)
+
+var (
+	msgUserNotFound = &i18n.Message{
+		ID:    "retention.command.user_not_found",
+		Other: "Cannot find user `{{.User}}`.",
+	}
+	msgRemoveError = &i18n.Message{
+		ID:    "retention.remove.error",
+		Other: "Cannot remove @{{.Username}}: {{.Error}}",
+	}
+
+	// names of the integration types listed in removal results
+	integrationTypeNames = map[string]*i18n.Message{
+		store.IntegrationTypeIncomingWebhook: {ID: "retention.integration.incoming_webhook", Other: "Incoming webhook"},
+		store.IntegrationTypeOutgoingWebhook: {ID: "retention.integration.outgoing_webhook", Other: "Outgoing webhook"},
+		store.IntegrationTypeSlashCommand:    {ID: "retention.integration.slash_command", Other: "Slash command"},
+	}
+
+	// user action descriptions, shown in English by the autocomplete and localized by help
+	userActionHelp = map[string]*i18n.Message{
+		"plan":     {ID: "retention.command.help_user_plan", Other: "Show what removing a user from all teams and channels would do"},
+		"remove":   {ID: "retention.command.help_user_remove", Other: "Remove a user from all teams and channels"},
+		"restore":  {ID: "retention.command.help_user_restore", Other: "Add a reactivated user back to the teams and channels they were removed from"},
+		"offboard": {ID: "retention.command.help_user_offboard", Other: "Run the configured offboarding steps for a user, or resume a failed offboarding"},
+	}
+)
+
+// UserRemovalJobs runs user removals as background jobs, which are resumed after a restart.
+type UserRemovalJobs interface {
+	// StartUserRemoval starts a job removing a user from all teams and channels, returning its ID.
+	// progressFn is called as memberships are removed and resultFn once the user is removed, as
+	// long as the job runs on this server.
+	StartUserRemoval(user *model.User, requesterID string, keep users.KeepList, cleanupData bool, progressFn func(report *users.RemovalReport), resultFn func(report *users.RemovalReport, err error)) (string, error)
+}
+
+// RetentionCmd handles the `/retention` command, managing the data of deactivated users.
+type RetentionCmd struct {
+	client   *pluginapi.Client
+	papi     plugin.API
+	sqlStore *store.SQLStore
+	bot      *bot.Bot
+	config   *config.Configuration
+	i18n     *i18n.Bundle
+	audit    *audit.Logger
+
+	offboarder  *users.Offboarder
+	removalJobs UserRemovalJobs
+}
+
+// RegisterRetention is called by the plugin to register the `/retention` command.
+func RegisterRetention(client *pluginapi.Client, papi plugin.API, store *store.SQLStore, configuration *config.Configuration, bundle *i18n.Bundle, auditLogger *audit.Logger, offboarder *users.Offboarder, removalJobs UserRemovalJobs) (*RetentionCmd, error) {
+	cmdPlan := model.NewAutocompleteData("plan", "[@username]", userActionHelp["plan"].Other)
+	cmdPlan.AddTextArgument("User to plan the removal of: @username, email or user ID", "[@username]", "")
+
+	cmdRemove := model.NewAutocompleteData("remove", "[@username]", userActionHelp["remove"].Other)
+	cmdRemove.AddDynamicListArgument("Deactivated user to remove: @username, email or user ID", DeactivatedUsersAutocompleteRoute, true)
+	cmdRemove.AddNamedStaticListArgument(paramNameDryRun, "Show what would be removed, without removing anything", false, []model.AutocompleteListItem{{Item: "true"}})
+	cmdRemove.AddNamedTextArgument(paramNameKeepTeam, "Comma separated list of team names/IDs the user stays in. No spaces.", "[team]", "", false)
+	cmdRemove.AddNamedTextArgument(paramNameKeepChannel, "Comma separated list of channel names/IDs or name patterns the user stays in. No spaces.", "[channel]", "", false)
+	cmdRemove.AddNamedStaticListArgument(paramNameCleanupData, "Hide the user's direct messages and delete their preferences, sidebar and threads", false, []model.AutocompleteListItem{{Item: "true"}, {Item: "false"}})
+
+	cmdRestore := model.NewAutocompleteData("restore", "[@username]", userActionHelp["restore"].Other)
+	cmdRestore.AddTextArgument("Reactivated user to restore: @username, email or user ID", "[@username]", "")
+
+	cmdOffboard := model.NewAutocompleteData("offboard", "[@username]", userActionHelp["offboard"].Other)
+	cmdOffboard.AddTextArgument("User to offboard: @username, email or user ID", "[@username]", "")
+	cmdOffboard.AddNamedTextArgument(paramNameManager, "Manager notified of the offboarding: @username, email or user ID", "[@username]", "", false)
+
+	cmdUser := model.NewAutocompleteData("user", "[plan|remove|restore|offboard]", "Manage deactivated users")
+	cmdUser.SubCommands = []*model.AutocompleteData{cmdPlan, cmdRemove, cmdRestore, cmdOffboard}
+
+	cmd := model.NewAutocompleteData(RetentionTrigger, "[user]", "Manage the data of deactivated users.")
+	cmd.SubCommands = []*model.AutocompleteData{cmdUser}
+	cmd.RoleID = model.SystemAdminRoleId
+
+	iconData, err := command.GetIconData(&client.System, "assets/archiver.svg")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get icon data")
+	}
+
+	bot, err := bot.New(client)
+	if err != nil {
+		return nil, err
+	}
+
+	err = client.SlashCommand.Register(&model.Command{
+		Trigger:              RetentionTrigger,
+		DisplayName:          "Retention",
+		Description:          "Manage the data of deactivated users.",
+		AutoComplete:         true,
+		AutoCompleteDesc:     "user",
+		AutoCompleteHint:     "(subcommand)",
+		AutocompleteData:     cmd,
+		AutocompleteIconData: iconData,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RetentionCmd{
+		client:   client,
+		papi:     papi,
+		sqlStore: store,
+		bot:      bot,
+		config:   configuration,
+		i18n:     bundle,
+		audit:    auditLogger,
+
+		offboarder:  offboarder,
+		removalJobs: removalJobs,
+	}, nil
+}
+
+func (rc *RetentionCmd) OnConfigurationChange(newConfig *config.Configuration) {
+	rc.config = newConfig
+}
+
+func (rc *RetentionCmd) Execute(args *model.CommandArgs) (*model.CommandResponse, error) {
+	params := parseNamedArgs(args.Command)
+	subCommand := params[SubCommandKey]
+
+	var err error
+	var msg string
+
+	// command output is only seen by the user running the command
+	loc := rc.i18n.UserLocalizer(args.UserId)
+
+	switch subCommand {
+	case "user":
+		msg, err = rc.handleUser(args, loc)
+	default:
+		err = ErrInvalidSubCommand{subCommand: subCommand}
+	}
+
+	if msg != "" {
+		_ = rc.bot.SendEphemeralPost(args.ChannelId, args.UserId, msg)
+	}
+
+	return &model.CommandResponse{}, err
+}
+
+func (rc *RetentionCmd) handleUser(args *model.CommandArgs, loc *i18n.Localizer) (string, error) {
+	if !rc.client.User.HasPermissionTo(args.UserId, model.PermissionManageSystem) {
+		return loc.T(msgRequirePermission, map[string]any{"Permission": model.PermissionManageSystem.Id}), nil
+	}
+
+	positional := parsePositionalArgs(args.Command)
+	if len(positional) == 0 {
+		return rc.userHelp(loc), nil
+	}
+
+	switch positional[0] {
+	case "plan":
+		return rc.handleUserPlan(positional[1:], loc)
+	case "remove":
+		return rc.handleUserRemove(args, positional[1:], loc)
+	case "restore":
+		return rc.handleUserRestore(args, positional[1:], loc)
+	case "offboard":
+		return rc.handleUserOffboard(args, positional[1:], loc)
+	default:
+		return rc.userHelp(loc), nil
+	}
+}
+
+func (rc *RetentionCmd) handleUserPlan(positional []string, loc *i18n.Localizer) (string, error) {
+	if len(positional) == 0 {
+		return rc.userHelp(loc), nil
+	}
+
+	remover := users.NewRemover(rc.papi, rc.sqlStore, rc.audit)
+	user, err := remover.FindUser(positional[0])
+	if err != nil {
+		return loc.T(msgUserNotFound, map[string]any{"User": positional[0]}), nil
+	}
+
+	plan, err := remover.PlanRemoval(user, users.RemoveOpts{})
+	if err != nil {
+		return loc.T(&i18n.Message{
+			ID:    "retention.plan.error",
+			Other: "Error planning the removal: {{.Error}}",
+		}, map[string]any{"Error": err.Error()}), nil
+	}
+
+	return formatRemovalPlan(plan, loc), nil
+}
+
+// handleUserRemove starts a job removing a user from all teams and channels, except the teams to
+// keep, and posts the progress of the removal. In dry run mode, the removal plan is shown instead.
+func (rc *RetentionCmd) handleUserRemove(args *model.CommandArgs, positional []string, loc *i18n.Localizer) (string, error) {
+	if len(positional) == 0 {
+		return rc.userHelp(loc), nil
+	}
+	params := parseNamedArgs(args.Command)
+
+	remover := users.NewRemover(rc.papi, rc.sqlStore, rc.audit)
+	user, err := remover.FindUser(positional[0])
+	if err != nil {
+		return loc.T(msgUserNotFound, map[string]any{"User": positional[0]}), nil
+	}
+
+	opts := users.RemoveOpts{
+		RequesterID:  args.UserId,
+		Keep:         users.NewKeepList(rc.config),
+		Handover:     users.NewHandoverOpts(rc.config.ChannelAdminSuccessor, rc.config.FallbackChannelAdmin, rc.bot, rc.i18n),
+		Integrations: users.NewIntegrationOpts(rc.config.RevokeAccessTokens, rc.config.OwnedBotsAction, rc.config.IntegrationsAction, rc.config.IntegrationOwner),
+		CleanupData:  rc.config.CleanupRemovedUserData,
+	}
+	if cleanup, ok := params[paramNameCleanupData]; ok {
+		opts.CleanupData = cleanup != "false"
+	}
+	if keep, ok := params[paramNameKeepTeam]; ok && keep != "" {
+		for _, ref := range strings.Split(keep, ",") {
+			team, err := rc.getTeam(ref)
+			if err != nil {
+				return loc.T(&i18n.Message{
+					ID:    "retention.command.team_not_found",
+					Other: "Cannot find team `{{.Team}}`.",
+				}, map[string]any{"Team": ref}), nil
+			}
+			opts.Keep.Teams = append(opts.Keep.Teams, team.Id)
+		}
+	}
+	if keep, ok := params[paramNameKeepChannel]; ok && keep != "" {
+		for _, entry := range strings.Split(keep, ",") {
+			if strings.ContainsAny(entry, "*?[") {
+				opts.Keep.ChannelPatterns = append(opts.Keep.ChannelPatterns, entry)
+			} else {
+				opts.Keep.Channels = append(opts.Keep.Channels, entry)
+			}
+		}
+		if err := opts.Keep.Validate(); err != nil {
+			return err.Error(), nil
+		}
+	}
+
+	if dryRun, ok := params[paramNameDryRun]; ok && dryRun != "false" {
+		plan, err := remover.PlanRemoval(user, opts)
+		if err != nil {
+			return loc.T(&i18n.Message{
+				ID:    "retention.plan.error",
+				Other: "Error planning the removal: {{.Error}}",
+			}, map[string]any{"Error": err.Error()}), nil
+		}
+		return formatRemovalPlan(plan, loc), nil
+	}
+
+	teamCount := 0
+	progressFn := func(report *users.RemovalReport) {
+		// post when a team is done, and every removeProgressChannels channels
+		if len(report.TeamsRemoved) == teamCount && len(report.ChannelsRemoved)%removeProgressChannels != 0 {
+			return
+		}
+		teamCount = len(report.TeamsRemoved)
+		_ = rc.bot.SendEphemeralPost(args.ChannelId, args.UserId, loc.T(&i18n.Message{
+			ID:    "retention.remove.progress",
+			Other: "Removal progress for @{{.Username}} -- {{.TeamCount}} teams and {{.ChannelCount}} channels removed.",
+		}, removalSummary(user, report)))
+	}
+	resultFn := func(report *users.RemovalReport, err error) {
+		_ = rc.bot.SendEphemeralPost(args.ChannelId, args.UserId, formatRemovalResult(user, report, err, loc))
+	}
+
+	jobID, err := rc.removalJobs.StartUserRemoval(user, args.UserId, opts.Keep, opts.CleanupData, progressFn, resultFn)
+	if err != nil {
+		return loc.T(msgRemoveError, map[string]any{"Username": user.Username, "Error": err.Error()}), nil
+	}
+
+	return loc.T(&i18n.Message{
+		ID:    "retention.remove.job_started",
+		Other: "Removing @{{.Username}} from all teams and channels in job `{{.JobID}}`...",
+	}, map[string]any{"Username": user.Username, "JobID": jobID}), nil
+}
+
+// formatRemovalResult describes the outcome of removing a user. The report is nil if the user could
+// not be removed at all.
+func formatRemovalResult(user *model.User, report *users.RemovalReport, err error, loc *i18n.Localizer) string {
+	if report == nil {
+		return loc.T(msgRemoveError, map[string]any{"Username": user.Username, "Error": err.Error()})
+	}
+
+	var sb strings.Builder
+	if err == nil {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.remove.done",
+			Other: "@{{.Username}} was removed from {{.TeamCount}} teams and {{.ChannelCount}} channels.",
+		}, removalSummary(user, report)) + "\n")
+	} else {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.remove.failed",
+			Other: "@{{.Username}} was removed from {{.TeamCount}} teams and {{.ChannelCount}} channels, but {{.FailureCount}} memberships could not be removed:",
+		}, removalSummary(user, report)) + "\n")
+		for _, failure := range report.Failures {
+			sb.WriteString(fmt.Sprintf("- %s\n", failure.Error))
+		}
+	}
+
+	for _, handover := range report.Handovers {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.remove.handover",
+			Other: "- ~{{.ChannelName}} was handed over to @{{.SuccessorUsername}}",
+		}, handover) + "\n")
+	}
+	sb.WriteString(formatIntegrations(report, loc))
+	sb.WriteString(formatPreserved(report.Preserved, loc))
+	if report.DataCleanup != nil {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.remove.data_cleanup",
+			Other: "- {{.HiddenChannels}} direct and group messages were hidden for the other participants, and {{.Preferences}} preferences, {{.SidebarCategories}} sidebar categories, {{.SidebarChannels}} sidebar channels and {{.ThreadMemberships}} thread memberships were deleted",
+		}, report.DataCleanup) + "\n")
+	}
+	return sb.String()
+}
+
+// handleUserRestore adds a reactivated user back to the teams and channels of their membership snapshot.
+func (rc *RetentionCmd) handleUserRestore(args *model.CommandArgs, positional []string, loc *i18n.Localizer) (string, error) {
+	if len(positional) == 0 {
+		return rc.userHelp(loc), nil
+	}
+
+	remover := users.NewRemover(rc.papi, rc.sqlStore, rc.audit)
+	user, err := remover.FindUser(positional[0])
+	if err != nil {
+		return loc.T(msgUserNotFound, map[string]any{"User": positional[0]}), nil
+	}
+
+	report, err := remover.RestoreMemberships(user, args.UserId)
+	switch {
+	case errors.Is(err, users.ErrNoMembershipSnapshot):
+		return loc.T(&i18n.Message{
+			ID:    "retention.restore.no_snapshot",
+			Other: "There are no removed memberships to restore for @{{.Username}}.",
+		}, map[string]any{"Username": user.Username}), nil
+	case errors.Is(err, users.ErrUserDeactivated):
+		return loc.T(&i18n.Message{
+			ID:    "retention.restore.deactivated",
+			Other: "@{{.Username}} is deactivated. Reactivate the user before restoring their memberships.",
+		}, map[string]any{"Username": user.Username}), nil
+	case report == nil:
+		return loc.T(&i18n.Message{
+			ID:    "retention.restore.error",
+			Other: "Error restoring the memberships: {{.Error}}",
+		}, map[string]any{"Error": err.Error()}), nil
+	}
+
+	data := map[string]any{
+		"Username":     user.Username,
+		"TeamCount":    len(report.TeamsRestored),
+		"ChannelCount": len(report.ChannelsRestored),
+		"SkippedCount": len(report.Skipped),
+		"FailureCount": len(report.Failures),
+	}
+
+	var sb strings.Builder
+	sb.WriteString(loc.T(&i18n.Message{
+		ID:    "retention.restore.done",
+		Other: "@{{.Username}} was added back to {{.TeamCount}} teams and {{.ChannelCount}} channels. {{.SkippedCount}} archived or deleted teams and channels were skipped.",
+	}, data) + "\n")
+	if err != nil {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.restore.failed",
+			Other: "{{.FailureCount}} memberships could not be restored, run the command again to retry:",
+		}, data) + "\n")
+		for _, failure := range report.Failures {
+			sb.WriteString(fmt.Sprintf("- %s\n", failure.Error))
+		}
+	}
+	return sb.String(), nil
+}
+
+// handleUserOffboard resumes the failed or interrupted offboarding of a user, or starts a new one.
+// The summary is posted to the admin channel once the offboarding finishes.
+func (rc *RetentionCmd) handleUserOffboard(args *model.CommandArgs, positional []string, loc *i18n.Localizer) (string, error) {
+	if len(positional) == 0 {
+		return rc.userHelp(loc), nil
+	}
+	params := parseNamedArgs(args.Command)
+
+	remover := users.NewRemover(rc.papi, rc.sqlStore, rc.audit)
+	user, err := remover.FindUser(positional[0])
+	if err != nil {
+		return loc.T(msgUserNotFound, map[string]any{"User": positional[0]}), nil
+	}
+
+	managerID := ""
+	if ref, ok := params[paramNameManager]; ok && ref != "" {
+		manager, err := remover.FindUser(ref)
+		if err != nil {
+			return loc.T(msgUserNotFound, map[string]any{"User": ref}), nil
+		}
+		managerID = manager.Id
+	}
+
+	opts, err := users.NewOffboardOpts(rc.config, rc.bot, rc.i18n)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]any{"Username": user.Username}
+	offboarding, err := rc.offboarder.Get(user.Id)
+	if err != nil {
+		return "", err
+	}
+	if offboarding != nil && offboarding.Status == users.OffboardingStatusFailed {
+		if _, err := rc.offboarder.Resume(user.Id, opts); err != nil {
+			return offboardingError(err, data, loc), nil
+		}
+		return loc.T(&i18n.Message{
+			ID:    "retention.offboard.resumed",
+			Other: "Resuming the offboarding of @{{.Username}} from the failed step. A summary is posted to the admin channel once it finishes.",
+		}, data), nil
+	}
+	if offboarding != nil && offboarding.Status == users.OffboardingStatusRunning {
+		// continued if the server running it stopped, or reported as in progress
+		if _, err := rc.offboarder.Resume(user.Id, opts); err != nil {
+			return offboardingError(err, data, loc), nil
+		}
+		return loc.T(&i18n.Message{
+			ID:    "retention.offboard.continued",
+			Other: "Continuing the interrupted offboarding of @{{.Username}}. A summary is posted to the admin channel once it finishes.",
+		}, data), nil
+	}
+
+	if _, err := rc.offboarder.Start(user, args.UserId, managerID, opts); err != nil {
+		return offboardingError(err, data, loc), nil
+	}
+	return loc.T(&i18n.Message{
+		ID:    "retention.offboard.started",
+		Other: "Offboarding @{{.Username}}. A summary is posted to the admin channel once it finishes.",
+	}, data), nil
+}
+
+func offboardingError(err error, data map[string]any, loc *i18n.Localizer) string {
+	switch {
+	case errors.Is(err, users.ErrOffboardingInProgress):
+		return loc.T(&i18n.Message{
+			ID:    "retention.offboard.in_progress",
+			Other: "The offboarding of @{{.Username}} is already in progress.",
+		}, data)
+	case errors.Is(err, users.ErrOffboardingBot):
+		return loc.T(&i18n.Message{
+			ID:    "retention.offboard.bot",
+			Other: "@{{.Username}} is a bot, and cannot be offboarded.",
+		}, data)
+	default:
+		data["Error"] = err.Error()
+		return loc.T(&i18n.Message{
+			ID:    "retention.offboard.error",
+			Other: "Error offboarding @{{.Username}}: {{.Error}}",
+		}, data)
+	}
+}
+
+// formatPreserved lists the memberships a removal left in place, as they are kept.
+func formatPreserved(preserved []users.PreservedMembership, loc *i18n.Localizer) string {
+	var sb strings.Builder
+	for _, membership := range preserved {
+		msg := &i18n.Message{ID: "retention.remove.kept_team", Other: "- Team **{{.Name}}** was kept"}
+		switch membership.Reason {
+		case users.KeepReasonChannel:
+			msg = &i18n.Message{ID: "retention.remove.kept_channel", Other: "- ~{{.Name}} was kept (`{{.Entry}}`)"}
+		case users.KeepReasonKeptChannel:
+			msg = &i18n.Message{ID: "retention.remove.kept_channel_team", Other: "- Team **{{.Name}}** was kept, as it holds kept channels"}
+		}
+		data := membership
+		if data.Name == "" {
+			data.Name = data.TeamID
+		}
+		sb.WriteString(loc.T(msg, data) + "\n")
+	}
+	return sb.String()
+}
+
+// formatIntegrations lists the access tokens revoked, and the bots and integrations of a removed user.
+func formatIntegrations(report *users.RemovalReport, loc *i18n.Localizer) string {
+	var sb strings.Builder
+	if report.TokensRevoked > 0 {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "retention.remove.tokens_revoked",
+			Other: "- {{.Count}} personal access tokens were revoked",
+		}, map[string]any{"Count": report.TokensRevoked}) + "\n")
+	}
+
+	for _, bot := range report.Bots {
+		msg := &i18n.Message{ID: "retention.remove.bot_disabled", Other: "- Bot @{{.Username}} was disabled"}
+		if bot.Action == users.BotActionReassigned {
+			msg = &i18n.Message{ID: "retention.remove.bot_reassigned", Other: "- Bot @{{.Username}} was reassigned to the integration owner"}
+		}
+		sb.WriteString(loc.T(msg, bot) + "\n")
+	}
+
+	for _, integration := range report.Integrations {
+		msg := &i18n.Message{ID: "retention.remove.integration", Other: "- {{.Type}} `{{.Name}}` is still owned by the removed user"}
+		if integration.NewOwnerID != "" {
+			msg = &i18n.Message{ID: "retention.remove.integration_reassigned", Other: "- {{.Type}} `{{.Name}}` was reassigned to the integration owner"}
+		}
+		integrationType := integration.Type
+		if typeMsg, ok := integrationTypeNames[integration.Type]; ok {
+			integrationType = loc.T(typeMsg, nil)
+		}
+		sb.WriteString(loc.T(msg, map[string]any{
+			"Type": integrationType,
+			"Name": integration.Name,
+		}) + "\n")
+	}
+	return sb.String()
+}
+
+func removalSummary(user *model.User, report *users.RemovalReport) map[string]any {
+	return map[string]any{
+		"Username":     user.Username,
+		"TeamCount":    len(report.TeamsRemoved),
+		"ChannelCount": len(report.ChannelsRemoved),
+		"FailureCount": len(report.Failures),
+	}
+}
+
+// getTeam looks up a team by ID or name.
+func (rc *RetentionCmd) getTeam(ref string) (*model.Team, error) {
+	if model.IsValidId(ref) {
+		if team, err := rc.client.Team.Get(ref); err == nil {
+			return team, nil
+		}
+	}
+	return rc.client.Team.GetByName(ref)
+}
+
+// formatRemovalPlan summarizes a removal plan, listing the channels that need attention.
+func formatRemovalPlan(plan *users.RemovalPlan, loc *i18n.Localizer) string {
+	var sb strings.Builder
+	writeLine := func(msg *i18n.Message, data any) {
+		sb.WriteString(loc.T(msg, data) + "\n")
+	}
+
+	writeLine(&i18n.Message{
+		ID:    "retention.plan.title",
+		Other: "#### Removal plan for @{{.Username}}",
+	}, map[string]any{"Username": plan.Username})
+	writeLine(&i18n.Message{
+		ID:    "retention.plan.summary",
+		Other: "Nothing was removed. The user would be removed from {{.TeamCount}} teams and {{.ChannelCount}} channels.",
+	}, plan)
+
+	for _, team := range plan.Teams {
+		writeLine(&i18n.Message{
+			ID:    "retention.plan.team",
+			Other: "- **{{.TeamName}}**: {{.ChannelCount}} channels",
+		}, map[string]any{"TeamName": team.TeamName, "ChannelCount": len(team.Channels)})
+
+		for _, ch := range team.Channels {
+			if !ch.Flagged() {
+				continue
+			}
+			notes := make([]string, 0, 3)
+			switch ch.FailReason {
+			case users.FailReasonDefaultChannel:
+				notes = append(notes, loc.T(&i18n.Message{ID: "retention.plan.fail_default", Other: "cannot be left, removed with the team"}, nil))
+			case users.FailReasonArchived:
+				notes = append(notes, loc.T(&i18n.Message{ID: "retention.plan.fail_archived", Other: "archived, removal would fail"}, nil))
+			}
+			if ch.LastAdmin {
+				notes = append(notes, loc.T(&i18n.Message{ID: "retention.plan.last_admin", Other: "last channel admin"}, nil))
+			}
+			if ch.LastMember {
+				notes = append(notes, loc.T(&i18n.Message{ID: "retention.plan.last_member", Other: "last member"}, nil))
+			}
+			sb.WriteString(fmt.Sprintf("  - ~%s: %s\n", ch.ChannelName, strings.Join(notes, ", ")))
+		}
+	}
+
+	if len(plan.Preserved) > 0 {
+		writeLine(&i18n.Message{
+			ID:    "retention.plan.preserved",
+			Other: "The user would stay in these kept teams and channels:",
+		}, nil)
+		sb.WriteString(formatPreserved(plan.Preserved, loc))
+	}
+
+	return sb.String()
+}
+
+func (rc *RetentionCmd) userHelp(loc *i18n.Localizer) string {
+	resp := ""
+	for _, action := range []string{"plan", "remove", "restore", "offboard"} {
+		resp += fmt.Sprintf("/%s user %s - %s\n", RetentionTrigger, action, loc.T(userActionHelp[action], nil))
+	}
+	return resp
+}
@@ -10,11 +10,11 @@ const (
 
 // parseNamedArgs parses a command string into a map of arguments. It is assumed the
 // command string is of the form `<subcommand> --arg1 value1 ...` Supports empty values.
-// Arg names are limited to [0-9a-zA-Z_].
+// Arg names are limited to [0-9a-zA-Z_]. Double quoted values may contain spaces.
 func parseNamedArgs(cmd string) map[string]string {
 	m := make(map[string]string)
 
-	split := strings.Fields(cmd)
+	split := splitFields(cmd)
 
 	// check for optional action
 	if len(split) >= 2 && !strings.HasPrefix(split[1], "--") {
@@ -37,6 +37,47 @@ func parseNamedArgs(cmd string) map[string]string {
 	return m
 }
 
+// parsePositionalArgs returns the arguments following the subcommand, up to the first named
+// argument. It is assumed the command string is of the form `<command> <subcommand> arg1 arg2 --arg3 value3`.
+func parsePositionalArgs(cmd string) []string {
+	args := make([]string, 0)
+
+	split := splitFields(cmd)
+	if len(split) < 2 || strings.HasPrefix(split[1], "--") {
+		return args
+	}
+
+	for _, s := range split[2:] {
+		if strings.HasPrefix(s, "--") {
+			break
+		}
+		args = append(args, trimSpaceAndQuotes(s))
+	}
+	return args
+}
+
+// splitFields splits a command string around white space, keeping double quoted values
+// that contain white space together. Unterminated quotes are treated as regular characters.
+func splitFields(cmd string) []string {
+	fields := strings.Fields(cmd)
+	split := make([]string, 0, len(fields))
+
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if strings.HasPrefix(field, "\"") && (len(field) == 1 || !strings.HasSuffix(field, "\"")) {
+			for j := i + 1; j < len(fields); j++ {
+				if strings.HasSuffix(fields[j], "\"") {
+					field = strings.Join(fields[i:j+1], " ")
+					i = j
+					break
+				}
+			}
+		}
+		split = append(split, field)
+	}
+	return split
+}
+
 func trimSpaceAndQuotes(s string) string {
 	trimmed := strings.TrimSpace(s)
 	trimmed = strings.TrimPrefix(trimmed, "\"")
@@ -27,6 +27,9 @@ func TestParseNamedArgs(t *testing.T) {
 		{"quote embedded", "channel-archiver add --arg1 O'Brien", map[string]string{SubCommandKey: "add", "arg1": "O'Brien"}},
 		{"quote prefix, suffix, and embedded", "channel-archiver add --arg1 \"O'Brien\"", map[string]string{SubCommandKey: "add", "arg1": "O'Brien"}},
 		{"empty quotes", "channel-archiver add --arg1 \"\"", map[string]string{SubCommandKey: "add", "arg1": ""}},
+		{"quoted spaces", "channel-archiver keep --reason \"still needed for audits\" --until 2030-01-01", map[string]string{SubCommandKey: "keep", "reason": "still needed for audits", "until": "2030-01-01"}},
+		{"quoted spaces and embedded quote", "channel-archiver keep --reason \"O'Brien's project\"", map[string]string{SubCommandKey: "keep", "reason": "O'Brien's project"}},
+		{"unterminated quote", "channel-archiver keep --reason \"still needed", map[string]string{SubCommandKey: "keep", "reason": "still"}},
 	}
 
 	for _, tt := range data {
@@ -35,3 +38,26 @@ func TestParseNamedArgs(t *testing.T) {
 		assert.Equal(t, tt.m, m, tt.name)
 	}
 }
+
+func TestParsePositionalArgs(t *testing.T) {
+	data := []struct {
+		name string
+		s    string
+		args []string
+	}{
+		{"empty", "", []string{}},
+		{"command only", "channel-archiver", []string{}},
+		{"action only", "channel-archiver inspect", []string{}},
+		{"one arg", "channel-archiver inspect ~town-square", []string{"~town-square"}},
+		{"two args", "channel-archiver inspect ~one ~two", []string{"~one", "~two"}},
+		{"named args after", "channel-archiver inspect ~one --days 30", []string{"~one"}},
+		{"named args only", "channel-archiver inspect --days 30 ~one", []string{}},
+		{"no action", "channel-archiver --days 30", []string{}},
+		{"quoted", "channel-archiver inspect \"~one\"", []string{"~one"}},
+	}
+
+	for _, tt := range data {
+		args := parsePositionalArgs(tt.s)
+		assert.Equal(t, tt.args, args, tt.name)
+	}
+}
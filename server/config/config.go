@@ -3,7 +3,10 @@ package config
 import (
 	"errors"
 	"fmt"
+	"path"
+	"slices"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -15,8 +18,57 @@ const (
 	DefaultAgeInDays = 365
 	MinAgeInDays     = 30
 	MaxAgeInDays     = 10000
+
+	DefaultPurgeAgeInDays   = 365
+	MinPurgeAgeInDays       = 7
+	DefaultPurgeMaxChannels = 100
+	MaxPurgeMaxChannels     = 10000
+
+	MaxWarningDays = 90
+
+	MaxDeactivationCleanupDelayHours = 720
+
+	DefaultSweepBatchSize = 100
+	DefaultSweepFrequency = "weekly"
+
+	// how a successor is picked for the private channels a removed user is the only admin of
+	SuccessorLongestStanding     = "longest_standing"
+	SuccessorMostActive          = "most_active"
+	SuccessorNone                = "none"
+	DefaultChannelAdminSuccessor = SuccessorLongestStanding
+
+	// what happens to the bots a removed user owns
+	OwnedBotsKeep     = "keep"
+	OwnedBotsDisable  = "disable"
+	OwnedBotsReassign = "reassign"
+
+	// what happens to the webhooks and slash commands a removed user created
+	IntegrationsKeep     = "keep"
+	IntegrationsReport   = "report"
+	IntegrationsReassign = "reassign"
+
+	// offboarding steps, run in the configured order
+	OffboardingStepDeactivate   = "deactivate"
+	OffboardingStepHandover     = "handover_channels"
+	OffboardingStepRemove       = "remove_memberships"
+	OffboardingStepRevokeTokens = "revoke_tokens"
+	OffboardingStepIntegrations = "integrations"
+	OffboardingStepExport       = "export_data"
+	OffboardingStepNotify       = "notify_manager"
+	DefaultOffboardingSteps     = "deactivate, handover_channels, remove_memberships, revoke_tokens, export_data, notify_manager"
 )
 
+// KnownOffboardingSteps lists the offboarding steps that can be configured.
+var KnownOffboardingSteps = []string{
+	OffboardingStepDeactivate,
+	OffboardingStepHandover,
+	OffboardingStepRemove,
+	OffboardingStepRevokeTokens,
+	OffboardingStepIntegrations,
+	OffboardingStepExport,
+	OffboardingStepNotify,
+}
+
 var (
 	ErrInvalidConfig = errors.New("invalid config")
 )
@@ -33,21 +85,65 @@ var (
 // If you add non-reference types to your Configuration struct, be sure to rewrite Clone as a deep
 // copy appropriate for your types.
 type Configuration struct {
-	EnableChannelArchiver           bool
-	AgeInDays                       int
-	Frequency                       string
-	DayOfWeek                       string
-	TimeOfDay                       string
-	ExcludeChannels                 string
-	BatchSize                       int
-	AdminChannel                    string
-	EnableChannelArchiverDryRunMode bool
+	EnableChannelArchiver                bool
+	AgeInDays                            int
+	OrphanedAgeInDays                    int
+	AbandonedAgeInDays                   int
+	Frequency                            string
+	DayOfWeek                            string
+	TimeOfDay                            string
+	ExcludeChannels                      string
+	BatchSize                            int
+	AdminChannel                         string
+	ArchiveTeam                          string
+	WarningDays                          int
+	ArchiveNoticeTemplate                string
+	ArchiveWarningTemplate               string
+	AdminReportTemplate                  string
+	RestoreInstructions                  string
+	ContactLink                          string
+	ChannelPostLocale                    string
+	EnableChannelArchiverDryRunMode      bool
+	MaxKeepDays                          int
+	EnableChannelPurge                   bool
+	EnableChannelPurgeDryRunMode         bool
+	PurgeAgeInDays                       int
+	PurgeMaxChannels                     int
+	EnableDeactivationCleanup            bool
+	DeactivationCleanupDelayHours        int
+	DeactivationCleanupExcludeTeams      string
+	EnableDeactivatedUserSweep           bool
+	EnableDeactivatedUserSweepDryRunMode bool
+	SweepFrequency                       string
+	SweepBatchSize                       int
+	ChannelAdminSuccessor                string
+	FallbackChannelAdmin                 string
+	RevokeAccessTokens                   bool
+	OwnedBotsAction                      string
+	IntegrationsAction                   string
+	IntegrationOwner                     string
+	RemovalKeepTeams                     string
+	RemovalKeepChannels                  string
+	RemovalKeepChannelPatterns           string
+	CleanupRemovedUserData               bool
+	OffboardingSteps                     string
+	WebhookURLs                          string
+	WebhookSecret                        string
 }
 
 func NewConfiguration() *Configuration {
 	return &Configuration{
-		AgeInDays: DefaultAgeInDays,
-		BatchSize: DefaultArchiveBatchSize,
+		AgeInDays:        DefaultAgeInDays,
+		BatchSize:        DefaultArchiveBatchSize,
+		PurgeAgeInDays:   DefaultPurgeAgeInDays,
+		PurgeMaxChannels: DefaultPurgeMaxChannels,
+		SweepFrequency:   DefaultSweepFrequency,
+		SweepBatchSize:   DefaultSweepBatchSize,
+
+		ChannelAdminSuccessor: DefaultChannelAdminSuccessor,
+		OwnedBotsAction:       OwnedBotsKeep,
+		IntegrationsAction:    IntegrationsKeep,
+		OffboardingSteps:      DefaultOffboardingSteps,
 	}
 }
 
@@ -58,6 +154,82 @@ func (c *Configuration) Clone() *Configuration {
 	return &clone
 }
 
+// GetExcludeChannels returns the configured list of excluded channel names/IDs.
+func (c *Configuration) GetExcludeChannels() []string {
+	return SplitList(c.ExcludeChannels)
+}
+
+// GetDeactivationCleanupExcludeTeams returns the configured list of team names/IDs deactivated users
+// are not removed from.
+func (c *Configuration) GetDeactivationCleanupExcludeTeams() []string {
+	return SplitList(c.DeactivationCleanupExcludeTeams)
+}
+
+// GetRemovalKeepTeams returns the configured list of team names/IDs users are never removed from.
+func (c *Configuration) GetRemovalKeepTeams() []string {
+	return SplitList(c.RemovalKeepTeams)
+}
+
+// GetRemovalKeepChannels returns the configured list of channel names/IDs users are never removed from.
+func (c *Configuration) GetRemovalKeepChannels() []string {
+	return SplitList(c.RemovalKeepChannels)
+}
+
+// GetRemovalKeepChannelPatterns returns the configured list of channel name patterns users are never
+// removed from, or an error if a pattern is malformed.
+func (c *Configuration) GetRemovalKeepChannelPatterns() ([]string, error) {
+	patterns := SplitList(c.RemovalKeepChannelPatterns)
+	if err := ValidateChannelPatterns(patterns); err != nil {
+		return nil, err
+	}
+	return patterns, nil
+}
+
+// ValidateChannelPatterns returns an error if a channel name pattern is malformed. Patterns use the
+// syntax of path.Match, such as `compliance-*`.
+func ValidateChannelPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid channel name pattern `%s`: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// GetOffboardingSteps returns the configured offboarding steps in order, or an error if a step is
+// unknown or listed twice.
+func (c *Configuration) GetOffboardingSteps() ([]string, error) {
+	steps := SplitList(c.OffboardingSteps)
+	for i, step := range steps {
+		if !slices.Contains(KnownOffboardingSteps, step) {
+			return nil, fmt.Errorf("unknown offboarding step `%s`, expected one of: %s", step, strings.Join(KnownOffboardingSteps, ", "))
+		}
+		if slices.Contains(steps[:i], step) {
+			return nil, fmt.Errorf("offboarding step `%s` is listed twice", step)
+		}
+	}
+	return steps, nil
+}
+
+// GetWebhookURLs returns the configured list of webhook URLs.
+func (c *Configuration) GetWebhookURLs() []string {
+	return SplitList(c.WebhookURLs)
+}
+
+// SplitList splits a comma and/or space separated list, dropping empty entries.
+func SplitList(s string) []string {
+	nospaces := strings.ReplaceAll(s, " ", ",")
+	split := strings.Split(nospaces, ",")
+	list := make([]string, 0, len(split))
+	for _, item := range split {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func ParseInt(s string, minVal int, maxVal int) (int, error) {
 	i64, err := strconv.ParseInt(s, 10, 32)
 	if err != nil {
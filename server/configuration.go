@@ -1,11 +1,17 @@
 package main
 
 import (
+	"encoding/json"
 	"reflect"
 
 	"github.com/pkg/errors"
 
+	"github.com/mattermost/mattermost/server/public/model"
+
+	root "github.com/mattermost/mattermost-plugin-retention-tooling"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/webhook"
 )
 
 // getConfiguration retrieves the active configuration under lock, making it safe to use
@@ -58,6 +64,22 @@ func (p *Plugin) OnConfigurationChange() error {
 		return errors.Wrap(err, "failed to load plugin configuration")
 	}
 
+	if _, err := channels.NewMessageTemplates(configuration); err != nil {
+		return err
+	}
+
+	if _, err := configuration.GetRemovalKeepChannelPatterns(); err != nil {
+		return err
+	}
+
+	if _, err := configuration.GetOffboardingSteps(); err != nil {
+		return err
+	}
+
+	if err := webhook.ValidateConfig(configuration.GetWebhookURLs(), configuration.WebhookSecret); err != nil {
+		return err
+	}
+
 	if p.jobManager != nil {
 		if err := p.jobManager.OnConfigurationChange(configuration); err != nil {
 			return err
@@ -68,6 +90,10 @@ func (p *Plugin) OnConfigurationChange() error {
 		p.channelArchiverCmd.OnConfigurationChange(configuration)
 	}
 
+	if p.retentionCmd != nil {
+		p.retentionCmd.OnConfigurationChange(configuration)
+	}
+
 	if configuration.AdminChannel != "" {
 		// Ensure the admin channel exists
 		if _, err := p.API.GetChannel(configuration.AdminChannel); err != nil {
@@ -75,7 +101,54 @@ func (p *Plugin) OnConfigurationChange() error {
 		}
 	}
 
+	if configuration.ArchiveTeam != "" && p.Client != nil {
+		// Ensure the archive team exists
+		if _, err := channels.ResolveTeam(p.Client, configuration.ArchiveTeam); err != nil {
+			return errors.Wrap(err, "failed to get archive team")
+		}
+	}
+
+	if p.webhooks != nil {
+		if err := p.webhooks.Configure(configuration.GetWebhookURLs(), configuration.WebhookSecret); err != nil {
+			return err
+		}
+	}
+
 	p.setConfiguration(configuration)
 
 	return nil
 }
+
+// ConfigurationWillBeSaved rejects plugin settings with invalid message templates, so mistakes are
+// reported in the System Console instead of when channels are archived.
+func (p *Plugin) ConfigurationWillBeSaved(newCfg *model.Config) (*model.Config, error) {
+	settings, ok := newCfg.PluginSettings.Plugins[root.Manifest.Id]
+	if !ok {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal plugin settings")
+	}
+
+	// only decode the message settings, other settings may not be typed yet when coming from the System Console
+	var messages struct {
+		ArchiveNoticeTemplate  string
+		ArchiveWarningTemplate string
+		AdminReportTemplate    string
+	}
+	if err = json.Unmarshal(data, &messages); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal message templates")
+	}
+
+	configuration := config.NewConfiguration()
+	configuration.ArchiveNoticeTemplate = messages.ArchiveNoticeTemplate
+	configuration.ArchiveWarningTemplate = messages.ArchiveWarningTemplate
+	configuration.AdminReportTemplate = messages.AdminReportTemplate
+
+	if _, err = channels.NewMessageTemplates(configuration); err != nil {
+		return nil, err
+	}
+	return nil, nil
+}
@@ -0,0 +1,120 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"golang.org/x/text/language"
+
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+// Translations are embedded in the plugin. English messages are defined in the code; active.en.json
+// is generated from the code with `go test ./i18n -update` and serves as the source for translators.
+//
+//go:embed translations/*.json
+var translations embed.FS
+
+const translationsDir = "translations"
+
+// Message is a string that can be localized.
+type Message = i18n.Message
+
+var english = i18n.NewLocalizer(i18n.NewBundle(language.English), language.English.String())
+
+// Bundle holds the translations of all bot messages.
+type Bundle struct {
+	bundle *i18n.Bundle
+	client *pluginapi.Client
+}
+
+// NewBundle loads the embedded translations.
+func NewBundle(client *pluginapi.Client) (*Bundle, error) {
+	bundle := i18n.NewBundle(language.English)
+	bundle.RegisterUnmarshalFunc("json", json.Unmarshal)
+
+	files, err := translations.ReadDir(translationsDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read translations: %w", err)
+	}
+
+	for _, file := range files {
+		if file.Name() == "active.en.json" || !strings.HasPrefix(file.Name(), "active.") {
+			continue
+		}
+		if _, err = bundle.LoadMessageFileFS(translations, path.Join(translationsDir, file.Name())); err != nil {
+			return nil, fmt.Errorf("cannot load translation file %s: %w", file.Name(), err)
+		}
+	}
+
+	return &Bundle{
+		bundle: bundle,
+		client: client,
+	}, nil
+}
+
+// UserLocalizer returns a localizer for the locale of a user, for messages only that user sees.
+func (b *Bundle) UserLocalizer(userID string) *Localizer {
+	if b == nil {
+		return nil
+	}
+
+	user, err := b.client.User.Get(userID)
+	if err != nil {
+		b.client.Log.Warn("Cannot get user locale, using the server locale", "user_id", userID, "err", err)
+		return b.LocaleLocalizer("")
+	}
+	return b.LocaleLocalizer(user.Locale)
+}
+
+// LocaleLocalizer returns a localizer for a locale, or for the default server locale if empty. It is
+// meant for messages seen by many users, such as channel posts.
+func (b *Bundle) LocaleLocalizer(locale string) *Localizer {
+	if b == nil {
+		return nil
+	}
+
+	if locale == "" {
+		if cfg := b.client.Configuration.GetConfig(); cfg != nil && cfg.LocalizationSettings.DefaultServerLocale != nil {
+			locale = *cfg.LocalizationSettings.DefaultServerLocale
+		}
+	}
+	return &Localizer{localizer: i18n.NewLocalizer(b.bundle, locale)}
+}
+
+// Localizer localizes messages into a single locale. A nil *Localizer localizes into English.
+type Localizer struct {
+	localizer *i18n.Localizer
+}
+
+// T localizes a message, executing it as a template with data.
+func (l *Localizer) T(msg *Message, data any) string {
+	return l.localize(&i18n.LocalizeConfig{DefaultMessage: msg, TemplateData: data})
+}
+
+// Plural localizes a message with a plural form chosen by count. Count is available to the
+// template as {{.Count}} unless data is set.
+func (l *Localizer) Plural(msg *Message, count int, data any) string {
+	if data == nil {
+		data = map[string]any{"Count": count}
+	}
+	return l.localize(&i18n.LocalizeConfig{DefaultMessage: msg, TemplateData: data, PluralCount: count})
+}
+
+func (l *Localizer) localize(lc *i18n.LocalizeConfig) string {
+	localizer := english
+	if l != nil {
+		localizer = l.localizer
+	}
+
+	// a missing translation is reported as an error along with the English message
+	s, err := localizer.Localize(lc)
+	if s == "" && err != nil {
+		return lc.DefaultMessage.Other
+	}
+	return s
+}
@@ -0,0 +1,238 @@
+package i18n
+
+import (
+	"encoding/json"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "regenerate active.en.json from the messages in the code")
+
+const englishFile = "translations/active.en.json"
+
+// TestEnglishMessages ensures active.en.json, the source for translators, matches the messages in
+// the code. Run `go test ./i18n -update` to regenerate it.
+func TestEnglishMessages(t *testing.T) {
+	messages := collectMessages(t, "..")
+	require.NotEmpty(t, messages)
+
+	if *update {
+		data, err := json.MarshalIndent(messages, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(englishFile, append(data, '\n'), 0600))
+	}
+
+	var english map[string]any
+	data, err := os.ReadFile(englishFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &english))
+
+	expected := make(map[string]any, len(messages))
+	for id, msg := range messages {
+		expected[id] = msg
+	}
+	// round trip through JSON so both sides have the same types
+	data, err = json.Marshal(expected)
+	require.NoError(t, err)
+	expected = nil
+	require.NoError(t, json.Unmarshal(data, &expected))
+
+	assert.Equal(t, expected, english, "active.en.json is out of date, run `go test ./i18n -update`")
+}
+
+// TestTranslations ensures translations only contain known messages and valid templates.
+func TestTranslations(t *testing.T) {
+	var english map[string]any
+	data, err := os.ReadFile(englishFile)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &english))
+
+	files, err := translations.ReadDir(translationsDir)
+	require.NoError(t, err)
+
+	for _, file := range files {
+		t.Run(file.Name(), func(t *testing.T) {
+			data, err := translations.ReadFile(translationsDir + "/" + file.Name())
+			require.NoError(t, err)
+
+			var translated map[string]any
+			require.NoError(t, json.Unmarshal(data, &translated))
+
+			for id, msg := range translated {
+				assert.Contains(t, english, id, "unknown message")
+				for _, text := range messageTexts(msg) {
+					_, err := template.New(id).Parse(text)
+					assert.NoError(t, err, id)
+				}
+			}
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	mockAPI := &plugintest.API{}
+	defer mockAPI.AssertExpectations(t)
+	mockAPI.On("GetConfig").Return(&model.Config{
+		LocalizationSettings: model.LocalizationSettings{DefaultServerLocale: model.NewPointer("es")},
+	})
+	mockAPI.On("GetUser", "german_user").Return(&model.User{Id: "german_user", Locale: "de"}, nil)
+	mockAPI.On("GetUser", "unknown_user").Return(nil, &model.AppError{Message: "not found"})
+	mockAPI.On("LogWarn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+
+	bundle, err := NewBundle(pluginapi.NewClient(mockAPI, nil))
+	require.NoError(t, err)
+
+	keep := &Message{ID: "archiver.warning.keep_button", Other: "Keep"}
+	untranslated := &Message{ID: "test.untranslated", Other: "Untranslated"}
+
+	assert.Equal(t, "Behalten", bundle.UserLocalizer("german_user").T(keep, nil))
+	assert.Equal(t, "Conservar", bundle.UserLocalizer("unknown_user").T(keep, nil), "server locale")
+	assert.Equal(t, "Conservar", bundle.LocaleLocalizer("").T(keep, nil), "server locale")
+	assert.Equal(t, "Keep", bundle.LocaleLocalizer("en").T(keep, nil))
+	assert.Equal(t, "Keep", bundle.LocaleLocalizer("fr").T(keep, nil), "unsupported locale")
+	assert.Equal(t, "Untranslated", bundle.LocaleLocalizer("de").T(untranslated, nil))
+
+	recipients := &Message{ID: "archiver.report.warning_recipients", One: "{{.Count}} recipient", Other: "{{.Count}} recipients"}
+	assert.Equal(t, "1 destinatario", bundle.LocaleLocalizer("es").Plural(recipients, 1, nil))
+	assert.Equal(t, "2 destinatarios", bundle.LocaleLocalizer("es").Plural(recipients, 2, nil))
+}
+
+func TestLocalizer(t *testing.T) {
+	msg := &Message{ID: "test.greeting", Other: "Hello {{.Name}}"}
+	plural := &Message{ID: "test.count", One: "{{.Count}} channel", Other: "{{.Count}} channels"}
+
+	var nilLocalizer *Localizer
+	assert.Equal(t, "Hello Ana", nilLocalizer.T(msg, map[string]any{"Name": "Ana"}))
+	assert.Equal(t, "1 channel", nilLocalizer.Plural(plural, 1, nil))
+	assert.Equal(t, "3 channels", nilLocalizer.Plural(plural, 3, nil))
+
+	var nilBundle *Bundle
+	assert.Nil(t, nilBundle.UserLocalizer("user_id"))
+	assert.Nil(t, nilBundle.LocaleLocalizer("de"))
+}
+
+type englishMessage struct {
+	One   string `json:"one,omitempty"`
+	Other string `json:"other"`
+}
+
+func (m englishMessage) MarshalJSON() ([]byte, error) {
+	if m.One == "" {
+		return json.Marshal(m.Other)
+	}
+	type plural englishMessage
+	return json.Marshal(plural(m))
+}
+
+// collectMessages parses all non-test Go files below root, returning every message literal, that
+// is every composite literal with string ID and Other fields.
+func collectMessages(t *testing.T, root string) map[string]englishMessage {
+	messages := make(map[string]englishMessage)
+	fset := token.NewFileSet()
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+
+		ast.Inspect(file, func(n ast.Node) bool {
+			lit, ok := n.(*ast.CompositeLit)
+			if !ok {
+				return true
+			}
+
+			fields := make(map[string]string)
+			for _, elt := range lit.Elts {
+				kv, ok := elt.(*ast.KeyValueExpr)
+				if !ok {
+					continue
+				}
+				key, ok := kv.Key.(*ast.Ident)
+				if !ok {
+					continue
+				}
+				if value, ok := stringValue(kv.Value); ok {
+					fields[key.Name] = value
+				}
+			}
+
+			id, other := fields["ID"], fields["Other"]
+			if id == "" || other == "" {
+				return true
+			}
+
+			msg := englishMessage{One: fields["One"], Other: other}
+			if existing, ok := messages[id]; ok && existing != msg {
+				t.Errorf("message %s is defined twice with different texts at %s", id, fset.Position(lit.Pos()))
+			}
+			messages[id] = msg
+			return true
+		})
+		return nil
+	})
+	require.NoError(t, err)
+
+	return messages
+}
+
+// stringValue evaluates string literals and concatenations of string literals.
+func stringValue(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return "", false
+		}
+		s, err := strconv.Unquote(e.Value)
+		return s, err == nil
+	case *ast.BinaryExpr:
+		if e.Op != token.ADD {
+			return "", false
+		}
+		x, ok := stringValue(e.X)
+		if !ok {
+			return "", false
+		}
+		y, ok := stringValue(e.Y)
+		return x + y, ok
+	default:
+		return "", false
+	}
+}
+
+func messageTexts(msg any) []string {
+	switch m := msg.(type) {
+	case string:
+		return []string{m}
+	case map[string]any:
+		texts := make([]string, 0, len(m))
+		for _, v := range m {
+			if s, ok := v.(string); ok {
+				texts = append(texts, s)
+			}
+		}
+		return texts
+	default:
+		return nil
+	}
+}
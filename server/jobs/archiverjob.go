@@ -8,9 +8,11 @@ import (
 
 	"github.com/wiggin77/merror"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
 	"github.com/mattermost/mattermost/server/public/plugin"
 	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
@@ -28,9 +30,13 @@ type ChannelArchiverJob struct {
 	client   *pluginapi.Client
 	bot      *bot.Bot
 	sqlstore *store.SQLStore
+	audit    *audit.Logger
+
+	warningActionURL string
+	i18n             *i18n.Bundle
 }
 
-func NewChannelArchiverJob(id string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore) (*ChannelArchiverJob, error) {
+func NewChannelArchiverJob(id string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore, auditLogger *audit.Logger, warningActionURL string, bundle *i18n.Bundle) (*ChannelArchiverJob, error) {
 	bot, err := bot.New(client)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create bot for job: %w", err)
@@ -43,6 +49,10 @@ func NewChannelArchiverJob(id string, api plugin.API, client *pluginapi.Client,
 		client:   client,
 		bot:      bot,
 		sqlstore: sqlstore,
+		audit:    auditLogger,
+
+		warningActionURL: warningActionURL,
+		i18n:             bundle,
 	}, nil
 }
 
@@ -63,7 +73,7 @@ func (j *ChannelArchiverJob) OnConfigurationChange(cfg *config.Configuration) er
 		j.client.Log.Error("Error stopping Channel Archiver job for config change", "err", err)
 	}
 
-	if settings.EnableChannelArchiver {
+	if settings.EnableChannelArchiver || settings.EnableChannelPurge {
 		return j.start(settings)
 	}
 
@@ -173,6 +183,17 @@ func (j *ChannelArchiverJob) run() {
 		return
 	}
 
+	if settings.EnableChannelArchiver {
+		j.runArchiver(ctx, settings)
+	}
+
+	// second stage: permanently delete channels that have been archived for long enough
+	if settings.EnableChannelPurge && ctx.Err() == nil {
+		j.runPurge(ctx, settings)
+	}
+}
+
+func (j *ChannelArchiverJob) runArchiver(ctx context.Context, settings *ChannelArchiverJobSettings) {
 	opts := channels.ArchiverOpts{
 		StaleChannelOpts: store.StaleChannelOpts{
 			AgeInDays:                 settings.AgeInDays,
@@ -181,9 +202,18 @@ func (j *ChannelArchiverJob) run() {
 			ExcludeChannels:           settings.ExcludeChannels,
 			AdminChannel:              settings.AdminChannel,
 		},
-		BatchSize: settings.BatchSize,
-		Bot:       j.bot,
-		ListOnly:  settings.EnableChannelArchiverDryRunMode,
+		BatchSize:   settings.BatchSize,
+		Bot:         j.bot,
+		ListOnly:    settings.EnableChannelArchiverDryRunMode,
+		ArchiveTeam: settings.ArchiveTeam,
+		WarningDays: settings.WarningDays,
+		Policies:    settings.Policies,
+		Messages:    settings.Messages,
+		I18n:        j.i18n,
+		Locale:      settings.ChannelPostLocale,
+		Audit:       j.audit,
+
+		WarningActionURL: j.warningActionURL,
 	}
 
 	results, err := channels.ArchiveStaleChannels(ctx, j.sqlstore, j.client, opts)
@@ -195,6 +225,28 @@ func (j *ChannelArchiverJob) run() {
 	j.client.Log.Info("Channel Archiver job", "channels_archived", len(results.ChannelsArchived), "status", results.ExitReason, "duration", results.Duration.String())
 }
 
+func (j *ChannelArchiverJob) runPurge(ctx context.Context, settings *ChannelArchiverJobSettings) {
+	opts := channels.PurgeOpts{
+		AgeInDays:    settings.PurgeAgeInDays,
+		MaxChannels:  settings.PurgeMaxChannels,
+		DryRun:       settings.EnableChannelPurgeDryRunMode,
+		AdminChannel: settings.AdminChannel,
+		Bot:          j.bot,
+		Audit:        j.audit,
+		I18n:         j.i18n,
+		Locale:       settings.ChannelPostLocale,
+	}
+
+	results, err := channels.PurgeArchivedChannels(ctx, j.sqlstore, j.client, opts)
+	if err != nil {
+		j.client.Log.Error("Error running Channel Purge job", "err", err)
+		return
+	}
+
+	j.client.Log.Info("Channel Purge job", "channels_purged", len(results.ChannelIDs), "dry_run", opts.DryRun, "cap_reached", results.CapReached,
+		"posts", results.Counts.Posts, "status", results.ExitReason, "duration", results.Duration.String())
+}
+
 type runInstance struct {
 	canceller  func()        // called to stop a currently executing run
 	exitSignal chan struct{} // closed when the currently executing run has exited
@@ -2,9 +2,9 @@ package jobs
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
 )
 
@@ -17,12 +17,21 @@ type ChannelArchiverJobSettings struct {
 	EnableChannelArchiver           bool
 	EnableChannelArchiverDryRunMode bool
 	AgeInDays                       int
+	Policies                        channels.PolicyOpts
 	Frequency                       Frequency
 	DayOfWeek                       int
 	TimeOfDay                       time.Time
 	ExcludeChannels                 []string
 	BatchSize                       int
 	AdminChannel                    string
+	ArchiveTeam                     string
+	WarningDays                     int
+	Messages                        *channels.MessageTemplates
+	ChannelPostLocale               string
+	EnableChannelPurge              bool
+	EnableChannelPurgeDryRunMode    bool
+	PurgeAgeInDays                  int
+	PurgeMaxChannels                int
 }
 
 func (c *ChannelArchiverJobSettings) Clone() *ChannelArchiverJobSettings {
@@ -33,30 +42,62 @@ func (c *ChannelArchiverJobSettings) Clone() *ChannelArchiverJobSettings {
 		EnableChannelArchiver:           c.EnableChannelArchiver,
 		EnableChannelArchiverDryRunMode: c.EnableChannelArchiverDryRunMode,
 		AgeInDays:                       c.AgeInDays,
+		Policies:                        c.Policies,
 		Frequency:                       c.Frequency,
+		DayOfWeek:                       c.DayOfWeek,
 		TimeOfDay:                       c.TimeOfDay,
 		ExcludeChannels:                 exclude,
 		BatchSize:                       c.BatchSize,
 		AdminChannel:                    c.AdminChannel,
+		ArchiveTeam:                     c.ArchiveTeam,
+		WarningDays:                     c.WarningDays,
+		Messages:                        c.Messages,
+		ChannelPostLocale:               c.ChannelPostLocale,
+		EnableChannelPurge:              c.EnableChannelPurge,
+		EnableChannelPurgeDryRunMode:    c.EnableChannelPurgeDryRunMode,
+		PurgeAgeInDays:                  c.PurgeAgeInDays,
+		PurgeMaxChannels:                c.PurgeMaxChannels,
 	}
 }
 
 func (c *ChannelArchiverJobSettings) String() string {
-	return fmt.Sprintf("enabled=%T; ageDays=%d; freq=%s; tod=%s; batchSize=%d; excludeLen=%d",
-		c.EnableChannelArchiver, c.AgeInDays, c.Frequency, c.TimeOfDay.Format(TimeOfDayLayout), c.BatchSize, len(c.ExcludeChannels))
+	return fmt.Sprintf("enabled=%T; ageDays=%d; freq=%s; tod=%s; batchSize=%d; excludeLen=%d; purge=%T; purgeAgeDays=%d",
+		c.EnableChannelArchiver, c.AgeInDays, c.Frequency, c.TimeOfDay.Format(TimeOfDayLayout), c.BatchSize, len(c.ExcludeChannels),
+		c.EnableChannelPurge, c.PurgeAgeInDays)
 }
 
 func parseChannelArchiverJobSettings(cfg *config.Configuration) (*ChannelArchiverJobSettings, error) {
-	if !cfg.EnableChannelArchiver {
+	if !cfg.EnableChannelArchiver && !cfg.EnableChannelPurge {
 		return &ChannelArchiverJobSettings{
 			EnableChannelArchiver: false,
 		}, nil
 	}
 
-	if cfg.AgeInDays < config.MinAgeInDays {
+	if cfg.EnableChannelArchiver && cfg.AgeInDays < config.MinAgeInDays {
 		return nil, fmt.Errorf("`Days of inactivity` cannot be less than %d", config.MinAgeInDays)
 	}
 
+	if cfg.OrphanedAgeInDays < 0 || cfg.OrphanedAgeInDays > config.MaxAgeInDays {
+		return nil, fmt.Errorf("`Days of inactivity for orphaned channels` cannot be less than 0 or more than %d", config.MaxAgeInDays)
+	}
+
+	if cfg.AbandonedAgeInDays < 0 || cfg.AbandonedAgeInDays > config.MaxAgeInDays {
+		return nil, fmt.Errorf("`Days before unused channels are archived` cannot be less than 0 or more than %d", config.MaxAgeInDays)
+	}
+
+	if cfg.WarningDays < 0 || cfg.WarningDays > config.MaxWarningDays {
+		return nil, fmt.Errorf("`Days of warning before archiving` cannot be less than 0 or more than %d", config.MaxWarningDays)
+	}
+
+	if cfg.EnableChannelPurge {
+		if cfg.PurgeAgeInDays < config.MinPurgeAgeInDays {
+			return nil, fmt.Errorf("`Days archived before permanent deletion` cannot be less than %d", config.MinPurgeAgeInDays)
+		}
+		if cfg.PurgeMaxChannels < 1 || cfg.PurgeMaxChannels > config.MaxPurgeMaxChannels {
+			return nil, fmt.Errorf("`Maximum channels deleted per run` cannot be less than 1 or more than %d", config.MaxPurgeMaxChannels)
+		}
+	}
+
 	freq, err := FreqFromString(cfg.Frequency)
 	if err != nil {
 		return nil, err
@@ -72,14 +113,11 @@ func parseChannelArchiverJobSettings(cfg *config.Configuration) (*ChannelArchive
 		return nil, fmt.Errorf("cannot parse `Time of day`: %w", err)
 	}
 
-	nospaces := strings.ReplaceAll(cfg.ExcludeChannels, " ", ",")
-	split := strings.Split(nospaces, ",")
-	excludes := make([]string, 0)
-	for _, s := range split {
-		ch := strings.TrimSpace(s)
-		if ch != "" {
-			excludes = append(excludes, ch)
-		}
+	excludes := cfg.GetExcludeChannels()
+
+	messages, err := channels.NewMessageTemplates(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	if cfg.BatchSize < config.MinBatchSize || cfg.BatchSize > config.MaxBatchSize {
@@ -90,11 +128,20 @@ func parseChannelArchiverJobSettings(cfg *config.Configuration) (*ChannelArchive
 		EnableChannelArchiver:           cfg.EnableChannelArchiver,
 		EnableChannelArchiverDryRunMode: cfg.EnableChannelArchiverDryRunMode,
 		AgeInDays:                       cfg.AgeInDays,
+		Policies:                        channels.NewPolicyOpts(cfg),
 		Frequency:                       freq,
 		DayOfWeek:                       dow,
 		TimeOfDay:                       tod,
 		ExcludeChannels:                 excludes,
 		BatchSize:                       cfg.BatchSize,
 		AdminChannel:                    cfg.AdminChannel,
+		ArchiveTeam:                     cfg.ArchiveTeam,
+		WarningDays:                     cfg.WarningDays,
+		Messages:                        messages,
+		ChannelPostLocale:               cfg.ChannelPostLocale,
+		EnableChannelPurge:              cfg.EnableChannelPurge,
+		EnableChannelPurgeDryRunMode:    cfg.EnableChannelPurgeDryRunMode,
+		PurgeAgeInDays:                  cfg.PurgeAgeInDays,
+		PurgeMaxChannels:                cfg.PurgeMaxChannels,
 	}, nil
 }
@@ -0,0 +1,113 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestParseChannelArchiverJobSettings(t *testing.T) {
+	newConfig := func() *config.Configuration {
+		cfg := config.NewConfiguration()
+		cfg.Frequency = "weekly"
+		cfg.DayOfWeek = "3"
+		cfg.TimeOfDay = "1:00am -0700"
+		cfg.ExcludeChannels = "general, random"
+		return cfg
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		settings, err := parseChannelArchiverJobSettings(newConfig())
+		require.NoError(t, err)
+		assert.False(t, settings.EnableChannelArchiver)
+		assert.False(t, settings.EnableChannelPurge)
+	})
+
+	t.Run("archiver enabled", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelArchiver = true
+
+		settings, err := parseChannelArchiverJobSettings(cfg)
+		require.NoError(t, err)
+		assert.True(t, settings.EnableChannelArchiver)
+		assert.Equal(t, []string{"general", "random"}, settings.ExcludeChannels)
+
+		clone := settings.Clone()
+		assert.Equal(t, settings, clone)
+	})
+
+	t.Run("archiver age too low", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelArchiver = true
+		cfg.AgeInDays = 5
+
+		_, err := parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("warning days out of range", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelArchiver = true
+		cfg.WarningDays = config.MaxWarningDays + 1
+
+		_, err := parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("orphaned and abandoned channels policies", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelArchiver = true
+		cfg.OrphanedAgeInDays = 7
+		cfg.AbandonedAgeInDays = 14
+
+		settings, err := parseChannelArchiverJobSettings(cfg)
+		require.NoError(t, err)
+		expected := channels.PolicyOpts{OrphanedAgeInDays: 7, AbandonedAgeInDays: 14}
+		assert.Equal(t, expected, settings.Policies)
+		assert.Equal(t, expected, settings.Clone().Policies)
+
+		cfg.OrphanedAgeInDays = -1
+		_, err = parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+
+		cfg.OrphanedAgeInDays = 7
+		cfg.AbandonedAgeInDays = config.MaxAgeInDays + 1
+		_, err = parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("purge only", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelPurge = true
+		cfg.AgeInDays = 0 // not validated when the archiver is disabled
+
+		settings, err := parseChannelArchiverJobSettings(cfg)
+		require.NoError(t, err)
+		assert.False(t, settings.EnableChannelArchiver)
+		assert.True(t, settings.EnableChannelPurge)
+		assert.Equal(t, config.DefaultPurgeAgeInDays, settings.PurgeAgeInDays)
+		assert.Equal(t, config.DefaultPurgeMaxChannels, settings.PurgeMaxChannels)
+	})
+
+	t.Run("purge age too low", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelPurge = true
+		cfg.PurgeAgeInDays = 1
+
+		_, err := parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+	})
+
+	t.Run("purge cap out of range", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.EnableChannelPurge = true
+		cfg.PurgeMaxChannels = 0
+
+		_, err := parseChannelArchiverJobSettings(cfg)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,211 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wiggin77/merror"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+// DeactivationCleanupInterval is how often pending removals of deactivated users are checked.
+const DeactivationCleanupInterval = time.Minute * 5
+
+// DeactivationCleanupJob removes deactivated users from all teams and channels once the
+// configured delay after their deactivation has passed.
+type DeactivationCleanupJob struct {
+	mux      sync.Mutex
+	settings *DeactivationCleanupJobSettings
+	job      *cluster.Job
+	runner   *runInstance
+
+	id       string
+	papi     plugin.API
+	client   *pluginapi.Client
+	bot      *bot.Bot
+	sqlstore *store.SQLStore
+	audit    *audit.Logger
+	i18n     *i18n.Bundle
+}
+
+func NewDeactivationCleanupJob(id string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore, auditLogger *audit.Logger, bundle *i18n.Bundle) (*DeactivationCleanupJob, error) {
+	bot, err := bot.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bot for job: %w", err)
+	}
+
+	return &DeactivationCleanupJob{
+		settings: &DeactivationCleanupJobSettings{},
+		id:       id,
+		papi:     api,
+		client:   client,
+		bot:      bot,
+		sqlstore: sqlstore,
+		audit:    auditLogger,
+		i18n:     bundle,
+	}, nil
+}
+
+func (j *DeactivationCleanupJob) GetID() string {
+	return j.id
+}
+
+// OnConfigurationChange is called by the job manager whenenver the plugin settings have changed.
+// Stop current job (if any) and start a new job (if enabled) with new settings.
+func (j *DeactivationCleanupJob) OnConfigurationChange(cfg *config.Configuration) error {
+	settings, err := parseDeactivationCleanupJobSettings(cfg)
+	if err != nil {
+		return err
+	}
+
+	// stop existing job (if any)
+	if err := j.Stop(time.Second * 10); err != nil {
+		j.client.Log.Error("Error stopping Deactivation Cleanup job for config change", "err", err)
+	}
+
+	if settings.EnableDeactivationCleanup {
+		return j.start(settings)
+	}
+
+	return nil
+}
+
+// start schedules a new job with specified settings.
+func (j *DeactivationCleanupJob) start(settings *DeactivationCleanupJobSettings) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.settings = settings
+
+	job, err := cluster.Schedule(j.papi, j.id, cluster.MakeWaitForInterval(DeactivationCleanupInterval), j.run)
+	if err != nil {
+		return fmt.Errorf("cannot start Deactivation Cleanup: %w", err)
+	}
+	j.job = job
+
+	j.client.Log.Debug("Deactivation Cleanup started", "interval", DeactivationCleanupInterval.String())
+
+	return nil
+}
+
+// Stop stops the current job (if any). If the timeout is exceeded an error
+// is returned.
+func (j *DeactivationCleanupJob) Stop(timeout time.Duration) error {
+	var job *cluster.Job
+	var runner *runInstance
+
+	j.mux.Lock()
+	job = j.job
+	runner = j.runner
+	j.job = nil
+	j.runner = nil
+	j.mux.Unlock()
+
+	merr := merror.New()
+
+	if job != nil {
+		if err := job.Close(); err != nil {
+			merr.Append(fmt.Errorf("error closing job: %w", err))
+		}
+	}
+
+	if runner != nil {
+		if err := runner.stop(timeout); err != nil {
+			merr.Append(fmt.Errorf("error stopping job runner: %w", err))
+		}
+	}
+
+	j.client.Log.Debug("Deactivation Cleanup stopped", "err", merr.ErrorOrNil())
+
+	return merr.ErrorOrNil()
+}
+
+func (j *DeactivationCleanupJob) run() {
+	exitSignal := make(chan struct{})
+	ctx, canceller := context.WithCancel(context.Background())
+
+	runner := &runInstance{
+		canceller:  canceller,
+		exitSignal: exitSignal,
+	}
+
+	var oldRunner *runInstance
+	var settings *DeactivationCleanupJobSettings
+	j.mux.Lock()
+	oldRunner = j.runner
+	j.runner = runner
+	settings = j.settings.Clone()
+	j.mux.Unlock()
+
+	defer func() {
+		close(exitSignal)
+		j.mux.Lock()
+		j.runner = nil
+		j.mux.Unlock()
+	}()
+
+	if oldRunner != nil {
+		j.client.Log.Error("Multiple Deactivation Cleanup jobs scheduled concurrently; there can be only one")
+		return
+	}
+
+	// resolve the excluded teams on each run, so renamed or deleted teams are noticed before removing anyone
+	excludeTeamIDs, err := resolveTeamIDs(j.client, settings.ExcludeTeams)
+	if err != nil {
+		j.client.Log.Error("Cannot resolve team excluded from Deactivation Cleanup; skipping run", "err", err)
+		return
+	}
+
+	opts := users.CleanupOpts{
+		ExcludeTeamIDs: excludeTeamIDs,
+		AdminChannel:   settings.AdminChannel,
+		Handover:       users.NewHandoverOpts(settings.ChannelAdminSuccessor, settings.FallbackChannelAdmin, j.bot, j.i18n),
+		Integrations:   users.NewIntegrationOpts(settings.RevokeAccessTokens, settings.OwnedBotsAction, settings.IntegrationsAction, settings.IntegrationOwner),
+		CleanupData:    settings.CleanupRemovedUserData,
+		Bot:            j.bot,
+		Audit:          j.audit,
+		I18n:           j.i18n,
+		Locale:         settings.ChannelPostLocale,
+		Keep: users.KeepList{
+			Teams:           settings.KeepTeams,
+			Channels:        settings.KeepChannels,
+			ChannelPatterns: settings.KeepChannelPatterns,
+		},
+	}
+
+	results, err := users.CleanupDeactivatedUsers(ctx, j.sqlstore, j.papi, j.client, opts)
+	if err != nil {
+		j.client.Log.Error("Error running Deactivation Cleanup job", "err", err)
+		return
+	}
+
+	if len(results.Removed) > 0 || len(results.Failed) > 0 {
+		j.client.Log.Info("Deactivation Cleanup job", "users_removed", len(results.Removed), "users_failed", len(results.Failed),
+			"users_reactivated", results.Reactivated, "duration", results.Duration.String())
+	}
+}
+
+// resolveTeamIDs returns the IDs of teams given by name or ID.
+func resolveTeamIDs(client *pluginapi.Client, teamRefs []string) ([]string, error) {
+	teamIDs := make([]string, 0, len(teamRefs))
+	for _, teamRef := range teamRefs {
+		team, err := channels.ResolveTeam(client, teamRef)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find team %s: %w", teamRef, err)
+		}
+		teamIDs = append(teamIDs, team.Id)
+	}
+	return teamIDs, nil
+}
@@ -0,0 +1,78 @@
+package jobs
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+type DeactivationCleanupJobSettings struct {
+	EnableDeactivationCleanup bool
+	ExcludeTeams              []string
+	AdminChannel              string
+	ChannelPostLocale         string
+	ChannelAdminSuccessor     string
+	FallbackChannelAdmin      string
+	RevokeAccessTokens        bool
+	OwnedBotsAction           string
+	IntegrationsAction        string
+	IntegrationOwner          string
+	KeepTeams                 []string
+	KeepChannels              []string
+	KeepChannelPatterns       []string
+	CleanupRemovedUserData    bool
+}
+
+func (c *DeactivationCleanupJobSettings) Clone() *DeactivationCleanupJobSettings {
+	return &DeactivationCleanupJobSettings{
+		EnableDeactivationCleanup: c.EnableDeactivationCleanup,
+		ExcludeTeams:              slices.Clone(c.ExcludeTeams),
+		AdminChannel:              c.AdminChannel,
+		ChannelPostLocale:         c.ChannelPostLocale,
+		ChannelAdminSuccessor:     c.ChannelAdminSuccessor,
+		FallbackChannelAdmin:      c.FallbackChannelAdmin,
+		RevokeAccessTokens:        c.RevokeAccessTokens,
+		OwnedBotsAction:           c.OwnedBotsAction,
+		IntegrationsAction:        c.IntegrationsAction,
+		IntegrationOwner:          c.IntegrationOwner,
+		KeepTeams:                 slices.Clone(c.KeepTeams),
+		KeepChannels:              slices.Clone(c.KeepChannels),
+		KeepChannelPatterns:       slices.Clone(c.KeepChannelPatterns),
+		CleanupRemovedUserData:    c.CleanupRemovedUserData,
+	}
+}
+
+func parseDeactivationCleanupJobSettings(cfg *config.Configuration) (*DeactivationCleanupJobSettings, error) {
+	if !cfg.EnableDeactivationCleanup {
+		return &DeactivationCleanupJobSettings{
+			EnableDeactivationCleanup: false,
+		}, nil
+	}
+
+	if cfg.DeactivationCleanupDelayHours < 0 || cfg.DeactivationCleanupDelayHours > config.MaxDeactivationCleanupDelayHours {
+		return nil, fmt.Errorf("`Hours before removing deactivated users` cannot be less than 0 or more than %d", config.MaxDeactivationCleanupDelayHours)
+	}
+
+	keepPatterns, err := cfg.GetRemovalKeepChannelPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeactivationCleanupJobSettings{
+		EnableDeactivationCleanup: cfg.EnableDeactivationCleanup,
+		ExcludeTeams:              cfg.GetDeactivationCleanupExcludeTeams(),
+		AdminChannel:              cfg.AdminChannel,
+		ChannelPostLocale:         cfg.ChannelPostLocale,
+		ChannelAdminSuccessor:     cfg.ChannelAdminSuccessor,
+		FallbackChannelAdmin:      cfg.FallbackChannelAdmin,
+		RevokeAccessTokens:        cfg.RevokeAccessTokens,
+		OwnedBotsAction:           cfg.OwnedBotsAction,
+		IntegrationsAction:        cfg.IntegrationsAction,
+		IntegrationOwner:          cfg.IntegrationOwner,
+		KeepTeams:                 cfg.GetRemovalKeepTeams(),
+		KeepChannels:              cfg.GetRemovalKeepChannels(),
+		KeepChannelPatterns:       keepPatterns,
+		CleanupRemovedUserData:    cfg.CleanupRemovedUserData,
+	}, nil
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestParseDeactivationCleanupJobSettings(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		settings, err := parseDeactivationCleanupJobSettings(config.NewConfiguration())
+		require.NoError(t, err)
+		assert.False(t, settings.EnableDeactivationCleanup)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.EnableDeactivationCleanup = true
+		cfg.DeactivationCleanupDelayHours = 48
+		cfg.DeactivationCleanupExcludeTeams = "alumni, legal"
+		cfg.AdminChannel = "admin-channel-id"
+		cfg.FallbackChannelAdmin = "it-admin"
+		cfg.OwnedBotsAction = config.OwnedBotsDisable
+		cfg.CleanupRemovedUserData = true
+
+		settings, err := parseDeactivationCleanupJobSettings(cfg)
+		require.NoError(t, err)
+		assert.True(t, settings.EnableDeactivationCleanup)
+		assert.Equal(t, []string{"alumni", "legal"}, settings.ExcludeTeams)
+		assert.Equal(t, "admin-channel-id", settings.AdminChannel)
+		assert.Equal(t, config.SuccessorLongestStanding, settings.ChannelAdminSuccessor)
+		assert.Equal(t, "it-admin", settings.FallbackChannelAdmin)
+		assert.Equal(t, config.OwnedBotsDisable, settings.OwnedBotsAction)
+		assert.True(t, settings.CleanupRemovedUserData)
+		assert.Equal(t, settings, settings.Clone())
+	})
+
+	t.Run("delay out of range", func(t *testing.T) {
+		cfg := config.NewConfiguration()
+		cfg.EnableDeactivationCleanup = true
+		cfg.DeactivationCleanupDelayHours = config.MaxDeactivationCleanupDelayHours + 1
+
+		_, err := parseDeactivationCleanupJobSettings(cfg)
+		require.Error(t, err)
+
+		cfg.DeactivationCleanupDelayHours = -1
+		_, err = parseDeactivationCleanupJobSettings(cfg)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wiggin77/merror"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+)
+
+// SweepPause is the pause after each user removed by the sweep, to spread the load on the server.
+const SweepPause = time.Millisecond * 100
+
+// DeactivatedUserSweepJob periodically removes deactivated users who still have team or channel
+// memberships, such as users deactivated before the plugin was installed.
+type DeactivatedUserSweepJob struct {
+	mux      sync.Mutex
+	settings *DeactivatedUserSweepJobSettings
+	job      *cluster.Job
+	runner   *runInstance
+
+	id       string
+	papi     plugin.API
+	client   *pluginapi.Client
+	bot      *bot.Bot
+	sqlstore *store.SQLStore
+	audit    *audit.Logger
+	i18n     *i18n.Bundle
+}
+
+func NewDeactivatedUserSweepJob(id string, api plugin.API, client *pluginapi.Client, sqlstore *store.SQLStore, auditLogger *audit.Logger, bundle *i18n.Bundle) (*DeactivatedUserSweepJob, error) {
+	bot, err := bot.New(client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create bot for job: %w", err)
+	}
+
+	return &DeactivatedUserSweepJob{
+		settings: &DeactivatedUserSweepJobSettings{},
+		id:       id,
+		papi:     api,
+		client:   client,
+		bot:      bot,
+		sqlstore: sqlstore,
+		audit:    auditLogger,
+		i18n:     bundle,
+	}, nil
+}
+
+func (j *DeactivatedUserSweepJob) GetID() string {
+	return j.id
+}
+
+// OnConfigurationChange is called by the job manager whenenver the plugin settings have changed.
+// Stop current job (if any) and start a new job (if enabled) with new settings.
+func (j *DeactivatedUserSweepJob) OnConfigurationChange(cfg *config.Configuration) error {
+	settings, err := parseDeactivatedUserSweepJobSettings(cfg)
+	if err != nil {
+		return err
+	}
+
+	// stop existing job (if any)
+	if err := j.Stop(time.Second * 10); err != nil {
+		j.client.Log.Error("Error stopping Deactivated User Sweep job for config change", "err", err)
+	}
+
+	if settings.EnableDeactivatedUserSweep {
+		return j.start(settings)
+	}
+
+	return nil
+}
+
+// start schedules a new job with specified settings.
+func (j *DeactivatedUserSweepJob) start(settings *DeactivatedUserSweepJobSettings) error {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.settings = settings
+
+	job, err := cluster.Schedule(j.papi, j.id, j.nextWaitInterval, j.run)
+	if err != nil {
+		return fmt.Errorf("cannot start Deactivated User Sweep: %w", err)
+	}
+	j.job = job
+
+	j.client.Log.Debug("Deactivated User Sweep started", "freq", settings.Frequency)
+
+	return nil
+}
+
+// Stop stops the current job (if any). If the timeout is exceeded an error
+// is returned.
+func (j *DeactivatedUserSweepJob) Stop(timeout time.Duration) error {
+	var job *cluster.Job
+	var runner *runInstance
+
+	j.mux.Lock()
+	job = j.job
+	runner = j.runner
+	j.job = nil
+	j.runner = nil
+	j.mux.Unlock()
+
+	merr := merror.New()
+
+	if job != nil {
+		if err := job.Close(); err != nil {
+			merr.Append(fmt.Errorf("error closing job: %w", err))
+		}
+	}
+
+	if runner != nil {
+		if err := runner.stop(timeout); err != nil {
+			merr.Append(fmt.Errorf("error stopping job runner: %w", err))
+		}
+	}
+
+	j.client.Log.Debug("Deactivated User Sweep stopped", "err", merr.ErrorOrNil())
+
+	return merr.ErrorOrNil()
+}
+
+func (j *DeactivatedUserSweepJob) getSettings() *DeactivatedUserSweepJobSettings {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+	return j.settings.Clone()
+}
+
+// nextWaitInterval is called by the cluster job scheduler to determine how long to wait until the
+// next job run.
+func (j *DeactivatedUserSweepJob) nextWaitInterval(now time.Time, metaData cluster.JobMetadata) time.Duration {
+	settings := j.getSettings()
+
+	lastFinished := metaData.LastFinished
+	if lastFinished.IsZero() {
+		lastFinished = now
+	}
+
+	next := settings.Frequency.CalcNext(lastFinished, settings.DayOfWeek, settings.TimeOfDay)
+	delta := next.Sub(now)
+
+	j.client.Log.Debug("Deactivated User Sweep next run scheduled", "last", lastFinished.Format(FullLayout), "next", next.Format(FullLayout), "wait", delta.String())
+
+	return delta
+}
+
+func (j *DeactivatedUserSweepJob) run() {
+	exitSignal := make(chan struct{})
+	ctx, canceller := context.WithCancel(context.Background())
+
+	runner := &runInstance{
+		canceller:  canceller,
+		exitSignal: exitSignal,
+	}
+
+	var oldRunner *runInstance
+	var settings *DeactivatedUserSweepJobSettings
+	j.mux.Lock()
+	oldRunner = j.runner
+	j.runner = runner
+	settings = j.settings.Clone()
+	j.mux.Unlock()
+
+	defer func() {
+		close(exitSignal)
+		j.mux.Lock()
+		j.runner = nil
+		j.mux.Unlock()
+	}()
+
+	if oldRunner != nil {
+		j.client.Log.Error("Multiple Deactivated User Sweep jobs scheduled concurrently; there can be only one")
+		return
+	}
+
+	// resolve the excluded teams on each run, so renamed or deleted teams are noticed before removing anyone
+	excludeTeamIDs, err := resolveTeamIDs(j.client, settings.ExcludeTeams)
+	if err != nil {
+		j.client.Log.Error("Cannot resolve team excluded from Deactivated User Sweep; skipping run", "err", err)
+		return
+	}
+
+	opts := users.SweepOpts{
+		DeactivatedUserOpts: store.DeactivatedUserOpts{
+			DeactivatedBefore: model.GetMillisForTime(time.Now().Add(-settings.Delay)),
+			ExcludeTeamIDs:    excludeTeamIDs,
+		},
+		BatchSize:    settings.BatchSize,
+		Pause:        SweepPause,
+		DryRun:       settings.EnableDeactivatedUserSweepDryRunMode,
+		AdminChannel: settings.AdminChannel,
+		Handover:     users.NewHandoverOpts(settings.ChannelAdminSuccessor, settings.FallbackChannelAdmin, j.bot, j.i18n),
+		Integrations: users.NewIntegrationOpts(settings.RevokeAccessTokens, settings.OwnedBotsAction, settings.IntegrationsAction, settings.IntegrationOwner),
+		CleanupData:  settings.CleanupRemovedUserData,
+		Bot:          j.bot,
+		Audit:        j.audit,
+		I18n:         j.i18n,
+		Locale:       settings.ChannelPostLocale,
+		Keep: users.KeepList{
+			Teams:           settings.KeepTeams,
+			Channels:        settings.KeepChannels,
+			ChannelPatterns: settings.KeepChannelPatterns,
+		},
+	}
+
+	results, err := users.SweepDeactivatedUsers(ctx, j.sqlstore, j.papi, j.client, opts)
+	if err != nil {
+		j.client.Log.Error("Error running Deactivated User Sweep job", "err", err)
+		return
+	}
+
+	j.client.Log.Info("Deactivated User Sweep job", "users_removed", len(results.Removed), "users_failed", len(results.Failed),
+		"dry_run", opts.DryRun, "cancelled", results.Cancelled, "duration", results.Duration.String())
+}
@@ -0,0 +1,117 @@
+package jobs
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+type DeactivatedUserSweepJobSettings struct {
+	EnableDeactivatedUserSweep           bool
+	EnableDeactivatedUserSweepDryRunMode bool
+	Frequency                            Frequency
+	DayOfWeek                            int
+	TimeOfDay                            time.Time
+	BatchSize                            int
+	ExcludeTeams                         []string
+	Delay                                time.Duration // users deactivated more recently are left to the deactivation cleanup
+	AdminChannel                         string
+	ChannelPostLocale                    string
+	ChannelAdminSuccessor                string
+	FallbackChannelAdmin                 string
+	RevokeAccessTokens                   bool
+	OwnedBotsAction                      string
+	IntegrationsAction                   string
+	IntegrationOwner                     string
+	KeepTeams                            []string
+	KeepChannels                         []string
+	KeepChannelPatterns                  []string
+	CleanupRemovedUserData               bool
+}
+
+func (c *DeactivatedUserSweepJobSettings) Clone() *DeactivatedUserSweepJobSettings {
+	return &DeactivatedUserSweepJobSettings{
+		EnableDeactivatedUserSweep:           c.EnableDeactivatedUserSweep,
+		EnableDeactivatedUserSweepDryRunMode: c.EnableDeactivatedUserSweepDryRunMode,
+		Frequency:                            c.Frequency,
+		DayOfWeek:                            c.DayOfWeek,
+		TimeOfDay:                            c.TimeOfDay,
+		BatchSize:                            c.BatchSize,
+		ExcludeTeams:                         slices.Clone(c.ExcludeTeams),
+		Delay:                                c.Delay,
+		AdminChannel:                         c.AdminChannel,
+		ChannelPostLocale:                    c.ChannelPostLocale,
+		ChannelAdminSuccessor:                c.ChannelAdminSuccessor,
+		FallbackChannelAdmin:                 c.FallbackChannelAdmin,
+		RevokeAccessTokens:                   c.RevokeAccessTokens,
+		OwnedBotsAction:                      c.OwnedBotsAction,
+		IntegrationsAction:                   c.IntegrationsAction,
+		IntegrationOwner:                     c.IntegrationOwner,
+		KeepTeams:                            slices.Clone(c.KeepTeams),
+		KeepChannels:                         slices.Clone(c.KeepChannels),
+		KeepChannelPatterns:                  slices.Clone(c.KeepChannelPatterns),
+		CleanupRemovedUserData:               c.CleanupRemovedUserData,
+	}
+}
+
+func parseDeactivatedUserSweepJobSettings(cfg *config.Configuration) (*DeactivatedUserSweepJobSettings, error) {
+	if !cfg.EnableDeactivatedUserSweep {
+		return &DeactivatedUserSweepJobSettings{
+			EnableDeactivatedUserSweep: false,
+		}, nil
+	}
+
+	freq, err := FreqFromString(cfg.SweepFrequency)
+	if err != nil {
+		return nil, err
+	}
+
+	dow, err := config.ParseInt(cfg.DayOfWeek, 0, 6)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Day of week`: %w", err)
+	}
+
+	tod, err := time.Parse(TimeOfDayLayout, cfg.TimeOfDay)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse `Time of day`: %w", err)
+	}
+
+	if cfg.SweepBatchSize < config.MinBatchSize || cfg.SweepBatchSize > config.MaxBatchSize {
+		return nil, fmt.Errorf("`Deactivated user sweep batch size` cannot be less than %d or more than %d", config.MinBatchSize, config.MaxBatchSize)
+	}
+
+	keepPatterns, err := cfg.GetRemovalKeepChannelPatterns()
+	if err != nil {
+		return nil, err
+	}
+
+	var delay time.Duration
+	if cfg.EnableDeactivationCleanup {
+		delay = time.Duration(cfg.DeactivationCleanupDelayHours) * time.Hour
+	}
+
+	return &DeactivatedUserSweepJobSettings{
+		EnableDeactivatedUserSweep:           cfg.EnableDeactivatedUserSweep,
+		EnableDeactivatedUserSweepDryRunMode: cfg.EnableDeactivatedUserSweepDryRunMode,
+		Frequency:                            freq,
+		DayOfWeek:                            dow,
+		TimeOfDay:                            tod,
+		BatchSize:                            cfg.SweepBatchSize,
+		ExcludeTeams:                         cfg.GetDeactivationCleanupExcludeTeams(),
+		Delay:                                delay,
+		AdminChannel:                         cfg.AdminChannel,
+		ChannelPostLocale:                    cfg.ChannelPostLocale,
+		ChannelAdminSuccessor:                cfg.ChannelAdminSuccessor,
+		FallbackChannelAdmin:                 cfg.FallbackChannelAdmin,
+		RevokeAccessTokens:                   cfg.RevokeAccessTokens,
+		OwnedBotsAction:                      cfg.OwnedBotsAction,
+		IntegrationsAction:                   cfg.IntegrationsAction,
+		IntegrationOwner:                     cfg.IntegrationOwner,
+		KeepTeams:                            cfg.GetRemovalKeepTeams(),
+		KeepChannels:                         cfg.GetRemovalKeepChannels(),
+		KeepChannelPatterns:                  keepPatterns,
+		CleanupRemovedUserData:               cfg.CleanupRemovedUserData,
+	}, nil
+}
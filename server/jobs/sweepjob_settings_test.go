@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestParseDeactivatedUserSweepJobSettings(t *testing.T) {
+	newConfig := func() *config.Configuration {
+		cfg := config.NewConfiguration()
+		cfg.EnableDeactivatedUserSweep = true
+		cfg.DayOfWeek = "1"
+		cfg.TimeOfDay = "2:00am -0700"
+		return cfg
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		settings, err := parseDeactivatedUserSweepJobSettings(config.NewConfiguration())
+		require.NoError(t, err)
+		assert.False(t, settings.EnableDeactivatedUserSweep)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.DeactivationCleanupExcludeTeams = "alumni"
+
+		settings, err := parseDeactivatedUserSweepJobSettings(cfg)
+		require.NoError(t, err)
+		assert.True(t, settings.EnableDeactivatedUserSweep)
+		assert.Equal(t, Weekly, settings.Frequency)
+		assert.Equal(t, 1, settings.DayOfWeek)
+		assert.Equal(t, config.DefaultSweepBatchSize, settings.BatchSize)
+		assert.Equal(t, []string{"alumni"}, settings.ExcludeTeams)
+		assert.Zero(t, settings.Delay)
+		assert.Equal(t, settings, settings.Clone())
+	})
+
+	t.Run("deactivation cleanup delay", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.DeactivationCleanupDelayHours = 24
+
+		settings, err := parseDeactivatedUserSweepJobSettings(cfg)
+		require.NoError(t, err)
+		assert.Zero(t, settings.Delay, "the delay only applies with the deactivation cleanup enabled")
+
+		cfg.EnableDeactivationCleanup = true
+		settings, err = parseDeactivatedUserSweepJobSettings(cfg)
+		require.NoError(t, err)
+		assert.Equal(t, 24*time.Hour, settings.Delay)
+	})
+
+	t.Run("invalid settings", func(t *testing.T) {
+		cfg := newConfig()
+		cfg.SweepFrequency = "hourly"
+		_, err := parseDeactivatedUserSweepJobSettings(cfg)
+		require.Error(t, err)
+
+		cfg = newConfig()
+		cfg.SweepBatchSize = config.MaxBatchSize + 1
+		_, err = parseDeactivatedUserSweepJobSettings(cfg)
+		require.Error(t, err)
+	})
+}
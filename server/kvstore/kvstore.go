@@ -0,0 +1,33 @@
+package kvstore
+
+import (
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+const listKeysPerPage = 1000
+
+// ListKeysWithPrefix returns all the plugin's KV keys starting with prefix, fetching keys
+// a page at a time. The plugin API cannot list keys by prefix, so each page is filtered as
+// it is read, and only the matching keys are kept in memory.
+func ListKeysWithPrefix(kv *pluginapi.KVService, prefix string) ([]string, error) {
+	keys := make([]string, 0)
+
+	for page := 0; ; page++ {
+		// pages are filtered before they are returned, so the keys read are counted to find the last page
+		read := 0
+		countKeys := pluginapi.WithChecker(func(string) (bool, error) {
+			read++
+			return true, nil
+		})
+
+		pageKeys, err := kv.ListKeys(page, listKeysPerPage, countKeys, pluginapi.WithPrefix(prefix))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, pageKeys...)
+
+		if read < listKeysPerPage {
+			return keys, nil
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package kvstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+func TestListKeysWithPrefix(t *testing.T) {
+	api := &plugintest.API{}
+	client := pluginapi.NewClient(api, nil)
+
+	// the first page holds no matching key, which must not end the listing
+	firstPage := make([]string, listKeysPerPage)
+	for i := range firstPage {
+		firstPage[i] = fmt.Sprintf("other_%d", i)
+	}
+	api.On("KVList", 0, listKeysPerPage).Return(firstPage, nil)
+	api.On("KVList", 1, listKeysPerPage).Return([]string{"job_1", "other", "job_2"}, nil)
+
+	keys, err := ListKeysWithPrefix(&client.KV, "job_")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"job_1", "job_2"}, keys)
+	api.AssertNumberOfCalls(t, "KVList", 2)
+}
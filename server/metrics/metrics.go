@@ -0,0 +1,288 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+)
+
+const (
+	namespace = "retention_"
+
+	// ContentType is the content type of the Prometheus text exposition format.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+var (
+	durationBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900, 3600}
+	countBuckets    = []float64{0, 1, 5, 10, 50, 100, 500, 1000, 5000, 10000}
+)
+
+// Metrics collects retention activity and serves it in the Prometheus text format. Most metrics
+// are derived from audit records, so Metrics is registered as an audit.Sink. A nil *Metrics
+// ignores all observations.
+//
+// Metrics are kept in memory by each server, so they start over when the plugin restarts.
+type Metrics struct {
+	mux     sync.Mutex
+	metrics []*metric
+
+	channelsArchived   *metric
+	channelsWarned     *metric
+	channelsPurged     *metric
+	archivedPerRun     *metric
+	staleQueryDuration *metric
+	runDuration        *metric
+	userRemovals       *metric
+	errors             *metric
+	staleChannels      *metric
+}
+
+var _ audit.Sink = (*Metrics)(nil)
+
+func New() *Metrics {
+	m := &Metrics{}
+	m.channelsArchived = m.register("channels_archived_total", "Number of channels archived.", kindCounter, nil, nil)
+	m.channelsWarned = m.register("channels_warned_total", "Number of stale channels whose admins were warned before archiving.", kindCounter, nil, nil)
+	m.channelsPurged = m.register("channels_purged_total", "Number of archived channels permanently deleted.", kindCounter, nil, nil)
+	m.archivedPerRun = m.register("channels_archived_per_run", "Number of channels archived by each archiver run, excluding dry runs.", kindHistogram, nil, countBuckets)
+	m.staleQueryDuration = m.register("stale_channels_query_duration_seconds", "Duration of the queries fetching stale channels.", kindHistogram, nil, durationBuckets)
+	m.runDuration = m.register("run_duration_seconds", "Duration of archiver and permanent deletion runs.", kindHistogram, []string{"run", "exit_reason"}, durationBuckets)
+	m.userRemovals = m.register("user_removals_total", "Number of users removed from all teams and channels.", kindCounter, []string{"status"}, nil)
+	m.errors = m.register("errors_total", "Number of failed retention actions, by audit event.", kindCounter, []string{"event"}, nil)
+	m.staleChannels = m.register("stale_channels", "Number of stale channels found by the last completed archiver scan.", kindGauge, nil, nil)
+	return m
+}
+
+// Send updates the metrics derived from an audit record.
+func (m *Metrics) Send(rec audit.Record) {
+	if m == nil {
+		return
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if rec.Status == model.AuditStatusFail {
+		m.errors.add(1, rec.Event)
+		if rec.Event == audit.EventUserRemovedFromAllTeams {
+			m.userRemovals.add(1, rec.Status)
+		}
+		return
+	}
+
+	switch rec.Event {
+	case audit.EventChannelArchived:
+		m.channelsArchived.add(1)
+	case audit.EventChannelWarned:
+		m.channelsWarned.add(1)
+	case audit.EventChannelPurged:
+		m.channelsPurged.add(1)
+	case audit.EventUserRemovedFromAllTeams:
+		m.userRemovals.add(1, rec.Status)
+	case audit.EventRunCompleted:
+		m.runCompleted(rec.Data)
+	}
+}
+
+func (m *Metrics) runCompleted(data map[string]any) {
+	run, _ := data["run"].(string)
+	exitReason, _ := data["exit_reason"].(string)
+	dryRun, _ := data["dry_run"].(bool)
+
+	m.runDuration.observe(number(data["duration_ms"])/1000, run, exitReason)
+
+	if run != "archive" {
+		return
+	}
+	if !dryRun {
+		m.archivedPerRun.observe(number(data["channel_count"]))
+	}
+	// only runs that scanned all channels report the stale count
+	if staleCount, ok := data["stale_count"]; ok {
+		m.staleChannels.set(number(staleCount))
+	}
+}
+
+// ObserveStaleQuery records the duration of a query fetching stale channels.
+func (m *Metrics) ObserveStaleQuery(d time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.staleQueryDuration.observe(d.Seconds())
+}
+
+// ServeHTTP writes all metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	var buf bytes.Buffer
+	m.WriteText(&buf)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(buf.Bytes())
+}
+
+// WriteText writes all metrics in the Prometheus text format.
+func (m *Metrics) WriteText(w io.Writer) {
+	if m == nil {
+		return
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for _, mt := range m.metrics {
+		mt.write(w)
+	}
+}
+
+type kind string
+
+const (
+	kindCounter   kind = "counter"
+	kindGauge     kind = "gauge"
+	kindHistogram kind = "histogram"
+)
+
+// metric is a metric family with a series per combination of label values.
+type metric struct {
+	name    string
+	help    string
+	kind    kind
+	labels  []string
+	buckets []float64 // upper bounds, histograms only
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counters and gauges
+	counts      []uint64 // observations per bucket, histograms only
+	sum         float64
+	count       uint64
+}
+
+func (m *Metrics) register(name, help string, k kind, labels []string, buckets []float64) *metric {
+	mt := &metric{
+		name:    namespace + name,
+		help:    help,
+		kind:    k,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	// metrics without labels are reported from the start
+	if len(labels) == 0 {
+		mt.get()
+	}
+	m.metrics = append(m.metrics, mt)
+	return mt
+}
+
+func (mt *metric) get(labelValues ...string) *series {
+	key := strings.Join(labelValues, "\xff")
+	s, ok := mt.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues}
+		if mt.kind == kindHistogram {
+			s.counts = make([]uint64, len(mt.buckets))
+		}
+		mt.series[key] = s
+	}
+	return s
+}
+
+func (mt *metric) add(v float64, labelValues ...string) {
+	mt.get(labelValues...).value += v
+}
+
+func (mt *metric) set(v float64, labelValues ...string) {
+	mt.get(labelValues...).value = v
+}
+
+func (mt *metric) observe(v float64, labelValues ...string) {
+	s := mt.get(labelValues...)
+	for i, upper := range mt.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (mt *metric) write(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", mt.name, mt.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", mt.name, mt.kind)
+
+	keys := make([]string, 0, len(mt.series))
+	for key := range mt.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := mt.series[key]
+		if mt.kind != kindHistogram {
+			fmt.Fprintf(w, "%s%s %s\n", mt.name, formatLabels(mt.labels, s.labelValues), formatFloat(s.value))
+			continue
+		}
+
+		bucketLabels := append(slices.Clone(mt.labels), "le")
+		var cumulative uint64
+		for i, upper := range mt.buckets {
+			cumulative += s.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", mt.name, formatLabels(bucketLabels, append(slices.Clone(s.labelValues), formatFloat(upper))), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", mt.name, formatLabels(bucketLabels, append(slices.Clone(s.labelValues), "+Inf")), s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", mt.name, formatLabels(mt.labels, s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", mt.name, formatLabels(mt.labels, s.labelValues), s.count)
+	}
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names))
+	for i, name := range names {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, name, labelValueEscaper.Replace(values[i])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// number converts a numeric audit data value to a float64, returning 0 for other values.
+func number(v any) float64 {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float64:
+		return n
+	default:
+		return 0
+	}
+}
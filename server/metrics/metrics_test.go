@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+)
+
+func TestMetrics(t *testing.T) {
+	m := New()
+
+	m.Send(audit.Record{Event: audit.EventChannelArchived, Status: model.AuditStatusSuccess})
+	m.Send(audit.Record{Event: audit.EventChannelArchived, Status: model.AuditStatusSuccess})
+	m.Send(audit.Record{Event: audit.EventChannelArchived, Status: model.AuditStatusFail, Error: "boom"})
+	m.Send(audit.Record{Event: audit.EventChannelWarned, Status: model.AuditStatusSuccess})
+	m.Send(audit.Record{Event: audit.EventUserRemovedFromAllTeams, Status: model.AuditStatusSuccess})
+	m.Send(audit.Record{Event: audit.EventUserRemovedFromAllTeams, Status: model.AuditStatusFail})
+	m.Send(audit.Record{Event: audit.EventRunCompleted, Status: model.AuditStatusSuccess, Data: map[string]any{
+		"run":           "archive",
+		"dry_run":       false,
+		"channel_count": 2,
+		"stale_count":   7,
+		"exit_reason":   "completed normally",
+		"duration_ms":   int64(2500),
+	}})
+	m.Send(audit.Record{Event: audit.EventRunCompleted, Status: model.AuditStatusSuccess, Data: map[string]any{
+		"run":           "archive",
+		"dry_run":       true,
+		"channel_count": 50,
+		"exit_reason":   "canceled",
+		"duration_ms":   int64(40),
+	}})
+	m.ObserveStaleQuery(30 * time.Millisecond)
+
+	var sb strings.Builder
+	m.WriteText(&sb)
+	out := sb.String()
+
+	for _, line := range []string{
+		"# TYPE retention_channels_archived_total counter",
+		"retention_channels_archived_total 2",
+		"retention_channels_warned_total 1",
+		"retention_channels_purged_total 0",
+		`retention_errors_total{event="channel.archived"} 1`,
+		`retention_errors_total{event="user.removed_from_all_teams"} 1`,
+		`retention_user_removals_total{status="success"} 1`,
+		`retention_user_removals_total{status="fail"} 1`,
+		"# TYPE retention_stale_channels gauge",
+		"retention_stale_channels 7",
+		// dry runs are not counted as archiving runs
+		"# TYPE retention_channels_archived_per_run histogram",
+		`retention_channels_archived_per_run_bucket{le="1"} 0`,
+		`retention_channels_archived_per_run_bucket{le="5"} 1`,
+		`retention_channels_archived_per_run_bucket{le="+Inf"} 1`,
+		"retention_channels_archived_per_run_sum 2",
+		"retention_channels_archived_per_run_count 1",
+		`retention_run_duration_seconds_bucket{run="archive",exit_reason="completed normally",le="1"} 0`,
+		`retention_run_duration_seconds_bucket{run="archive",exit_reason="completed normally",le="5"} 1`,
+		`retention_run_duration_seconds_sum{run="archive",exit_reason="completed normally"} 2.5`,
+		`retention_run_duration_seconds_count{run="archive",exit_reason="canceled"} 1`,
+		`retention_stale_channels_query_duration_seconds_bucket{le="0.01"} 0`,
+		`retention_stale_channels_query_duration_seconds_bucket{le="0.05"} 1`,
+		"retention_stale_channels_query_duration_seconds_count 1",
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+	w := httptest.NewRecorder()
+	New().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, ContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "retention_channels_archived_total 0\n")
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.Send(audit.Record{Event: audit.EventChannelArchived})
+	m.ObserveStaleQuery(time.Second)
+
+	var sb strings.Builder
+	m.WriteText(&sb)
+	assert.Empty(t, sb.String())
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", formatLabels(nil, nil))
+	assert.Equal(t, `{event="a\"b\\c\nd"}`, formatLabels([]string{"event"}, []string{"a\"b\\c\nd"}))
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+)
+
+// handleMetrics serves the retention metrics in the Prometheus text format.
+func (p *Plugin) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	p.metrics.ServeHTTP(w, r)
+}
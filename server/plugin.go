@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
@@ -12,15 +11,35 @@ import (
 	"github.com/mattermost/mattermost/server/public/plugin"
 	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
 
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/channels"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/command"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/jobs"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/metrics"
 	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/webhook"
 )
 
 const (
 	routeRemoveUserFromAllTeamsAndChannels = "/remove_user_from_all_teams_and_channels"
+	routeRemoveUsersFromAllTeams           = "/remove_users_from_all_teams_and_channels"
+	routeUserRemovalJobStatus              = "/user_removal/job_status"
+	routeRestoreUserMemberships            = "/restore_user_memberships"
+	routeOffboardUser                      = "/offboard_user"
+	routeOffboardingStatus                 = "/offboarding_status"
+	routeResumeOffboarding                 = "/resume_offboarding"
+	routeArchiverStaleChannels             = "/channel_archiver/stale_channels"
+	routeArchiverStartRun                  = "/channel_archiver/start_run"
+	routeArchiverRunStatus                 = "/channel_archiver/run_status"
+	routeArchiverCancelRun                 = "/channel_archiver/cancel_run"
+	routeMetrics                           = "/metrics"
 	ChannelArchiverJobID                   = "channel_archiver_job"
+	DeactivationCleanupJobID               = "deactivation_cleanup_job"
+	DeactivatedUserSweepJobID              = "deactivated_user_sweep_job"
 )
 
 type ErrorResponse struct {
@@ -38,11 +57,23 @@ type Plugin struct {
 
 	Client   *pluginapi.Client
 	SQLStore *store.SQLStore
+	bot      *bot.Bot
+	audit    *audit.Logger
+	webhooks *webhook.Dispatcher
+	metrics  *metrics.Metrics
 
 	channelArchiverCmd *command.ChannelArchiverCmd
+	retentionCmd       *command.RetentionCmd
 
 	channelArchiverJob *jobs.ChannelArchiverJob
+	cleanupJob         *jobs.DeactivationCleanupJob
+	sweepJob           *jobs.DeactivatedUserSweepJob
 	jobManager         *jobs.JobManager
+
+	archiverRuns    *archiverRunRegistry
+	userRemovalJobs *userRemovalJobRegistry
+	offboarder      *users.Offboarder
+	i18n            *i18n.Bundle
 }
 
 func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Request) {
@@ -52,11 +83,34 @@ func (p *Plugin) ServeHTTP(_ *plugin.Context, w http.ResponseWriter, r *http.Req
 	case routeRemoveUserFromAllTeamsAndChannels:
 		p.handleRemoveUserFromAllTeamsAndChannels(w, r)
 		return
+	case routeRemoveUsersFromAllTeams:
+		p.handleBulkRemoveUsers(w, r)
+	case routeUserRemovalJobStatus:
+		p.handleGetUserRemovalJob(w, r)
+	case routeRestoreUserMemberships:
+		p.handleRestoreUserMemberships(w, r)
+	case routeOffboardUser:
+		p.handleOffboardUser(w, r)
+	case routeOffboardingStatus:
+		p.handleGetOffboarding(w, r)
+	case routeResumeOffboarding:
+		p.handleResumeOffboarding(w, r)
+	case routeArchiverStaleChannels:
+		p.handleGetStaleChannels(w, r)
+	case routeArchiverStartRun:
+		p.handleStartArchiverRun(w, r)
+	case routeArchiverRunStatus:
+		p.handleGetArchiverRunStatus(w, r)
+	case routeArchiverCancelRun:
+		p.handleCancelArchiverRun(w, r)
+	case command.DeactivatedUsersAutocompleteRoute:
+		p.handleDeactivatedUsersAutocomplete(w, r)
+	case channels.WarningActionRoute:
+		p.handleWarningAction(w, r)
+	case routeMetrics:
+		p.handleMetrics(w, r)
 	default:
-		w.WriteHeader(http.StatusNotFound)
-		_ = json.NewEncoder(w).Encode(ErrorResponse{
-			fmt.Sprintf("no handler for route %s", r.URL.Path),
-		})
+		writeError(w, fmt.Sprintf("no handler for route %s", r.URL.Path), http.StatusNotFound)
 	}
 }
 
@@ -69,34 +123,99 @@ func (p *Plugin) OnActivate() error {
 	}
 	p.SQLStore = SQLStore
 
+	p.metrics = metrics.New()
+	p.SQLStore.SetMetrics(p.metrics)
+
+	p.bot, err = bot.New(p.Client)
+	if err != nil {
+		return fmt.Errorf("cannot create bot: %w", err)
+	}
+
+	p.webhooks = webhook.NewDispatcher(&p.Client.Log, webhook.Options{})
+	cfg := p.getConfiguration()
+	if err = p.webhooks.Configure(cfg.GetWebhookURLs(), cfg.WebhookSecret); err != nil {
+		return fmt.Errorf("cannot configure webhooks: %w", err)
+	}
+	p.audit = audit.NewLogger(p.API, p.webhooks, p.metrics)
+	p.archiverRuns = newArchiverRunRegistry(&p.Client.KV)
+	p.userRemovalJobs = newUserRemovalJobRegistry(&p.Client.KV)
+	p.offboarder = users.NewOffboarder(p.API, p.SQLStore, p.audit)
+
+	p.i18n, err = i18n.NewBundle(p.Client)
+	if err != nil {
+		return fmt.Errorf("cannot load translations: %w", err)
+	}
+
 	// Register slash command for channel archiver
-	p.channelArchiverCmd, err = command.RegisterChannelArchiver(p.Client, p.SQLStore, p.getConfiguration())
+	p.channelArchiverCmd, err = command.RegisterChannelArchiver(p.Client, p.SQLStore, p.getConfiguration(), p.i18n, p.audit)
 	if err != nil {
 		return fmt.Errorf("cannot register channel archiver slash command: %w", err)
 	}
 
+	// Register slash command for deactivated users
+	p.retentionCmd, err = command.RegisterRetention(p.Client, p.API, p.SQLStore, p.getConfiguration(), p.i18n, p.audit, p.offboarder, p)
+	if err != nil {
+		return fmt.Errorf("cannot register retention slash command: %w", err)
+	}
+
 	// Create job manager
 	p.jobManager = jobs.NewJobManager(&p.Client.Log)
 
 	// Create job for channel archiver
-	p.channelArchiverJob, err = jobs.NewChannelArchiverJob(ChannelArchiverJobID, p.API, p.Client, SQLStore)
+	p.channelArchiverJob, err = jobs.NewChannelArchiverJob(ChannelArchiverJobID, p.API, p.Client, SQLStore, p.audit, p.pluginURL(channels.WarningActionRoute), p.i18n)
 	if err != nil {
 		return fmt.Errorf("cannot create channel archiver job: %w", err)
 	}
 	if err := p.jobManager.AddJob(p.channelArchiverJob); err != nil {
 		return fmt.Errorf("cannot add channel archiver job: %w", err)
 	}
+
+	// Create job for removing deactivated users from their teams and channels
+	p.cleanupJob, err = jobs.NewDeactivationCleanupJob(DeactivationCleanupJobID, p.API, p.Client, SQLStore, p.audit, p.i18n)
+	if err != nil {
+		return fmt.Errorf("cannot create deactivation cleanup job: %w", err)
+	}
+	if err := p.jobManager.AddJob(p.cleanupJob); err != nil {
+		return fmt.Errorf("cannot add deactivation cleanup job: %w", err)
+	}
+
+	// Create job for removing deactivated users who still have memberships
+	p.sweepJob, err = jobs.NewDeactivatedUserSweepJob(DeactivatedUserSweepJobID, p.API, p.Client, SQLStore, p.audit, p.i18n)
+	if err != nil {
+		return fmt.Errorf("cannot create deactivated user sweep job: %w", err)
+	}
+	if err := p.jobManager.AddJob(p.sweepJob); err != nil {
+		return fmt.Errorf("cannot add deactivated user sweep job: %w", err)
+	}
 	_ = p.jobManager.OnConfigurationChange(p.getConfiguration())
 
+	go p.resumeUserRemovalJobs()
+	go p.failInterruptedArchiverRuns()
+	go p.resumeOffboardings()
+
 	return nil
 }
 
 func (p *Plugin) OnDeactivate() error {
+	if p.archiverRuns != nil {
+		p.archiverRuns.cancelAll()
+	}
+	if p.userRemovalJobs != nil {
+		p.userRemovalJobs.cancelAll()
+	}
+	if p.offboarder != nil {
+		p.offboarder.CancelAll()
+	}
 	if p.jobManager != nil {
 		if err := p.jobManager.Close(time.Second * 15); err != nil {
 			return fmt.Errorf("error closing job manager: %w", err)
 		}
 	}
+	if p.webhooks != nil {
+		if err := p.webhooks.Close(time.Second * 5); err != nil {
+			return fmt.Errorf("error closing webhooks: %w", err)
+		}
+	}
 	return nil
 }
 
@@ -113,13 +232,16 @@ func (p *Plugin) ExecuteCommand(_ *plugin.Context, args *model.CommandArgs) (*mo
 		return &model.CommandResponse{Text: "Error verifying whether user is a system admin."}, nil
 	}
 
-	if !isAdmin {
+	// Channel admin commands check permissions themselves.
+	if !isAdmin && !(cmd == command.ArchiverTrigger && command.IsChannelAdminCommand(args.Command)) {
 		return &model.CommandResponse{Text: "User must be a system admin to use this command."}, nil
 	}
 
 	switch cmd {
 	case command.ArchiverTrigger:
 		response, err = p.channelArchiverCmd.Execute(args)
+	case command.RetentionTrigger:
+		response, err = p.retentionCmd.Execute(args)
 	default:
 		err = fmt.Errorf("invalid command '%s'", cmd)
 	}
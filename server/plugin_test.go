@@ -3,18 +3,28 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	root "github.com/mattermost/mattermost-plugin-retention-tooling"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
 )
 
-const deleteChannelMembersRoute = "/remove_user_from_all_teams_and_channels"
+const (
+	deleteChannelMembersRoute = "/remove_user_from_all_teams_and_channels"
+	deactivatedUserID         = "deactivateduser00000000000"
+)
 
 func TestServeHTTP(t *testing.T) {
 	for name, tc := range map[string]struct {
@@ -99,7 +109,7 @@ func TestServeHTTP(t *testing.T) {
 		"user id provided in request": {
 			makeRequest: func(api *plugintest.API) *http.Request {
 				payload := Payload{
-					UserID: "deactivated_user_id",
+					UserID: deactivatedUserID,
 				}
 				b, _ := json.Marshal(payload)
 
@@ -110,23 +120,25 @@ func TestServeHTTP(t *testing.T) {
 					Roles: "system_user system_admin",
 				}, nil)
 
-				api.On("GetUser", "deactivated_user_id").Return(&model.User{
-					Id:       "deactivated_user_id",
+				api.On("GetUser", deactivatedUserID).Return(&model.User{
+					Id:       deactivatedUserID,
 					Username: "deactivated_username",
 				}, nil)
 
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{}, nil)
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{}, nil)
 
+				api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
+				api.On("LogInfo", "Finished user removal job.", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 				return r
 			},
-			expectedStatus: 200,
+			expectedStatus: 202,
 			expectedError:  "",
 		},
 		"username provided in request": {
 			makeRequest: func(api *plugintest.API) *http.Request {
 				payload := Payload{
-					UserID: "deactivated_user_id",
+					UserID: deactivatedUserID,
 				}
 				b, _ := json.Marshal(payload)
 
@@ -137,22 +149,24 @@ func TestServeHTTP(t *testing.T) {
 					Roles: "system_user system_admin",
 				}, nil)
 
-				api.On("GetUser", "deactivated_user_id").Return(&model.User{
-					Id:       "deactivated_user_id",
+				api.On("GetUser", deactivatedUserID).Return(&model.User{
+					Id:       deactivatedUserID,
 					Username: "deactivated_username",
 				}, nil)
 
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{}, nil)
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{}, nil)
 
+				api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(true, nil)
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
+				api.On("LogInfo", "Finished user removal job.", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
 				return r
 			},
-			expectedStatus: 200,
+			expectedStatus: 202,
 			expectedError:  "",
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
-			p := &Plugin{}
+			p := &Plugin{userRemovalJobs: newUserRemovalJobRegistry(nil)}
 			api := &plugintest.API{}
 			p.SetAPI(api)
 
@@ -182,216 +196,237 @@ func TestServeHTTP(t *testing.T) {
 	}
 }
 
+// mockChannelMembers mocks the channel memberships of a user, by team. Like the server, it returns the
+// memberships in all teams at once, whatever the team asked for.
+func mockChannelMembers(api *plugintest.API, userID string, members map[string][]*model.ChannelMember) {
+	all := make([]*model.ChannelMember, 0)
+	for teamID, teamMembers := range members {
+		channels := make([]*model.Channel, 0, len(teamMembers))
+		for _, cm := range teamMembers {
+			all = append(all, cm)
+			channels = append(channels, &model.Channel{Id: cm.ChannelId, TeamId: teamID})
+		}
+		api.On("GetChannelsForTeamForUser", teamID, userID, true).Return(channels, nil)
+	}
+	api.On("GetChannelMembersForUser", "", userID, 0, 1000).Return(all, nil)
+}
+
 func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 	for name, tc := range map[string]struct {
-		runAssertions  func(api *plugintest.API)
-		expectedStatus int
-		expectedError  string
+		runAssertions        func(api *plugintest.API)
+		expectedResultStatus string
+		expectedError        string
 	}{
 		"happy path, user is member of one team": {
 			runAssertions: func(api *plugintest.API) {
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{{
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{{
 					TeamId: "teamid1",
-					UserId: "deactivated_user_id",
+					UserId: deactivatedUserID,
 				}}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid1",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid2",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid3",
-						UserId:    "deactivated_user_id",
+				mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{
+					"teamid1": {
+						{
+							ChannelId: "channelid1",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid2",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid3",
+							UserId:    deactivatedUserID,
+						},
 					},
-				}, nil)
+				})
 
-				api.On("DeleteChannelMember", "channelid1", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid2", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid3", "deactivated_user_id").Return(nil)
+				api.On("DeleteChannelMember", "channelid1", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid2", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid3", deactivatedUserID).Return(nil)
 
-				api.On("DeleteTeamMember", "teamid1", "deactivated_user_id", "requesting_user_id").Return(nil)
+				api.On("DeleteTeamMember", "teamid1", deactivatedUserID, "requesting_user_id").Return(nil)
 
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid1")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
+			expectedResultStatus: UserRemovalStatusSuccess,
 		},
 		"happy path, user is member of two teams": {
 			runAssertions: func(api *plugintest.API) {
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{
 					{
 						TeamId: "teamid1",
-						UserId: "deactivated_user_id",
+						UserId: deactivatedUserID,
 					}, {
 						TeamId: "teamid2",
-						UserId: "deactivated_user_id",
+						UserId: deactivatedUserID,
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid1",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid2",
-						UserId:    "deactivated_user_id",
+				mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{
+					"teamid1": {
+						{
+							ChannelId: "channelid1",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid2",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid3",
+							UserId:    deactivatedUserID,
+						},
 					},
-					{
-						ChannelId: "channelid3",
-						UserId:    "deactivated_user_id",
+					"teamid2": {
+						{
+							ChannelId: "channelid4",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid5",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid6",
+							UserId:    deactivatedUserID,
+						},
 					},
-				}, nil)
-
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid2", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid4",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid5",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid6",
-						UserId:    "deactivated_user_id",
-					},
-				}, nil)
+				})
 
-				api.On("DeleteChannelMember", "channelid1", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid2", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid3", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid4", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid5", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid6", "deactivated_user_id").Return(nil)
+				api.On("DeleteChannelMember", "channelid1", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid2", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid3", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid4", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid5", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid6", deactivatedUserID).Return(nil)
 
-				api.On("DeleteTeamMember", "teamid1", "deactivated_user_id", "requesting_user_id").Return(nil)
-				api.On("DeleteTeamMember", "teamid2", "deactivated_user_id", "requesting_user_id").Return(nil)
+				api.On("DeleteTeamMember", "teamid1", deactivatedUserID, "requesting_user_id").Return(nil)
+				api.On("DeleteTeamMember", "teamid2", deactivatedUserID, "requesting_user_id").Return(nil)
 
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid1")
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid2")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
+			expectedResultStatus: UserRemovalStatusSuccess,
 		},
 		"error deleting team member": {
 			runAssertions: func(api *plugintest.API) {
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{
 					{
 						TeamId: "teamid1",
-						UserId: "deactivated_user_id",
+						UserId: deactivatedUserID,
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid1",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid2",
-						UserId:    "deactivated_user_id",
+				mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{
+					"teamid1": {
+						{
+							ChannelId: "channelid1",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid2",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid3",
+							UserId:    deactivatedUserID,
+						},
 					},
-					{
-						ChannelId: "channelid3",
-						UserId:    "deactivated_user_id",
-					},
-				}, nil)
+				})
 
-				api.On("DeleteChannelMember", "channelid1", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid2", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid3", "deactivated_user_id").Return(nil)
+				api.On("DeleteChannelMember", "channelid1", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid2", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid3", deactivatedUserID).Return(nil)
 
-				api.On("DeleteTeamMember", "teamid1", "deactivated_user_id", "requesting_user_id").Return(&model.AppError{DetailedError: "some database error"})
+				api.On("DeleteTeamMember", "teamid1", deactivatedUserID, "requesting_user_id").Return(&model.AppError{DetailedError: "some database error"})
 
-				api.On("LogError", "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: , some database error")
+				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 500,
-			expectedError:  "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: , some database error",
+			expectedResultStatus: UserRemovalStatusPartial,
+			expectedError:        "failed to process team member. user=deactivated_username team=teamid1: failed to remove user from team: , some database error",
 		},
 		"error deleting channel member": {
 			runAssertions: func(api *plugintest.API) {
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{
 					{
 						TeamId: "teamid1",
-						UserId: "deactivated_user_id",
+						UserId: deactivatedUserID,
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid1",
-						UserId:    "deactivated_user_id",
+				mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{
+					"teamid1": {
+						{
+							ChannelId: "channelid1",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid2",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid3",
+							UserId:    deactivatedUserID,
+						},
 					},
-					{
-						ChannelId: "channelid2",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid3",
-						UserId:    "deactivated_user_id",
-					},
-				}, nil)
+				})
 
-				api.On("DeleteChannelMember", "channelid1", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid2", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid3", "deactivated_user_id").Return(&model.AppError{DetailedError: "some database error"})
+				api.On("DeleteChannelMember", "channelid1", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid2", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid3", deactivatedUserID).Return(&model.AppError{DetailedError: "some database error"})
 
 				api.On("GetChannel", "channelid3").Return(&model.Channel{Name: "channelname3"}, nil)
 
-				api.On("LogError", "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: , some database error")
+				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 500,
-			expectedError:  "error processing request: failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: , some database error",
+			expectedResultStatus: UserRemovalStatusPartial,
+			expectedError:        "failed to process team member. user=deactivated_username team=teamid1: failed to process channel member. channel=channelid3: failed to remove user from channel: , some database error",
 		},
 		"handle town square case": {
 			runAssertions: func(api *plugintest.API) {
-				api.On("GetTeamMembersForUser", "deactivated_user_id", 0, 1000).Return([]*model.TeamMember{
+				api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{
 					{
 						TeamId: "teamid1",
-						UserId: "deactivated_user_id",
+						UserId: deactivatedUserID,
 					},
 				}, nil)
 
-				api.On("GetChannelMembersForUser", "deactivated_user_id", "teamid1", 0, 1000).Return([]*model.ChannelMember{
-					{
-						ChannelId: "channelid1",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid2",
-						UserId:    "deactivated_user_id",
-					},
-					{
-						ChannelId: "channelid3",
-						UserId:    "deactivated_user_id",
+				mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{
+					"teamid1": {
+						{
+							ChannelId: "channelid1",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid2",
+							UserId:    deactivatedUserID,
+						},
+						{
+							ChannelId: "channelid3",
+							UserId:    deactivatedUserID,
+						},
 					},
-				}, nil)
+				})
 
-				api.On("DeleteChannelMember", "channelid1", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid2", "deactivated_user_id").Return(nil)
-				api.On("DeleteChannelMember", "channelid3", "deactivated_user_id").Return(&model.AppError{DetailedError: "some database error"})
+				api.On("DeleteChannelMember", "channelid1", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid2", deactivatedUserID).Return(nil)
+				api.On("DeleteChannelMember", "channelid3", deactivatedUserID).Return(&model.AppError{DetailedError: "some database error"})
 
 				api.On("GetChannel", "channelid3").Return(&model.Channel{Name: "town-square"}, nil)
 
-				api.On("DeleteTeamMember", "teamid1", "deactivated_user_id", "requesting_user_id").Return(nil)
+				api.On("DeleteTeamMember", "teamid1", deactivatedUserID, "requesting_user_id").Return(nil)
 
 				api.On("LogDebug", "Removed user from all channels in team.", "username", "deactivated_username", "team", "teamid1")
 				api.On("LogDebug", "Finished for user.", "username", "deactivated_username")
 			},
-			expectedStatus: 200,
-			expectedError:  "",
+			expectedResultStatus: UserRemovalStatusSuccess,
 		},
 	} {
 		t.Run(name, func(t *testing.T) {
-			p := &Plugin{}
+			p := &Plugin{userRemovalJobs: newUserRemovalJobRegistry(nil)}
 			api := &plugintest.API{}
 			p.SetAPI(api)
 
@@ -410,31 +445,451 @@ func TestHandleRemoveUserFromAllTeamsAndChannels(t *testing.T) {
 			}, nil)
 
 			api.On("GetUserByUsername", "deactivated_username").Return(&model.User{
-				Id:       "deactivated_user_id",
+				Id:       deactivatedUserID,
 				Username: "deactivated_username",
 			}, nil)
 
+			// the job looks the user up by ID, and holds a cluster lock while it runs
+			api.On("GetUser", deactivatedUserID).Return(&model.User{
+				Id:       deactivatedUserID,
+				Username: "deactivated_username",
+			}, nil)
+			mockKVStore(api)
+			api.On("LogInfo", "Finished user removal job.", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
 			tc.runAssertions(api)
 
 			p.ServeHTTP(nil, w, r)
 
+			result := w.Result()
+			require.NotNil(t, result)
+			defer result.Body.Close()
+
+			require.Equal(t, result.Header.Get("Content-Type"), "application/json")
+			require.Equal(t, http.StatusAccepted, result.StatusCode)
+
+			var job UserRemovalJob
+			require.NoError(t, json.NewDecoder(result.Body).Decode(&job))
+			require.Equal(t, UserRemovalJobTypeSingle, job.Type)
+			require.Equal(t, 1, job.Total)
+
+			require.Eventually(t, func() bool {
+				return p.userRemovalJobs.get(job.ID).Status == UserRemovalJobStatusCompleted
+			}, 5*time.Second, 10*time.Millisecond)
+
+			status := p.userRemovalJobs.get(job.ID)
+			require.Len(t, status.Results, 1)
+			require.Equal(t, tc.expectedResultStatus, status.Results[0].Status)
+			require.Equal(t, tc.expectedError, status.Results[0].Error)
+		})
+	}
+}
+
+func TestChannelArchiverAPI(t *testing.T) {
+	for name, tc := range map[string]struct {
+		method         string
+		path           string
+		body           string
+		isAdmin        bool
+		expectedStatus int
+		expectedError  string
+	}{
+		"stale channels, invalid http method": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/stale_channels",
+			isAdmin:        true,
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method POST. Should be GET",
+		},
+		"stale channels, user is not sysadmin": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/stale_channels",
+			expectedStatus: 401,
+			expectedError:  "user requesting_user_id is not a system admin",
+		},
+		"stale channels, invalid days": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/stale_channels?days=5",
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "invalid days parameter: number must be greater than or equal to 30",
+		},
+		"stale channels, invalid page": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/stale_channels?days=30&page=-1",
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "invalid page parameter: must be a non-negative integer",
+		},
+		"stale channels, invalid per_page": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/stale_channels?days=30&per_page=5000",
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "invalid per_page parameter: number must be less than or equal to 1000",
+		},
+		"start run, invalid http method": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/start_run",
+			isAdmin:        true,
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method GET. Should be POST",
+		},
+		"start run, missing payload": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/start_run",
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "error decoding run payload: EOF",
+		},
+		"start run, invalid days": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/start_run",
+			body:           `{"days": 10}`,
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "days must be between 30 and 10000",
+		},
+		"start run, invalid batch size": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/start_run",
+			body:           `{"days": 30, "batch_size": 1}`,
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "batch_size must be between 10 and 10000",
+		},
+		"run status, missing run id": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/run_status",
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "missing run_id parameter",
+		},
+		"run status, run not found": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/run_status?run_id=unknown",
+			isAdmin:        true,
+			expectedStatus: 404,
+			expectedError:  "run unknown not found",
+		},
+		"cancel run, missing run id": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/cancel_run",
+			body:           `{}`,
+			isAdmin:        true,
+			expectedStatus: 400,
+			expectedError:  "missing run_id in the request payload",
+		},
+		"cancel run, run not found": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/cancel_run",
+			body:           `{"run_id": "unknown"}`,
+			isAdmin:        true,
+			expectedStatus: 404,
+			expectedError:  "run unknown not found",
+		},
+		"warning action, invalid http method": {
+			method:         http.MethodGet,
+			path:           "/channel_archiver/warning_action",
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method GET. Should be POST",
+		},
+		"warning action, invalid channel id": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/warning_action",
+			body:           `{"context": {"action": "keep", "channel_id": "invalid"}}`,
+			expectedStatus: 400,
+			expectedError:  "invalid channel_id",
+		},
+		"warning action, invalid action": {
+			method:         http.MethodPost,
+			path:           "/channel_archiver/warning_action",
+			body:           `{"context": {"action": "delete", "channel_id": "abcdefghijklmnopqrstuvwxyz"}}`,
+			expectedStatus: 400,
+			expectedError:  "invalid action \"delete\"",
+		},
+		"metrics, invalid http method": {
+			method:         http.MethodPost,
+			path:           "/metrics",
+			isAdmin:        true,
+			expectedStatus: 405,
+			expectedError:  "unexpected HTTP method POST. Should be GET",
+		},
+		"metrics, user is not sysadmin": {
+			method:         http.MethodGet,
+			path:           "/metrics",
+			expectedStatus: 401,
+			expectedError:  "user requesting_user_id is not a system admin",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := &Plugin{archiverRuns: newArchiverRunRegistry(nil)}
+			api := &plugintest.API{}
+			p.SetAPI(api)
+
+			roles := "system_user"
+			if tc.isAdmin {
+				roles = "system_user system_admin"
+			}
+			api.On("GetUser", "requesting_user_id").Return(&model.User{Roles: roles}, nil)
+
+			w := httptest.NewRecorder()
+			var body io.Reader
+			if tc.body != "" {
+				body = bytes.NewReader([]byte(tc.body))
+			}
+			r := httptest.NewRequest(tc.method, tc.path, body)
+			r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+
+			p.ServeHTTP(nil, w, r)
+
 			result := w.Result()
 			require.NotNil(t, result)
 			defer result.Body.Close()
 			bodyBytes, err := io.ReadAll(result.Body)
 			require.NoError(t, err)
 
-			require.Equal(t, result.Header.Get("Content-Type"), "application/json")
+			require.Equal(t, "application/json", result.Header.Get("Content-Type"))
 			require.Equal(t, tc.expectedStatus, result.StatusCode)
 
 			var errResponse ErrorResponse
 			err = json.Unmarshal(bodyBytes, &errResponse)
 			require.NoError(t, err)
-			if tc.expectedError != "" {
-				require.Equal(t, tc.expectedError, errResponse.Error)
-			} else {
-				require.Equal(t, "", errResponse.Error)
-			}
+			require.Equal(t, tc.expectedError, errResponse.Error)
+		})
+	}
+}
+
+func TestArchiverRunRegistry(t *testing.T) {
+	reg := newArchiverRunRegistry(nil)
+
+	canceled := false
+	run1 := &ArchiverRun{ID: "run1", Status: ArchiverRunStatusRunning, cancel: func() { canceled = true }}
+	require.NoError(t, reg.start(run1))
+
+	// only one run may be active at a time
+	require.Error(t, reg.start(&ArchiverRun{ID: "run2", Status: ArchiverRunStatusRunning}))
+
+	require.True(t, reg.cancel("run1"))
+	require.True(t, canceled)
+	require.False(t, reg.cancel("unknown"))
+
+	require.NoError(t, reg.update("run1", func(run *ArchiverRun) {
+		run.Status = ArchiverRunStatusCanceled
+		run.ChannelIDs = []string{"channel1"}
+	}))
+
+	status := reg.get("run1")
+	require.NotNil(t, status)
+	require.Equal(t, ArchiverRunStatusCanceled, status.Status)
+	require.Equal(t, []string{"channel1"}, status.ChannelIDs)
+	require.Nil(t, reg.get("unknown"))
+
+	// a new run can start once the previous one has finished
+	require.NoError(t, reg.start(&ArchiverRun{ID: "run2", Status: ArchiverRunStatusRunning}))
+
+	t.Run("finished runs kept in memory are capped", func(t *testing.T) {
+		reg := newArchiverRunRegistry(nil)
+		for i := range maxFinishedArchiverRuns + 5 {
+			runID := fmt.Sprintf("run%d", i)
+			require.NoError(t, reg.start(&ArchiverRun{ID: runID, Status: ArchiverRunStatusRunning}))
+			require.NoError(t, reg.update(runID, func(run *ArchiverRun) {
+				run.Status = ArchiverRunStatusCompleted
+				run.EndAt = int64(i + 1)
+			}))
+		}
+
+		require.Len(t, reg.runs, maxFinishedArchiverRuns)
+		require.Nil(t, reg.get("run4"))
+		require.NotNil(t, reg.get("run5"))
+	})
+}
+
+func TestArchiverRunRegistryKVStore(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	client := pluginapi.NewClient(api, nil)
+
+	reg := newArchiverRunRegistry(&client.KV)
+	other := newArchiverRunRegistry(&client.KV) // another server of the cluster
+
+	require.NoError(t, reg.start(&ArchiverRun{ID: "run1", Status: ArchiverRunStatusRunning}))
+	require.NoError(t, reg.update("run1", func(run *ArchiverRun) {
+		run.ChannelIDs = []string{"channel1"}
+	}))
+
+	// the other server sees the progress, but cannot cancel the run
+	status := other.get("run1")
+	require.NotNil(t, status)
+	require.Equal(t, ArchiverRunStatusRunning, status.Status)
+	require.Equal(t, []string{"channel1"}, status.ChannelIDs)
+	require.False(t, other.cancel("run1"))
+
+	runIDs, err := other.interrupted()
+	require.NoError(t, err)
+	require.Equal(t, []string{"run1"}, runIDs)
+	runIDs, err = reg.interrupted()
+	require.NoError(t, err)
+	require.Empty(t, runIDs)
+
+	// finished runs are only kept in the KV store
+	require.NoError(t, reg.update("run1", func(run *ArchiverRun) {
+		run.Status = ArchiverRunStatusCompleted
+	}))
+	require.Empty(t, reg.runs)
+	require.Equal(t, ArchiverRunStatusCompleted, other.get("run1").Status)
+
+	// a run left running by a stopped server is marked as failed
+	require.NoError(t, reg.start(&ArchiverRun{ID: "run2", Status: ArchiverRunStatusRunning}))
+	restarted := newArchiverRunRegistry(&client.KV)
+	require.NoError(t, restarted.fail("run2", "interrupted"))
+	status = restarted.get("run2")
+	require.Equal(t, ArchiverRunStatusFailed, status.Status)
+	require.Equal(t, "interrupted", status.Error)
+	require.NotZero(t, status.EndAt)
+}
+
+func TestTryClusterLock(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	p := &Plugin{}
+	p.SetAPI(api)
+
+	mutex, locked, err := p.tryClusterLock(archiverRunLockKey)
+	require.NoError(t, err)
+	require.True(t, locked)
+
+	// a second run, here or on another server, is refused while the lock is held
+	_, locked, err = p.tryClusterLock(archiverRunLockKey)
+	require.NoError(t, err)
+	require.False(t, locked)
+
+	mutex.Unlock()
+	mutex, locked, err = p.tryClusterLock(archiverRunLockKey)
+	require.NoError(t, err)
+	require.True(t, locked)
+	mutex.Unlock()
+}
+
+func TestConfigurationWillBeSaved(t *testing.T) {
+	newConfig := func(settings map[string]any) *model.Config {
+		cfg := &model.Config{}
+		cfg.SetDefaults()
+		cfg.PluginSettings.Plugins[root.Manifest.Id] = settings
+		return cfg
+	}
+
+	p := &Plugin{}
+
+	t.Run("valid templates", func(t *testing.T) {
+		cfg := newConfig(map[string]any{
+			"ageindays":             365,
+			"archivenoticetemplate": "{{.ChannelName}} was archived after {{.DaysIdle}} days.",
+		})
+		_, err := p.ConfigurationWillBeSaved(cfg)
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		cfg := newConfig(map[string]any{
+			"archivenoticetemplate": "{{.Unknown}}",
 		})
+		_, err := p.ConfigurationWillBeSaved(cfg)
+		require.ErrorContains(t, err, "Archive notice template")
+	})
+
+	t.Run("no plugin settings", func(t *testing.T) {
+		cfg := &model.Config{}
+		cfg.SetDefaults()
+		_, err := p.ConfigurationWillBeSaved(cfg)
+		require.NoError(t, err)
+	})
+}
+
+func TestHandleRemoveUserFromAllTeamsAndChannelsDryRun(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+
+	api.On("GetUser", "requesting_user_id").Return(&model.User{Roles: "system_user system_admin"}, nil)
+	api.On("GetUserByUsername", "deactivated_username").Return(&model.User{Id: deactivatedUserID, Username: "deactivated_username"}, nil)
+	api.On("GetTeamMembersForUser", deactivatedUserID, 0, 1000).Return([]*model.TeamMember{{TeamId: "teamid1"}}, nil)
+	api.On("GetTeam", "teamid1").Return(&model.Team{Id: "teamid1", Name: "team1"}, nil)
+	mockChannelMembers(api, deactivatedUserID, map[string][]*model.ChannelMember{"teamid1": {{ChannelId: "channelid1"}}})
+	api.On("GetChannel", "channelid1").Return(&model.Channel{Id: "channelid1", Name: "town-square", Type: model.ChannelTypeOpen}, nil)
+
+	b, _ := json.Marshal(Payload{Username: "deactivated_username", DryRun: true})
+	r := httptest.NewRequest(http.MethodPost, deleteChannelMembersRoute, bytes.NewReader(b))
+	r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+	w := httptest.NewRecorder()
+
+	p.ServeHTTP(nil, w, r)
+
+	result := w.Result()
+	defer result.Body.Close()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+
+	var plan users.RemovalPlan
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&plan))
+	require.Equal(t, 1, plan.TeamCount)
+	require.Equal(t, 1, plan.ChannelCount)
+	require.Equal(t, users.FailReasonDefaultChannel, plan.Teams[0].Channels[0].FailReason)
+
+	// nothing was removed
+	api.AssertNotCalled(t, "DeleteChannelMember", mock.Anything, mock.Anything)
+	api.AssertNotCalled(t, "DeleteTeamMember", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHandleRestoreUserMemberships(t *testing.T) {
+	p := &Plugin{}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	mockKVStore(api)
+
+	user := &model.User{Id: deactivatedUserID, Username: "returning_username"}
+	api.On("GetUser", "requesting_user_id").Return(&model.User{Roles: "system_user system_admin"}, nil)
+	api.On("GetUserByUsername", "returning_username").Return(user, nil)
+	api.On("GetTeam", "teamid1").Return(&model.Team{Id: "teamid1"}, nil)
+	api.On("CreateTeamMember", "teamid1", deactivatedUserID).Return(&model.TeamMember{Roles: "team_user"}, nil)
+	api.On("GetChannel", "channelid1").Return(&model.Channel{Id: "channelid1", DeleteAt: 1000}, nil)
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	restore := func(dryRun bool) *http.Response {
+		b, _ := json.Marshal(Payload{Username: "returning_username", DryRun: dryRun})
+		r := httptest.NewRequest(http.MethodPost, routeRestoreUserMemberships, bytes.NewReader(b))
+		r.Header.Set("Mattermost-User-Id", "requesting_user_id")
+		w := httptest.NewRecorder()
+		p.ServeHTTP(nil, w, r)
+		return w.Result()
 	}
+
+	// nothing to restore yet
+	result := restore(false)
+	defer result.Body.Close()
+	require.Equal(t, http.StatusNotFound, result.StatusCode)
+
+	snapshot := users.MembershipSnapshot{UserID: deactivatedUserID, Teams: []users.TeamSnapshot{
+		{TeamID: "teamid1", Roles: "team_user", Channels: []users.ChannelSnapshot{{ChannelID: "channelid1"}}},
+	}}
+	data, _ := json.Marshal(snapshot)
+	_, appErr := api.KVSetWithOptions("snap_"+deactivatedUserID, data, model.PluginKVSetOptions{})
+	require.Nil(t, appErr)
+
+	// a dry run returns the snapshot
+	result = restore(true)
+	defer result.Body.Close()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	var stored users.MembershipSnapshot
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&stored))
+	require.Equal(t, snapshot.Teams, stored.Teams)
+	api.AssertNotCalled(t, "CreateTeamMember", mock.Anything, mock.Anything)
+
+	result = restore(false)
+	defer result.Body.Close()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	var report users.RestoreReport
+	require.NoError(t, json.NewDecoder(result.Body).Decode(&report))
+	require.Equal(t, []string{"teamid1"}, report.TeamsRestored)
+	require.Empty(t, report.ChannelsRestored)
+	require.Equal(t, []users.RestoreSkip{{TeamID: "teamid1", ChannelID: "channelid1", Reason: users.SkipReasonArchived}}, report.Skipped)
 }
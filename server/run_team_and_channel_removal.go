@@ -2,64 +2,147 @@ package main
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
 )
 
 type Payload struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
+	DryRun   bool   `json:"dry_run"` // return the removal plan instead of removing the user
+	Manager  string `json:"manager"` // offboarding only: manager notified of the offboarding, username, email or user ID
+
+	Keep *KeepOverride `json:"keep,omitempty"` // removals only: teams and channels the user stays in
+}
+
+// KeepOverride lists teams and channels users stay in for a single request, on top of the
+// configured keep-lists, or instead of them.
+type KeepOverride struct {
+	users.KeepList
+	Replace bool `json:"replace"` // the configured keep-lists are ignored
 }
 
 func (p *Plugin) handleRemoveUserFromAllTeamsAndChannels(w http.ResponseWriter, r *http.Request) {
-	var writeError = func(errorString string, statusCode int) {
-		w.WriteHeader(statusCode)
-		_ = json.NewEncoder(w).Encode(ErrorResponse{errorString})
+	if !checkMethod(w, r, http.MethodPost) {
+		return
 	}
 
-	if r.Method != http.MethodPost {
-		writeError(fmt.Sprintf("unexpected HTTP method %s. Should be POST", r.Method), http.StatusMethodNotAllowed)
+	requesterID, ok := p.requireSystemAdmin(w, r)
+	if !ok {
 		return
 	}
 
-	requesterID := r.Header.Get("Mattermost-User-Id")
-	if requesterID == "" {
-		writeError("request is not from an authenticated user", http.StatusUnauthorized)
+	payload, user, err := p.readRemoveUserPayload(r)
+	if err != nil {
+		err = errors.Wrap(err, "error processing request")
+		p.API.LogError(err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	isAdmin, err := p.ensureSystemAdmin(requesterID)
+	keep, err := p.removalKeepList(payload.Keep)
 	if err != nil {
-		writeError(fmt.Sprintf("error verifying whether user %s is a system admin: %s", requesterID, err.Error()), http.StatusUnauthorized)
+		writeError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if !isAdmin {
-		writeError(fmt.Sprintf("user %s is not a system admin", requesterID), http.StatusUnauthorized)
+	if payload.DryRun {
+		var plan *users.RemovalPlan
+		plan, err = p.userRemover().PlanRemoval(user, users.RemoveOpts{RequesterID: requesterID, Keep: keep})
+		if err == nil {
+			writeJSON(w, http.StatusOK, plan)
+			return
+		}
+	}
+	if err != nil {
+		err = errors.Wrap(err, "error processing request")
+		p.API.LogError(err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = p.removeUserFromAllTeamsAndChannels(r, requesterID)
+	// removing a user with many memberships takes a while, so it runs as a job
+	p.startUserRemovalJob(w, UserRemovalJobTypeSingle, requesterID, []string{user.Id}, keep)
+}
+
+// removalKeepList returns the keep-lists of a removal: the configured ones, along with or replaced
+// by the ones of the request.
+func (p *Plugin) removalKeepList(override *KeepOverride) (users.KeepList, error) {
+	keep := users.NewKeepList(p.getConfiguration())
+	if override == nil {
+		return keep, nil
+	}
+	if override.Replace {
+		keep = override.KeepList
+	} else {
+		keep = keep.Add(override.KeepList)
+	}
+	if err := keep.Validate(); err != nil {
+		return users.KeepList{}, err
+	}
+	return keep, nil
+}
+
+// handleRestoreUserMemberships adds a reactivated user back to the teams and channels they were
+// removed from. In dry run mode, the membership snapshot is returned instead.
+func (p *Plugin) handleRestoreUserMemberships(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	requesterID, ok := p.requireSystemAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	payload, user, err := p.readRemoveUserPayload(r)
 	if err != nil {
 		err = errors.Wrap(err, "error processing request")
 		p.API.LogError(err.Error())
-		writeError(err.Error(), http.StatusInternalServerError)
+		writeError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	_ = json.NewEncoder(w).Encode(SuccessResponse{true})
+	remover := p.userRemover()
+	if payload.DryRun {
+		snapshot, err := remover.GetMembershipSnapshot(user.Id)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if snapshot == nil {
+			writeError(w, users.ErrNoMembershipSnapshot.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, snapshot)
+		return
+	}
+
+	report, err := remover.RestoreMemberships(user, requesterID)
+	switch {
+	case errors.Is(err, users.ErrNoMembershipSnapshot):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, users.ErrUserDeactivated):
+		writeError(w, err.Error(), http.StatusBadRequest)
+	case report == nil:
+		writeError(w, err.Error(), http.StatusInternalServerError)
+	default:
+		// memberships that could not be restored are listed in the report
+		writeJSON(w, http.StatusOK, report)
+	}
 }
 
-func (p *Plugin) removeUserFromAllTeamsAndChannels(r *http.Request, requesterID string) error {
+// readRemoveUserPayload returns the request payload and the user it names.
+func (p *Plugin) readRemoveUserPayload(r *http.Request) (*Payload, *model.User, error) {
 	var payload Payload
 	err := json.NewDecoder(r.Body).Decode(&payload)
 	if err != nil {
-		return errors.Wrap(err, "error decoding user info payload")
+		return nil, nil, errors.Wrap(err, "error decoding user info payload")
 	}
 	r.Body.Close()
 
@@ -71,77 +154,21 @@ func (p *Plugin) removeUserFromAllTeamsAndChannels(r *http.Request, requesterID
 	case payload.UserID != "":
 		user, appErr = p.API.GetUser(payload.UserID)
 		if appErr != nil {
-			return errors.Wrapf(appErr, "failed to get user with id %s", payload.UserID)
+			return nil, nil, errors.Wrapf(appErr, "failed to get user with id %s", payload.UserID)
 		}
 	case payload.Username != "":
 		user, appErr = p.API.GetUserByUsername(payload.Username)
 		if appErr != nil {
-			return errors.Wrapf(appErr, "failed to get user with username %s", payload.Username)
+			return nil, nil, errors.Wrapf(appErr, "failed to get user with username %s", payload.Username)
 		}
 	default:
-		return errors.New("please provide either user_id or username in the request payload")
-	}
-
-	// Start team/channel removal process
-	teamMembers, appErr := p.API.GetTeamMembersForUser(user.Id, 0, 1000)
-	if appErr != nil {
-		return errors.Wrapf(appErr, "failed to get team members for user. user=%s", user.Username)
-	}
-
-	for _, tm := range teamMembers {
-		err = p.processTeamMember(user, tm.TeamId, requesterID)
-		if err != nil {
-			return errors.Wrapf(err, "failed to process team member. user=%s team=%s", user.Username, tm.TeamId)
-		}
-	}
-
-	p.API.LogDebug("Finished for user.", "username", user.Username)
-
-	return nil
-}
-
-func (p *Plugin) processTeamMember(user *model.User, teamID string, requesterID string) error {
-	var appErr *model.AppError
-
-	// Remove user from channels in this team
-	channelMembers, appErr := p.API.GetChannelMembersForUser(user.Id, teamID, 0, 1000)
-	if appErr != nil {
-		return errors.Wrapf(appErr, "failed to get channel members")
-	}
-
-	for _, cm := range channelMembers {
-		err := p.processChannelMember(user, cm.ChannelId)
-		if err != nil {
-			return errors.Wrapf(err, "failed to process channel member. channel=%s", cm.ChannelId)
-		}
-	}
-
-	// Remove user from team
-	appErr = p.API.DeleteTeamMember(teamID, user.Id, requesterID)
-	if appErr != nil {
-		return errors.Wrap(appErr, "failed to remove user from team")
+		return nil, nil, errors.New("please provide either user_id or username in the request payload")
 	}
 
-	p.API.LogDebug("Removed user from all channels in team.", "username", user.Username, "team", teamID)
-
-	return nil
+	return &payload, user, nil
 }
 
-func (p *Plugin) processChannelMember(user *model.User, channelID string) error {
-	// Remove user from channel
-	appErr := p.API.DeleteChannelMember(channelID, user.Id)
-	if appErr != nil {
-		c, channelErr := p.API.GetChannel(channelID)
-		if channelErr != nil {
-			return errors.Wrapf(channelErr, "failed to get channel %s", channelID)
-		}
-
-		if c.Name == model.DefaultChannelName {
-			return nil
-		}
-
-		return errors.Wrap(appErr, "failed to remove user from channel")
-	}
-
-	return nil
+// userRemover returns a remover using the plugin API, store and audit logger.
+func (p *Plugin) userRemover() *users.Remover {
+	return users.NewRemover(p.API, p.SQLStore, p.audit)
 }
@@ -1,6 +1,8 @@
 package store
 
 import (
+	"database/sql"
+	"errors"
 	"time"
 
 	sq "github.com/Masterminds/squirrel"
@@ -12,6 +14,17 @@ var (
 	defaultChannels = []string{"town-square", "off-topic"}
 )
 
+// IsDefaultChannel returns true if the channel name is one of the default channels that
+// are never archived.
+func IsDefaultChannel(name string) bool {
+	for _, ch := range defaultChannels {
+		if ch == name {
+			return true
+		}
+	}
+	return false
+}
+
 type StaleChannelOpts struct {
 	AgeInDays                 int
 	ExcludeChannels           []string
@@ -20,9 +33,30 @@ type StaleChannelOpts struct {
 	IncludeChannelTypeDirect  bool
 	IncludeChannelTypeGroup   bool
 	AdminChannel              string
+	TeamID                    string // optional, only return channels from this team
+	OrphanedOnly              bool   // only return channels without active members, see IsChannelOrphaned
+	AbandonedOnly             bool   // only return channels created before the cutoff without user posts, see IsChannelAbandoned
 }
 
+// activeMembersQuery selects the active human members of the channel ch. Bots don't count as
+// members, as they never keep a channel in use on their own.
+const activeMembersQuery = "SELECT 1 FROM ChannelMembers AS cm" +
+	" JOIN Users AS u ON u.Id = cm.UserId" +
+	" LEFT JOIN Bots AS b ON b.UserId = cm.UserId" +
+	" WHERE cm.ChannelId = ch.Id AND u.DeleteAt = 0 AND b.UserId IS NULL"
+
+// userPostsQuery selects the posts made by users, rather than system messages, in the channel ch.
+// Deleted posts count, as the channel was used.
+const userPostsQuery = "SELECT 1 FROM Posts AS up" +
+	" WHERE up.ChannelId = ch.Id AND up.Type NOT LIKE 'system_%'"
+
 func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize int) ([]*model.Channel, bool, error) {
+	return ss.GetStaleChannelsWithOffset(opts, page*pageSize, pageSize)
+}
+
+// GetStaleChannelsWithOffset is the same as GetStaleChannels but skips an arbitrary number of
+// channels instead of whole pages. This is useful when some channels of a page are left in place.
+func (ss *SQLStore) GetStaleChannelsWithOffset(opts StaleChannelOpts, offset int, pageSize int) ([]*model.Channel, bool, error) {
 	olderThan := model.GetMillisForTime(time.Now().AddDate(0, 0, -opts.AgeInDays))
 
 	excludeChannels := make([]string, 0)
@@ -33,27 +67,32 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 	}
 
 	// find all channels where no posts or reactions have been modified,deleted since the olderThan timestamp.
-	query := ss.builder.Select("ch.Id", "ch.Name").Distinct().
+	query := ss.builder.Select("ch.Id", "ch.Name", "ch.DisplayName", "ch.TeamId", "ch.Type").Distinct().
 		From("Channels as ch").
 		LeftJoin("Posts as p ON ch.Id=p.ChannelId").
 		LeftJoin("Reactions as r ON p.Id=r.PostId").
-		Where(sq.And{
-			sq.Eq{"ch.DeleteAt": 0},
-			sq.Lt{"ch.UpdateAt": olderThan},
-		}).
-		GroupBy("ch.Id", "ch.Name").
-		Having(sq.And{
-			sq.Or{
-				sq.Eq{"MAX(p.UpdateAt)": nil},
-				sq.Lt{"MAX(p.UpdateAt)": olderThan},
-			},
-			sq.Or{
-				sq.Eq{"MAX(r.UpdateAt)": nil},
-				sq.Lt{"MAX(r.UpdateAt)": olderThan},
-			},
-		}).
+		Where(sq.Eq{"ch.DeleteAt": 0}).
+		GroupBy("ch.Id", "ch.Name", "ch.DisplayName", "ch.TeamId", "ch.Type").
 		OrderBy("ch.Id")
 
+	if opts.AbandonedOnly {
+		// system messages such as joins don't count, so only the creation time matters
+		query = query.Where(sq.Lt{"ch.CreateAt": olderThan}).
+			Where("NOT EXISTS (" + userPostsQuery + ")")
+	} else {
+		query = query.Where(sq.Lt{"ch.UpdateAt": olderThan}).
+			Having(sq.And{
+				sq.Or{
+					sq.Eq{"MAX(p.UpdateAt)": nil},
+					sq.Lt{"MAX(p.UpdateAt)": olderThan},
+				},
+				sq.Or{
+					sq.Eq{"MAX(r.UpdateAt)": nil},
+					sq.Lt{"MAX(r.UpdateAt)": olderThan},
+				},
+			})
+	}
+
 	if len(excludeChannels) > 0 {
 		query = query.Where(sq.And{
 			sq.NotEq{"ch.Id": excludeChannels},
@@ -61,6 +100,14 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 		})
 	}
 
+	if opts.TeamID != "" {
+		query = query.Where(sq.Eq{"ch.TeamId": opts.TeamID})
+	}
+
+	if opts.OrphanedOnly {
+		query = query.Where("NOT EXISTS (" + activeMembersQuery + ")")
+	}
+
 	channelTypes := []string{}
 	if opts.IncludeChannelTypeOpen {
 		channelTypes = append(channelTypes, string(model.ChannelTypeOpen))
@@ -76,8 +123,8 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 	}
 	query = query.Where(sq.Eq{"ch.Type": channelTypes})
 
-	if page > 0 {
-		query = query.Offset(uint64(page) * uint64(pageSize)) //nolint:gosec // page and pageSize are validated to be non-negative
+	if offset > 0 {
+		query = query.Offset(uint64(offset)) //nolint:gosec // offset is validated to be non-negative
 	}
 
 	if pageSize > 0 {
@@ -85,6 +132,9 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 		query = query.Limit(uint64(pageSize) + 1)
 	}
 
+	start := time.Now()
+	defer func() { ss.metrics.ObserveStaleQuery(time.Since(start)) }()
+
 	rows, err := query.Query()
 	if err != nil {
 		ss.logger.Error("error fetching stale channels", "err", err)
@@ -95,7 +145,7 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 	for rows.Next() {
 		channel := &model.Channel{}
 
-		if err := rows.Scan(&channel.Id, &channel.Name); err != nil {
+		if err := rows.Scan(&channel.Id, &channel.Name, &channel.DisplayName, &channel.TeamId, &channel.Type); err != nil {
 			ss.logger.Error("error scanning stale channels", "err", err)
 			return nil, false, err
 		}
@@ -110,3 +160,189 @@ func (ss *SQLStore) GetStaleChannels(opts StaleChannelOpts, page int, pageSize i
 
 	return channels, hasMore, nil
 }
+
+// ChannelActivity holds the timestamps used to determine whether a channel is stale.
+type ChannelActivity struct {
+	ChannelID       string
+	ChannelUpdateAt int64
+	LastPostAt      int64
+	LastReactionAt  int64
+}
+
+// LastActivityAt returns the most recent of the channel, post and reaction timestamps.
+func (ca *ChannelActivity) LastActivityAt() int64 {
+	last := ca.ChannelUpdateAt
+	if ca.LastPostAt > last {
+		last = ca.LastPostAt
+	}
+	if ca.LastReactionAt > last {
+		last = ca.LastReactionAt
+	}
+	return last
+}
+
+// IsStale returns true if no activity happened since the olderThan timestamp. This mirrors
+// the conditions used by GetStaleChannels.
+func (ca *ChannelActivity) IsStale(olderThan int64) bool {
+	return ca.LastActivityAt() < olderThan
+}
+
+// GetChannelActivity fetches the last post, last reaction and channel update timestamps
+// for a single channel. Deleted posts and reactions count as activity, same as GetStaleChannels.
+func (ss *SQLStore) GetChannelActivity(channelID string) (*ChannelActivity, error) {
+	query := ss.builder.Select(
+		"ch.UpdateAt",
+		"COALESCE(MAX(p.UpdateAt), 0)",
+		"COALESCE(MAX(r.UpdateAt), 0)",
+	).
+		From("Channels as ch").
+		LeftJoin("Posts as p ON ch.Id=p.ChannelId").
+		LeftJoin("Reactions as r ON p.Id=r.PostId").
+		Where(sq.Eq{"ch.Id": channelID}).
+		GroupBy("ch.Id", "ch.UpdateAt")
+
+	activity := &ChannelActivity{ChannelID: channelID}
+	err := query.QueryRow().Scan(&activity.ChannelUpdateAt, &activity.LastPostAt, &activity.LastReactionAt)
+	if err != nil {
+		ss.logger.Error("error fetching channel activity", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+
+	return activity, nil
+}
+
+// GetChannelAdminIDs returns the IDs of the active, non-bot users that are admins of the channel.
+func (ss *SQLStore) GetChannelAdminIDs(channelID string) ([]string, error) {
+	query := ss.builder.Select("cm.UserId").
+		From("ChannelMembers as cm").
+		Join("Users as u ON u.Id=cm.UserId").
+		LeftJoin("Bots as b ON b.UserId=cm.UserId").
+		Where(sq.And{
+			sq.Eq{"cm.ChannelId": channelID},
+			sq.Eq{"cm.SchemeAdmin": true},
+			sq.Eq{"u.DeleteAt": 0},
+			sq.Eq{"b.UserId": nil},
+		}).
+		OrderBy("cm.UserId")
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching channel admins", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []string{}
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			ss.logger.Error("error scanning channel admins", "channel_id", channelID, "err", err)
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, rows.Err()
+}
+
+// IsChannelAbandoned returns true if a channel was created before the createdBefore timestamp and
+// has no posts made by users.
+func (ss *SQLStore) IsChannelAbandoned(channelID string, createdBefore int64) (bool, error) {
+	query := ss.builder.Select("COUNT(*)").
+		From("Channels AS ch").
+		Where(sq.Eq{"ch.Id": channelID}).
+		Where(sq.Lt{"ch.CreateAt": createdBefore}).
+		Where("NOT EXISTS (" + userPostsQuery + ")")
+
+	var count int
+	if err := query.QueryRow().Scan(&count); err != nil {
+		ss.logger.Error("error checking whether channel is abandoned", "channel_id", channelID, "err", err)
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// IsChannelOrphaned returns true if all members of a channel are deactivated users or bots, or if
+// the channel has no members at all.
+func (ss *SQLStore) IsChannelOrphaned(channelID string) (bool, error) {
+	query := ss.builder.Select("COUNT(*)").
+		From("Channels AS ch").
+		Where(sq.Eq{"ch.Id": channelID}).
+		Where("EXISTS (" + activeMembersQuery + ")")
+
+	var count int
+	if err := query.QueryRow().Scan(&count); err != nil {
+		ss.logger.Error("error checking whether channel is orphaned", "channel_id", channelID, "err", err)
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// ChannelMemberCounts holds the number of active, non-bot members and admins of a channel.
+type ChannelMemberCounts struct {
+	Members int
+	Admins  int
+}
+
+// GetChannelMemberCounts counts the active, non-bot members and admins of a channel, not counting
+// the user excludeUserID.
+func (ss *SQLStore) GetChannelMemberCounts(channelID string, excludeUserID string) (*ChannelMemberCounts, error) {
+	query := ss.builder.Select("COUNT(*)", "COALESCE(SUM(CASE WHEN cm.SchemeAdmin THEN 1 ELSE 0 END), 0)").
+		From("ChannelMembers as cm").
+		Join("Users as u ON u.Id=cm.UserId").
+		LeftJoin("Bots as b ON b.UserId=cm.UserId").
+		Where(sq.And{
+			sq.Eq{"cm.ChannelId": channelID},
+			sq.NotEq{"cm.UserId": excludeUserID},
+			sq.Eq{"u.DeleteAt": 0},
+			sq.Eq{"b.UserId": nil},
+		})
+
+	counts := &ChannelMemberCounts{}
+	if err := query.QueryRow().Scan(&counts.Members, &counts.Admins); err != nil {
+		ss.logger.Error("error counting channel members", "channel_id", channelID, "err", err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// GetChannelAdminSuccessor returns the active, non-bot member of a channel, other than the user
+// excludeUserID, best placed to take over as channel admin: the member with the most posts in the
+// channel if byActivity is true, or else the longest-standing member. It returns an empty string
+// if there is no such member.
+func (ss *SQLStore) GetChannelAdminSuccessor(channelID string, excludeUserID string, byActivity bool) (string, error) {
+	query := ss.builder.Select("cm.UserId").
+		From("ChannelMembers as cm").
+		Join("Users as u ON u.Id=cm.UserId").
+		LeftJoin("Bots as b ON b.UserId=cm.UserId").
+		Where(sq.And{
+			sq.Eq{"cm.ChannelId": channelID},
+			sq.NotEq{"cm.UserId": excludeUserID},
+			sq.Eq{"u.DeleteAt": 0},
+			sq.Eq{"b.UserId": nil},
+		}).
+		Limit(1)
+
+	if byActivity {
+		query = query.OrderBy(
+			"(SELECT COUNT(*) FROM Posts AS p WHERE p.ChannelId = cm.ChannelId AND p.UserId = cm.UserId AND p.DeleteAt = 0) DESC",
+			"cm.UserId",
+		)
+	} else {
+		// memberships predating the member history are ordered by account age
+		query = query.OrderBy(
+			"COALESCE((SELECT MIN(h.JoinTime) FROM ChannelMemberHistory AS h WHERE h.ChannelId = cm.ChannelId AND h.UserId = cm.UserId AND h.LeaveTime IS NULL), u.CreateAt)",
+			"cm.UserId",
+		)
+	}
+
+	var userID string
+	err := query.QueryRow().Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		ss.logger.Error("error fetching channel admin successor", "channel_id", channelID, "err", err)
+		return "", err
+	}
+	return userID, nil
+}
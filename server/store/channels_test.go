@@ -1,6 +1,7 @@
 package store
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -228,6 +229,154 @@ func TestSQLStore_GetStaleChannelsNone(t *testing.T) {
 	assert.Empty(t, staleChannels)
 }
 
+func TestSQLStore_GetChannelActivity(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(2, "activity-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	posts, err := th.CreatePosts(2, th.User1.Id, channels[0].Id)
+	require.NoError(t, err)
+	_, err = th.CreateReactions(posts, th.User1.Id)
+	require.NoError(t, err)
+
+	// channel 0 - posts a year old, reactions a week old (not stale)
+	SetTimestamps(t, th, "Channels", channels[0].Id, yearAgo, yearAgo, 0)
+	SetTimestamps(t, th, "Posts", channels[0].Id, yearAgo, yearAgo, 0)
+	SetTimestamps(t, th, "Reactions", channels[0].Id, weekAgo, weekAgo, 0)
+
+	// channel 1 - everything a year old (stale)
+	SetTimestamps(t, th, "Channels", channels[1].Id, yearAgo, yearAgo, 0)
+	SetTimestamps(t, th, "Posts", channels[1].Id, yearAgo, yearAgo, 0)
+
+	olderThan := model.GetMillisForTime(time.Now().AddDate(0, 0, -30))
+
+	activity, err := th.Store.GetChannelActivity(channels[0].Id)
+	require.NoError(t, err)
+	assert.Equal(t, yearAgo, activity.ChannelUpdateAt)
+	assert.Equal(t, yearAgo, activity.LastPostAt)
+	assert.Equal(t, weekAgo, activity.LastReactionAt)
+	assert.Equal(t, weekAgo, activity.LastActivityAt())
+	assert.False(t, activity.IsStale(olderThan))
+
+	activity, err = th.Store.GetChannelActivity(channels[1].Id)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), activity.LastReactionAt)
+	assert.Equal(t, yearAgo, activity.LastActivityAt())
+	assert.True(t, activity.IsStale(olderThan))
+}
+
+func TestSQLStore_GetChannelAdminIDs(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(1, "admins-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	users, err := th.CreateUsers(1, "admins-test-member")
+	require.NoError(t, err)
+	_, _, err = th.AdminClient.AddChannelMember(context.TODO(), channels[0].Id, users[0].Id)
+	require.NoError(t, err)
+
+	// the channel creator is the only channel admin
+	adminIDs, err := th.Store.GetChannelAdminIDs(channels[0].Id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{th.User1.Id}, adminIDs)
+}
+
+func TestSQLStore_OrphanedChannels(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(3, "orphaned-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	users, err := th.CreateUsers(2, "orphaned-test-member")
+	require.NoError(t, err)
+	for i, user := range users {
+		_, _, err = th.AdminClient.AddChannelMember(context.TODO(), channels[i].Id, user.Id)
+		require.NoError(t, err)
+	}
+
+	// channel 0 - the only remaining member is deactivated (orphaned)
+	_, err = th.AdminClient.RemoveUserFromChannel(context.TODO(), channels[0].Id, th.User1.Id)
+	require.NoError(t, err)
+	_, err = th.AdminClient.DeleteUser(context.TODO(), users[0].Id)
+	require.NoError(t, err)
+
+	// channel 1 - no members left (orphaned)
+	_, err = th.AdminClient.RemoveUserFromChannel(context.TODO(), channels[1].Id, th.User1.Id)
+	require.NoError(t, err)
+	_, err = th.AdminClient.RemoveUserFromChannel(context.TODO(), channels[1].Id, users[1].Id)
+	require.NoError(t, err)
+
+	// channel 2 - the creator is still an active member (not orphaned)
+
+	for _, channel := range channels {
+		SetTimestamps(t, th, "Channels", channel.Id, yearAgo, yearAgo, 0)
+	}
+
+	for i, expected := range []bool{true, true, false} {
+		orphaned, err := th.Store.IsChannelOrphaned(channels[i].Id)
+		require.NoError(t, err)
+		assert.Equal(t, expected, orphaned, "channel %d", i)
+	}
+
+	opts := StaleChannelOpts{
+		AgeInDays:                 30,
+		IncludeChannelTypeOpen:    true,
+		IncludeChannelTypePrivate: true,
+		OrphanedOnly:              true,
+	}
+	stale, _, err := th.Store.GetStaleChannels(opts, 0, 100)
+	require.NoError(t, err)
+
+	ids := extractChannelIDs(stale)
+	assert.Contains(t, ids, channels[0].Id)
+	assert.Contains(t, ids, channels[1].Id)
+	assert.NotContains(t, ids, channels[2].Id)
+}
+
+func TestSQLStore_AbandonedChannels(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(3, "abandoned-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// channel 0 - created a year ago, only system messages (abandoned)
+	SetTimestamps(t, th, "Channels", channels[0].Id, yearAgo, weekAgo, 0)
+
+	// channel 1 - created a year ago with a user post (not abandoned)
+	SetTimestamps(t, th, "Channels", channels[1].Id, yearAgo, weekAgo, 0)
+	_, err = th.CreatePosts(1, th.User1.Id, channels[1].Id)
+	require.NoError(t, err)
+
+	// channel 2 - created just now (not abandoned)
+
+	createdBefore := model.GetMillisForTime(time.Now().AddDate(0, 0, -14))
+	for i, expected := range []bool{true, false, false} {
+		abandoned, err := th.Store.IsChannelAbandoned(channels[i].Id, createdBefore)
+		require.NoError(t, err)
+		assert.Equal(t, expected, abandoned, "channel %d", i)
+	}
+
+	opts := StaleChannelOpts{
+		AgeInDays:                 14,
+		IncludeChannelTypeOpen:    true,
+		IncludeChannelTypePrivate: true,
+		AbandonedOnly:             true,
+	}
+	stale, _, err := th.Store.GetStaleChannels(opts, 0, 100)
+	require.NoError(t, err)
+
+	ids := extractChannelIDs(stale)
+	assert.Contains(t, ids, channels[0].Id)
+	assert.NotContains(t, ids, channels[1].Id)
+	assert.NotContains(t, ids, channels[2].Id)
+}
+
 func extractChannelIDs(channels []*model.Channel) []string {
 	ids := make([]string, 0, len(channels))
 	for _, ch := range channels {
@@ -235,3 +384,68 @@ func extractChannelIDs(channels []*model.Channel) []string {
 	}
 	return ids
 }
+
+func TestSQLStore_GetChannelMemberCounts(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	// the creator is the channel admin
+	channels, err := th.CreateChannels(1, "member-counts-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	users, err := th.CreateUsers(2, "member-counts-test-member")
+	require.NoError(t, err)
+	for _, user := range users {
+		_, _, err = th.AdminClient.AddChannelMember(context.TODO(), channels[0].Id, user.Id)
+		require.NoError(t, err)
+	}
+	_, err = th.AdminClient.DeleteUser(context.TODO(), users[1].Id)
+	require.NoError(t, err)
+
+	counts, err := th.Store.GetChannelMemberCounts(channels[0].Id, "")
+	require.NoError(t, err)
+	assert.Equal(t, &ChannelMemberCounts{Members: 2, Admins: 1}, counts)
+
+	// without the admin
+	counts, err = th.Store.GetChannelMemberCounts(channels[0].Id, th.User1.Id)
+	require.NoError(t, err)
+	assert.Equal(t, &ChannelMemberCounts{Members: 1, Admins: 0}, counts)
+}
+
+func TestSQLStore_GetChannelAdminSuccessor(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	// the creator is the channel admin
+	channels, err := th.CreateChannels(1, "successor-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// users join in order, the last one posts
+	users, err := th.CreateUsers(3, "successor-test-member")
+	require.NoError(t, err)
+	for _, user := range users {
+		_, _, err = th.AdminClient.AddChannelMember(context.TODO(), channels[0].Id, user.Id)
+		require.NoError(t, err)
+	}
+	_, err = th.CreatePosts(2, users[2].Id, channels[0].Id)
+	require.NoError(t, err)
+
+	// deactivated members are never picked
+	_, err = th.AdminClient.DeleteUser(context.TODO(), users[0].Id)
+	require.NoError(t, err)
+
+	successorID, err := th.Store.GetChannelAdminSuccessor(channels[0].Id, th.User1.Id, false)
+	require.NoError(t, err)
+	assert.Equal(t, users[1].Id, successorID)
+
+	successorID, err = th.Store.GetChannelAdminSuccessor(channels[0].Id, th.User1.Id, true)
+	require.NoError(t, err)
+	assert.Equal(t, users[2].Id, successorID)
+
+	// no one left
+	channels, err = th.CreateChannels(1, "successor-empty-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+	successorID, err = th.Store.GetChannelAdminSuccessor(channels[0].Id, th.User1.Id, false)
+	require.NoError(t, err)
+	assert.Empty(t, successorID)
+}
@@ -0,0 +1,129 @@
+package store
+
+import (
+	"fmt"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	IntegrationTypeIncomingWebhook = "incoming_webhook"
+	IntegrationTypeOutgoingWebhook = "outgoing_webhook"
+	IntegrationTypeSlashCommand    = "slash_command"
+)
+
+// integrationTables maps the integration types to their table and the column holding their creator.
+var integrationTables = map[string]struct {
+	table       string
+	ownerColumn string
+	nameColumn  string
+}{
+	IntegrationTypeIncomingWebhook: {"IncomingWebhooks", "UserId", "DisplayName"},
+	IntegrationTypeOutgoingWebhook: {"OutgoingWebhooks", "CreatorId", "DisplayName"},
+	IntegrationTypeSlashCommand:    {"Commands", "CreatorId", "Trigger"},
+}
+
+// Integration is a webhook or custom slash command created by a user.
+type Integration struct {
+	Type   string `json:"type"` // see IntegrationTypeIncomingWebhook
+	ID     string `json:"id"`
+	TeamID string `json:"team_id"`
+	Name   string `json:"name"` // display name of webhooks, trigger of slash commands
+}
+
+// GetUserAccessTokenIDs returns the IDs of the personal access tokens of a user, including the
+// disabled ones.
+func (ss *SQLStore) GetUserAccessTokenIDs(userID string) ([]string, error) {
+	rows, err := ss.builder.Select("Id").
+		From("UserAccessTokens").
+		Where(sq.Eq{"UserId": userID}).
+		OrderBy("Id").
+		Query()
+	if err != nil {
+		ss.logger.Error("error fetching user access tokens", "user_id", userID, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokenIDs := []string{}
+	for rows.Next() {
+		var tokenID string
+		if err := rows.Scan(&tokenID); err != nil {
+			ss.logger.Error("error scanning user access tokens", "user_id", userID, "err", err)
+			return nil, err
+		}
+		tokenIDs = append(tokenIDs, tokenID)
+	}
+	return tokenIDs, rows.Err()
+}
+
+// GetIntegrationsCreatedBy returns the webhooks and custom slash commands created by a user that
+// are not deleted. Slash commands registered by plugins are left out.
+func (ss *SQLStore) GetIntegrationsCreatedBy(userID string) ([]*Integration, error) {
+	integrations := []*Integration{}
+	for _, integrationType := range []string{IntegrationTypeIncomingWebhook, IntegrationTypeOutgoingWebhook, IntegrationTypeSlashCommand} {
+		t := integrationTables[integrationType]
+		query := ss.builder.Select("Id", "TeamId", t.nameColumn).
+			From(t.table).
+			Where(sq.Eq{t.ownerColumn: userID, "DeleteAt": 0}).
+			OrderBy("Id")
+		if integrationType == IntegrationTypeSlashCommand {
+			query = query.Where(sq.Eq{"PluginId": ""})
+		}
+
+		rows, err := query.Query()
+		if err != nil {
+			ss.logger.Error("error fetching integrations", "user_id", userID, "type", integrationType, "err", err)
+			return nil, err
+		}
+		for rows.Next() {
+			integration := &Integration{Type: integrationType}
+			if err := rows.Scan(&integration.ID, &integration.TeamID, &integration.Name); err != nil {
+				rows.Close()
+				ss.logger.Error("error scanning integrations", "user_id", userID, "type", integrationType, "err", err)
+				return nil, err
+			}
+			integrations = append(integrations, integration)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return integrations, nil
+}
+
+// ReassignIntegration makes another user the creator of a webhook or custom slash command.
+func (ss *SQLStore) ReassignIntegration(integration *Integration, ownerID string) error {
+	t, ok := integrationTables[integration.Type]
+	if !ok {
+		return fmt.Errorf("unknown integration type %s", integration.Type)
+	}
+
+	_, err := ss.builder.Update(t.table).
+		Set(t.ownerColumn, ownerID).
+		Set("UpdateAt", model.GetMillis()).
+		Where(sq.Eq{"Id": integration.ID}).
+		Exec()
+	if err != nil {
+		ss.logger.Error("error reassigning integration", "id", integration.ID, "type", integration.Type, "err", err)
+		return err
+	}
+	return nil
+}
+
+// ReassignBot makes another user the owner of a bot.
+func (ss *SQLStore) ReassignBot(botUserID string, ownerID string) error {
+	_, err := ss.builder.Update("Bots").
+		Set("OwnerId", ownerID).
+		Set("UpdateAt", model.GetMillis()).
+		Where(sq.Eq{"UserId": botUserID}).
+		Exec()
+	if err != nil {
+		ss.logger.Error("error reassigning bot", "bot_user_id", botUserID, "err", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestSQLStore_Integrations(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	ctx := context.TODO()
+	admin, _, err := th.AdminClient.GetMe(ctx, "")
+	require.NoError(t, err)
+	channels, err := th.CreateChannels(1, "hooks", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	incoming, _, err := th.AdminClient.CreateIncomingWebhook(ctx, &model.IncomingWebhook{
+		ChannelId:   channels[0].Id,
+		DisplayName: "alerts",
+	})
+	require.NoError(t, err)
+	command, _, err := th.AdminClient.CreateCommand(ctx, &model.Command{
+		TeamId:  th.Team1.Id,
+		Trigger: "deploy",
+		Method:  model.CommandMethodPost,
+		URL:     "http://localhost/deploy",
+	})
+	require.NoError(t, err)
+
+	integrations, err := th.Store.GetIntegrationsCreatedBy(admin.Id)
+	require.NoError(t, err)
+	require.Len(t, integrations, 2)
+	assert.Equal(t, &Integration{Type: IntegrationTypeIncomingWebhook, ID: incoming.Id, TeamID: th.Team1.Id, Name: "alerts"}, integrations[0])
+	assert.Equal(t, &Integration{Type: IntegrationTypeSlashCommand, ID: command.Id, TeamID: th.Team1.Id, Name: "deploy"}, integrations[1])
+
+	for _, integration := range integrations {
+		require.NoError(t, th.Store.ReassignIntegration(integration, th.User1.Id))
+	}
+
+	integrations, err = th.Store.GetIntegrationsCreatedBy(admin.Id)
+	require.NoError(t, err)
+	assert.Empty(t, integrations)
+
+	integrations, err = th.Store.GetIntegrationsCreatedBy(th.User1.Id)
+	require.NoError(t, err)
+	assert.Len(t, integrations, 2)
+
+	tokenIDs, err := th.Store.GetUserAccessTokenIDs(th.User1.Id)
+	require.NoError(t, err)
+	assert.Empty(t, tokenIDs)
+}
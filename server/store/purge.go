@@ -0,0 +1,313 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// purgeBatchSize is the number of posts, or of rows of other tables, deleted per transaction when
+// purging a channel.
+const purgeBatchSize = 1000
+
+// PurgeCounts holds the number of rows permanently deleted for a channel.
+type PurgeCounts struct {
+	Posts          int64 `json:"posts"`
+	Reactions      int64 `json:"reactions"`
+	FileInfos      int64 `json:"file_infos"`
+	Threads        int64 `json:"threads"`
+	Drafts         int64 `json:"drafts"`
+	Bookmarks      int64 `json:"bookmarks"`
+	ChannelMembers int64 `json:"channel_members"`
+
+	// FilePaths lists the files, thumbnails and previews of the deleted file info. The plugin API
+	// cannot delete files, so they are left in the file store for an admin to remove.
+	FilePaths []string `json:"file_paths,omitempty"`
+}
+
+// Add accumulates the counts of other into pc.
+func (pc *PurgeCounts) Add(other *PurgeCounts) {
+	pc.Posts += other.Posts
+	pc.Reactions += other.Reactions
+	pc.FileInfos += other.FileInfos
+	pc.Threads += other.Threads
+	pc.Drafts += other.Drafts
+	pc.Bookmarks += other.Bookmarks
+	pc.ChannelMembers += other.ChannelMembers
+	pc.FilePaths = append(pc.FilePaths, other.FilePaths...)
+}
+
+// GetArchivedChannels returns up to limit public and private channels that were archived before
+// the archivedBefore timestamp, oldest first.
+func (ss *SQLStore) GetArchivedChannels(archivedBefore int64, excludeChannels []string, limit int) ([]*model.Channel, error) {
+	query := ss.builder.Select("Id", "Name", "DisplayName", "TeamId", "Type", "DeleteAt").
+		From("Channels").
+		Where(sq.And{
+			sq.Gt{"DeleteAt": 0},
+			sq.Lt{"DeleteAt": archivedBefore},
+			sq.Eq{"Type": []string{string(model.ChannelTypeOpen), string(model.ChannelTypePrivate)}},
+		}).
+		OrderBy("DeleteAt", "Id")
+
+	if len(excludeChannels) > 0 {
+		query = query.Where(sq.NotEq{"Id": excludeChannels})
+	}
+
+	if limit > 0 {
+		query = query.Limit(uint64(limit)) //nolint:gosec // limit is validated to be positive
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching archived channels", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	channels := []*model.Channel{}
+	for rows.Next() {
+		channel := &model.Channel{}
+		if err := rows.Scan(&channel.Id, &channel.Name, &channel.DisplayName, &channel.TeamId, &channel.Type, &channel.DeleteAt); err != nil {
+			ss.logger.Error("error scanning archived channels", "err", err)
+			return nil, err
+		}
+		channels = append(channels, channel)
+	}
+	return channels, rows.Err()
+}
+
+// PurgeChannel permanently deletes an archived channel including its posts, reactions, file
+// info, threads, drafts, bookmarks and memberships. Channels that are not archived are left
+// untouched. Rows are deleted in batches of purgeBatchSize, each in its own transaction, so that
+// large channels do not lock the tables for long; a channel unarchived meanwhile is not purged
+// further. The channel itself goes last, so that a purge that fails halfway is finished by the
+// next one. The rows are deleted directly from the
+// database, so the files themselves stay in the file store, see PurgeCounts.FilePaths, and the
+// server caches are not invalidated.
+func (ss *SQLStore) PurgeChannel(channelID string) (*PurgeCounts, error) {
+	// make sure the channel is still archived before deleting anything
+	if err := checkArchived(ss.builder, channelID); err != nil {
+		return nil, err
+	}
+
+	counts := &PurgeCounts{FilePaths: []string{}}
+
+	// posts are deleted along with the rows referencing them
+	for {
+		postIDs, err := selectBatch(ss.builder, "Posts", "Id", sq.Eq{"ChannelId": channelID})
+		if err != nil {
+			ss.logger.Error("error fetching posts of channel", "channel_id", channelID, "err", err)
+			return nil, err
+		}
+		if len(postIDs) == 0 {
+			break
+		}
+
+		err = ss.purgeTx(channelID, func(builder sq.StatementBuilderType) error {
+			byPost := sq.Eq{"PostId": postIDs}
+			return execPurgeSteps(builder, counts, []purgeStep{
+				{table: "Reactions", where: byPost, count: &counts.Reactions},
+				{table: "FileInfo", where: byPost, count: &counts.FileInfos, filePaths: true},
+				{table: "ThreadMemberships", where: byPost},
+				{table: "Threads", where: byPost, count: &counts.Threads},
+				{table: "Posts", where: sq.Eq{"Id": postIDs}, count: &counts.Posts},
+			})
+		})
+		if err != nil {
+			ss.logger.Error("error purging posts of channel", "channel_id", channelID, "err", err)
+			return nil, err
+		}
+	}
+
+	byChannel := sq.Eq{"ChannelId": channelID}
+	steps := []struct {
+		purgeStep
+		key string // column selecting the rows of a batch
+	}{
+		// files uploaded to the channel but never posted, and the files of bookmarks
+		{purgeStep{table: "FileInfo", where: byChannel, count: &counts.FileInfos, filePaths: true}, "Id"},
+		{purgeStep{table: "Drafts", where: byChannel, count: &counts.Drafts}, "UserId"},
+		{purgeStep{table: "ChannelBookmarks", where: byChannel, count: &counts.Bookmarks}, "Id"},
+		{purgeStep{table: "ChannelMembers", where: byChannel, count: &counts.ChannelMembers}, "UserId"},
+		{purgeStep{table: "ChannelMemberHistory", where: byChannel}, "UserId"},
+		{purgeStep{table: "SidebarChannels", where: byChannel}, "CategoryId"},
+	}
+
+	for _, step := range steps {
+		// channel bookmarks were added in server v9.5
+		if step.table == "ChannelBookmarks" {
+			exists, err := ss.tableExists(step.table)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				continue
+			}
+		}
+
+		for {
+			keys, err := selectBatch(ss.builder, step.table, step.key, step.where)
+			if err != nil {
+				ss.logger.Error("error fetching rows of channel", "channel_id", channelID, "table", step.table, "err", err)
+				return nil, err
+			}
+			if len(keys) == 0 {
+				break
+			}
+
+			batch := step.purgeStep
+			batch.where = sq.And{step.where, sq.Eq{step.key: keys}}
+			err = ss.purgeTx(channelID, func(builder sq.StatementBuilderType) error {
+				return execPurgeSteps(builder, counts, []purgeStep{batch})
+			})
+			if err != nil {
+				ss.logger.Error("error purging channel", "channel_id", channelID, "table", step.table, "err", err)
+				return nil, err
+			}
+		}
+	}
+
+	err := ss.purgeTx(channelID, func(builder sq.StatementBuilderType) error {
+		return execPurgeSteps(builder, counts, []purgeStep{{table: "Channels", where: sq.Eq{"Id": channelID}}})
+	})
+	if err != nil {
+		ss.logger.Error("error purging channel", "channel_id", channelID, "table", "Channels", "err", err)
+		return nil, err
+	}
+	return counts, nil
+}
+
+// purgeStep deletes the rows of a table, adding their number to count unless nil.
+type purgeStep struct {
+	table     string
+	where     sq.Sqlizer
+	count     *int64
+	filePaths bool // the paths of the deleted file info are added to PurgeCounts.FilePaths
+}
+
+func execPurgeSteps(builder sq.StatementBuilderType, counts *PurgeCounts, steps []purgeStep) error {
+	for _, step := range steps {
+		if step.filePaths {
+			paths, err := getFilePaths(builder, step.where)
+			if err != nil {
+				return err
+			}
+			counts.FilePaths = append(counts.FilePaths, paths...)
+		}
+
+		affected, err := execDelete(builder, step.table, step.where)
+		if err != nil {
+			return err
+		}
+		if step.count != nil {
+			*step.count += affected
+		}
+	}
+	return nil
+}
+
+// purgeTx runs fn in a transaction, once it has checked that the channel is still archived.
+func (ss *SQLStore) purgeTx(channelID string, fn func(builder sq.StatementBuilderType) error) (retErr error) {
+	tx, err := ss.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			if err := tx.Rollback(); err != nil {
+				ss.logger.Error("error rolling back channel purge", "channel_id", channelID, "err", err)
+			}
+		}
+	}()
+
+	builder := ss.builder.RunWith(tx)
+	if err := checkArchived(builder, channelID); err != nil {
+		return err
+	}
+	if err := fn(builder); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkArchived returns ErrChannelNotArchived if the channel is not archived.
+func checkArchived(builder sq.StatementBuilderType, channelID string) error {
+	var deleteAt int64
+	if err := builder.Select("DeleteAt").From("Channels").Where(sq.Eq{"Id": channelID}).QueryRow().Scan(&deleteAt); err != nil {
+		return err
+	}
+	if deleteAt == 0 {
+		return ErrChannelNotArchived
+	}
+	return nil
+}
+
+// selectBatch returns up to purgeBatchSize distinct values of the key column of the matching rows.
+func selectBatch(builder sq.StatementBuilderType, table string, key string, where sq.Sqlizer) ([]string, error) {
+	rows, err := builder.Select(key).Distinct().From(table).Where(where).Limit(purgeBatchSize).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []string{}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// tableExists reports whether a table exists, for tables added by recent server versions.
+func (ss *SQLStore) tableExists(table string) (bool, error) {
+	query := ss.builder.Select("COUNT(*)").From("information_schema.tables")
+	if ss.db.DriverName() == model.DatabaseDriverPostgres {
+		// unquoted identifiers are lower case in Postgres
+		query = query.Where("table_schema = current_schema()").Where(sq.Eq{"table_name": strings.ToLower(table)})
+	} else {
+		query = query.Where("table_schema = DATABASE()").Where(sq.Eq{"table_name": table})
+	}
+
+	var count int
+	if err := query.QueryRow().Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// getFilePaths returns the non-empty file, thumbnail and preview paths of the matching file info.
+func getFilePaths(builder sq.StatementBuilderType, where sq.Sqlizer) ([]string, error) {
+	rows, err := builder.Select("Path", "ThumbnailPath", "PreviewPath").From("FileInfo").Where(where).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths := []string{}
+	for rows.Next() {
+		var path, thumbnailPath, previewPath sql.NullString
+		if err := rows.Scan(&path, &thumbnailPath, &previewPath); err != nil {
+			return nil, err
+		}
+		for _, p := range []sql.NullString{path, thumbnailPath, previewPath} {
+			if p.String != "" {
+				paths = append(paths, p.String)
+			}
+		}
+	}
+	return paths, rows.Err()
+}
+
+func execDelete(builder sq.StatementBuilderType, table string, where sq.Sqlizer) (int64, error) {
+	result, err := builder.Delete(table).Where(where).Exec()
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestSQLStore_GetArchivedChannels(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(4, "archived-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	// channels 0,1 archived a year ago, channel 2 archived a week ago, channel 3 not archived
+	SetTimestamps(t, th, "Channels", channels[0].Id, yearAgo, yearAgo, yearAgo)
+	SetTimestamps(t, th, "Channels", channels[1].Id, yearAgo, yearAgo, yearAgo+1)
+	SetTimestamps(t, th, "Channels", channels[2].Id, yearAgo, weekAgo, weekAgo)
+
+	archived, err := th.Store.GetArchivedChannels(weekAgo-1, nil, 0)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{channels[0].Id, channels[1].Id}, extractChannelIDs(archived))
+
+	// oldest first, limited
+	archived, err = th.Store.GetArchivedChannels(weekAgo-1, nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{channels[0].Id}, extractChannelIDs(archived))
+
+	// excluded
+	archived, err = th.Store.GetArchivedChannels(weekAgo-1, []string{channels[0].Id}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{channels[1].Id}, extractChannelIDs(archived))
+}
+
+func TestSQLStore_PurgeChannel(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	channels, err := th.CreateChannels(2, "purge-test", th.User1.Id, th.Team1.Id)
+	require.NoError(t, err)
+
+	for _, ch := range channels {
+		posts, err := th.CreatePosts(3, th.User1.Id, ch.Id)
+		require.NoError(t, err)
+		_, err = th.CreateReactions(posts, th.User1.Id)
+		require.NoError(t, err)
+	}
+
+	// a file attached to a post, whose blob stays in the file store
+	upload, _, err := th.UserClient.UploadFile(context.TODO(), []byte("report"), channels[0].Id, "report.txt")
+	require.NoError(t, err)
+	require.Len(t, upload.FileInfos, 1)
+	_, _, err = th.UserClient.CreatePost(context.TODO(), &model.Post{ChannelId: channels[0].Id, Message: "report", FileIds: []string{upload.FileInfos[0].Id}})
+	require.NoError(t, err)
+
+	// a file uploaded but never posted, and a draft
+	unposted, _, err := th.UserClient.UploadFile(context.TODO(), []byte("notes"), channels[0].Id, "notes.txt")
+	require.NoError(t, err)
+	require.Len(t, unposted.FileInfos, 1)
+	_, _, err = th.UserClient.UpsertDraft(context.TODO(), &model.Draft{UserId: th.User1.Id, ChannelId: channels[0].Id, Message: "unsent"})
+	require.NoError(t, err)
+
+	// channels that are not archived cannot be purged
+	_, err = th.Store.PurgeChannel(channels[0].Id)
+	require.ErrorIs(t, err, ErrChannelNotArchived)
+
+	SetTimestamps(t, th, "Channels", channels[0].Id, yearAgo, yearAgo, yearAgo)
+
+	counts, err := th.Store.PurgeChannel(channels[0].Id)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, counts.Posts, int64(3))
+	assert.Equal(t, int64(3), counts.Reactions)
+	assert.Equal(t, int64(1), counts.ChannelMembers)
+	assert.Equal(t, int64(2), counts.FileInfos)
+	assert.Equal(t, int64(1), counts.Drafts)
+	assert.Contains(t, counts.FilePaths, upload.FileInfos[0].Path)
+	assert.Contains(t, counts.FilePaths, unposted.FileInfos[0].Path)
+
+	activity, err := th.Store.GetChannelActivity(channels[0].Id)
+	require.Error(t, err, "purged channel should no longer exist")
+	assert.Nil(t, activity)
+
+	// other channels are untouched
+	activity, err = th.Store.GetChannelActivity(channels[1].Id)
+	require.NoError(t, err)
+	assert.NotZero(t, activity.LastReactionAt)
+}
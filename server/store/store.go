@@ -2,6 +2,7 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 
 	// Load the Postgres driver
 	_ "github.com/lib/pq"
@@ -10,6 +11,12 @@ import (
 	"github.com/jmoiron/sqlx"
 
 	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/metrics"
+)
+
+var (
+	ErrChannelNotArchived = errors.New("channel is not archived")
 )
 
 type SQLStoreSource interface {
@@ -28,6 +35,7 @@ type SQLStore struct {
 	db      *sqlx.DB
 	builder sq.StatementBuilderType
 	logger  Logger
+	metrics *metrics.Metrics
 }
 
 // New constructs a new instance of SQLStore.
@@ -52,8 +60,13 @@ func New(src SQLStoreSource, logger Logger) (*SQLStore, error) {
 	builder = builder.RunWith(db)
 
 	return &SQLStore{
-		db,
-		builder,
-		logger,
+		db:      db,
+		builder: builder,
+		logger:  logger,
 	}, nil
 }
+
+// SetMetrics sets the metrics recording query durations.
+func (ss *SQLStore) SetMetrics(m *metrics.Metrics) {
+	ss.metrics = m
+}
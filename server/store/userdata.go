@@ -0,0 +1,101 @@
+package store
+
+import (
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// UserDataCounts holds the number of rows changed when cleaning up the data of a removed user.
+type UserDataCounts struct {
+	HiddenChannels    int64 `json:"hidden_channels"` // direct and group messages hidden, counted once per other participant
+	Preferences       int64 `json:"preferences"`
+	SidebarCategories int64 `json:"sidebar_categories"`
+	SidebarChannels   int64 `json:"sidebar_channels"` // channels in the user's sidebar categories, including favorites
+	ThreadMemberships int64 `json:"thread_memberships"`
+}
+
+// DirectChannelParticipant is another member of a direct or group message channel of a user.
+type DirectChannelParticipant struct {
+	UserID      string
+	ChannelID   string
+	ChannelType model.ChannelType
+}
+
+// GetDirectChannelParticipants returns the other members of the direct and group message channels
+// of a user, once per channel.
+func (ss *SQLStore) GetDirectChannelParticipants(userID string) ([]DirectChannelParticipant, error) {
+	rows, err := ss.builder.Select("cm.UserId", "c.Id", "c.Type").
+		From("ChannelMembers cm").
+		Join("Channels c ON c.Id = cm.ChannelId").
+		Where(sq.And{
+			sq.Eq{"c.Type": []string{string(model.ChannelTypeDirect), string(model.ChannelTypeGroup)}},
+			sq.Expr("cm.ChannelId IN (SELECT ChannelId FROM ChannelMembers WHERE UserId = ?)", userID),
+			sq.NotEq{"cm.UserId": userID},
+		}).
+		OrderBy("cm.UserId", "c.Id").
+		Query()
+	if err != nil {
+		ss.logger.Error("error fetching direct channel participants", "user_id", userID, "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	participants := []DirectChannelParticipant{}
+	for rows.Next() {
+		var participant DirectChannelParticipant
+		if err := rows.Scan(&participant.UserID, &participant.ChannelID, &participant.ChannelType); err != nil {
+			ss.logger.Error("error scanning direct channel participants", "user_id", userID, "err", err)
+			return nil, err
+		}
+		participants = append(participants, participant)
+	}
+	return participants, rows.Err()
+}
+
+// CleanupUserData permanently deletes the preferences, sidebar categories and thread memberships of
+// a user in a single transaction. Posts and channels are left untouched. The rows are deleted
+// directly from the database, so the server may serve the user's cached preferences and sidebar
+// until its caches expire; deactivated users cannot log in to see them. HiddenChannels is left to
+// the caller, which hides direct messages through the plugin API.
+func (ss *SQLStore) CleanupUserData(userID string) (counts *UserDataCounts, retErr error) {
+	tx, err := ss.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if retErr != nil {
+			if err := tx.Rollback(); err != nil {
+				ss.logger.Error("error rolling back user data cleanup", "user_id", userID, "err", err)
+			}
+		}
+	}()
+
+	builder := ss.builder.RunWith(tx)
+	counts = &UserDataCounts{}
+
+	byUser := sq.Eq{"UserId": userID}
+	steps := []struct {
+		table string
+		count *int64
+	}{
+		{"Preferences", &counts.Preferences},
+		{"SidebarChannels", &counts.SidebarChannels},
+		{"SidebarCategories", &counts.SidebarCategories},
+		{"ThreadMemberships", &counts.ThreadMemberships},
+	}
+
+	for _, step := range steps {
+		affected, err := execDelete(builder, step.table, byUser)
+		if err != nil {
+			ss.logger.Error("error cleaning up user data", "user_id", userID, "table", step.table, "err", err)
+			return nil, err
+		}
+		*step.count = affected
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestSQLStore_CleanupUserData(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	ctx := context.TODO()
+	others, err := th.CreateUsers(2, "cleanup.user")
+	require.NoError(t, err)
+	direct, _, err := th.UserClient.CreateDirectChannel(ctx, th.User1.Id, others[0].Id)
+	require.NoError(t, err)
+	group, _, err := th.UserClient.CreateGroupChannel(ctx, []string{th.User1.Id, others[0].Id, others[1].Id})
+	require.NoError(t, err)
+
+	participants, err := th.Store.GetDirectChannelParticipants(th.User1.Id)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []DirectChannelParticipant{
+		{UserID: others[0].Id, ChannelID: direct.Id, ChannelType: model.ChannelTypeDirect},
+		{UserID: others[0].Id, ChannelID: group.Id, ChannelType: model.ChannelTypeGroup},
+		{UserID: others[1].Id, ChannelID: group.Id, ChannelType: model.ChannelTypeGroup},
+	}, participants)
+
+	counts, err := th.Store.CleanupUserData(th.User1.Id)
+	require.NoError(t, err)
+	assert.Positive(t, counts.Preferences)
+	assert.Positive(t, counts.SidebarCategories)
+
+	// nothing is left to delete
+	counts, err = th.Store.CleanupUserData(th.User1.Id)
+	require.NoError(t, err)
+	assert.Equal(t, &UserDataCounts{}, counts)
+
+	// the other participants keep their data
+	prefs, _, err := th.AdminClient.GetPreferences(ctx, others[0].Id)
+	require.NoError(t, err)
+	assert.NotEmpty(t, prefs)
+}
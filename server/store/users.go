@@ -0,0 +1,117 @@
+package store
+
+import (
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// DeactivatedUserOpts selects the deactivated users still holding team or channel memberships.
+type DeactivatedUserOpts struct {
+	DeactivatedBefore int64    // only users deactivated before this time (millis)
+	ExcludeTeamIDs    []string // memberships of these teams and their channels are ignored
+}
+
+// GetDeactivatedUsersWithMemberships returns up to limit deactivated users, excluding bots, who are
+// still members of a team or of an active channel of a team. Users are ordered by ID, starting after
+// the user with ID afterID, so that results can be paged through while memberships are removed.
+func (ss *SQLStore) GetDeactivatedUsersWithMemberships(opts DeactivatedUserOpts, afterID string, limit int) ([]*model.User, error) {
+	teamMembers := sq.Select("1").
+		From("TeamMembers AS tm").
+		Where("tm.UserId = u.Id").
+		Where(sq.Eq{"tm.DeleteAt": 0})
+
+	channelMembers := sq.Select("1").
+		From("ChannelMembers AS cm").
+		Join("Channels AS ch ON ch.Id = cm.ChannelId").
+		Where("cm.UserId = u.Id").
+		Where(sq.Eq{
+			"ch.DeleteAt": 0,
+			"ch.Type":     []string{string(model.ChannelTypeOpen), string(model.ChannelTypePrivate)},
+		})
+
+	if len(opts.ExcludeTeamIDs) > 0 {
+		teamMembers = teamMembers.Where(sq.NotEq{"tm.TeamId": opts.ExcludeTeamIDs})
+		channelMembers = channelMembers.Where(sq.NotEq{"ch.TeamId": opts.ExcludeTeamIDs})
+	}
+
+	query := ss.builder.Select("u.Id", "u.Username", "u.DeleteAt").
+		From("Users AS u").
+		LeftJoin("Bots AS b ON b.UserId = u.Id").
+		Where(sq.And{
+			sq.Gt{"u.DeleteAt": 0},
+			sq.Lt{"u.DeleteAt": opts.DeactivatedBefore},
+			sq.Gt{"u.Id": afterID},
+			sq.Expr("b.UserId IS NULL"),
+			sq.Or{
+				sq.Expr("EXISTS (?)", teamMembers),
+				sq.Expr("EXISTS (?)", channelMembers),
+			},
+		}).
+		OrderBy("u.Id")
+
+	if limit > 0 {
+		query = query.Limit(uint64(limit)) //nolint:gosec // limit is validated to be positive
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error fetching deactivated users", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	for rows.Next() {
+		user := &model.User{}
+		if err := rows.Scan(&user.Id, &user.Username, &user.DeleteAt); err != nil {
+			ss.logger.Error("error scanning deactivated users", "err", err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// SearchDeactivatedUsers returns up to limit deactivated users, excluding bots, whose username starts
+// with prefix, ordered by username.
+func (ss *SQLStore) SearchDeactivatedUsers(prefix string, limit int) ([]*model.User, error) {
+	query := ss.builder.Select("u.Id", "u.Username", "u.DeleteAt").
+		From("Users AS u").
+		LeftJoin("Bots AS b ON b.UserId = u.Id").
+		Where(sq.And{
+			sq.Gt{"u.DeleteAt": 0},
+			sq.Expr("b.UserId IS NULL"),
+			sq.Like{"u.Username": escapeLike(strings.ToLower(prefix)) + "%"},
+		}).
+		OrderBy("u.Username")
+
+	if limit > 0 {
+		query = query.Limit(uint64(limit)) //nolint:gosec // limit is validated to be positive
+	}
+
+	rows, err := query.Query()
+	if err != nil {
+		ss.logger.Error("error searching deactivated users", "err", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*model.User{}
+	for rows.Next() {
+		user := &model.User{}
+		if err := rows.Scan(&user.Id, &user.Username, &user.DeleteAt); err != nil {
+			ss.logger.Error("error scanning deactivated users", "err", err)
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in a search term, using the default escape character.
+func escapeLike(term string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+}
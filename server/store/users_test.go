@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestSQLStore_GetDeactivatedUsersWithMemberships(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	ctx := context.TODO()
+	users, err := th.CreateUsers(3, "deactivated.user")
+	require.NoError(t, err)
+
+	// users 0 and 1 deactivated, user 1 without any memberships left, user 2 active
+	for _, user := range users[:2] {
+		_, err = th.AdminClient.DeleteUser(ctx, user.Id)
+		require.NoError(t, err)
+	}
+	for _, team := range []*model.Team{th.Team1, th.Team2} {
+		_, err = th.AdminClient.RemoveTeamMember(ctx, team.Id, users[1].Id)
+		require.NoError(t, err)
+	}
+
+	opts := DeactivatedUserOpts{DeactivatedBefore: model.GetMillis() + 1000}
+	found, err := th.Store.GetDeactivatedUsersWithMemberships(opts, "", 10)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, users[0].Id, found[0].Id)
+	assert.Equal(t, users[0].Username, found[0].Username)
+
+	// paged past the user
+	found, err = th.Store.GetDeactivatedUsersWithMemberships(opts, users[0].Id, 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// deactivated too recently
+	found, err = th.Store.GetDeactivatedUsersWithMemberships(DeactivatedUserOpts{DeactivatedBefore: yearAgo}, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	// memberships of excluded teams are ignored
+	opts.ExcludeTeamIDs = []string{th.Team1.Id, th.Team2.Id}
+	found, err = th.Store.GetDeactivatedUsersWithMemberships(opts, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
+
+func TestSQLStore_SearchDeactivatedUsers(t *testing.T) {
+	th := SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	ctx := context.TODO()
+	users, err := th.CreateUsers(3, "search.user")
+	require.NoError(t, err)
+
+	// users 0 and 1 deactivated, user 2 active
+	for _, user := range users[:2] {
+		_, err = th.AdminClient.DeleteUser(ctx, user.Id)
+		require.NoError(t, err)
+	}
+
+	found, err := th.Store.SearchDeactivatedUsers("Search.", 10)
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, users[0].Username, found[0].Username)
+	assert.Equal(t, users[1].Username, found[1].Username)
+
+	found, err = th.Store.SearchDeactivatedUsers("search.", 1)
+	require.NoError(t, err)
+	assert.Len(t, found, 1)
+
+	// wildcards are matched literally
+	found, err = th.Store.SearchDeactivatedUsers("search%", 10)
+	require.NoError(t, err)
+	assert.Empty(t, found)
+}
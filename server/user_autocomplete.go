@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const maxDeactivatedUserSuggestions = 25
+
+// handleDeactivatedUsersAutocomplete suggests the deactivated users whose username starts with the
+// last word of the command being typed.
+func (p *Plugin) handleDeactivatedUsersAutocomplete(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	prefix := ""
+	userInput := r.URL.Query().Get("user_input")
+	if fields := strings.Fields(userInput); len(fields) > 0 && !strings.HasSuffix(userInput, " ") {
+		prefix = strings.TrimPrefix(fields[len(fields)-1], "@")
+	}
+
+	found, err := p.SQLStore.SearchDeactivatedUsers(prefix, maxDeactivatedUserSuggestions)
+	if err != nil {
+		writeError(w, "error searching deactivated users", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]model.AutocompleteListItem, 0, len(found))
+	for _, user := range found {
+		items = append(items, model.AutocompleteListItem{
+			Item:     "@" + user.Username,
+			HelpText: "Deactivated " + model.GetTimeForMillis(user.DeleteAt).Format("2006-01-02"),
+		})
+	}
+	writeJSON(w, http.StatusOK, items)
+}
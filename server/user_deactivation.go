@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+// UserHasBeenDeactivated schedules the removal of a deactivated user from all teams and channels when
+// the deactivation cleanup is enabled. This includes users deactivated by LDAP or SAML sync.
+func (p *Plugin) UserHasBeenDeactivated(_ *plugin.Context, user *model.User) {
+	cfg := p.getConfiguration()
+	if !cfg.EnableDeactivationCleanup || user.IsBot {
+		return
+	}
+
+	now := time.Now()
+	pending := &users.PendingRemoval{
+		UserID:        user.Id,
+		DeactivatedAt: model.GetMillisForTime(now),
+		RemoveAt:      model.GetMillisForTime(now.Add(time.Duration(cfg.DeactivationCleanupDelayHours) * time.Hour)),
+	}
+	if err := users.SavePendingRemoval(p.Client, pending); err != nil {
+		p.API.LogError("Cannot schedule removal of deactivated user", "user_id", user.Id, "err", err.Error())
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+func TestUserHasBeenDeactivated(t *testing.T) {
+	setup := func(cfg *config.Configuration) (*Plugin, *plugintest.API) {
+		api := &plugintest.API{}
+		p := &Plugin{}
+		p.SetAPI(api)
+		p.Client = pluginapi.NewClient(api, nil)
+		p.setConfiguration(cfg)
+		return p, api
+	}
+
+	user := &model.User{Id: model.NewId(), Username: "alice", DeleteAt: model.GetMillis()}
+
+	t.Run("disabled", func(t *testing.T) {
+		p, api := setup(&config.Configuration{})
+		p.UserHasBeenDeactivated(nil, user)
+		api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("bots are not removed", func(t *testing.T) {
+		p, api := setup(&config.Configuration{EnableDeactivationCleanup: true})
+		p.UserHasBeenDeactivated(nil, &model.User{Id: model.NewId(), IsBot: true})
+		api.AssertNotCalled(t, "KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("removal scheduled after the delay", func(t *testing.T) {
+		p, api := setup(&config.Configuration{EnableDeactivationCleanup: true, DeactivationCleanupDelayHours: 24})
+
+		var saved users.PendingRemoval
+		api.On("KVSetWithOptions", "deact_"+user.Id, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			require.NoError(t, json.Unmarshal(args.Get(1).([]byte), &saved))
+		}).Return(true, nil)
+
+		p.UserHasBeenDeactivated(nil, user)
+
+		api.AssertExpectations(t)
+		assert.Equal(t, user.Id, saved.UserID)
+		assert.Equal(t, int64(24*60*60*1000), saved.RemoveAt-saved.DeactivatedAt)
+	})
+}
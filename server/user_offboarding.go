@@ -0,0 +1,134 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+// handleOffboardUser starts offboarding a user in the background, responding with the new offboarding.
+func (p *Plugin) handleOffboardUser(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	requesterID, ok := p.requireSystemAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	payload, user, err := p.readRemoveUserPayload(r)
+	if err != nil {
+		err = errors.Wrap(err, "error processing request")
+		p.API.LogError(err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	managerID := ""
+	if payload.Manager != "" {
+		manager, err := p.userRemover().FindUser(payload.Manager)
+		if err != nil {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		managerID = manager.Id
+	}
+
+	opts, err := users.NewOffboardOpts(p.getConfiguration(), p.bot, p.i18n)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	offboarding, err := p.offboarder.Start(user, requesterID, managerID, opts)
+	if err != nil {
+		writeError(w, err.Error(), offboardingErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, offboarding)
+}
+
+func (p *Plugin) handleGetOffboarding(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeError(w, "missing user_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	offboarding, err := p.offboarder.Get(userID)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if offboarding == nil {
+		writeError(w, users.ErrNoOffboarding.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, offboarding)
+}
+
+// handleResumeOffboarding runs the failed step of an offboarding again, along with the steps after it.
+func (p *Plugin) handleResumeOffboarding(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	_, user, err := p.readRemoveUserPayload(r)
+	if err != nil {
+		err = errors.Wrap(err, "error processing request")
+		p.API.LogError(err.Error())
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	opts, err := users.NewOffboardOpts(p.getConfiguration(), p.bot, p.i18n)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	offboarding, err := p.offboarder.Resume(user.Id, opts)
+	if err != nil {
+		writeError(w, err.Error(), offboardingErrorStatus(err))
+		return
+	}
+	writeJSON(w, http.StatusAccepted, offboarding)
+}
+
+func offboardingErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, users.ErrOffboardingInProgress), errors.Is(err, users.ErrOffboardingNotFailed):
+		return http.StatusConflict
+	case errors.Is(err, users.ErrNoOffboarding):
+		return http.StatusNotFound
+	case errors.Is(err, users.ErrOffboardingBot):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// resumeOffboardings resumes the offboardings interrupted by a plugin restart.
+func (p *Plugin) resumeOffboardings() {
+	opts, err := users.NewOffboardOpts(p.getConfiguration(), p.bot, p.i18n)
+	if err != nil {
+		p.API.LogError("Cannot resume offboardings.", "err", err.Error())
+		return
+	}
+	p.offboarder.ResumeInterrupted(opts)
+}
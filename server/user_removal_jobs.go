@@ -0,0 +1,826 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/kvstore"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+const (
+	maxUserRemovalIdentifiers = 10000
+	maxUserRemovalUploadSize  = 10 << 20 // 10 MB
+
+	userRemovalJobKeyPrefix     = "rmjob_"   // job status, without the results
+	userRemovalUsersKeyPrefix   = "rmusers_" // users of a job, kept until the job completes
+	userRemovalResultsKeyPrefix = "rmres_"   // results of a job, in chunks of userRemovalResultsPerChunk
+	userRemovalResultsPerChunk  = 100
+	userRemovalBulkLockKey      = "user_removal_bulk" // held while a bulk job runs, so that one runs at a time across the cluster
+	userRemovalJobRetention     = 7 * 24 * time.Hour
+	userRemovalResumeTimeout    = 30 * time.Second // longer than the lock expiry, for locks left by a previous plugin instance
+
+	UserRemovalJobTypeSingle = "single" // a user removed via the single user endpoint
+	UserRemovalJobTypeBulk   = "bulk"
+
+	UserRemovalJobStatusRunning   = "running"
+	UserRemovalJobStatusCompleted = "completed"
+
+	UserRemovalStatusSuccess = "success" // removed from all teams and channels
+	UserRemovalStatusPartial = "partial" // removed from some teams or channels, but not all
+	UserRemovalStatusFailed  = "failed"  // not found, or not removed from any team or channel
+)
+
+var errUserRemovalJobInProgress = errors.New("is already in progress")
+
+// csvHeaders are the first-column values recognized as a CSV header row.
+var csvHeaders = []string{"id", "user", "user_id", "username", "email"}
+
+type BulkUserRemovalPayload struct {
+	Users []string      `json:"users"`          // user IDs, usernames or emails
+	Keep  *KeepOverride `json:"keep,omitempty"` // teams and channels the users stay in
+}
+
+// UserRemovalResult is the outcome of removing a single user from all teams and channels.
+type UserRemovalResult struct {
+	Identifier string                      `json:"identifier"`
+	UserID     string                      `json:"user_id,omitempty"`
+	Username   string                      `json:"username,omitempty"`
+	Status     string                      `json:"status"`
+	TeamIDs    []string                    `json:"team_ids"`    // teams the user was removed from
+	ChannelIDs []string                    `json:"channel_ids"` // channels the user was removed from
+	Failures   []users.RemovalFailure      `json:"failures,omitempty"`
+	Handovers  []users.AdminHandover       `json:"handovers,omitempty"` // private channels handed over to a new admin
+	Preserved  []users.PreservedMembership `json:"preserved,omitempty"` // memberships left in place, as they are kept
+	Error      string                      `json:"error,omitempty"`
+
+	TokensRevoked int                      `json:"tokens_revoked,omitempty"`
+	Bots          []users.OwnedBot         `json:"bots,omitempty"`         // bots disabled or reassigned
+	Integrations  []users.OwnedIntegration `json:"integrations,omitempty"` // webhooks and slash commands created by the user
+	DataCleanup   *store.UserDataCounts    `json:"data_cleanup,omitempty"` // rows changed by the data cleanup, if enabled
+}
+
+// UserRemovalJob is the status of a user removal started via the REST API. Jobs are stored in the
+// KV store, so that their status outlives the plugin and interrupted jobs can be resumed. Results
+// are stored in chunks under their own keys, so that recording one does not rewrite all of them.
+type UserRemovalJob struct {
+	ID              string              `json:"id"`
+	Type            string              `json:"type"`
+	RequesterID     string              `json:"requester_id"`
+	Status          string              `json:"status"`
+	StartAt         int64               `json:"start_at"`
+	EndAt           int64               `json:"end_at,omitempty"`
+	Total           int                 `json:"total"`
+	Processed       int                 `json:"processed"`
+	SuccessCount    int                 `json:"success_count"`
+	PartialCount    int                 `json:"partial_count"`
+	FailedCount     int                 `json:"failed_count"`
+	TeamsRemoved    int                 `json:"teams_removed"`          // team memberships removed so far
+	ChannelsRemoved int                 `json:"channels_removed"`       // channel memberships removed so far
+	Keep            users.KeepList      `json:"keep"`                   // teams and channels the users stay in
+	CleanupData     *bool               `json:"cleanup_data,omitempty"` // overrides the data cleanup setting
+	Results         []UserRemovalResult `json:"results"`
+
+	current *users.RemovalReport // removal in progress, counted in TeamsRemoved and ChannelsRemoved
+	cancel  context.CancelFunc
+
+	// optional, told about the removals while the job runs on the server it was started on
+	progressFn func(report *users.RemovalReport)
+	resultFn   func(report *users.RemovalReport, err error)
+}
+
+// userRemovalJobRegistry tracks the user removals running on this server. Only one bulk job may be
+// running at a time; the cluster lock userRemovalBulkLockKey extends this to the cluster.
+type userRemovalJobRegistry struct {
+	mux    sync.Mutex
+	kv     *pluginapi.KVService // optional, jobs are only kept in memory without it
+	jobs   map[string]*UserRemovalJob
+	active string
+}
+
+func newUserRemovalJobRegistry(kv *pluginapi.KVService) *userRemovalJobRegistry {
+	return &userRemovalJobRegistry{
+		kv:   kv,
+		jobs: make(map[string]*UserRemovalJob),
+	}
+}
+
+func userRemovalJobKey(jobID string) string {
+	return userRemovalJobKeyPrefix + jobID
+}
+
+func userRemovalUsersKey(jobID string) string {
+	return userRemovalUsersKeyPrefix + jobID
+}
+
+func userRemovalResultsKey(jobID string, chunk int) string {
+	return fmt.Sprintf("%s%s_%d", userRemovalResultsKeyPrefix, jobID, chunk)
+}
+
+// start registers and stores a new job along with the users it removes, returning an error if
+// another bulk job is still running.
+func (reg *userRemovalJobRegistry) start(job *UserRemovalJob, identifiers []string) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	bulk := job.Type == UserRemovalJobTypeBulk
+	if bulk && reg.active != "" {
+		return fmt.Errorf("user removal job %s %w", reg.active, errUserRemovalJobInProgress)
+	}
+
+	if reg.kv != nil {
+		if _, err := reg.kv.Set(userRemovalUsersKey(job.ID), identifiers); err != nil {
+			return fmt.Errorf("cannot save users of user removal job %s: %w", job.ID, err)
+		}
+		if err := reg.save(job); err != nil {
+			_ = reg.kv.Delete(userRemovalUsersKey(job.ID))
+			return err
+		}
+	}
+
+	reg.jobs[job.ID] = job
+	if bulk {
+		reg.active = job.ID
+	}
+	return nil
+}
+
+// resume loads an interrupted job and the users it removes, registering the job as running on this
+// server. It returns nil if the job is no longer running.
+func (reg *userRemovalJobRegistry) resume(jobID string) (*UserRemovalJob, []string, error) {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if _, ok := reg.jobs[jobID]; ok || reg.kv == nil {
+		return nil, nil, nil
+	}
+
+	job, err := reg.load(jobID)
+	if err != nil || job == nil || job.Status != UserRemovalJobStatusRunning {
+		return nil, nil, err
+	}
+
+	var identifiers []string
+	if err := reg.kv.Get(userRemovalUsersKey(jobID), &identifiers); err != nil {
+		return nil, nil, fmt.Errorf("cannot get users of user removal job %s: %w", jobID, err)
+	}
+
+	reg.jobs[job.ID] = job
+	if job.Type == UserRemovalJobTypeBulk && reg.active == "" {
+		reg.active = job.ID
+	}
+	return job, identifiers, nil
+}
+
+// interrupted returns the IDs of the stored jobs that are still running but not on this server.
+func (reg *userRemovalJobRegistry) interrupted() ([]string, error) {
+	if reg.kv == nil {
+		return nil, nil
+	}
+
+	keys, err := kvstore.ListKeysWithPrefix(reg.kv, userRemovalJobKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list user removal jobs: %w", err)
+	}
+
+	jobIDs := make([]string, 0)
+	for _, key := range keys {
+		jobID := strings.TrimPrefix(key, userRemovalJobKeyPrefix)
+		job, err := reg.loadStatus(jobID)
+		if err != nil {
+			return nil, err
+		}
+		if job != nil && job.Status == UserRemovalJobStatusRunning && !reg.isRunningHere(jobID) {
+			jobIDs = append(jobIDs, jobID)
+		}
+	}
+	return jobIDs, nil
+}
+
+func (reg *userRemovalJobRegistry) isRunningHere(jobID string) bool {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	_, ok := reg.jobs[jobID]
+	return ok
+}
+
+// setCancel records how to stop a job running on this server.
+func (reg *userRemovalJobRegistry) setCancel(jobID string, cancel context.CancelFunc) {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if job, ok := reg.jobs[jobID]; ok {
+		job.cancel = cancel
+	}
+}
+
+// progress updates the memberships removed so far while a user is being removed.
+func (reg *userRemovalJobRegistry) progress(jobID string, report *users.RemovalReport) {
+	reg.mux.Lock()
+	job, ok := reg.jobs[jobID]
+	if !ok {
+		reg.mux.Unlock()
+		return
+	}
+	job.current = report
+	progressFn := job.progressFn
+	reg.mux.Unlock()
+
+	if progressFn != nil {
+		progressFn(report)
+	}
+}
+
+// notifyResult passes the outcome of removing one user to the job's resultFn, if any.
+func (reg *userRemovalJobRegistry) notifyResult(jobID string, report *users.RemovalReport, err error) {
+	reg.mux.Lock()
+	var resultFn func(report *users.RemovalReport, err error)
+	if job, ok := reg.jobs[jobID]; ok {
+		resultFn = job.resultFn
+	}
+	reg.mux.Unlock()
+
+	if resultFn != nil {
+		resultFn(report, err)
+	}
+}
+
+// addResult records the outcome for one user of a job, and stores the job.
+func (reg *userRemovalJobRegistry) addResult(jobID string, result UserRemovalResult) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	job, ok := reg.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	job.Results = append(job.Results, result)
+	job.Processed++
+	job.TeamsRemoved += len(result.TeamIDs)
+	job.ChannelsRemoved += len(result.ChannelIDs)
+	job.current = nil
+	switch result.Status {
+	case UserRemovalStatusSuccess:
+		job.SuccessCount++
+	case UserRemovalStatusPartial:
+		job.PartialCount++
+	default:
+		job.FailedCount++
+	}
+
+	// the chunk goes first, so that a stored job never counts results that are not stored
+	if err := reg.saveResults(job, (len(job.Results)-1)/userRemovalResultsPerChunk); err != nil {
+		return err
+	}
+	return reg.save(job)
+}
+
+// finish marks a job as completed. Completed jobs are kept for userRemovalJobRetention.
+func (reg *userRemovalJobRegistry) finish(jobID string) error {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if reg.active == jobID {
+		reg.active = ""
+	}
+
+	job, ok := reg.jobs[jobID]
+	if !ok {
+		return nil
+	}
+	delete(reg.jobs, jobID)
+
+	job.Status = UserRemovalJobStatusCompleted
+	job.EndAt = model.GetMillis()
+	if reg.kv == nil {
+		// without a KV store, completed jobs stay in memory
+		reg.jobs[jobID] = job
+		return nil
+	}
+
+	for chunk := 0; chunk*userRemovalResultsPerChunk < len(job.Results); chunk++ {
+		if err := reg.saveResults(job, chunk, pluginapi.SetExpiry(userRemovalJobRetention)); err != nil {
+			return err
+		}
+	}
+	if _, err := reg.kv.Set(userRemovalJobKey(jobID), withoutResults(job), pluginapi.SetExpiry(userRemovalJobRetention)); err != nil {
+		return fmt.Errorf("cannot save user removal job %s: %w", jobID, err)
+	}
+	if err := reg.kv.Delete(userRemovalUsersKey(jobID)); err != nil {
+		return fmt.Errorf("cannot delete users of user removal job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// stop removes a job from this server without completing it, so that it can be resumed.
+func (reg *userRemovalJobRegistry) stop(jobID string) {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	if reg.active == jobID {
+		reg.active = ""
+	}
+	delete(reg.jobs, jobID)
+}
+
+// cancelAll stops the jobs running on this server. They are resumed when the plugin starts again.
+func (reg *userRemovalJobRegistry) cancelAll() {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	for _, job := range reg.jobs {
+		if job.cancel != nil {
+			job.cancel()
+		}
+	}
+}
+
+// get returns a copy of the job, or nil if the job does not exist. Jobs not running on this server
+// are read from the KV store.
+func (reg *userRemovalJobRegistry) get(jobID string) *UserRemovalJob {
+	reg.mux.Lock()
+	defer reg.mux.Unlock()
+
+	job, ok := reg.jobs[jobID]
+	if !ok {
+		if reg.kv == nil {
+			return nil
+		}
+		job, _ = reg.load(jobID)
+		return job
+	}
+
+	jobCopy := *job
+	jobCopy.Results = append([]UserRemovalResult{}, job.Results...)
+	if job.current != nil {
+		jobCopy.TeamsRemoved += len(job.current.TeamsRemoved)
+		jobCopy.ChannelsRemoved += len(job.current.ChannelsRemoved)
+	}
+	jobCopy.current = nil
+	jobCopy.progressFn = nil
+	jobCopy.resultFn = nil
+	return &jobCopy
+}
+
+// save stores the job without its results, which are stored by saveResults.
+func (reg *userRemovalJobRegistry) save(job *UserRemovalJob) error {
+	if reg.kv == nil {
+		return nil
+	}
+	if _, err := reg.kv.Set(userRemovalJobKey(job.ID), withoutResults(job)); err != nil {
+		return fmt.Errorf("cannot save user removal job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// saveResults stores one chunk of the results of a job.
+func (reg *userRemovalJobRegistry) saveResults(job *UserRemovalJob, chunk int, opts ...pluginapi.KVSetOption) error {
+	if reg.kv == nil {
+		return nil
+	}
+	start := chunk * userRemovalResultsPerChunk
+	results := job.Results[start:min(start+userRemovalResultsPerChunk, len(job.Results))]
+	if _, err := reg.kv.Set(userRemovalResultsKey(job.ID, chunk), results, opts...); err != nil {
+		return fmt.Errorf("cannot save results of user removal job %s: %w", job.ID, err)
+	}
+	return nil
+}
+
+// loadStatus reads a job without its results, or returns nil if the job does not exist.
+func (reg *userRemovalJobRegistry) loadStatus(jobID string) (*UserRemovalJob, error) {
+	var job *UserRemovalJob
+	if err := reg.kv.Get(userRemovalJobKey(jobID), &job); err != nil {
+		return nil, fmt.Errorf("cannot get user removal job %s: %w", jobID, err)
+	}
+	return job, nil
+}
+
+// load reads a job and its results, or returns nil if the job does not exist.
+func (reg *userRemovalJobRegistry) load(jobID string) (*UserRemovalJob, error) {
+	job, err := reg.loadStatus(jobID)
+	if err != nil || job == nil {
+		return nil, err
+	}
+
+	job.Results = make([]UserRemovalResult, 0, job.Processed)
+	for chunk := 0; len(job.Results) < job.Processed; chunk++ {
+		var results []UserRemovalResult
+		if err := reg.kv.Get(userRemovalResultsKey(jobID, chunk), &results); err != nil {
+			return nil, fmt.Errorf("cannot get results of user removal job %s: %w", jobID, err)
+		}
+		if len(results) == 0 {
+			return nil, fmt.Errorf("results of user removal job %s are missing", jobID)
+		}
+		job.Results = append(job.Results, results...)
+	}
+	// a result stored just before an interruption is not counted by the job, and is recorded again
+	job.Results = job.Results[:job.Processed]
+	return job, nil
+}
+
+func withoutResults(job *UserRemovalJob) *UserRemovalJob {
+	jobCopy := *job
+	jobCopy.Results = nil
+	return &jobCopy
+}
+
+func (p *Plugin) handleBulkRemoveUsers(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	requesterID, ok := p.requireSystemAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	identifiers, override, err := readUserIdentifiers(w, r)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	keep, err := p.removalKeepList(override)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.startUserRemovalJob(w, UserRemovalJobTypeBulk, requesterID, identifiers, keep)
+}
+
+func newUserRemovalJob(jobType string, requesterID string, identifiers []string, keep users.KeepList) *UserRemovalJob {
+	return &UserRemovalJob{
+		ID:          model.NewId(),
+		Type:        jobType,
+		RequesterID: requesterID,
+		Status:      UserRemovalJobStatusRunning,
+		StartAt:     model.GetMillis(),
+		Total:       len(identifiers),
+		Keep:        keep,
+		Results:     make([]UserRemovalResult, 0, len(identifiers)),
+	}
+}
+
+// startUserRemovalJob starts removing the users in the background, responding with the new job.
+func (p *Plugin) startUserRemovalJob(w http.ResponseWriter, jobType string, requesterID string, identifiers []string, keep users.KeepList) {
+	job := newUserRemovalJob(jobType, requesterID, identifiers, keep)
+	if err := p.launchUserRemovalJob(job, identifiers); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errUserRemovalJobInProgress) {
+			status = http.StatusConflict
+		}
+		writeError(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, p.userRemovalJobs.get(job.ID))
+}
+
+// StartUserRemoval starts a job removing a user from all teams and channels, for the /retention
+// command. progressFn and resultFn are called as long as the job runs on this server.
+func (p *Plugin) StartUserRemoval(user *model.User, requesterID string, keep users.KeepList, cleanupData bool, progressFn func(report *users.RemovalReport), resultFn func(report *users.RemovalReport, err error)) (string, error) {
+	job := newUserRemovalJob(UserRemovalJobTypeSingle, requesterID, []string{user.Id}, keep)
+	job.CleanupData = &cleanupData
+	job.progressFn = progressFn
+	job.resultFn = resultFn
+	if err := p.launchUserRemovalJob(job, []string{user.Id}); err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// launchUserRemovalJob registers a new job and runs it in the background, returning an error
+// wrapping errUserRemovalJobInProgress if it is a bulk job and another one is running.
+func (p *Plugin) launchUserRemovalJob(job *UserRemovalJob, identifiers []string) error {
+	var bulkLock *cluster.Mutex
+	if job.Type == UserRemovalJobTypeBulk {
+		var locked bool
+		var err error
+		if bulkLock, locked, err = p.tryClusterLock(userRemovalBulkLockKey); err != nil {
+			return fmt.Errorf("cannot create user removal job lock: %w", err)
+		}
+		if !locked {
+			return fmt.Errorf("a bulk user removal job %w", errUserRemovalJobInProgress)
+		}
+	}
+
+	if err := p.userRemovalJobs.start(job, identifiers); err != nil {
+		if bulkLock != nil {
+			bulkLock.Unlock()
+		}
+		return err
+	}
+
+	go p.runUserRemovalJobLocked(context.Background(), job.ID, job.RequesterID, identifiers, bulkLock)
+	return nil
+}
+
+func (p *Plugin) handleGetUserRemovalJob(w http.ResponseWriter, r *http.Request) {
+	if !checkMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	if _, ok := p.requireSystemAdmin(w, r); !ok {
+		return
+	}
+
+	jobID := r.URL.Query().Get("job_id")
+	if jobID == "" {
+		writeError(w, "missing job_id parameter", http.StatusBadRequest)
+		return
+	}
+
+	job := p.userRemovalJobs.get(jobID)
+	if job == nil {
+		writeError(w, fmt.Sprintf("user removal job %s not found", jobID), http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+// resumeUserRemovalJobs resumes the jobs interrupted by a plugin restart. Jobs still running on
+// another server of the cluster are left alone.
+func (p *Plugin) resumeUserRemovalJobs() {
+	jobIDs, err := p.userRemovalJobs.interrupted()
+	if err != nil {
+		p.API.LogError("Cannot list interrupted user removal jobs.", "err", err.Error())
+		return
+	}
+
+	for _, jobID := range jobIDs {
+		go func(jobID string) {
+			ctx, cancel := context.WithTimeout(context.Background(), userRemovalResumeTimeout)
+			defer cancel()
+
+			mutex, err := cluster.NewMutex(p.API, userRemovalJobKey(jobID))
+			if err != nil {
+				p.API.LogError("Cannot create user removal job lock.", "job_id", jobID, "err", err.Error())
+				return
+			}
+			if err := mutex.LockWithContext(ctx); err != nil {
+				// the job is running on another server
+				return
+			}
+			defer mutex.Unlock()
+
+			job, identifiers, err := p.userRemovalJobs.resume(jobID)
+			if err != nil {
+				p.API.LogError("Cannot resume user removal job.", "job_id", jobID, "err", err.Error())
+				return
+			}
+			if job == nil {
+				return
+			}
+
+			runCtx, stop := context.WithCancel(context.Background())
+			defer stop()
+			if job.Type == UserRemovalJobTypeBulk {
+				// a bulk job started meanwhile on another server runs first
+				p.userRemovalJobs.setCancel(jobID, stop)
+				bulkLock, err := cluster.NewMutex(p.API, userRemovalBulkLockKey)
+				if err == nil {
+					err = bulkLock.LockWithContext(runCtx)
+				}
+				if err != nil {
+					p.API.LogInfo("Stopped user removal job.", "job_id", jobID, "err", err.Error())
+					p.userRemovalJobs.stop(jobID)
+					return
+				}
+				defer bulkLock.Unlock()
+			}
+
+			p.API.LogInfo("Resuming user removal job.", "job_id", jobID, "processed", job.Processed, "total", job.Total)
+			p.runUserRemovalJob(runCtx, jobID, job.RequesterID, identifiers)
+		}(jobID)
+	}
+}
+
+// runUserRemovalJobLocked runs a job while holding a cluster lock, so that the job is not resumed by
+// another server while it runs. bulkLock, held for bulk jobs only, is released once the job stops.
+func (p *Plugin) runUserRemovalJobLocked(ctx context.Context, jobID string, requesterID string, identifiers []string, bulkLock *cluster.Mutex) {
+	if bulkLock != nil {
+		defer bulkLock.Unlock()
+	}
+
+	mutex, err := cluster.NewMutex(p.API, userRemovalJobKey(jobID))
+	if err != nil {
+		p.API.LogError("Cannot create user removal job lock.", "job_id", jobID, "err", err.Error())
+		p.userRemovalJobs.stop(jobID)
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	p.runUserRemovalJob(ctx, jobID, requesterID, identifiers)
+}
+
+// runUserRemovalJob removes each user from all teams and channels, recording the outcome per user.
+// A failure for one user does not stop the job. The users already processed by an interrupted job
+// are skipped. Once canceled, the job stops without completing, so that it is resumed later.
+func (p *Plugin) runUserRemovalJob(ctx context.Context, jobID string, requesterID string, identifiers []string) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	p.userRemovalJobs.setCancel(jobID, cancel)
+
+	job := p.userRemovalJobs.get(jobID)
+	if job == nil {
+		return
+	}
+
+	for _, identifier := range identifiers[min(job.Processed, len(identifiers)):] {
+		select {
+		case <-ctx.Done():
+			p.userRemovalJobs.stop(jobID)
+			p.API.LogInfo("Stopped user removal job.", "job_id", jobID)
+			return
+		default:
+		}
+
+		result, report, err := p.removeUserByIdentifier(identifier, requesterID, job.Keep, job.CleanupData, func(report *users.RemovalReport) {
+			p.userRemovalJobs.progress(jobID, report)
+		})
+		if saveErr := p.userRemovalJobs.addResult(jobID, result); saveErr != nil {
+			p.API.LogWarn("Cannot save user removal job progress.", "job_id", jobID, "err", saveErr.Error())
+		}
+		p.userRemovalJobs.notifyResult(jobID, report, err)
+	}
+
+	job = p.userRemovalJobs.get(jobID)
+	if err := p.userRemovalJobs.finish(jobID); err != nil {
+		p.API.LogError("Cannot save completed user removal job.", "job_id", jobID, "err", err.Error())
+	}
+	p.API.LogInfo("Finished user removal job.", "job_id", jobID, "success", job.SuccessCount, "partial", job.PartialCount, "failed", job.FailedCount)
+}
+
+// removeUserByIdentifier removes a user from all teams and channels, returning the outcome along with
+// the removal report and error. The report is nil if the user is not found. cleanupData overrides
+// the data cleanup setting unless nil.
+func (p *Plugin) removeUserByIdentifier(identifier string, requesterID string, keep users.KeepList, cleanupData *bool, progressFn func(report *users.RemovalReport)) (UserRemovalResult, *users.RemovalReport, error) {
+	result := UserRemovalResult{
+		Identifier: identifier,
+		Status:     UserRemovalStatusFailed,
+		TeamIDs:    []string{},
+		ChannelIDs: []string{},
+	}
+
+	remover := p.userRemover()
+	user, err := remover.FindUser(identifier)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil, err
+	}
+	result.UserID = user.Id
+	result.Username = user.Username
+
+	cfg := p.getConfiguration()
+	if cleanupData == nil {
+		cleanupData = &cfg.CleanupRemovedUserData
+	}
+	report, err := remover.RemoveFromAllTeams(user, users.RemoveOpts{
+		RequesterID:  requesterID,
+		Keep:         keep,
+		Handover:     users.NewHandoverOpts(cfg.ChannelAdminSuccessor, cfg.FallbackChannelAdmin, p.bot, p.i18n),
+		Integrations: users.NewIntegrationOpts(cfg.RevokeAccessTokens, cfg.OwnedBotsAction, cfg.IntegrationsAction, cfg.IntegrationOwner),
+		CleanupData:  *cleanupData,
+		ProgressFn:   progressFn,
+	})
+	result.TeamIDs = report.TeamsRemoved
+	result.ChannelIDs = report.ChannelsRemoved
+	if len(report.Failures) > 0 {
+		result.Failures = report.Failures
+	}
+	if len(report.Handovers) > 0 {
+		result.Handovers = report.Handovers
+	}
+	if len(report.Preserved) > 0 {
+		result.Preserved = report.Preserved
+	}
+	result.TokensRevoked = report.TokensRevoked
+	if len(report.Bots) > 0 {
+		result.Bots = report.Bots
+	}
+	if len(report.Integrations) > 0 {
+		result.Integrations = report.Integrations
+	}
+	result.DataCleanup = report.DataCleanup
+	switch {
+	case err == nil:
+		result.Status = UserRemovalStatusSuccess
+	case report.RemovedAny():
+		result.Status = UserRemovalStatusPartial
+		result.Error = err.Error()
+	default:
+		result.Error = err.Error()
+	}
+	return result, report, err
+}
+
+// readUserIdentifiers reads the users to remove from a JSON payload, a CSV body or an uploaded CSV
+// file. Identifiers are trimmed and deduplicated, keeping their order. Only JSON payloads can
+// override the keep-lists.
+func readUserIdentifiers(w http.ResponseWriter, r *http.Request) ([]string, *KeepOverride, error) {
+	defer r.Body.Close()
+	r.Body = http.MaxBytesReader(w, r.Body, maxUserRemovalUploadSize)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	var identifiers []string
+	var override *KeepOverride
+	var err error
+	switch mediaType {
+	case "text/csv":
+		identifiers, err = parseUserIdentifiersCSV(r.Body)
+	case "multipart/form-data":
+		file, _, formErr := r.FormFile("file")
+		if formErr != nil {
+			return nil, nil, errors.Wrap(formErr, "error reading uploaded CSV file")
+		}
+		defer file.Close()
+		identifiers, err = parseUserIdentifiersCSV(file)
+	default:
+		var payload BulkUserRemovalPayload
+		if err = json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			return nil, nil, errors.Wrap(err, "error decoding users payload")
+		}
+		identifiers = payload.Users
+		override = payload.Keep
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identifiers = uniqueIdentifiers(identifiers)
+	if len(identifiers) == 0 {
+		return nil, nil, errors.New("please provide at least one user ID, username or email")
+	}
+	if len(identifiers) > maxUserRemovalIdentifiers {
+		return nil, nil, fmt.Errorf("too many users: at most %d users can be removed per request", maxUserRemovalIdentifiers)
+	}
+	return identifiers, override, nil
+}
+
+// parseUserIdentifiersCSV returns the first column of each row, skipping an optional header row.
+func parseUserIdentifiersCSV(reader io.Reader) ([]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing CSV")
+	}
+
+	identifiers := make([]string, 0, len(records))
+	for i, record := range records {
+		if len(record) == 0 {
+			continue
+		}
+		value := strings.TrimSpace(record[0])
+		if i == 0 && isCSVHeader(value) {
+			continue
+		}
+		identifiers = append(identifiers, value)
+	}
+	return identifiers, nil
+}
+
+func isCSVHeader(value string) bool {
+	for _, header := range csvHeaders {
+		if strings.EqualFold(value, header) {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueIdentifiers(identifiers []string) []string {
+	seen := make(map[string]bool, len(identifiers))
+	unique := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		identifier = strings.TrimSpace(identifier)
+		if identifier == "" || seen[identifier] {
+			continue
+		}
+		seen[identifier] = true
+		unique = append(unique, identifier)
+	}
+	return unique
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/users"
+)
+
+func TestReadUserIdentifiers(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, strings.NewReader(`{"users": ["alice", " bob@example.com ", "alice", ""]}`))
+		identifiers, _, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob@example.com"}, identifiers)
+	})
+
+	t.Run("json with keep-lists", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, strings.NewReader(`{"users": ["alice"], "keep": {"channels": ["audit"], "channel_patterns": ["compliance-*"], "replace": true}}`))
+		identifiers, override, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice"}, identifiers)
+		require.NotNil(t, override)
+		assert.Equal(t, []string{"audit"}, override.Channels)
+		assert.Equal(t, []string{"compliance-*"}, override.ChannelPatterns)
+		assert.True(t, override.Replace)
+	})
+
+	t.Run("csv body with header", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, strings.NewReader("username,reason\nalice,layoff\n@bob\n\ncarol@example.com,layoff\n"))
+		r.Header.Set("Content-Type", "text/csv; charset=utf-8")
+		identifiers, _, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "@bob", "carol@example.com"}, identifiers)
+	})
+
+	t.Run("uploaded csv file", func(t *testing.T) {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		part, err := writer.CreateFormFile("file", "users.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("alice\nbob\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, &body)
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		identifiers, _, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alice", "bob"}, identifiers)
+	})
+
+	t.Run("no users", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, strings.NewReader(`{"users": []}`))
+		_, _, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.EqualError(t, err, "please provide at least one user ID, username or email")
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, routeRemoveUsersFromAllTeams, strings.NewReader(`{"users": "alice"`))
+		_, _, err := readUserIdentifiers(httptest.NewRecorder(), r)
+		require.ErrorContains(t, err, "error decoding users payload")
+	})
+}
+
+func TestRunUserRemovalJob(t *testing.T) {
+	p := &Plugin{userRemovalJobs: newUserRemovalJobRegistry(nil)}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	mockKVStore(api)
+
+	alice := &model.User{Id: model.NewId(), Username: "alice"}
+	bob := &model.User{Id: model.NewId(), Username: "bob"}
+
+	// alice is removed from her only team
+	api.On("GetUser", alice.Id).Return(alice, nil)
+	api.On("GetTeamMembersForUser", alice.Id, 0, 1000).Return([]*model.TeamMember{{TeamId: "team1", UserId: alice.Id}}, nil)
+	mockChannelMembers(api, alice.Id, map[string][]*model.ChannelMember{"team1": {{ChannelId: "channel1", UserId: alice.Id}}})
+	api.On("DeleteChannelMember", "channel1", alice.Id).Return(nil)
+	api.On("DeleteTeamMember", "team1", alice.Id, "requesting_user_id").Return(nil)
+
+	// bob is removed from the first team, but not the second one
+	api.On("GetUserByEmail", "bob@example.com").Return(bob, nil)
+	api.On("GetTeamMembersForUser", bob.Id, 0, 1000).Return([]*model.TeamMember{{TeamId: "team1", UserId: bob.Id}, {TeamId: "team2", UserId: bob.Id}}, nil)
+	mockChannelMembers(api, bob.Id, map[string][]*model.ChannelMember{"team1": {}, "team2": {}})
+	api.On("DeleteTeamMember", "team1", bob.Id, "requesting_user_id").Return(nil)
+	api.On("DeleteTeamMember", "team2", bob.Id, "requesting_user_id").Return(&model.AppError{DetailedError: "some database error"})
+
+	// carol does not exist
+	api.On("GetUserByUsername", "carol").Return(nil, &model.AppError{DetailedError: "not found"})
+
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	identifiers := []string{alice.Id, "bob@example.com", "@carol"}
+	job := &UserRemovalJob{ID: "job1", Type: UserRemovalJobTypeBulk, Status: UserRemovalJobStatusRunning, Total: 3}
+	require.NoError(t, p.userRemovalJobs.start(job, identifiers))
+	require.ErrorIs(t, p.userRemovalJobs.start(&UserRemovalJob{ID: "job2", Type: UserRemovalJobTypeBulk}, nil), errUserRemovalJobInProgress)
+
+	// single user jobs can run alongside a bulk job
+	require.NoError(t, p.userRemovalJobs.start(&UserRemovalJob{ID: "single", Type: UserRemovalJobTypeSingle}, nil))
+
+	p.runUserRemovalJob(context.Background(), "job1", "requesting_user_id", identifiers)
+
+	status := p.userRemovalJobs.get("job1")
+	require.NotNil(t, status)
+	assert.Equal(t, UserRemovalJobStatusCompleted, status.Status)
+	assert.NotZero(t, status.EndAt)
+	assert.Equal(t, 3, status.Processed)
+	assert.Equal(t, 1, status.SuccessCount)
+	assert.Equal(t, 1, status.PartialCount)
+	assert.Equal(t, 1, status.FailedCount)
+	assert.Equal(t, 2, status.TeamsRemoved)
+	assert.Equal(t, 1, status.ChannelsRemoved)
+
+	require.Len(t, status.Results, 3)
+	assert.Equal(t, UserRemovalStatusSuccess, status.Results[0].Status)
+	assert.Equal(t, []string{"team1"}, status.Results[0].TeamIDs)
+	assert.Equal(t, UserRemovalStatusPartial, status.Results[1].Status)
+	assert.Equal(t, "bob", status.Results[1].Username)
+	assert.Equal(t, []string{"team1"}, status.Results[1].TeamIDs)
+	assert.Contains(t, status.Results[1].Error, "failed to remove user from team")
+	assert.Equal(t, UserRemovalStatusFailed, status.Results[2].Status)
+	assert.Equal(t, "@carol", status.Results[2].Identifier)
+	assert.Contains(t, status.Results[2].Error, "failed to get user @carol")
+
+	// the memberships are kept for a restore, including the team bob is still in
+	snapshot, err := p.userRemover().GetMembershipSnapshot(bob.Id)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	require.Len(t, snapshot.Teams, 2)
+	assert.Equal(t, "team2", snapshot.Teams[1].TeamID)
+
+	// a new job can start once the previous one has finished
+	require.NoError(t, p.userRemovalJobs.start(&UserRemovalJob{ID: "job2", Type: UserRemovalJobTypeBulk}, nil))
+}
+
+func TestStartUserRemoval(t *testing.T) {
+	p := &Plugin{userRemovalJobs: newUserRemovalJobRegistry(nil)}
+	api := &plugintest.API{}
+	p.SetAPI(api)
+	mockKVStore(api)
+
+	alice := &model.User{Id: model.NewId(), Username: "alice"}
+	api.On("GetUser", alice.Id).Return(alice, nil)
+	api.On("GetTeamMembersForUser", alice.Id, 0, 1000).Return([]*model.TeamMember{{TeamId: "team1", UserId: alice.Id}}, nil)
+	mockChannelMembers(api, alice.Id, map[string][]*model.ChannelMember{"team1": {{ChannelId: "channel1", UserId: alice.Id}}})
+	api.On("DeleteChannelMember", "channel1", alice.Id).Return(nil)
+	api.On("DeleteTeamMember", "team1", alice.Id, "requesting_user_id").Return(nil)
+
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	var mux sync.Mutex
+	var progress []*users.RemovalReport
+	done := make(chan *users.RemovalReport, 1)
+	jobID, err := p.StartUserRemoval(alice, "requesting_user_id", users.KeepList{}, false,
+		func(report *users.RemovalReport) {
+			mux.Lock()
+			defer mux.Unlock()
+			progress = append(progress, report)
+		},
+		func(report *users.RemovalReport, err error) {
+			assert.NoError(t, err)
+			done <- report
+		})
+	require.NoError(t, err)
+
+	select {
+	case report := <-done:
+		assert.Equal(t, []string{"team1"}, report.TeamsRemoved)
+		assert.Equal(t, []string{"channel1"}, report.ChannelsRemoved)
+		assert.Nil(t, report.DataCleanup)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the removal did not finish")
+	}
+
+	mux.Lock()
+	assert.NotEmpty(t, progress)
+	mux.Unlock()
+
+	require.Eventually(t, func() bool {
+		return p.userRemovalJobs.get(jobID).Status == UserRemovalJobStatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+	status := p.userRemovalJobs.get(jobID)
+	assert.Equal(t, UserRemovalJobTypeSingle, status.Type)
+	require.NotNil(t, status.CleanupData)
+	assert.False(t, *status.CleanupData)
+	assert.Equal(t, 1, status.SuccessCount)
+}
+
+func TestUserRemovalJobResultChunks(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	client := pluginapi.NewClient(api, nil)
+
+	reg := newUserRemovalJobRegistry(&client.KV)
+	other := newUserRemovalJobRegistry(&client.KV) // another server of the cluster
+
+	total := 2*userRemovalResultsPerChunk + 1
+	job := &UserRemovalJob{ID: "job1", Type: UserRemovalJobTypeBulk, Status: UserRemovalJobStatusRunning, Total: total}
+	require.NoError(t, reg.start(job, nil))
+	for i := range total {
+		require.NoError(t, reg.addResult(job.ID, UserRemovalResult{Identifier: fmt.Sprintf("user%d", i), Status: UserRemovalStatusSuccess}))
+	}
+
+	// the job is stored without its results, which are stored in chunks
+	var stored map[string]any
+	require.NoError(t, client.KV.Get(userRemovalJobKey(job.ID), &stored))
+	assert.Nil(t, stored["results"])
+	var chunk []UserRemovalResult
+	require.NoError(t, client.KV.Get(userRemovalResultsKey(job.ID, 2), &chunk))
+	require.Len(t, chunk, 1)
+	assert.Equal(t, fmt.Sprintf("user%d", total-1), chunk[0].Identifier)
+
+	status := other.get(job.ID)
+	require.NotNil(t, status)
+	assert.Equal(t, total, status.Processed)
+	require.Len(t, status.Results, total)
+	assert.Equal(t, "user0", status.Results[0].Identifier)
+	assert.Equal(t, fmt.Sprintf("user%d", total-1), status.Results[total-1].Identifier)
+
+	// completed jobs keep their results
+	require.NoError(t, reg.finish(job.ID))
+	status = other.get(job.ID)
+	assert.Equal(t, UserRemovalJobStatusCompleted, status.Status)
+	assert.Len(t, status.Results, total)
+}
+
+// mockKVStore backs the KV store API calls with a map.
+func mockKVStore(api *plugintest.API) {
+	var mux sync.Mutex
+	values := map[string][]byte{}
+
+	api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, value []byte, opts model.PluginKVSetOptions) (bool, *model.AppError) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		if opts.Atomic && !bytes.Equal(values[key], opts.OldValue) {
+			return false, nil
+		}
+		if value == nil {
+			delete(values, key)
+		} else {
+			values[key] = value
+		}
+		return true, nil
+	})
+	api.On("KVGet", mock.Anything).Return(func(key string) ([]byte, *model.AppError) {
+		mux.Lock()
+		defer mux.Unlock()
+		return values[key], nil
+	})
+	api.On("KVList", mock.Anything, mock.Anything).Return(func(page, perPage int) ([]string, *model.AppError) {
+		mux.Lock()
+		defer mux.Unlock()
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		start := min(page*perPage, len(keys))
+		return keys[start:min(start+perPage, len(keys))], nil
+	})
+}
+
+func TestResumeUserRemovalJobs(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	client := pluginapi.NewClient(api, nil)
+
+	alice := &model.User{Id: model.NewId(), Username: "alice"}
+	bob := &model.User{Id: model.NewId(), Username: "bob"}
+
+	// alice was removed before the plugin restarted, bob was not
+	api.On("GetUser", bob.Id).Return(bob, nil)
+	api.On("GetTeamMembersForUser", bob.Id, 0, 1000).Return([]*model.TeamMember{{TeamId: "team1", UserId: bob.Id}}, nil)
+	mockChannelMembers(api, bob.Id, map[string][]*model.ChannelMember{"team1": {{ChannelId: "channel1", UserId: bob.Id}}})
+	api.On("DeleteChannelMember", "channel1", bob.Id).Return(nil)
+	api.On("DeleteTeamMember", "team1", bob.Id, "requesting_user_id").Return(nil)
+
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+	identifiers := []string{alice.Id, bob.Id}
+	job := &UserRemovalJob{ID: "job1", Type: UserRemovalJobTypeBulk, RequesterID: "requesting_user_id", Status: UserRemovalJobStatusRunning, Total: 2}
+	previous := newUserRemovalJobRegistry(&client.KV)
+	require.NoError(t, previous.start(job, identifiers))
+	require.NoError(t, previous.addResult(job.ID, UserRemovalResult{
+		Identifier: alice.Id,
+		Status:     UserRemovalStatusSuccess,
+		TeamIDs:    []string{"team1"},
+		ChannelIDs: []string{},
+	}))
+
+	p := &Plugin{userRemovalJobs: newUserRemovalJobRegistry(&client.KV)}
+	p.SetAPI(api)
+
+	// the job status is read from the KV store
+	status := p.userRemovalJobs.get(job.ID)
+	require.NotNil(t, status)
+	assert.Equal(t, 1, status.Processed)
+
+	p.resumeUserRemovalJobs()
+
+	require.Eventually(t, func() bool {
+		return p.userRemovalJobs.get(job.ID).Status == UserRemovalJobStatusCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+
+	status = p.userRemovalJobs.get(job.ID)
+	assert.Equal(t, 2, status.Processed)
+	assert.Equal(t, 2, status.SuccessCount)
+	assert.Equal(t, 2, status.TeamsRemoved)
+	assert.Equal(t, 1, status.ChannelsRemoved)
+	require.Len(t, status.Results, 2)
+	assert.Equal(t, "bob", status.Results[1].Username)
+	api.AssertNotCalled(t, "GetUser", alice.Id)
+
+	// completed jobs are not resumed again, and their users are no longer stored
+	jobIDs, err := p.userRemovalJobs.interrupted()
+	require.NoError(t, err)
+	assert.Empty(t, jobIDs)
+
+	var stored []string
+	require.NoError(t, client.KV.Get(userRemovalUsersKey(job.ID), &stored))
+	assert.Empty(t, stored)
+}
@@ -0,0 +1,137 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+type CleanupOpts struct {
+	ExcludeTeamIDs []string // teams deactivated users stay in
+	Keep           KeepList // teams and channels removed users stay in
+	AdminChannel   string   // optional channel receiving a report of each run that removed users
+
+	Handover     *HandoverOpts    // optional, hands over the private channels removed users are the only admins of
+	Integrations *IntegrationOpts // optional, revokes the access tokens and handles the bots and integrations of removed users
+	CleanupData  bool             // hides the direct messages of removed users, and deletes their preferences, sidebar and threads
+
+	Bot    *bot.Bot      // bot posting the report, and recorded as the actor of removals
+	Audit  *audit.Logger // optional audit logger
+	I18n   *i18n.Bundle  // optional translations; the report is in English without it
+	Locale string        // locale of the admin channel report, the server locale if empty
+}
+
+type CleanupResults struct {
+	Removed     []string // IDs of the users removed from their teams and channels
+	Failed      []string // IDs of the users that could not be removed, retried on the next run
+	Reactivated int      // users reactivated before their removal was due
+	Duration    time.Duration
+}
+
+// CleanupDeactivatedUsers removes the users whose pending removal is due from all teams and channels,
+// except the excluded teams. Users reactivated in the meantime are left alone.
+func CleanupDeactivatedUsers(ctx context.Context, sqlstore *store.SQLStore, papi plugin.API, client *pluginapi.Client, opts CleanupOpts) (*CleanupResults, error) {
+	start := time.Now()
+	results := &CleanupResults{
+		Removed: make([]string, 0),
+		Failed:  make([]string, 0),
+	}
+	defer func() {
+		results.Duration = time.Since(start)
+	}()
+
+	due, err := GetDuePendingRemovals(client, model.GetMillis())
+	if err != nil {
+		return results, err
+	}
+	if len(due) == 0 {
+		return results, nil
+	}
+
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+	remover := NewRemover(papi, sqlstore, opts.Audit)
+	removeOpts := RemoveOpts{
+		RequesterID:    opts.Bot.UserID(),
+		ExcludeTeamIDs: opts.ExcludeTeamIDs,
+		Keep:           opts.Keep,
+		Handover:       opts.Handover,
+		Integrations:   opts.Integrations,
+		CleanupData:    opts.CleanupData,
+	}
+
+	var report strings.Builder
+	for _, pending := range due {
+		if ctx.Err() != nil {
+			break
+		}
+
+		user, err := client.User.Get(pending.UserID)
+		if errors.Is(err, pluginapi.ErrNotFound) {
+			// permanently deleted users have no memberships left
+			if err := DeletePendingRemoval(client, pending.UserID); err != nil {
+				client.Log.Error("Cannot delete pending removal", "user_id", pending.UserID, "err", err)
+			}
+			continue
+		}
+		if err != nil {
+			client.Log.Error("Cannot get deactivated user", "user_id", pending.UserID, "err", err)
+			results.Failed = append(results.Failed, pending.UserID)
+			continue
+		}
+
+		if user.DeleteAt == 0 {
+			results.Reactivated++
+			if err := DeletePendingRemoval(client, user.Id); err != nil {
+				client.Log.Error("Cannot delete pending removal", "user_id", user.Id, "err", err)
+			}
+			continue
+		}
+
+		removal, err := remover.RemoveFromAllTeams(user, removeOpts)
+		if err != nil {
+			client.Log.Error("Cannot remove deactivated user from all teams", "user_id", user.Id, "err", err)
+			results.Failed = append(results.Failed, user.Id)
+			report.WriteString(loc.T(&i18n.Message{ID: "cleanup.report.failed", Other: "- @{{.Username}}: {{.Error}}"}, map[string]any{
+				"Username": user.Username,
+				"Error":    err.Error(),
+			}) + "\n")
+			continue
+		}
+
+		if err := DeletePendingRemoval(client, user.Id); err != nil {
+			client.Log.Error("Cannot delete pending removal", "user_id", user.Id, "err", err)
+		}
+		results.Removed = append(results.Removed, user.Id)
+		report.WriteString(loc.T(&i18n.Message{ID: "cleanup.report.removed", Other: "- @{{.Username}} removed from {{.TeamCount}} teams"}, map[string]any{
+			"Username":  user.Username,
+			"TeamCount": len(removal.TeamsRemoved),
+		}) + "\n")
+	}
+
+	if opts.AdminChannel == "" || report.Len() == 0 {
+		return results, nil
+	}
+
+	msg := loc.T(&i18n.Message{
+		ID:    "cleanup.report.header",
+		Other: "Deactivated users were removed from their teams and channels ({{.Removed}} removed, {{.Failed}} failed):",
+	}, map[string]any{
+		"Removed": len(results.Removed),
+		"Failed":  len(results.Failed),
+	})
+	if err := opts.Bot.SendPost(opts.AdminChannel, msg+"\n"+report.String()); err != nil {
+		return results, fmt.Errorf("failed to post deactivation cleanup report: %w", err)
+	}
+	return results, nil
+}
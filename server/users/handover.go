@@ -0,0 +1,172 @@
+package users
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+)
+
+const (
+	HandoverReasonLongestStanding = config.SuccessorLongestStanding // longest-standing remaining member
+	HandoverReasonMostActive      = config.SuccessorMostActive      // remaining member with the most posts
+	HandoverReasonFallback        = "fallback"                      // fallback admin, no other active member was left
+)
+
+// HandoverOpts controls how the private channels a removed user is the only admin of are handed
+// over to a successor.
+type HandoverOpts struct {
+	ByActivity    bool   // promote the most active member rather than the longest-standing one
+	FallbackAdmin string // optional user promoted when no other active member is left: username, email or user ID
+
+	Bot  *bot.Bot     // bot notifying successors by direct message
+	I18n *i18n.Bundle // optional translations; notifications are in English without it
+}
+
+// NewHandoverOpts returns the handover options for a configured successor, see config.SuccessorLongestStanding,
+// or nil if channels are not handed over.
+func NewHandoverOpts(successor string, fallbackAdmin string, bot *bot.Bot, bundle *i18n.Bundle) *HandoverOpts {
+	if successor == config.SuccessorNone {
+		return nil
+	}
+	return &HandoverOpts{
+		ByActivity:    successor == config.SuccessorMostActive,
+		FallbackAdmin: fallbackAdmin,
+		Bot:           bot,
+		I18n:          bundle,
+	}
+}
+
+// AdminHandover records a private channel handed over to a successor, as the removed user was its only admin.
+type AdminHandover struct {
+	ChannelID         string `json:"channel_id"`
+	ChannelName       string `json:"channel_name"`
+	SuccessorID       string `json:"successor_id"`
+	SuccessorUsername string `json:"successor_username"`
+	Reason            string `json:"reason"` // how the successor was picked, see HandoverReasonLongestStanding
+}
+
+// handOverChannel promotes a successor to channel admin when the user is the only active admin of
+// a private channel, before the user leaves it. It returns nil if there is nothing to hand over,
+// or no one to hand it over to.
+func (r *Remover) handOverChannel(user *model.User, cm *model.ChannelMember, opts *HandoverOpts) (*AdminHandover, error) {
+	if opts == nil || r.sqlstore == nil || !cm.SchemeAdmin {
+		return nil, nil
+	}
+
+	channel, appErr := r.papi.GetChannel(cm.ChannelId)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get channel %s", cm.ChannelId)
+	}
+	if channel.Type != model.ChannelTypePrivate || channel.DeleteAt > 0 {
+		return nil, nil
+	}
+
+	counts, err := r.sqlstore.GetChannelMemberCounts(channel.Id, user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count channel members")
+	}
+	if counts.Admins > 0 {
+		return nil, nil
+	}
+
+	reason := HandoverReasonLongestStanding
+	if opts.ByActivity {
+		reason = HandoverReasonMostActive
+	}
+	successorID, err := r.sqlstore.GetChannelAdminSuccessor(channel.Id, user.Id, opts.ByActivity)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find channel admin successor")
+	}
+
+	if successorID == "" {
+		if opts.FallbackAdmin == "" {
+			return nil, nil
+		}
+		fallback, err := r.FindUser(opts.FallbackAdmin)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to find fallback channel admin")
+		}
+		if fallback.Id == user.Id {
+			return nil, nil
+		}
+		if fallback.DeleteAt > 0 {
+			return nil, errors.Errorf("fallback channel admin %s is deactivated", fallback.Username)
+		}
+		if _, appErr := r.papi.AddChannelMember(channel.Id, fallback.Id); appErr != nil {
+			return nil, errors.Wrap(appErr, "failed to add fallback channel admin to channel")
+		}
+		successorID = fallback.Id
+		reason = HandoverReasonFallback
+	}
+
+	if _, appErr := r.papi.UpdateChannelMemberRoles(channel.Id, successorID, model.ChannelUserRoleId+" "+model.ChannelAdminRoleId); appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to promote channel admin successor")
+	}
+
+	successor, appErr := r.papi.GetUser(successorID)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get channel admin successor %s", successorID)
+	}
+
+	r.notifySuccessor(user, channel, successor, opts)
+
+	return &AdminHandover{
+		ChannelID:         channel.Id,
+		ChannelName:       channel.Name,
+		SuccessorID:       successor.Id,
+		SuccessorUsername: successor.Username,
+		Reason:            reason,
+	}, nil
+}
+
+// HandOverChannels hands over the private channels a user is the only admin of, without removing
+// the user from them. Channels handed over before are left alone, so it is safe to repeat.
+func (r *Remover) HandOverChannels(user *model.User, opts *HandoverOpts) ([]AdminHandover, error) {
+	handovers := []AdminHandover{}
+	teamMembers, err := r.getTeamMembers(user)
+	if err != nil {
+		return handovers, errors.Wrap(err, "failed to get team members")
+	}
+	var memberships channelMemberships
+	for _, tm := range teamMembers {
+		channelMembers, err := r.getChannelMembers(user, tm.TeamId, &memberships)
+		if err != nil {
+			return handovers, errors.Wrapf(err, "failed to get channel members. team=%s", tm.TeamId)
+		}
+		for _, cm := range channelMembers {
+			handover, err := r.handOverChannel(user, cm, opts)
+			if err != nil {
+				return handovers, errors.Wrapf(err, "failed to hand over channel %s", cm.ChannelId)
+			}
+			if handover != nil {
+				handovers = append(handovers, *handover)
+			}
+		}
+	}
+	return handovers, nil
+}
+
+// notifySuccessor tells a successor by direct message that they are now admin of the channel. The
+// handover stands even if the message cannot be sent.
+func (r *Remover) notifySuccessor(user *model.User, channel *model.Channel, successor *model.User, opts *HandoverOpts) {
+	if opts.Bot == nil {
+		return
+	}
+
+	loc := opts.I18n.LocaleLocalizer(successor.Locale)
+	msg := loc.T(&i18n.Message{
+		ID:    "handover.notify",
+		Other: "@{{.Username}} was removed from the private channel **{{.ChannelDisplayName}}**, where they were the only channel admin. You are now an admin of the channel, and can manage its members and settings.",
+	}, map[string]any{
+		"Username":           user.Username,
+		"ChannelDisplayName": channel.DisplayName,
+	})
+
+	if err := opts.Bot.SendDirectPost(successor.Id, msg); err != nil {
+		r.papi.LogWarn("Cannot notify channel admin successor.", "channel_id", channel.Id, "user_id", successor.Id, "err", err.Error())
+	}
+}
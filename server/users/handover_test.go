@@ -0,0 +1,89 @@
+package users
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+func TestNewHandoverOpts(t *testing.T) {
+	assert.Nil(t, NewHandoverOpts(config.SuccessorNone, "", nil, nil))
+
+	opts := NewHandoverOpts(config.SuccessorMostActive, "it-admin", nil, nil)
+	require.NotNil(t, opts)
+	assert.True(t, opts.ByActivity)
+	assert.Equal(t, "it-admin", opts.FallbackAdmin)
+
+	// an unset successor is the default, the longest-standing member
+	opts = NewHandoverOpts("", "", nil, nil)
+	require.NotNil(t, opts)
+	assert.False(t, opts.ByActivity)
+}
+
+func TestHandOverChannelSkipped(t *testing.T) {
+	api := &plugintest.API{}
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	// members who are not channel admins have nothing to hand over
+	handover, err := remover.handOverChannel(user, &model.ChannelMember{ChannelId: "channel1"}, &HandoverOpts{})
+	require.NoError(t, err)
+	assert.Nil(t, handover)
+
+	// nor are channels handed over when disabled
+	handover, err = remover.handOverChannel(user, &model.ChannelMember{ChannelId: "channel1", SchemeAdmin: true}, nil)
+	require.NoError(t, err)
+	assert.Nil(t, handover)
+
+	api.AssertNotCalled(t, "GetChannel", "channel1")
+}
+
+func TestHandOverChannels(t *testing.T) {
+	th := store.SetupHelper(t).SetupBasic(t)
+	defer th.TearDown()
+
+	ctx := context.TODO()
+	successors, err := th.CreateUsers(1, "successor")
+	require.NoError(t, err)
+	successor := successors[0]
+
+	// User1 creates the channel, so is its only admin
+	channel, _, err := th.UserClient.CreateChannel(ctx, &model.Channel{
+		Name:        "private-handover",
+		DisplayName: "Private handover",
+		Type:        model.ChannelTypePrivate,
+		TeamId:      th.Team1.Id,
+	})
+	require.NoError(t, err)
+	_, _, err = th.UserClient.AddChannelMember(ctx, channel.Id, successor.Id)
+	require.NoError(t, err)
+
+	api := &plugintest.API{}
+	api.On("GetTeamMembersForUser", th.User1.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: th.Team1.Id}, {TeamId: th.Team2.Id}}, nil)
+	mockChannelMembers(api, th.User1.Id, map[string][]*model.ChannelMember{
+		th.Team1.Id: {{ChannelId: channel.Id, UserId: th.User1.Id, SchemeAdmin: true}},
+		th.Team2.Id: {},
+	})
+	api.On("GetChannel", channel.Id).Return(channel, nil)
+	api.On("UpdateChannelMemberRoles", channel.Id, successor.Id, model.ChannelUserRoleId+" "+model.ChannelAdminRoleId).Return(&model.ChannelMember{}, nil)
+	api.On("GetUser", successor.Id).Return(successor, nil)
+
+	remover := NewRemover(api, th.Store, nil)
+	handovers, err := remover.HandOverChannels(th.User1, &HandoverOpts{})
+	require.NoError(t, err)
+	assert.Equal(t, []AdminHandover{{
+		ChannelID:         channel.Id,
+		ChannelName:       channel.Name,
+		SuccessorID:       successor.Id,
+		SuccessorUsername: successor.Username,
+		Reason:            HandoverReasonLongestStanding,
+	}}, handovers)
+}
@@ -0,0 +1,208 @@
+package users
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	BotActionDisabled   = "disabled"   // the bot was deactivated
+	BotActionReassigned = "reassigned" // the bot is now owned by the integration owner
+
+	// botsPerPage is the number of bots owned by a user fetched at a time.
+	botsPerPage = 200
+)
+
+// IntegrationOpts controls what happens to the access tokens, bots and integrations of a removed user.
+type IntegrationOpts struct {
+	RevokeTokens       bool   // revoke the user's personal access tokens
+	BotsAction         string // what happens to the bots the user owns, see config.OwnedBotsDisable
+	IntegrationsAction string // what happens to the webhooks and slash commands the user created, see config.IntegrationsReport
+	Owner              string // user taking over reassigned bots and integrations: username, email or user ID
+}
+
+// NewIntegrationOpts returns the configured integration options, or nil if the access tokens, bots
+// and integrations of removed users are all kept.
+func NewIntegrationOpts(revokeTokens bool, botsAction string, integrationsAction string, owner string) *IntegrationOpts {
+	if !revokeTokens && (botsAction == "" || botsAction == config.OwnedBotsKeep) &&
+		(integrationsAction == "" || integrationsAction == config.IntegrationsKeep) {
+		return nil
+	}
+	return &IntegrationOpts{
+		RevokeTokens:       revokeTokens,
+		BotsAction:         botsAction,
+		IntegrationsAction: integrationsAction,
+		Owner:              owner,
+	}
+}
+
+func (opts *IntegrationOpts) reassigns() bool {
+	return opts.BotsAction == config.OwnedBotsReassign || opts.IntegrationsAction == config.IntegrationsReassign
+}
+
+// OwnedBot records a bot owned by a removed user that was disabled or reassigned.
+type OwnedBot struct {
+	BotUserID  string `json:"bot_user_id"`
+	Username   string `json:"username"`
+	Action     string `json:"action"` // see BotActionDisabled
+	NewOwnerID string `json:"new_owner_id,omitempty"`
+}
+
+// OwnedIntegration records a webhook or slash command created by a removed user. NewOwnerID is empty
+// if the integration was only reported.
+type OwnedIntegration struct {
+	store.Integration
+	NewOwnerID string `json:"new_owner_id,omitempty"`
+}
+
+// processIntegrations revokes the access tokens of a removed user, and disables or reassigns the
+// bots and integrations they own, as set in the options. Failures are added to the report, and do
+// not stop the other steps. The steps reading or writing the database directly are skipped without
+// the SQL store.
+func (r *Remover) processIntegrations(user *model.User, opts *IntegrationOpts, report *RemovalReport) {
+	if opts == nil {
+		return
+	}
+	wrapErr := func(err error) error {
+		return errors.Wrapf(err, "failed to process integrations. user=%s", user.Username)
+	}
+
+	if opts.RevokeTokens {
+		if err := r.revokeAccessTokens(user, report); err != nil {
+			report.addFailure("", "", wrapErr(err))
+		}
+	}
+
+	var owner *model.User
+	if opts.reassigns() {
+		var err error
+		if owner, err = r.getIntegrationOwner(user, opts); err != nil {
+			// the integrations are still listed in the report, so they can be reassigned by hand
+			report.addFailure("", "", wrapErr(err))
+		}
+	}
+
+	switch opts.BotsAction {
+	case config.OwnedBotsDisable, config.OwnedBotsReassign:
+		if err := r.processOwnedBots(user, opts, owner, report); err != nil {
+			report.addFailure("", "", wrapErr(err))
+		}
+	}
+
+	switch opts.IntegrationsAction {
+	case config.IntegrationsReport, config.IntegrationsReassign:
+		if err := r.processOwnedIntegrations(user, opts, owner, report); err != nil {
+			report.addFailure("", "", wrapErr(err))
+		}
+	}
+}
+
+// getIntegrationOwner returns the active user taking over the bots and integrations of a removed user.
+func (r *Remover) getIntegrationOwner(user *model.User, opts *IntegrationOpts) (*model.User, error) {
+	if opts.Owner == "" {
+		return nil, errors.New("no integration owner is set")
+	}
+	owner, err := r.FindUser(opts.Owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find integration owner")
+	}
+	if owner.Id == user.Id {
+		return nil, errors.New("the integration owner cannot be the removed user")
+	}
+	if owner.DeleteAt > 0 {
+		return nil, errors.Errorf("integration owner %s is deactivated", owner.Username)
+	}
+	return owner, nil
+}
+
+// revokeAccessTokens revokes all personal access tokens of a user, which also ends their sessions.
+func (r *Remover) revokeAccessTokens(user *model.User, report *RemovalReport) error {
+	if r.sqlstore == nil {
+		return nil
+	}
+
+	tokenIDs, err := r.sqlstore.GetUserAccessTokenIDs(user.Id)
+	if err != nil {
+		return errors.Wrap(err, "failed to get access tokens")
+	}
+	for _, tokenID := range tokenIDs {
+		if appErr := r.papi.RevokeUserAccessToken(tokenID); appErr != nil {
+			return errors.Wrapf(appErr, "failed to revoke access token %s", tokenID)
+		}
+		report.TokensRevoked++
+	}
+	return nil
+}
+
+// processOwnedBots disables the bots owned by a user, or reassigns them to the owner. Reassigned
+// bots include the ones already disabled, such as by the server when their owner was deactivated.
+func (r *Remover) processOwnedBots(user *model.User, opts *IntegrationOpts, owner *model.User, report *RemovalReport) error {
+	reassign := opts.BotsAction == config.OwnedBotsReassign
+	if reassign && (owner == nil || r.sqlstore == nil) {
+		return nil
+	}
+
+	// all pages are fetched first, as disabled bots drop out of the list
+	bots := make([]*model.Bot, 0)
+	for page := 0; ; page++ {
+		pageBots, appErr := r.papi.GetBots(&model.BotGetOptions{
+			OwnerId:        user.Id,
+			IncludeDeleted: reassign,
+			Page:           page,
+			PerPage:        botsPerPage,
+		})
+		if appErr != nil {
+			return errors.Wrap(appErr, "failed to get owned bots")
+		}
+		bots = append(bots, pageBots...)
+		if len(pageBots) < botsPerPage {
+			break
+		}
+	}
+
+	for _, bot := range bots {
+		if reassign {
+			if err := r.sqlstore.ReassignBot(bot.UserId, owner.Id); err != nil {
+				return errors.Wrapf(err, "failed to reassign bot %s", bot.Username)
+			}
+			report.Bots = append(report.Bots, OwnedBot{BotUserID: bot.UserId, Username: bot.Username, Action: BotActionReassigned, NewOwnerID: owner.Id})
+			continue
+		}
+
+		if _, appErr := r.papi.UpdateBotActive(bot.UserId, false); appErr != nil {
+			return errors.Wrapf(appErr, "failed to disable bot %s", bot.Username)
+		}
+		report.Bots = append(report.Bots, OwnedBot{BotUserID: bot.UserId, Username: bot.Username, Action: BotActionDisabled})
+	}
+	return nil
+}
+
+// processOwnedIntegrations lists the webhooks and slash commands created by a user in the report,
+// reassigning them to the owner if set in the options.
+func (r *Remover) processOwnedIntegrations(user *model.User, opts *IntegrationOpts, owner *model.User, report *RemovalReport) error {
+	if r.sqlstore == nil {
+		return nil
+	}
+
+	integrations, err := r.sqlstore.GetIntegrationsCreatedBy(user.Id)
+	if err != nil {
+		return errors.Wrap(err, "failed to get integrations")
+	}
+
+	reassign := opts.IntegrationsAction == config.IntegrationsReassign && owner != nil
+	for _, integration := range integrations {
+		owned := OwnedIntegration{Integration: *integration}
+		if reassign {
+			if err := r.sqlstore.ReassignIntegration(integration, owner.Id); err != nil {
+				return errors.Wrapf(err, "failed to reassign %s %s", integration.Type, integration.ID)
+			}
+			owned.NewOwnerID = owner.Id
+		}
+		report.Integrations = append(report.Integrations, owned)
+	}
+	return nil
+}
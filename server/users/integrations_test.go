@@ -0,0 +1,68 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestNewIntegrationOpts(t *testing.T) {
+	assert.Nil(t, NewIntegrationOpts(false, config.OwnedBotsKeep, config.IntegrationsKeep, "it-admin"))
+	assert.Nil(t, NewIntegrationOpts(false, "", "", ""))
+
+	opts := NewIntegrationOpts(true, config.OwnedBotsKeep, config.IntegrationsKeep, "")
+	require.NotNil(t, opts)
+	assert.True(t, opts.RevokeTokens)
+	assert.False(t, opts.reassigns())
+
+	opts = NewIntegrationOpts(false, config.OwnedBotsKeep, config.IntegrationsReassign, "it-admin")
+	require.NotNil(t, opts)
+	assert.True(t, opts.reassigns())
+	assert.Equal(t, "it-admin", opts.Owner)
+}
+
+func TestProcessIntegrations(t *testing.T) {
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	t.Run("owned bots are disabled", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+
+		api.On("GetBots", &model.BotGetOptions{OwnerId: user.Id, PerPage: botsPerPage}).Return([]*model.Bot{
+			{UserId: "bot1", Username: "deploy-bot"},
+			{UserId: "bot2", Username: "alerts-bot"},
+		}, nil)
+		api.On("UpdateBotActive", "bot1", false).Return(&model.Bot{}, nil)
+		api.On("UpdateBotActive", "bot2", false).Return(nil, &model.AppError{DetailedError: "some database error"})
+
+		report := newRemovalReport(user)
+		remover.processIntegrations(user, &IntegrationOpts{BotsAction: config.OwnedBotsDisable}, report)
+		assert.Equal(t, []OwnedBot{{BotUserID: "bot1", Username: "deploy-bot", Action: BotActionDisabled}}, report.Bots)
+		require.Len(t, report.Failures, 1)
+		assert.Contains(t, report.Failures[0].Error, "failed to disable bot alerts-bot")
+	})
+
+	t.Run("reassigning needs an active owner", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+		api.On("GetUserByUsername", "it-admin").Return(&model.User{Id: "admin_id", Username: "it-admin", DeleteAt: 1000}, nil)
+
+		report := newRemovalReport(user)
+		remover.processIntegrations(user, &IntegrationOpts{BotsAction: config.OwnedBotsReassign}, report)
+		require.Len(t, report.Failures, 1)
+		assert.Contains(t, report.Failures[0].Error, "no integration owner is set")
+
+		report = newRemovalReport(user)
+		remover.processIntegrations(user, &IntegrationOpts{BotsAction: config.OwnedBotsReassign, Owner: "it-admin"}, report)
+		require.Len(t, report.Failures, 1)
+		assert.Contains(t, report.Failures[0].Error, "integration owner it-admin is deactivated")
+
+		api.AssertNotCalled(t, "GetBots")
+	})
+}
@@ -0,0 +1,142 @@
+package users
+
+import (
+	"path"
+	"slices"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+const (
+	KeepReasonTeam        = "team"         // the team is kept, along with its channels
+	KeepReasonChannel     = "channel"      // the channel is kept by name, ID or name pattern
+	KeepReasonKeptChannel = "kept_channel" // the team holds a kept channel, so the user stays in it
+)
+
+// KeepList lists the teams and channels removals leave a user in, such as compliance channels that
+// must keep deactivated users as members.
+type KeepList struct {
+	Teams           []string `json:"teams,omitempty"`            // team names or IDs, kept along with their channels
+	Channels        []string `json:"channels,omitempty"`         // channel names or IDs, kept along with their team
+	ChannelPatterns []string `json:"channel_patterns,omitempty"` // channel name patterns, such as compliance-*, see path.Match
+}
+
+// NewKeepList returns the keep-lists set in the configuration.
+func NewKeepList(cfg *config.Configuration) KeepList {
+	// the patterns are validated when the configuration changes
+	patterns, _ := cfg.GetRemovalKeepChannelPatterns()
+	return KeepList{
+		Teams:           cfg.GetRemovalKeepTeams(),
+		Channels:        cfg.GetRemovalKeepChannels(),
+		ChannelPatterns: patterns,
+	}
+}
+
+// Add returns the entries of both keep-lists.
+func (kl KeepList) Add(other KeepList) KeepList {
+	return KeepList{
+		Teams:           append(slices.Clone(kl.Teams), other.Teams...),
+		Channels:        append(slices.Clone(kl.Channels), other.Channels...),
+		ChannelPatterns: append(slices.Clone(kl.ChannelPatterns), other.ChannelPatterns...),
+	}
+}
+
+// Validate returns an error if a channel name pattern is malformed.
+func (kl KeepList) Validate() error {
+	return config.ValidateChannelPatterns(kl.ChannelPatterns)
+}
+
+func (kl KeepList) keepsChannels() bool {
+	return len(kl.Channels) > 0 || len(kl.ChannelPatterns) > 0
+}
+
+// matchTeam returns the entry keeping a team, or an empty string if the team is not kept.
+func (kl KeepList) matchTeam(team *model.Team) string {
+	for _, entry := range kl.Teams {
+		if entry == team.Id || entry == team.Name {
+			return entry
+		}
+	}
+	return ""
+}
+
+// matchChannel returns the entry or pattern keeping a channel, or an empty string if the channel is
+// not kept.
+func (kl KeepList) matchChannel(channel *model.Channel) string {
+	for _, entry := range kl.Channels {
+		if entry == channel.Id || entry == channel.Name {
+			return entry
+		}
+	}
+	for _, pattern := range kl.ChannelPatterns {
+		if ok, _ := path.Match(pattern, channel.Name); ok {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// PreservedMembership is a team or channel membership a removal left in place.
+type PreservedMembership struct {
+	TeamID    string `json:"team_id"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Name      string `json:"name,omitempty"`  // name of the channel, or of the team for team memberships
+	Reason    string `json:"reason"`          // see KeepReasonTeam
+	Entry     string `json:"entry,omitempty"` // keep-list entry or pattern the membership matched
+}
+
+// keptTeam returns the preserved membership if a team is excluded from the removal or kept, or nil
+// if the user is removed from it.
+func (r *Remover) keptTeam(teamID string, opts RemoveOpts) (*PreservedMembership, error) {
+	if slices.Contains(opts.ExcludeTeamIDs, teamID) {
+		return &PreservedMembership{TeamID: teamID, Reason: KeepReasonTeam}, nil
+	}
+	if len(opts.Keep.Teams) == 0 {
+		return nil, nil
+	}
+
+	team, appErr := r.papi.GetTeam(teamID)
+	if appErr != nil {
+		return nil, errors.Wrap(appErr, "failed to get team")
+	}
+	if entry := opts.Keep.matchTeam(team); entry != "" {
+		return &PreservedMembership{TeamID: teamID, Name: team.Name, Reason: KeepReasonTeam, Entry: entry}, nil
+	}
+	return nil, nil
+}
+
+// filterKeptChannels splits the channel memberships of a user in a team into the ones to remove and
+// the kept ones. When channels are kept, the team membership is listed as preserved too.
+func (r *Remover) filterKeptChannels(teamID string, channelMembers []*model.ChannelMember, keep KeepList) ([]*model.ChannelMember, []PreservedMembership, error) {
+	if !keep.keepsChannels() {
+		return channelMembers, nil, nil
+	}
+
+	remove := make([]*model.ChannelMember, 0, len(channelMembers))
+	preserved := make([]PreservedMembership, 0)
+	for _, cm := range channelMembers {
+		channel, appErr := r.papi.GetChannel(cm.ChannelId)
+		if appErr != nil {
+			return nil, nil, errors.Wrapf(appErr, "failed to get channel %s", cm.ChannelId)
+		}
+		if entry := keep.matchChannel(channel); entry != "" {
+			preserved = append(preserved, PreservedMembership{TeamID: teamID, ChannelID: channel.Id, Name: channel.Name, Reason: KeepReasonChannel, Entry: entry})
+			continue
+		}
+		remove = append(remove, cm)
+	}
+	if len(preserved) == 0 {
+		return remove, nil, nil
+	}
+
+	team, appErr := r.papi.GetTeam(teamID)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get team")
+	}
+	preserved = append([]PreservedMembership{{TeamID: teamID, Name: team.Name, Reason: KeepReasonKeptChannel}}, preserved...)
+	return remove, preserved, nil
+}
@@ -0,0 +1,52 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+func TestPlanRemovalKeptChannels(t *testing.T) {
+	api := &plugintest.API{}
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}, {TeamId: "team2"}}, nil)
+	api.On("GetTeam", "team1").Return(&model.Team{Id: "team1", Name: "engineering"}, nil)
+	api.On("GetTeam", "team2").Return(&model.Team{Id: "team2", Name: "sales"}, nil)
+	mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{
+		"team1": {{ChannelId: "channel1"}, {ChannelId: "channel2"}},
+		"team2": {{ChannelId: "channel3"}, {ChannelId: "channel4"}},
+	})
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "compliance-eng", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2", Name: "design", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannel", "channel3").Return(&model.Channel{Id: "channel3", Name: "deals", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannel", "channel4").Return(&model.Channel{Id: "channel4", Name: "leads", Type: model.ChannelTypeOpen}, nil)
+
+	plan, err := remover.PlanRemoval(user, RemoveOpts{
+		Keep: KeepList{Channels: []string{"channel3"}, ChannelPatterns: []string{"compliance-*"}},
+	})
+	require.NoError(t, err)
+
+	// both teams hold a kept channel, so the user stays in them
+	assert.Equal(t, 0, plan.TeamCount)
+	assert.Equal(t, 2, plan.ChannelCount)
+	require.Len(t, plan.Teams, 2)
+	assert.True(t, plan.Teams[0].Stays)
+	assert.Equal(t, "design", plan.Teams[0].Channels[0].ChannelName)
+	assert.True(t, plan.Teams[1].Stays)
+	assert.Equal(t, "leads", plan.Teams[1].Channels[0].ChannelName)
+	assert.Equal(t, []PreservedMembership{
+		{TeamID: "team1", Name: "engineering", Reason: KeepReasonKeptChannel},
+		{TeamID: "team1", ChannelID: "channel1", Name: "compliance-eng", Reason: KeepReasonChannel, Entry: "compliance-*"},
+		{TeamID: "team2", Name: "sales", Reason: KeepReasonKeptChannel},
+		{TeamID: "team2", ChannelID: "channel3", Name: "deals", Reason: KeepReasonChannel, Entry: "channel3"},
+	}, plan.Preserved)
+
+	// the direct message belongs to no team, so it is neither kept nor planned
+	api.AssertNotCalled(t, "GetChannel", "dm_channel")
+}
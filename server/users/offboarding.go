@@ -0,0 +1,740 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/kvstore"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+const (
+	offboardingKeyPrefix     = "offboard_"
+	offboardingRetention     = 30 * 24 * time.Hour    // completed offboardings are kept this long, failed ones until resumed
+	offboardingResumeTimeout = 30 * time.Second       // longer than the lock expiry, for locks left by a previous plugin instance
+	offboardingLockTimeout   = 500 * time.Millisecond // a single attempt, when resuming an offboarding on request
+
+	OffboardingStatusRunning   = "running"
+	OffboardingStatusCompleted = "completed"
+	OffboardingStatusFailed    = "failed" // a step failed; the offboarding stops there until resumed
+
+	StepStatusPending   = "pending"
+	StepStatusRunning   = "running"
+	StepStatusCompleted = "completed"
+	StepStatusFailed    = "failed"
+	StepStatusSkipped   = "skipped"
+
+	StepSkipNotConfigured  = "not_configured"   // the options the step needs are not set
+	StepSkipNoAdminChannel = "no_admin_channel" // no admin channel to post the export to
+	StepSkipNoManager      = "no_manager"       // no manager to notify
+)
+
+var (
+	// ErrOffboardingInProgress is returned when starting or resuming an offboarding that is running.
+	ErrOffboardingInProgress = errors.New("offboarding is already in progress")
+
+	// ErrNoOffboarding is returned when resuming the offboarding of a user that was never offboarded.
+	ErrNoOffboarding = errors.New("no offboarding found for user")
+
+	// ErrOffboardingNotFailed is returned when resuming an offboarding that neither failed nor was interrupted.
+	ErrOffboardingNotFailed = errors.New("only failed or interrupted offboardings can be resumed")
+
+	// ErrOffboardingBot is returned when offboarding a bot.
+	ErrOffboardingBot = errors.New("bots cannot be offboarded")
+)
+
+// Offboarding is the state of the offboarding of a user: the configured steps, in order, and the
+// outcome of each. It is stored in the KV store, so that an offboarding interrupted by a restart or
+// stopped by a failed step can be resumed where it stopped.
+type Offboarding struct {
+	UserID      string            `json:"user_id"`
+	Username    string            `json:"username"`
+	RequesterID string            `json:"requester_id"`
+	ManagerID   string            `json:"manager_id,omitempty"` // user notified by the notify_manager step
+	Status      string            `json:"status"`               // see OffboardingStatusRunning
+	StartAt     int64             `json:"start_at"`
+	EndAt       int64             `json:"end_at,omitempty"`
+	Steps       []OffboardingStep `json:"steps"`
+}
+
+// OffboardingStep is the outcome of one offboarding step.
+type OffboardingStep struct {
+	Name       string         `json:"name"`   // see config.OffboardingStepDeactivate
+	Status     string         `json:"status"` // see StepStatusPending
+	StartAt    int64          `json:"start_at,omitempty"`
+	EndAt      int64          `json:"end_at,omitempty"`
+	SkipReason string         `json:"skip_reason,omitempty"` // see StepSkipNotConfigured
+	Error      string         `json:"error,omitempty"`
+	Result     map[string]any `json:"result,omitempty"` // what the step did, such as the number of memberships removed
+}
+
+// Err returns the error of the failed step, or nil if no step failed.
+func (o *Offboarding) Err() error {
+	for _, step := range o.Steps {
+		if step.Status == StepStatusFailed {
+			return errors.Errorf("offboarding step %s failed: %s", step.Name, step.Error)
+		}
+	}
+	return nil
+}
+
+// stepStatus returns the status of a step, or an empty string if the offboarding does not run it.
+func (o *Offboarding) stepStatus(name string) string {
+	for _, step := range o.Steps {
+		if step.Name == name {
+			return step.Status
+		}
+	}
+	return ""
+}
+
+// OffboardOpts controls how users are offboarded.
+type OffboardOpts struct {
+	Steps        []string         // steps of new offboardings, in order; resumed offboardings keep theirs
+	Handover     *HandoverOpts    // optional, used by the handover_channels and remove_memberships steps
+	Integrations *IntegrationOpts // optional, used by the integrations step, or by the remove_memberships step without it
+	CleanupData  bool             // the remove_memberships step hides the user's direct messages and deletes their preferences, sidebar and threads
+	Keep         KeepList         // teams and channels the remove_memberships step leaves the user in
+	AdminChannel string           // optional channel receiving the data export and the summary of each offboarding
+
+	Bot    *bot.Bot     // bot posting the export and the summary, and notifying the manager
+	I18n   *i18n.Bundle // optional translations; posts are in English without it
+	Locale string       // locale of the admin channel posts, the server locale if empty
+}
+
+// NewOffboardOpts returns the offboarding options set in the configuration. Access tokens are
+// revoked by their own step, so the integration options leave them alone.
+func NewOffboardOpts(cfg *config.Configuration, bot *bot.Bot, bundle *i18n.Bundle) (OffboardOpts, error) {
+	steps, err := cfg.GetOffboardingSteps()
+	if err != nil {
+		return OffboardOpts{}, err
+	}
+	return OffboardOpts{
+		Steps:        steps,
+		Handover:     NewHandoverOpts(cfg.ChannelAdminSuccessor, cfg.FallbackChannelAdmin, bot, bundle),
+		Integrations: NewIntegrationOpts(false, cfg.OwnedBotsAction, cfg.IntegrationsAction, cfg.IntegrationOwner),
+		Keep:         NewKeepList(cfg),
+		CleanupData:  cfg.CleanupRemovedUserData,
+		AdminChannel: cfg.AdminChannel,
+		Bot:          bot,
+		I18n:         bundle,
+		Locale:       cfg.ChannelPostLocale,
+	}, nil
+}
+
+// Offboarder runs the offboarding steps of users in the background.
+type Offboarder struct {
+	papi    plugin.API
+	kv      *pluginapi.KVService
+	remover *Remover
+
+	mux     sync.Mutex
+	running map[string]context.CancelFunc // offboardings running on this server, by user ID
+}
+
+func NewOffboarder(papi plugin.API, sqlstore *store.SQLStore, auditLogger *audit.Logger) *Offboarder {
+	remover := NewRemover(papi, sqlstore, auditLogger)
+	return &Offboarder{
+		papi:    papi,
+		kv:      remover.kv,
+		remover: remover,
+		running: make(map[string]context.CancelFunc),
+	}
+}
+
+func offboardingKey(userID string) string {
+	return offboardingKeyPrefix + userID
+}
+
+// Get returns the offboarding of a user, or nil if the user was never offboarded.
+func (o *Offboarder) Get(userID string) (*Offboarding, error) {
+	var offboarding *Offboarding
+	if err := o.kv.Get(offboardingKey(userID), &offboarding); err != nil {
+		return nil, errors.Wrapf(err, "failed to get offboarding for user %s", userID)
+	}
+	return offboarding, nil
+}
+
+func (o *Offboarder) save(offboarding *Offboarding) error {
+	var setOpts []pluginapi.KVSetOption
+	if offboarding.Status == OffboardingStatusCompleted {
+		setOpts = append(setOpts, pluginapi.SetExpiry(offboardingRetention))
+	}
+	if _, err := o.kv.Set(offboardingKey(offboarding.UserID), offboarding, setOpts...); err != nil {
+		return errors.Wrapf(err, "failed to save offboarding for user %s", offboarding.UserID)
+	}
+	return nil
+}
+
+func newOffboarding(user *model.User, requesterID string, managerID string, steps []string) *Offboarding {
+	offboarding := &Offboarding{
+		UserID:      user.Id,
+		Username:    user.Username,
+		RequesterID: requesterID,
+		ManagerID:   managerID,
+		Status:      OffboardingStatusRunning,
+		StartAt:     model.GetMillis(),
+		Steps:       make([]OffboardingStep, 0, len(steps)),
+	}
+	for _, name := range steps {
+		offboarding.Steps = append(offboarding.Steps, OffboardingStep{Name: name, Status: StepStatusPending})
+	}
+	return offboarding
+}
+
+// Start offboards a user in the background, running the steps set in the options. An earlier
+// offboarding of the user is replaced, unless it is still running.
+func (o *Offboarder) Start(user *model.User, requesterID string, managerID string, opts OffboardOpts) (*Offboarding, error) {
+	if user.IsBot {
+		return nil, ErrOffboardingBot
+	}
+	if len(opts.Steps) == 0 {
+		return nil, errors.New("no offboarding steps are configured")
+	}
+
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if err := o.checkNotRunning(user.Id); err != nil {
+		return nil, errors.Wrap(err, user.Username)
+	}
+
+	offboarding := newOffboarding(user, requesterID, managerID, opts.Steps)
+	if err := o.save(offboarding); err != nil {
+		return nil, err
+	}
+
+	go o.runLocked(o.register(user.Id), user.Id, nil, opts)
+	return offboarding, nil
+}
+
+// Resume runs the failed step of an offboarding again, followed by the steps after it. An
+// offboarding left running by a server that stopped is continued, once no server holds its lock.
+func (o *Offboarder) Resume(userID string, opts OffboardOpts) (*Offboarding, error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if _, ok := o.running[userID]; ok {
+		return nil, ErrOffboardingInProgress
+	}
+	offboarding, err := o.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	if offboarding == nil {
+		return nil, ErrNoOffboarding
+	}
+
+	var mutex *cluster.Mutex
+	switch offboarding.Status {
+	case OffboardingStatusFailed:
+		for i := range offboarding.Steps {
+			if offboarding.Steps[i].Status == StepStatusFailed {
+				offboarding.Steps[i] = OffboardingStep{Name: offboarding.Steps[i].Name, Status: StepStatusPending}
+			}
+		}
+		offboarding.Status = OffboardingStatusRunning
+		offboarding.EndAt = 0
+		if err := o.save(offboarding); err != nil {
+			return nil, err
+		}
+	case OffboardingStatusRunning:
+		if mutex, err = o.tryLock(userID); err != nil {
+			return nil, err
+		}
+		if mutex == nil {
+			return nil, ErrOffboardingInProgress
+		}
+	default:
+		return nil, ErrOffboardingNotFailed
+	}
+
+	go o.runLocked(o.register(userID), userID, mutex, opts)
+	return offboarding, nil
+}
+
+// tryLock takes the cluster lock of an offboarding, returning nil if another server holds it.
+func (o *Offboarder) tryLock(userID string) (*cluster.Mutex, error) {
+	mutex, err := cluster.NewMutex(o.papi, offboardingKey(userID))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create offboarding lock")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), offboardingLockTimeout)
+	defer cancel()
+	if err := mutex.LockWithContext(ctx); err != nil {
+		return nil, nil
+	}
+	return mutex, nil
+}
+
+// checkNotRunning returns ErrOffboardingInProgress if the offboarding of a user is running, here or
+// on another server. Must be called with the lock held.
+func (o *Offboarder) checkNotRunning(userID string) error {
+	if _, ok := o.running[userID]; ok {
+		return ErrOffboardingInProgress
+	}
+	offboarding, err := o.Get(userID)
+	if err != nil {
+		return err
+	}
+	if offboarding != nil && offboarding.Status == OffboardingStatusRunning {
+		return ErrOffboardingInProgress
+	}
+	return nil
+}
+
+// register records an offboarding as running on this server. Must be called with the lock held.
+func (o *Offboarder) register(userID string) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	o.running[userID] = cancel
+	return ctx
+}
+
+func (o *Offboarder) unregister(userID string) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	if cancel, ok := o.running[userID]; ok {
+		cancel()
+		delete(o.running, userID)
+	}
+}
+
+// CancelAll stops the offboardings running on this server. They are resumed when the plugin starts again.
+func (o *Offboarder) CancelAll() {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+
+	for _, cancel := range o.running {
+		cancel()
+	}
+}
+
+// ResumeInterrupted resumes the offboardings interrupted by a plugin restart. Offboardings still
+// running on another server of the cluster are left alone.
+func (o *Offboarder) ResumeInterrupted(opts OffboardOpts) {
+	keys, err := kvstore.ListKeysWithPrefix(o.kv, offboardingKeyPrefix)
+	if err != nil {
+		o.papi.LogError("Cannot list offboardings.", "err", err.Error())
+		return
+	}
+
+	for _, key := range keys {
+		userID := strings.TrimPrefix(key, offboardingKeyPrefix)
+		offboarding, err := o.Get(userID)
+		if err != nil {
+			o.papi.LogError("Cannot get offboarding.", "user_id", userID, "err", err.Error())
+			continue
+		}
+		if offboarding == nil || offboarding.Status != OffboardingStatusRunning {
+			continue
+		}
+
+		go func(userID string) {
+			ctx, cancel := context.WithTimeout(context.Background(), offboardingResumeTimeout)
+			defer cancel()
+
+			mutex, err := cluster.NewMutex(o.papi, offboardingKey(userID))
+			if err != nil {
+				o.papi.LogError("Cannot create offboarding lock.", "user_id", userID, "err", err.Error())
+				return
+			}
+			if err := mutex.LockWithContext(ctx); err != nil {
+				// the offboarding is running on another server
+				return
+			}
+			defer mutex.Unlock()
+
+			o.mux.Lock()
+			if _, ok := o.running[userID]; ok {
+				o.mux.Unlock()
+				return
+			}
+			runCtx := o.register(userID)
+			o.mux.Unlock()
+			defer o.unregister(userID)
+
+			o.papi.LogInfo("Resuming offboarding.", "user_id", userID)
+			o.run(runCtx, userID, opts)
+		}(userID)
+	}
+}
+
+// runLocked runs an offboarding while holding a cluster lock, so that it is not resumed by another
+// server while it runs. The lock is taken unless the caller already holds it.
+func (o *Offboarder) runLocked(ctx context.Context, userID string, mutex *cluster.Mutex, opts OffboardOpts) {
+	defer o.unregister(userID)
+
+	if mutex == nil {
+		var err error
+		if mutex, err = cluster.NewMutex(o.papi, offboardingKey(userID)); err != nil {
+			o.papi.LogError("Cannot create offboarding lock.", "user_id", userID, "err", err.Error())
+			return
+		}
+		mutex.Lock()
+	}
+	defer mutex.Unlock()
+
+	o.run(ctx, userID, opts)
+}
+
+// run runs the pending steps of an offboarding in order, saving the outcome of each. The
+// offboarding stops at the first failed step. A step interrupted by a restart is run again, so steps
+// must be safe to repeat. Once canceled, the offboarding stops without finishing, so that it is
+// resumed later.
+func (o *Offboarder) run(ctx context.Context, userID string, opts OffboardOpts) {
+	offboarding, err := o.Get(userID)
+	if err != nil {
+		o.papi.LogError("Cannot run offboarding.", "user_id", userID, "err", err.Error())
+		return
+	}
+	if offboarding == nil || offboarding.Status != OffboardingStatusRunning {
+		return
+	}
+
+	for i := range offboarding.Steps {
+		step := &offboarding.Steps[i]
+		if step.Status != StepStatusPending && step.Status != StepStatusRunning {
+			continue
+		}
+		if ctx.Err() != nil {
+			o.papi.LogInfo("Stopped offboarding.", "user_id", userID, "step", step.Name)
+			return
+		}
+
+		step.Status = StepStatusRunning
+		step.StartAt = model.GetMillis()
+		if err := o.save(offboarding); err != nil {
+			o.papi.LogWarn("Cannot save offboarding progress.", "user_id", userID, "err", err.Error())
+		}
+
+		result, skipReason, err := o.runStep(step.Name, offboarding, opts)
+		step.EndAt = model.GetMillis()
+		step.Result = result
+		switch {
+		case err != nil:
+			step.Status = StepStatusFailed
+			step.Error = err.Error()
+		case skipReason != "":
+			step.Status = StepStatusSkipped
+			step.SkipReason = skipReason
+		default:
+			step.Status = StepStatusCompleted
+		}
+
+		if err != nil {
+			break
+		}
+		if err := o.save(offboarding); err != nil {
+			o.papi.LogWarn("Cannot save offboarding progress.", "user_id", userID, "err", err.Error())
+		}
+	}
+
+	offboarding.Status = OffboardingStatusCompleted
+	if offboarding.Err() != nil {
+		offboarding.Status = OffboardingStatusFailed
+	}
+	offboarding.EndAt = model.GetMillis()
+	if err := o.save(offboarding); err != nil {
+		o.papi.LogError("Cannot save finished offboarding.", "user_id", userID, "err", err.Error())
+	}
+
+	if err := o.postSummary(offboarding, opts); err != nil {
+		o.papi.LogWarn("Cannot post offboarding summary.", "user_id", userID, "err", err.Error())
+	}
+	o.logUserOffboarded(offboarding)
+	o.papi.LogInfo("Finished offboarding.", "user_id", userID, "status", offboarding.Status)
+}
+
+// runStep runs one offboarding step, returning what it did, or why it was skipped.
+func (o *Offboarder) runStep(name string, offboarding *Offboarding, opts OffboardOpts) (map[string]any, string, error) {
+	// the user is read for each step, as steps change it
+	user, appErr := o.papi.GetUser(offboarding.UserID)
+	if appErr != nil {
+		return nil, "", errors.Wrapf(appErr, "failed to get user %s", offboarding.UserID)
+	}
+
+	switch name {
+	case config.OffboardingStepDeactivate:
+		return o.deactivate(user)
+	case config.OffboardingStepHandover:
+		return o.handOverChannels(user, opts)
+	case config.OffboardingStepRemove:
+		return o.removeMemberships(user, offboarding, opts)
+	case config.OffboardingStepRevokeTokens:
+		return o.revokeTokens(user)
+	case config.OffboardingStepIntegrations:
+		return o.processIntegrations(user, opts)
+	case config.OffboardingStepExport:
+		return o.exportData(user, opts)
+	case config.OffboardingStepNotify:
+		return o.notifyManager(user, offboarding, opts)
+	default:
+		return nil, "", errors.Errorf("unknown offboarding step %s", name)
+	}
+}
+
+func (o *Offboarder) deactivate(user *model.User) (map[string]any, string, error) {
+	if user.DeleteAt > 0 {
+		return map[string]any{"already_deactivated": true}, "", nil
+	}
+	if appErr := o.papi.UpdateUserActive(user.Id, false); appErr != nil {
+		return nil, "", errors.Wrap(appErr, "failed to deactivate user")
+	}
+	return nil, "", nil
+}
+
+func (o *Offboarder) handOverChannels(user *model.User, opts OffboardOpts) (map[string]any, string, error) {
+	if opts.Handover == nil {
+		return nil, StepSkipNotConfigured, nil
+	}
+	handovers, err := o.remover.HandOverChannels(user, opts.Handover)
+	return map[string]any{"handovers": handovers}, "", err
+}
+
+func (o *Offboarder) removeMemberships(user *model.User, offboarding *Offboarding, opts OffboardOpts) (map[string]any, string, error) {
+	removeOpts := RemoveOpts{
+		RequesterID: offboarding.RequesterID,
+		Keep:        opts.Keep,
+		Handover:    opts.Handover,
+		CleanupData: opts.CleanupData,
+	}
+	// bots and integrations are handled here, unless the offboarding has a step of its own for them
+	if !slices.ContainsFunc(offboarding.Steps, func(step OffboardingStep) bool { return step.Name == config.OffboardingStepIntegrations }) {
+		removeOpts.Integrations = opts.Integrations
+	}
+
+	report, err := o.remover.RemoveFromAllTeams(user, removeOpts)
+	return map[string]any{
+		"teams_removed":    len(report.TeamsRemoved),
+		"channels_removed": len(report.ChannelsRemoved),
+		"handovers":        report.Handovers,
+		"preserved":        report.Preserved,
+		"bots":             report.Bots,
+		"integrations":     report.Integrations,
+		"data_cleanup":     report.DataCleanup,
+		"failures":         report.Failures,
+	}, "", err
+}
+
+func (o *Offboarder) revokeTokens(user *model.User) (map[string]any, string, error) {
+	report := newRemovalReport(user)
+	err := o.remover.revokeAccessTokens(user, report)
+	return map[string]any{"tokens_revoked": report.TokensRevoked}, "", err
+}
+
+func (o *Offboarder) processIntegrations(user *model.User, opts OffboardOpts) (map[string]any, string, error) {
+	if opts.Integrations == nil {
+		return nil, StepSkipNotConfigured, nil
+	}
+	report := newRemovalReport(user)
+	o.remover.processIntegrations(user, opts.Integrations, report)
+	return map[string]any{
+		"bots":         report.Bots,
+		"integrations": report.Integrations,
+	}, "", report.Err()
+}
+
+// OffboardingExport is the data of an offboarded user posted to the admin channel.
+type OffboardingExport struct {
+	ExportedAt   int64                `json:"exported_at"`
+	User         *model.User          `json:"user"`
+	Teams        []TeamSnapshot       `json:"teams"`        // memberships, including the ones removed by the plugin
+	Integrations []*store.Integration `json:"integrations"` // webhooks and slash commands created by the user
+}
+
+// exportData posts the profile, memberships and integrations of a user to the admin channel as a
+// JSON file. The memberships come from the membership snapshot if the user was removed already.
+func (o *Offboarder) exportData(user *model.User, opts OffboardOpts) (map[string]any, string, error) {
+	if opts.Bot == nil || opts.AdminChannel == "" {
+		return nil, StepSkipNoAdminChannel, nil
+	}
+
+	exportUser := *user
+	exportUser.Sanitize(map[string]bool{"email": true, "fullname": true})
+	export := OffboardingExport{
+		ExportedAt:   model.GetMillis(),
+		User:         &exportUser,
+		Teams:        []TeamSnapshot{},
+		Integrations: []*store.Integration{},
+	}
+
+	snapshot, err := o.remover.GetMembershipSnapshot(user.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	if snapshot != nil {
+		export.Teams = snapshot.Teams
+	} else if export.Teams, err = o.remover.currentMemberships(user); err != nil {
+		return nil, "", errors.Wrap(err, "failed to get memberships")
+	}
+
+	if o.remover.sqlstore != nil {
+		if export.Integrations, err = o.remover.sqlstore.GetIntegrationsCreatedBy(user.Id); err != nil {
+			return nil, "", errors.Wrap(err, "failed to get integrations")
+		}
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to encode export")
+	}
+	fileName := fmt.Sprintf("offboarding_%s_%s.json", user.Username, time.Now().UTC().Format("20060102"))
+	fileInfo, err := opts.Bot.UploadFile(bytes.NewBuffer(data), fileName, opts.AdminChannel)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to upload export")
+	}
+
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+	msg := loc.T(&i18n.Message{
+		ID:    "offboarding.export",
+		Other: "Data export for the offboarding of @{{.Username}}.",
+	}, map[string]any{"Username": user.Username})
+	if err := opts.Bot.SendPostWithAttachment(opts.AdminChannel, msg, fileInfo); err != nil {
+		return nil, "", errors.Wrap(err, "failed to post export")
+	}
+	return map[string]any{"file_id": fileInfo.Id}, "", nil
+}
+
+// notifyManager tells the manager of an offboarded user by direct message, in the manager's locale.
+func (o *Offboarder) notifyManager(user *model.User, offboarding *Offboarding, opts OffboardOpts) (map[string]any, string, error) {
+	if offboarding.ManagerID == "" {
+		return nil, StepSkipNoManager, nil
+	}
+	if opts.Bot == nil {
+		return nil, StepSkipNotConfigured, nil
+	}
+
+	manager, appErr := o.papi.GetUser(offboarding.ManagerID)
+	if appErr != nil {
+		return nil, "", errors.Wrapf(appErr, "failed to get manager %s", offboarding.ManagerID)
+	}
+
+	msg := managerNotification(user.Username, offboarding, opts.I18n.LocaleLocalizer(manager.Locale))
+	if err := opts.Bot.SendDirectPost(manager.Id, msg); err != nil {
+		return nil, "", errors.Wrap(err, "failed to notify manager")
+	}
+	return map[string]any{"manager_username": manager.Username}, "", nil
+}
+
+// managerNotification is the message sent to the manager of an offboarded user. It mentions the
+// deactivation and the membership removal only if the offboarding runs them, as done or to come.
+func managerNotification(username string, offboarding *Offboarding, loc *i18n.Localizer) string {
+	sentences := []string{loc.T(&i18n.Message{
+		ID:    "offboarding.notify_manager.intro",
+		Other: "@{{.Username}} is being offboarded.",
+	}, map[string]any{"Username": username})}
+
+	switch offboarding.stepStatus(config.OffboardingStepDeactivate) {
+	case StepStatusCompleted:
+		sentences = append(sentences, loc.T(&i18n.Message{
+			ID:    "offboarding.notify_manager.deactivated",
+			Other: "Their account has been deactivated.",
+		}, nil))
+	case StepStatusPending:
+		sentences = append(sentences, loc.T(&i18n.Message{
+			ID:    "offboarding.notify_manager.will_deactivate",
+			Other: "Their account will be deactivated.",
+		}, nil))
+	}
+	switch offboarding.stepStatus(config.OffboardingStepRemove) {
+	case StepStatusCompleted:
+		sentences = append(sentences, loc.T(&i18n.Message{
+			ID:    "offboarding.notify_manager.removed",
+			Other: "They have left their teams and channels.",
+		}, nil))
+	case StepStatusPending:
+		sentences = append(sentences, loc.T(&i18n.Message{
+			ID:    "offboarding.notify_manager.will_remove",
+			Other: "They will leave their teams and channels.",
+		}, nil))
+	}
+	sentences = append(sentences, loc.T(&i18n.Message{
+		ID:    "offboarding.notify_manager.handover",
+		Other: "Please reach out to your system admin if any of their work needs to be handed over.",
+	}, nil))
+
+	return strings.Join(sentences, " ")
+}
+
+// offboardingStatusNames are the localized step statuses of the offboarding summary.
+var offboardingStatusNames = map[string]*i18n.Message{
+	StepStatusPending:   {ID: "offboarding.status.pending", Other: "not run"},
+	StepStatusCompleted: {ID: "offboarding.status.completed", Other: "done"},
+	StepStatusFailed:    {ID: "offboarding.status.failed", Other: "failed"},
+	StepStatusSkipped:   {ID: "offboarding.status.skipped", Other: "skipped"},
+}
+
+// postSummary posts the outcome of each step of a finished offboarding to the admin channel.
+func (o *Offboarder) postSummary(offboarding *Offboarding, opts OffboardOpts) error {
+	if opts.Bot == nil || opts.AdminChannel == "" {
+		return nil
+	}
+
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+	var sb strings.Builder
+	if offboarding.Status == OffboardingStatusCompleted {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "offboarding.summary.completed",
+			Other: "Offboarding of @{{.Username}} completed:",
+		}, offboarding) + "\n")
+	} else {
+		sb.WriteString(loc.T(&i18n.Message{
+			ID:    "offboarding.summary.failed",
+			Other: "Offboarding of @{{.Username}} stopped at a failed step. Resume it with `/retention user offboard @{{.Username}}` once the problem is fixed:",
+		}, offboarding) + "\n")
+	}
+
+	for _, step := range offboarding.Steps {
+		line := fmt.Sprintf("- `%s`: %s", step.Name, loc.T(offboardingStatusNames[step.Status], nil))
+		if step.Error != "" {
+			line += " -- " + step.Error
+		}
+		sb.WriteString(line + "\n")
+	}
+
+	return opts.Bot.SendPost(opts.AdminChannel, sb.String())
+}
+
+// logUserOffboarded records a finished offboarding.
+func (o *Offboarder) logUserOffboarded(offboarding *Offboarding) {
+	steps := make(map[string]string, len(offboarding.Steps))
+	for _, step := range offboarding.Steps {
+		steps[step.Name] = step.Status
+	}
+
+	rec := audit.Record{
+		Event:   audit.EventUserOffboarded,
+		Status:  model.AuditStatusSuccess,
+		ActorID: offboarding.RequesterID,
+		Data: map[string]any{
+			"user_id":    offboarding.UserID,
+			"username":   offboarding.Username,
+			"manager_id": offboarding.ManagerID,
+			"steps":      steps,
+		},
+	}
+	if err := offboarding.Err(); err != nil {
+		rec.Status = model.AuditStatusFail
+		rec.Error = err.Error()
+	}
+	o.remover.audit.Log(rec)
+}
@@ -0,0 +1,151 @@
+package users
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/config"
+)
+
+func TestOffboarding(t *testing.T) {
+	user := &model.User{Id: "user_id", Username: "alice"}
+	steps := []string{
+		config.OffboardingStepDeactivate,
+		config.OffboardingStepRemove,
+		config.OffboardingStepRevokeTokens,
+		config.OffboardingStepExport,
+		config.OffboardingStepNotify,
+	}
+
+	setup := func(t *testing.T) (*plugintest.API, *Offboarder) {
+		api := &plugintest.API{}
+		mockKVStore(api)
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("LogInfo", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("GetUser", user.Id).Return(user, nil)
+		api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}}, nil)
+		mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{"team1": {{ChannelId: "channel1"}}})
+		api.On("DeleteChannelMember", "channel1", user.Id).Return(nil)
+		api.On("DeleteTeamMember", "team1", user.Id, "requester").Return(nil)
+
+		offboarder := NewOffboarder(api, nil, nil)
+		require.NoError(t, offboarder.save(newOffboarding(user, "requester", "", steps)))
+		return api, offboarder
+	}
+
+	t.Run("steps run in order", func(t *testing.T) {
+		api, offboarder := setup(t)
+		api.On("UpdateUserActive", user.Id, false).Return(nil)
+
+		offboarder.run(context.Background(), user.Id, OffboardOpts{})
+
+		offboarding, err := offboarder.Get(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, OffboardingStatusCompleted, offboarding.Status)
+		assert.NotZero(t, offboarding.EndAt)
+		require.Len(t, offboarding.Steps, len(steps))
+		for i, status := range []string{StepStatusCompleted, StepStatusCompleted, StepStatusCompleted, StepStatusSkipped, StepStatusSkipped} {
+			assert.Equal(t, status, offboarding.Steps[i].Status, offboarding.Steps[i].Name)
+		}
+		assert.Equal(t, float64(1), offboarding.Steps[1].Result["teams_removed"])
+		assert.Equal(t, StepSkipNoAdminChannel, offboarding.Steps[3].SkipReason)
+		assert.Equal(t, StepSkipNoManager, offboarding.Steps[4].SkipReason)
+
+		_, err = offboarder.Resume(user.Id, OffboardOpts{})
+		require.ErrorIs(t, err, ErrOffboardingNotFailed)
+	})
+
+	t.Run("a failed step stops the offboarding until resumed", func(t *testing.T) {
+		api, offboarder := setup(t)
+		calls := 0
+		api.On("UpdateUserActive", user.Id, false).Return(func(string, bool) *model.AppError {
+			calls++
+			if calls == 1 {
+				return &model.AppError{DetailedError: "some database error"}
+			}
+			return nil
+		})
+
+		offboarder.run(context.Background(), user.Id, OffboardOpts{})
+
+		offboarding, err := offboarder.Get(user.Id)
+		require.NoError(t, err)
+		assert.Equal(t, OffboardingStatusFailed, offboarding.Status)
+		assert.Equal(t, StepStatusFailed, offboarding.Steps[0].Status)
+		assert.Contains(t, offboarding.Steps[0].Error, "failed to deactivate user")
+		assert.Equal(t, StepStatusPending, offboarding.Steps[1].Status)
+		require.ErrorContains(t, offboarding.Err(), "offboarding step deactivate failed")
+		api.AssertNotCalled(t, "DeleteTeamMember", "team1", user.Id, "requester")
+
+		offboarding, err = offboarder.Resume(user.Id, OffboardOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, OffboardingStatusRunning, offboarding.Status)
+		assert.Equal(t, StepStatusPending, offboarding.Steps[0].Status)
+
+		require.Eventually(t, func() bool {
+			offboarding, err := offboarder.Get(user.Id)
+			return err == nil && offboarding.Status == OffboardingStatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+		api.AssertCalled(t, "DeleteTeamMember", "team1", user.Id, "requester")
+	})
+
+	t.Run("interrupted offboardings are continued once unlocked", func(t *testing.T) {
+		api, offboarder := setup(t)
+		api.On("UpdateUserActive", user.Id, false).Return(nil)
+
+		mutex, err := cluster.NewMutex(api, offboardingKey(user.Id))
+		require.NoError(t, err)
+		mutex.Lock()
+		_, err = offboarder.Resume(user.Id, OffboardOpts{})
+		require.ErrorIs(t, err, ErrOffboardingInProgress)
+		mutex.Unlock()
+
+		offboarding, err := offboarder.Resume(user.Id, OffboardOpts{})
+		require.NoError(t, err)
+		assert.Equal(t, OffboardingStatusRunning, offboarding.Status)
+
+		require.Eventually(t, func() bool {
+			offboarding, err := offboarder.Get(user.Id)
+			return err == nil && offboarding.Status == OffboardingStatusCompleted
+		}, 5*time.Second, 10*time.Millisecond)
+		api.AssertCalled(t, "DeleteTeamMember", "team1", user.Id, "requester")
+	})
+
+	t.Run("running offboardings are not started again", func(t *testing.T) {
+		_, offboarder := setup(t)
+
+		_, err := offboarder.Start(user, "requester", "", OffboardOpts{Steps: steps})
+		require.ErrorIs(t, err, ErrOffboardingInProgress)
+
+		_, err = offboarder.Start(&model.User{Id: "bot_id", IsBot: true}, "requester", "", OffboardOpts{Steps: steps})
+		require.ErrorIs(t, err, ErrOffboardingBot)
+	})
+}
+
+func TestManagerNotification(t *testing.T) {
+	offboarding := &Offboarding{Steps: []OffboardingStep{
+		{Name: config.OffboardingStepDeactivate, Status: StepStatusCompleted},
+		{Name: config.OffboardingStepNotify, Status: StepStatusRunning},
+		{Name: config.OffboardingStepRemove, Status: StepStatusPending},
+	}}
+	assert.Equal(t, "@alice is being offboarded. Their account has been deactivated. They will leave their teams and channels. Please reach out to your system admin if any of their work needs to be handed over.",
+		managerNotification("alice", offboarding, nil))
+
+	// steps the offboarding does not run are not mentioned
+	offboarding = &Offboarding{Steps: []OffboardingStep{
+		{Name: config.OffboardingStepNotify, Status: StepStatusRunning},
+		{Name: config.OffboardingStepDeactivate, Status: StepStatusPending},
+	}}
+	assert.Equal(t, "@alice is being offboarded. Their account will be deactivated. Please reach out to your system admin if any of their work needs to be handed over.",
+		managerNotification("alice", offboarding, nil))
+}
@@ -0,0 +1,59 @@
+package users
+
+import (
+	"fmt"
+
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/kvstore"
+)
+
+const pendingRemovalKeyPrefix = "deact_"
+
+// PendingRemoval records a deactivated user to be removed from all teams and channels once the
+// configured delay has passed.
+type PendingRemoval struct {
+	UserID        string `json:"user_id"`
+	DeactivatedAt int64  `json:"deactivated_at"`
+	RemoveAt      int64  `json:"remove_at"` // the user is not removed before this time
+}
+
+func pendingRemovalKey(userID string) string {
+	return pendingRemovalKeyPrefix + userID
+}
+
+// SavePendingRemoval stores a pending removal, replacing any existing one for the user.
+func SavePendingRemoval(client *pluginapi.Client, pending *PendingRemoval) error {
+	if _, err := client.KV.Set(pendingRemovalKey(pending.UserID), pending); err != nil {
+		return fmt.Errorf("cannot save pending removal for user %s: %w", pending.UserID, err)
+	}
+	return nil
+}
+
+// DeletePendingRemoval removes the pending removal of a user.
+func DeletePendingRemoval(client *pluginapi.Client, userID string) error {
+	if err := client.KV.Delete(pendingRemovalKey(userID)); err != nil {
+		return fmt.Errorf("cannot delete pending removal for user %s: %w", userID, err)
+	}
+	return nil
+}
+
+// GetDuePendingRemovals returns the pending removals whose delay has passed at the given time (millis).
+func GetDuePendingRemovals(client *pluginapi.Client, now int64) ([]*PendingRemoval, error) {
+	keys, err := kvstore.ListKeysWithPrefix(&client.KV, pendingRemovalKeyPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("cannot list pending removals: %w", err)
+	}
+
+	due := make([]*PendingRemoval, 0)
+	for _, key := range keys {
+		var pending *PendingRemoval
+		if err := client.KV.Get(key, &pending); err != nil {
+			return nil, fmt.Errorf("cannot get pending removal %s: %w", key, err)
+		}
+		if pending != nil && pending.RemoveAt <= now {
+			due = append(due, pending)
+		}
+	}
+	return due, nil
+}
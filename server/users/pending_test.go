@@ -0,0 +1,36 @@
+package users
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+)
+
+func TestGetDuePendingRemovals(t *testing.T) {
+	api := &plugintest.API{}
+	client := pluginapi.NewClient(api, nil)
+
+	marshal := func(pending *PendingRemoval) []byte {
+		data, err := json.Marshal(pending)
+		require.NoError(t, err)
+		return data
+	}
+
+	api.On("KVList", 0, 1000).Return([]string{"deact_user1", "deact_user2", "keep_channel1"}, nil)
+	api.On("KVGet", "deact_user1").Return(marshal(&PendingRemoval{UserID: "user1", RemoveAt: 1000}), nil)
+	api.On("KVGet", "deact_user2").Return(marshal(&PendingRemoval{UserID: "user2", RemoveAt: 3000}), nil)
+
+	due, err := GetDuePendingRemovals(client, 2000)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+	assert.Equal(t, "user1", due[0].UserID)
+
+	due, err = GetDuePendingRemovals(client, 3000)
+	require.NoError(t, err)
+	assert.Len(t, due, 2)
+}
@@ -0,0 +1,153 @@
+package users
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	FailReasonDefaultChannel = "default_channel" // users cannot leave the default channel, they leave it with the team
+	FailReasonArchived       = "archived"        // members cannot be removed from archived channels
+)
+
+// RemovalPlan lists the memberships a removal would remove, without removing anything.
+type RemovalPlan struct {
+	UserID       string                `json:"user_id"`
+	Username     string                `json:"username"`
+	TeamCount    int                   `json:"team_count"`
+	ChannelCount int                   `json:"channel_count"`
+	Teams        []TeamPlan            `json:"teams"`
+	Preserved    []PreservedMembership `json:"preserved"` // memberships the removal would leave in place
+}
+
+// TeamPlan lists the channel memberships of a user in a team the user would be removed from.
+type TeamPlan struct {
+	TeamID   string        `json:"team_id"`
+	TeamName string        `json:"team_name"`
+	Channels []ChannelPlan `json:"channels"`
+	Stays    bool          `json:"stays,omitempty"` // the user would stay in the team, as it holds kept channels
+}
+
+// ChannelPlan describes a channel membership a removal would remove.
+type ChannelPlan struct {
+	ChannelID   string            `json:"channel_id"`
+	ChannelName string            `json:"channel_name"`
+	Type        model.ChannelType `json:"type"`
+	FailReason  string            `json:"fail_reason,omitempty"` // why removing the membership would fail, see FailReasonDefaultChannel
+	LastAdmin   bool              `json:"last_admin,omitempty"`  // private channel left without active admins
+	LastMember  bool              `json:"last_member,omitempty"` // private channel left without active members
+}
+
+// Flagged returns true if the membership needs attention before the removal.
+func (cp ChannelPlan) Flagged() bool {
+	return cp.FailReason != "" || cp.LastAdmin || cp.LastMember
+}
+
+// PlanRemoval returns the team and channel memberships RemoveFromAllTeams would remove for a user,
+// flagging the channels where removal would fail and the private channels the user is the last
+// admin or member of.
+func (r *Remover) PlanRemoval(user *model.User, opts RemoveOpts) (*RemovalPlan, error) {
+	plan := &RemovalPlan{
+		UserID:    user.Id,
+		Username:  user.Username,
+		Teams:     make([]TeamPlan, 0),
+		Preserved: make([]PreservedMembership, 0),
+	}
+
+	teamMembers, err := r.getTeamMembers(user)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get team members for user. user=%s", user.Username)
+	}
+
+	var memberships channelMemberships
+	for _, tm := range teamMembers {
+		wrapErr := func(err error) error {
+			return errors.Wrapf(err, "failed to plan team member. user=%s team=%s", user.Username, tm.TeamId)
+		}
+
+		kept, err := r.keptTeam(tm.TeamId, opts)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		if kept != nil {
+			plan.Preserved = append(plan.Preserved, *kept)
+			continue
+		}
+
+		teamPlan, preserved, err := r.planTeam(user, tm.TeamId, &memberships, opts.Keep)
+		if err != nil {
+			return nil, wrapErr(err)
+		}
+		plan.Teams = append(plan.Teams, *teamPlan)
+		plan.Preserved = append(plan.Preserved, preserved...)
+		if !teamPlan.Stays {
+			plan.TeamCount++
+		}
+		plan.ChannelCount += len(teamPlan.Channels)
+	}
+
+	return plan, nil
+}
+
+func (r *Remover) planTeam(user *model.User, teamID string, memberships *channelMemberships, keep KeepList) (*TeamPlan, []PreservedMembership, error) {
+	team, appErr := r.papi.GetTeam(teamID)
+	if appErr != nil {
+		return nil, nil, errors.Wrap(appErr, "failed to get team")
+	}
+
+	channelMembers, err := r.getChannelMembers(user, teamID, memberships)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to get channel members")
+	}
+	channelMembers, preserved, err := r.filterKeptChannels(teamID, channelMembers, keep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	teamPlan := &TeamPlan{
+		TeamID:   team.Id,
+		TeamName: team.Name,
+		Channels: make([]ChannelPlan, 0, len(channelMembers)),
+		Stays:    len(preserved) > 0,
+	}
+	for _, cm := range channelMembers {
+		channelPlan, err := r.planChannel(user, cm)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to plan channel member. channel=%s", cm.ChannelId)
+		}
+		teamPlan.Channels = append(teamPlan.Channels, *channelPlan)
+	}
+	return teamPlan, preserved, nil
+}
+
+func (r *Remover) planChannel(user *model.User, cm *model.ChannelMember) (*ChannelPlan, error) {
+	channel, appErr := r.papi.GetChannel(cm.ChannelId)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get channel %s", cm.ChannelId)
+	}
+
+	channelPlan := &ChannelPlan{
+		ChannelID:   channel.Id,
+		ChannelName: channel.Name,
+		Type:        channel.Type,
+	}
+
+	switch {
+	case channel.Name == model.DefaultChannelName:
+		channelPlan.FailReason = FailReasonDefaultChannel
+	case channel.DeleteAt > 0:
+		channelPlan.FailReason = FailReasonArchived
+	}
+
+	if channel.Type == model.ChannelTypePrivate && r.sqlstore != nil {
+		counts, err := r.sqlstore.GetChannelMemberCounts(channel.Id, user.Id)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to count channel members")
+		}
+		channelPlan.LastAdmin = cm.SchemeAdmin && counts.Admins == 0
+		channelPlan.LastMember = counts.Members == 0
+	}
+
+	return channelPlan, nil
+}
@@ -0,0 +1,53 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+func TestPlanRemoval(t *testing.T) {
+	api := &plugintest.API{}
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	api.On("GetTeamMembersForUser", user.Id, 0, 1000).Return([]*model.TeamMember{{TeamId: "team1"}, {TeamId: "team2"}, {TeamId: "team3"}}, nil)
+	api.On("GetTeam", "team1").Return(&model.Team{Id: "team1", Name: "engineering"}, nil)
+	api.On("GetTeam", "team3").Return(&model.Team{Id: "team3", Name: "sales"}, nil)
+	mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{
+		"team1": {{ChannelId: "channel1"}, {ChannelId: "channel2"}, {ChannelId: "channel3"}},
+		"team3": {{ChannelId: "channel4"}},
+	})
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: model.DefaultChannelName, Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2", Name: "old", Type: model.ChannelTypeOpen, DeleteAt: 1}, nil)
+	api.On("GetChannel", "channel3").Return(&model.Channel{Id: "channel3", Name: "design", Type: model.ChannelTypeOpen}, nil)
+	api.On("GetChannel", "channel4").Return(&model.Channel{Id: "channel4", Name: "deals", Type: model.ChannelTypeOpen}, nil)
+
+	// team2 is excluded, nothing is fetched for it, and the direct message belongs to no team
+	plan, err := remover.PlanRemoval(user, RemoveOpts{ExcludeTeamIDs: []string{"team2"}})
+	require.NoError(t, err)
+	api.AssertExpectations(t)
+	api.AssertNotCalled(t, "DeleteChannelMember")
+	api.AssertNotCalled(t, "DeleteTeamMember")
+
+	assert.Equal(t, "alice", plan.Username)
+	assert.Equal(t, 2, plan.TeamCount)
+	assert.Equal(t, 4, plan.ChannelCount)
+	require.Len(t, plan.Teams, 2)
+	assert.Equal(t, "engineering", plan.Teams[0].TeamName)
+
+	// the memberships of all teams come at once, and are split by team
+	assert.Equal(t, "sales", plan.Teams[1].TeamName)
+	require.Len(t, plan.Teams[1].Channels, 1)
+	assert.Equal(t, "deals", plan.Teams[1].Channels[0].ChannelName)
+
+	channels := plan.Teams[0].Channels
+	require.Len(t, channels, 3)
+	assert.Equal(t, FailReasonDefaultChannel, channels[0].FailReason)
+	assert.Equal(t, FailReasonArchived, channels[1].FailReason)
+	assert.False(t, channels[2].Flagged())
+}
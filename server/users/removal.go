@@ -0,0 +1,384 @@
+package users
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// RemoveOpts controls how a user is removed from their teams and channels.
+type RemoveOpts struct {
+	RequesterID    string   // user requesting the removal, recorded as the actor
+	ExcludeTeamIDs []string // teams the user stays in, along with their channels
+	Keep           KeepList // teams and channels the user stays in, by name, ID or channel name pattern
+
+	Handover     *HandoverOpts    // optional, hands over the private channels the user is the only admin of
+	Integrations *IntegrationOpts // optional, revokes the user's access tokens and handles the bots and integrations they own
+	CleanupData  bool             // hides the user's direct and group messages, and deletes their preferences, sidebar and threads
+
+	ProgressFn func(report *RemovalReport) // optional, called after each membership is removed
+}
+
+func (opts RemoveOpts) progress(report *RemovalReport) {
+	if opts.ProgressFn != nil {
+		opts.ProgressFn(report)
+	}
+}
+
+// Remover removes users from all teams and channels.
+type Remover struct {
+	papi     plugin.API
+	kv       *pluginapi.KVService // membership snapshots
+	sqlstore *store.SQLStore      // optional, used for plans, channel handovers, integrations and data cleanup of removed users
+	audit    *audit.Logger
+}
+
+func NewRemover(papi plugin.API, sqlstore *store.SQLStore, auditLogger *audit.Logger) *Remover {
+	client := pluginapi.NewClient(papi, nil)
+	return &Remover{
+		papi:     papi,
+		kv:       &client.KV,
+		sqlstore: sqlstore,
+		audit:    auditLogger,
+	}
+}
+
+// FindUser looks up a user by ID, email or username. Identifiers that look like IDs are tried as
+// usernames too, as usernames can have the same shape.
+func (r *Remover) FindUser(identifier string) (*model.User, error) {
+	if strings.Contains(identifier, "@") && !strings.HasPrefix(identifier, "@") {
+		user, appErr := r.papi.GetUserByEmail(identifier)
+		if appErr != nil {
+			return nil, errors.Wrapf(appErr, "failed to get user with email %s", identifier)
+		}
+		return user, nil
+	}
+
+	if model.IsValidId(identifier) {
+		if user, appErr := r.papi.GetUser(identifier); appErr == nil {
+			return user, nil
+		}
+	}
+
+	username := strings.TrimPrefix(identifier, "@")
+	user, appErr := r.papi.GetUserByUsername(username)
+	if appErr != nil {
+		return nil, errors.Wrapf(appErr, "failed to get user %s", identifier)
+	}
+	return user, nil
+}
+
+// membersPerPage is the number of team or channel memberships fetched at a time.
+const membersPerPage = 1000
+
+// RemovalReport is the outcome of removing a user from all teams and channels. Memberships that
+// could not be removed are listed in Failures, so that a retry only touches the remaining ones.
+type RemovalReport struct {
+	UserID          string                `json:"user_id"`
+	Username        string                `json:"username"`
+	TeamsRemoved    []string              `json:"teams_removed"`    // IDs of the teams the user was removed from
+	ChannelsRemoved []string              `json:"channels_removed"` // IDs of the channels the user was removed from
+	Failures        []RemovalFailure      `json:"failures"`
+	Handovers       []AdminHandover       `json:"handovers"` // private channels handed over to a new admin
+	Preserved       []PreservedMembership `json:"preserved"` // memberships left in place, as they are kept
+
+	TokensRevoked int                `json:"tokens_revoked"` // number of personal access tokens revoked
+	Bots          []OwnedBot         `json:"bots"`           // bots owned by the user that were disabled or reassigned
+	Integrations  []OwnedIntegration `json:"integrations"`   // webhooks and slash commands created by the user
+
+	DataCleanup *store.UserDataCounts `json:"data_cleanup,omitempty"` // rows changed by the data cleanup, if it ran
+}
+
+// RemovalFailure is a team or channel membership that could not be removed.
+type RemovalFailure struct {
+	TeamID    string `json:"team_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Error     string `json:"error"`
+}
+
+func newRemovalReport(user *model.User) *RemovalReport {
+	return &RemovalReport{
+		UserID:          user.Id,
+		Username:        user.Username,
+		TeamsRemoved:    []string{},
+		ChannelsRemoved: []string{},
+		Failures:        []RemovalFailure{},
+		Handovers:       []AdminHandover{},
+		Preserved:       []PreservedMembership{},
+		Bots:            []OwnedBot{},
+		Integrations:    []OwnedIntegration{},
+	}
+}
+
+func (rr *RemovalReport) addFailure(teamID string, channelID string, err error) {
+	rr.Failures = append(rr.Failures, RemovalFailure{TeamID: teamID, ChannelID: channelID, Error: err.Error()})
+}
+
+// RemovedAny returns true if the user was removed from at least one team or channel.
+func (rr *RemovalReport) RemovedAny() bool {
+	return len(rr.TeamsRemoved) > 0 || len(rr.ChannelsRemoved) > 0
+}
+
+// Err returns an error summarizing the failures, or nil if all memberships were removed.
+func (rr *RemovalReport) Err() error {
+	switch len(rr.Failures) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(rr.Failures[0].Error)
+	default:
+		return errors.Errorf("failed to remove %d memberships. first error: %s", len(rr.Failures), rr.Failures[0].Error)
+	}
+}
+
+// RemoveFromAllTeams removes a user from all channels and teams. The memberships are saved to the
+// user's membership snapshot first, so they can be restored later. A membership that cannot be
+// removed does not stop the removal; the user then stays in the team of a channel that could not be
+// left. The returned error summarizes the failures listed in the report.
+func (r *Remover) RemoveFromAllTeams(user *model.User, opts RemoveOpts) (*RemovalReport, error) {
+	report := newRemovalReport(user)
+	abort := func(err error) (*RemovalReport, error) {
+		report.addFailure("", "", err)
+		r.logUserRemovedFromAllTeams(user, opts.RequesterID, report)
+		return report, err
+	}
+
+	teamMembers, err := r.getTeamMembers(user)
+	if err != nil {
+		return abort(errors.Wrapf(err, "failed to get team members for user. user=%s", user.Username))
+	}
+
+	// all memberships are read before anything is removed, so the snapshot is complete
+	teams := make([]TeamSnapshot, 0, len(teamMembers))
+	var memberships channelMemberships
+	channelMembers := make(map[string][]*model.ChannelMember, len(teamMembers))
+	keptTeams := make(map[string]bool)
+	for _, tm := range teamMembers {
+		wrapErr := func(err error) error {
+			return errors.Wrapf(err, "failed to process team member. user=%s team=%s", user.Username, tm.TeamId)
+		}
+
+		kept, err := r.keptTeam(tm.TeamId, opts)
+		if err != nil {
+			report.addFailure(tm.TeamId, "", wrapErr(err))
+			continue
+		}
+		if kept != nil {
+			report.Preserved = append(report.Preserved, *kept)
+			continue
+		}
+
+		members, err := r.getChannelMembers(user, tm.TeamId, &memberships)
+		if err != nil {
+			report.addFailure(tm.TeamId, "", wrapErr(errors.Wrap(err, "failed to get channel members")))
+			continue
+		}
+		members, preserved, err := r.filterKeptChannels(tm.TeamId, members, opts.Keep)
+		if err != nil {
+			report.addFailure(tm.TeamId, "", wrapErr(err))
+			continue
+		}
+		if len(preserved) > 0 {
+			report.Preserved = append(report.Preserved, preserved...)
+			keptTeams[tm.TeamId] = true
+		}
+		channelMembers[tm.TeamId] = members
+
+		team := TeamSnapshot{TeamID: tm.TeamId, Roles: tm.Roles, Channels: make([]ChannelSnapshot, 0, len(members))}
+		for _, cm := range members {
+			team.Channels = append(team.Channels, ChannelSnapshot{ChannelID: cm.ChannelId, Roles: cm.Roles})
+		}
+		teams = append(teams, team)
+	}
+
+	if len(teams) > 0 {
+		if err := r.saveMembershipSnapshot(user, teams); err != nil {
+			return abort(err)
+		}
+	}
+
+	for _, team := range teams {
+		r.processTeamMember(user, team.TeamID, channelMembers[team.TeamID], !keptTeams[team.TeamID], opts, report)
+	}
+
+	r.processIntegrations(user, opts.Integrations, report)
+	if opts.CleanupData {
+		r.cleanupUserData(user, report)
+	}
+
+	r.logUserRemovedFromAllTeams(user, opts.RequesterID, report)
+	r.papi.LogDebug("Finished for user.", "username", user.Username)
+
+	return report, report.Err()
+}
+
+// getTeamMembers returns all team memberships of a user. All pages are fetched before anything is
+// removed, as removals would shift the pages.
+func (r *Remover) getTeamMembers(user *model.User) ([]*model.TeamMember, error) {
+	members := make([]*model.TeamMember, 0)
+	for page := 0; ; page++ {
+		pageMembers, appErr := r.papi.GetTeamMembersForUser(user.Id, page, membersPerPage)
+		if appErr != nil {
+			return nil, appErr
+		}
+		members = append(members, pageMembers...)
+		if len(pageMembers) < membersPerPage {
+			return members, nil
+		}
+	}
+}
+
+// channelMemberships maps channel IDs to the channel memberships of a user. It is filled on first use
+// by getChannelMembers.
+type channelMemberships map[string]*model.ChannelMember
+
+// getChannelMemberships returns all channel memberships of a user. The server ignores the team given
+// to GetChannelMembersForUser and returns the memberships in all teams, along with direct and group
+// messages, so they are fetched once per user and split by team with getChannelMembers.
+func (r *Remover) getChannelMemberships(user *model.User) (channelMemberships, error) {
+	memberships := make(channelMemberships)
+	for page := 0; ; page++ {
+		pageMembers, appErr := r.papi.GetChannelMembersForUser("", user.Id, page, membersPerPage)
+		if appErr != nil {
+			return nil, appErr
+		}
+		for _, cm := range pageMembers {
+			memberships[cm.ChannelId] = cm
+		}
+		if len(pageMembers) < membersPerPage {
+			return memberships, nil
+		}
+	}
+}
+
+// getChannelMembers returns the channel memberships of a user in the channels of a team, including
+// archived channels.
+func (r *Remover) getChannelMembers(user *model.User, teamID string, memberships *channelMemberships) ([]*model.ChannelMember, error) {
+	if *memberships == nil {
+		all, err := r.getChannelMemberships(user)
+		if err != nil {
+			return nil, err
+		}
+		*memberships = all
+	}
+
+	channels, appErr := r.papi.GetChannelsForTeamForUser(teamID, user.Id, true)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	members := make([]*model.ChannelMember, 0, len(channels))
+	for _, channel := range channels {
+		// the team's channels come with the user's direct and group messages
+		if channel.TeamId != teamID {
+			continue
+		}
+		if cm, ok := (*memberships)[channel.Id]; ok {
+			members = append(members, cm)
+		}
+	}
+	return members, nil
+}
+
+// logUserRemovedFromAllTeams records the removal of a user from all teams.
+func (r *Remover) logUserRemovedFromAllTeams(user *model.User, requesterID string, report *RemovalReport) {
+	rec := audit.Record{
+		Event:   audit.EventUserRemovedFromAllTeams,
+		Status:  model.AuditStatusSuccess,
+		ActorID: requesterID,
+		Data: map[string]any{
+			"user_id":        user.Id,
+			"username":       user.Username,
+			"team_ids":       report.TeamsRemoved,
+			"channel_count":  len(report.ChannelsRemoved),
+			"failure_count":  len(report.Failures),
+			"handovers":      report.Handovers,
+			"preserved":      report.Preserved,
+			"tokens_revoked": report.TokensRevoked,
+			"bots":           report.Bots,
+			"integrations":   report.Integrations,
+			"data_cleanup":   report.DataCleanup,
+		},
+	}
+	if err := report.Err(); err != nil {
+		rec.Status = model.AuditStatusFail
+		rec.Error = err.Error()
+	}
+	r.audit.Log(rec)
+}
+
+// processTeamMember removes a user from the channels of a team, then from the team itself unless
+// leaveTeam is false. The user stays in the team if any of its channels could not be left, so a
+// retry can pick them up.
+func (r *Remover) processTeamMember(user *model.User, teamID string, channelMembers []*model.ChannelMember, leaveTeam bool, opts RemoveOpts, report *RemovalReport) {
+	wrapErr := func(err error) error {
+		return errors.Wrapf(err, "failed to process team member. user=%s team=%s", user.Username, teamID)
+	}
+
+	// Remove user from channels in this team
+	failed := false
+	for _, cm := range channelMembers {
+		removed, err := r.processChannelMember(user, cm, opts, report)
+		if err != nil {
+			failed = true
+			report.addFailure(teamID, cm.ChannelId, wrapErr(errors.Wrapf(err, "failed to process channel member. channel=%s", cm.ChannelId)))
+			continue
+		}
+		if removed {
+			report.ChannelsRemoved = append(report.ChannelsRemoved, cm.ChannelId)
+			opts.progress(report)
+		}
+	}
+	if failed || !leaveTeam {
+		return
+	}
+
+	// Remove user from team
+	if appErr := r.papi.DeleteTeamMember(teamID, user.Id, opts.RequesterID); appErr != nil {
+		report.addFailure(teamID, "", wrapErr(errors.Wrap(appErr, "failed to remove user from team")))
+		return
+	}
+	report.TeamsRemoved = append(report.TeamsRemoved, teamID)
+	opts.progress(report)
+
+	r.papi.LogDebug("Removed user from all channels in team.", "username", user.Username, "team", teamID)
+}
+
+// processChannelMember removes a user from a channel, handing the channel over first if the user is
+// its only admin. It returns false without error for the default channel, which users leave along
+// with the team.
+func (r *Remover) processChannelMember(user *model.User, cm *model.ChannelMember, opts RemoveOpts, report *RemovalReport) (bool, error) {
+	channelID := cm.ChannelId
+
+	handover, err := r.handOverChannel(user, cm, opts.Handover)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to hand over channel")
+	}
+	if handover != nil {
+		report.Handovers = append(report.Handovers, *handover)
+	}
+
+	// Remove user from channel
+	appErr := r.papi.DeleteChannelMember(channelID, user.Id)
+	if appErr != nil {
+		c, channelErr := r.papi.GetChannel(channelID)
+		if channelErr != nil {
+			return false, errors.Wrapf(channelErr, "failed to get channel %s", channelID)
+		}
+
+		if c.Name == model.DefaultChannelName {
+			return false, nil
+		}
+
+		return false, errors.Wrap(appErr, "failed to remove user from channel")
+	}
+
+	return true, nil
+}
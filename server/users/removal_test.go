@@ -0,0 +1,157 @@
+package users
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+// mockChannelMembers mocks the channel memberships of a user, by team. Like the server, it returns the
+// memberships in all teams at once, along with a direct message, and lists the direct message among
+// the channels of every team.
+func mockChannelMembers(api *plugintest.API, userID string, members map[string][]*model.ChannelMember) {
+	dm := &model.Channel{Id: "dm_channel", Type: model.ChannelTypeDirect}
+	all := []*model.ChannelMember{{ChannelId: dm.Id, UserId: userID}}
+	for teamID, teamMembers := range members {
+		channels := []*model.Channel{dm}
+		for _, cm := range teamMembers {
+			all = append(all, cm)
+			channels = append(channels, &model.Channel{Id: cm.ChannelId, TeamId: teamID})
+		}
+		api.On("GetChannelsForTeamForUser", teamID, userID, true).Return(channels, nil)
+	}
+	api.On("GetChannelMembersForUser", "", userID, 0, membersPerPage).Return(all, nil)
+}
+
+func TestRemoveFromAllTeams(t *testing.T) {
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	t.Run("all memberships are paged through", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+
+		firstPage := make([]*model.ChannelMember, membersPerPage)
+		for i := range firstPage {
+			firstPage[i] = &model.ChannelMember{ChannelId: fmt.Sprintf("channel%d", i)}
+		}
+		api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}}, nil)
+		lastPage := []*model.ChannelMember{{ChannelId: "last"}, {ChannelId: "dm"}}
+		teamChannels := []*model.Channel{{Id: "dm", Type: model.ChannelTypeDirect}}
+		for _, cm := range append(firstPage, lastPage[0]) {
+			teamChannels = append(teamChannels, &model.Channel{Id: cm.ChannelId, TeamId: "team1"})
+		}
+		api.On("GetChannelMembersForUser", "", user.Id, 0, membersPerPage).Return(firstPage, nil)
+		api.On("GetChannelMembersForUser", "", user.Id, 1, membersPerPage).Return(lastPage, nil)
+		api.On("GetChannelsForTeamForUser", "team1", user.Id, true).Return(teamChannels, nil)
+		api.On("DeleteChannelMember", mock.Anything, user.Id).Return(nil)
+		api.On("DeleteTeamMember", "team1", user.Id, "requester").Return(nil)
+		api.On("KVGet", "snap_user_id").Return(nil, nil)
+		api.On("KVSetWithOptions", "snap_user_id", mock.Anything, mock.Anything).Return(true, nil)
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+		report, err := remover.RemoveFromAllTeams(user, RemoveOpts{RequesterID: "requester"})
+		require.NoError(t, err)
+		assert.Len(t, report.ChannelsRemoved, membersPerPage+1)
+		api.AssertNotCalled(t, "DeleteChannelMember", "dm", user.Id)
+		assert.Equal(t, []string{"team1"}, report.TeamsRemoved)
+		assert.Empty(t, report.Failures)
+	})
+
+	t.Run("failures do not stop the removal", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+
+		api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}, {TeamId: "team2"}}, nil)
+		mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{
+			"team1": {{ChannelId: "channel1"}, {ChannelId: "channel2"}, {ChannelId: "channel3"}},
+			"team2": {{ChannelId: "channel4"}},
+		})
+		api.On("DeleteChannelMember", "channel1", user.Id).Return(nil)
+		api.On("DeleteChannelMember", "channel2", user.Id).Return(&model.AppError{DetailedError: "some database error"})
+		api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2", Name: "channel2"}, nil)
+		api.On("DeleteChannelMember", "channel3", user.Id).Return(nil)
+		api.On("DeleteChannelMember", "channel4", user.Id).Return(nil)
+		api.On("DeleteTeamMember", "team2", user.Id, "requester").Return(nil)
+		api.On("KVGet", "snap_user_id").Return(nil, nil)
+		api.On("KVSetWithOptions", "snap_user_id", mock.Anything, mock.Anything).Return(true, nil)
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+		report, err := remover.RemoveFromAllTeams(user, RemoveOpts{RequesterID: "requester"})
+		require.ErrorContains(t, err, "failed to process channel member. channel=channel2")
+
+		// the user stays in team1, as channel2 could not be left
+		api.AssertNotCalled(t, "DeleteTeamMember", "team1", user.Id, "requester")
+		api.AssertNotCalled(t, "DeleteChannelMember", "dm_channel", user.Id)
+		assert.Equal(t, []string{"team2"}, report.TeamsRemoved)
+		assert.Equal(t, []string{"channel1", "channel3", "channel4"}, report.ChannelsRemoved)
+		require.Len(t, report.Failures, 1)
+		assert.Equal(t, "team1", report.Failures[0].TeamID)
+		assert.Equal(t, "channel2", report.Failures[0].ChannelID)
+		assert.True(t, report.RemovedAny())
+	})
+	t.Run("kept teams and channels are left in place", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+
+		api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}, {TeamId: "team2"}, {TeamId: "team3"}}, nil)
+		api.On("GetTeam", "team1").Return(&model.Team{Id: "team1", Name: "legal"}, nil)
+		api.On("GetTeam", "team2").Return(&model.Team{Id: "team2", Name: "engineering"}, nil)
+		api.On("GetTeam", "team3").Return(&model.Team{Id: "team3", Name: "sales"}, nil)
+		mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{
+			"team1": {{ChannelId: "channel0"}},
+			"team2": {{ChannelId: "channel1"}, {ChannelId: "channel2"}},
+			"team3": {{ChannelId: "channel3"}},
+		})
+		api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1", Name: "compliance-eng"}, nil)
+		api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2", Name: "design"}, nil)
+		api.On("GetChannel", "channel3").Return(&model.Channel{Id: "channel3", Name: "deals"}, nil)
+		api.On("DeleteChannelMember", "channel2", user.Id).Return(nil)
+		api.On("DeleteChannelMember", "channel3", user.Id).Return(nil)
+		api.On("DeleteTeamMember", "team3", user.Id, "requester").Return(nil)
+		api.On("KVGet", "snap_user_id").Return(nil, nil)
+		api.On("KVSetWithOptions", "snap_user_id", mock.Anything, mock.Anything).Return(true, nil)
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+
+		report, err := remover.RemoveFromAllTeams(user, RemoveOpts{
+			RequesterID: "requester",
+			Keep:        KeepList{Teams: []string{"legal"}, ChannelPatterns: []string{"compliance-*"}},
+		})
+		require.NoError(t, err)
+
+		// the user stays in engineering, as compliance-eng is kept
+		api.AssertNotCalled(t, "DeleteTeamMember", "team1", user.Id, "requester")
+		api.AssertNotCalled(t, "DeleteTeamMember", "team2", user.Id, "requester")
+		api.AssertNotCalled(t, "DeleteChannelMember", "channel1", user.Id)
+		assert.Equal(t, []string{"team3"}, report.TeamsRemoved)
+		assert.Equal(t, []string{"channel2", "channel3"}, report.ChannelsRemoved)
+		assert.Equal(t, []PreservedMembership{
+			{TeamID: "team1", Name: "legal", Reason: KeepReasonTeam, Entry: "legal"},
+			{TeamID: "team2", Name: "engineering", Reason: KeepReasonKeptChannel},
+			{TeamID: "team2", ChannelID: "channel1", Name: "compliance-eng", Reason: KeepReasonChannel, Entry: "compliance-*"},
+		}, report.Preserved)
+	})
+
+	t.Run("nothing is removed without a snapshot", func(t *testing.T) {
+		api := &plugintest.API{}
+		remover := NewRemover(api, nil, nil)
+
+		api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1"}}, nil)
+		mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{"team1": {{ChannelId: "channel1"}}})
+		api.On("KVGet", "snap_user_id").Return(nil, nil)
+		api.On("KVSetWithOptions", "snap_user_id", mock.Anything, mock.Anything).Return(false, &model.AppError{DetailedError: "some database error"})
+
+		report, err := remover.RemoveFromAllTeams(user, RemoveOpts{RequesterID: "requester"})
+		require.ErrorContains(t, err, "failed to save membership snapshot")
+		assert.False(t, report.RemovedAny())
+		api.AssertNotCalled(t, "DeleteChannelMember", "channel1", user.Id)
+	})
+}
@@ -0,0 +1,312 @@
+package users
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+)
+
+const (
+	membershipSnapshotKeyPrefix = "snap_"
+
+	SkipReasonDeleted  = "deleted"  // the team or channel no longer exists
+	SkipReasonArchived = "archived" // the team or channel was archived after the removal
+)
+
+var (
+	// ErrNoMembershipSnapshot is returned when restoring the memberships of a user that has no snapshot.
+	ErrNoMembershipSnapshot = errors.New("no membership snapshot found for user")
+
+	// ErrUserDeactivated is returned when restoring the memberships of a user that is not reactivated yet.
+	ErrUserDeactivated = errors.New("user is deactivated")
+)
+
+// MembershipSnapshot records the team and channel memberships of a user before they are removed,
+// so they can be restored if the user is reactivated.
+type MembershipSnapshot struct {
+	UserID    string         `json:"user_id"`
+	Username  string         `json:"username"`
+	UpdatedAt int64          `json:"updated_at"`
+	Teams     []TeamSnapshot `json:"teams"`
+}
+
+// TeamSnapshot is a team membership, along with the channel memberships of the user in the team.
+type TeamSnapshot struct {
+	TeamID   string            `json:"team_id"`
+	Roles    string            `json:"roles"`
+	Channels []ChannelSnapshot `json:"channels"`
+}
+
+// ChannelSnapshot is a channel membership.
+type ChannelSnapshot struct {
+	ChannelID string `json:"channel_id"`
+	Roles     string `json:"roles"`
+}
+
+func membershipSnapshotKey(userID string) string {
+	return membershipSnapshotKeyPrefix + userID
+}
+
+// GetMembershipSnapshot returns the membership snapshot of a user, or nil if there is none.
+func (r *Remover) GetMembershipSnapshot(userID string) (*MembershipSnapshot, error) {
+	var snapshot *MembershipSnapshot
+	if err := r.kv.Get(membershipSnapshotKey(userID), &snapshot); err != nil {
+		return nil, errors.Wrapf(err, "failed to get membership snapshot for user %s", userID)
+	}
+	return snapshot, nil
+}
+
+// saveMembershipSnapshot stores the memberships a removal is about to remove. They are merged into
+// an existing snapshot, so that retrying a partial removal keeps the memberships removed before.
+func (r *Remover) saveMembershipSnapshot(user *model.User, teams []TeamSnapshot) error {
+	snapshot, err := r.GetMembershipSnapshot(user.Id)
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		snapshot = &MembershipSnapshot{UserID: user.Id}
+	}
+	snapshot.Username = user.Username
+	snapshot.UpdatedAt = model.GetMillis()
+
+	for _, team := range teams {
+		i := slices.IndexFunc(snapshot.Teams, func(ts TeamSnapshot) bool { return ts.TeamID == team.TeamID })
+		if i < 0 {
+			snapshot.Teams = append(snapshot.Teams, team)
+			continue
+		}
+		existing := &snapshot.Teams[i]
+		existing.Roles = team.Roles
+		for _, channel := range team.Channels {
+			j := slices.IndexFunc(existing.Channels, func(cs ChannelSnapshot) bool { return cs.ChannelID == channel.ChannelID })
+			if j < 0 {
+				existing.Channels = append(existing.Channels, channel)
+			} else {
+				existing.Channels[j] = channel
+			}
+		}
+	}
+
+	if _, err := r.kv.Set(membershipSnapshotKey(user.Id), snapshot); err != nil {
+		return errors.Wrapf(err, "failed to save membership snapshot for user %s", user.Id)
+	}
+	return nil
+}
+
+// currentMemberships returns the team and channel memberships a user has now, in the shape of a
+// membership snapshot.
+func (r *Remover) currentMemberships(user *model.User) ([]TeamSnapshot, error) {
+	teamMembers, err := r.getTeamMembers(user)
+	if err != nil {
+		return nil, err
+	}
+	var memberships channelMemberships
+	teams := make([]TeamSnapshot, 0, len(teamMembers))
+	for _, tm := range teamMembers {
+		channelMembers, err := r.getChannelMembers(user, tm.TeamId, &memberships)
+		if err != nil {
+			return nil, err
+		}
+		team := TeamSnapshot{TeamID: tm.TeamId, Roles: tm.Roles, Channels: make([]ChannelSnapshot, 0, len(channelMembers))}
+		for _, cm := range channelMembers {
+			team.Channels = append(team.Channels, ChannelSnapshot{ChannelID: cm.ChannelId, Roles: cm.Roles})
+		}
+		teams = append(teams, team)
+	}
+	return teams, nil
+}
+
+// DeleteMembershipSnapshot removes the membership snapshot of a user.
+func (r *Remover) DeleteMembershipSnapshot(userID string) error {
+	if err := r.kv.Delete(membershipSnapshotKey(userID)); err != nil {
+		return errors.Wrapf(err, "failed to delete membership snapshot for user %s", userID)
+	}
+	return nil
+}
+
+// RestoreReport is the outcome of restoring the memberships of a user from their snapshot.
+type RestoreReport struct {
+	UserID           string           `json:"user_id"`
+	Username         string           `json:"username"`
+	TeamsRestored    []string         `json:"teams_restored"`    // IDs of the teams the user was added back to
+	ChannelsRestored []string         `json:"channels_restored"` // IDs of the channels the user was added back to
+	Skipped          []RestoreSkip    `json:"skipped"`
+	Failures         []RemovalFailure `json:"failures"`
+}
+
+// RestoreSkip is a team or channel that was not restored, as it was archived or deleted since the
+// removal. The channels of a skipped team are not listed.
+type RestoreSkip struct {
+	TeamID    string `json:"team_id,omitempty"`
+	ChannelID string `json:"channel_id,omitempty"`
+	Reason    string `json:"reason"` // see SkipReasonDeleted
+}
+
+// Err returns an error summarizing the failures, or nil if all memberships were restored or skipped.
+func (rr *RestoreReport) Err() error {
+	switch len(rr.Failures) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(rr.Failures[0].Error)
+	default:
+		return errors.Errorf("failed to restore %d memberships. first error: %s", len(rr.Failures), rr.Failures[0].Error)
+	}
+}
+
+func (rr *RestoreReport) addFailure(teamID string, channelID string, err error) {
+	rr.Failures = append(rr.Failures, RemovalFailure{TeamID: teamID, ChannelID: channelID, Error: err.Error()})
+}
+
+// RestoreMemberships adds a reactivated user back to the teams and channels of their membership
+// snapshot, with the roles they had. Teams and channels archived or deleted since the removal are
+// skipped. The snapshot is deleted once all memberships are restored, and kept for a retry otherwise.
+func (r *Remover) RestoreMemberships(user *model.User, requesterID string) (*RestoreReport, error) {
+	if user.DeleteAt > 0 {
+		return nil, errors.Wrap(ErrUserDeactivated, user.Username)
+	}
+
+	snapshot, err := r.GetMembershipSnapshot(user.Id)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		return nil, ErrNoMembershipSnapshot
+	}
+
+	report := &RestoreReport{
+		UserID:           user.Id,
+		Username:         user.Username,
+		TeamsRestored:    []string{},
+		ChannelsRestored: []string{},
+		Skipped:          []RestoreSkip{},
+		Failures:         []RemovalFailure{},
+	}
+
+	for _, ts := range snapshot.Teams {
+		r.restoreTeam(user, ts, report)
+	}
+
+	if len(report.Failures) == 0 {
+		if err := r.DeleteMembershipSnapshot(user.Id); err != nil {
+			r.papi.LogWarn("Cannot delete restored membership snapshot.", "user_id", user.Id, "err", err.Error())
+		}
+	}
+
+	r.logUserMembershipsRestored(user, requesterID, report)
+
+	return report, report.Err()
+}
+
+// restoreTeam adds a user back to a team, then to its channels.
+func (r *Remover) restoreTeam(user *model.User, ts TeamSnapshot, report *RestoreReport) {
+	wrapErr := func(err error) error {
+		return errors.Wrapf(err, "failed to restore team member. user=%s team=%s", user.Username, ts.TeamID)
+	}
+
+	team, appErr := r.papi.GetTeam(ts.TeamID)
+	if appErr != nil {
+		if appErr.StatusCode == http.StatusNotFound {
+			report.Skipped = append(report.Skipped, RestoreSkip{TeamID: ts.TeamID, Reason: SkipReasonDeleted})
+			return
+		}
+		report.addFailure(ts.TeamID, "", wrapErr(errors.Wrap(appErr, "failed to get team")))
+		return
+	}
+	if team.DeleteAt > 0 {
+		report.Skipped = append(report.Skipped, RestoreSkip{TeamID: ts.TeamID, Reason: SkipReasonArchived})
+		return
+	}
+
+	tm, appErr := r.papi.CreateTeamMember(team.Id, user.Id)
+	if appErr != nil {
+		report.addFailure(team.Id, "", wrapErr(errors.Wrap(appErr, "failed to add user to team")))
+		return
+	}
+	if !sameRoles(tm.Roles, ts.Roles) {
+		if _, appErr := r.papi.UpdateTeamMemberRoles(team.Id, user.Id, ts.Roles); appErr != nil {
+			report.addFailure(team.Id, "", wrapErr(errors.Wrap(appErr, "failed to restore team roles")))
+		}
+	}
+	report.TeamsRestored = append(report.TeamsRestored, team.Id)
+
+	for _, cs := range ts.Channels {
+		skip, err := r.restoreChannel(user, cs)
+		if err != nil {
+			report.addFailure(team.Id, cs.ChannelID, wrapErr(errors.Wrapf(err, "failed to restore channel member. channel=%s", cs.ChannelID)))
+			continue
+		}
+		if skip != "" {
+			report.Skipped = append(report.Skipped, RestoreSkip{TeamID: team.Id, ChannelID: cs.ChannelID, Reason: skip})
+			continue
+		}
+		report.ChannelsRestored = append(report.ChannelsRestored, cs.ChannelID)
+	}
+
+	r.papi.LogDebug("Restored user to team.", "username", user.Username, "team", team.Id)
+}
+
+// restoreChannel adds a user back to a channel. It returns the reason the channel was skipped, if any.
+func (r *Remover) restoreChannel(user *model.User, cs ChannelSnapshot) (string, error) {
+	channel, appErr := r.papi.GetChannel(cs.ChannelID)
+	if appErr != nil {
+		if appErr.StatusCode == http.StatusNotFound {
+			return SkipReasonDeleted, nil
+		}
+		return "", errors.Wrap(appErr, "failed to get channel")
+	}
+	if channel.DeleteAt > 0 {
+		return SkipReasonArchived, nil
+	}
+
+	cm, appErr := r.papi.AddChannelMember(channel.Id, user.Id)
+	if appErr != nil {
+		return "", errors.Wrap(appErr, "failed to add user to channel")
+	}
+	if !sameRoles(cm.Roles, cs.Roles) {
+		if _, appErr := r.papi.UpdateChannelMemberRoles(channel.Id, user.Id, cs.Roles); appErr != nil {
+			return "", errors.Wrap(appErr, "failed to restore channel roles")
+		}
+	}
+	return "", nil
+}
+
+// sameRoles returns true if two space separated role lists have the same roles. An empty snapshot
+// list means the roles were not recorded, and the default ones are kept.
+func sameRoles(current string, snapshot string) bool {
+	if snapshot == "" {
+		return true
+	}
+	a, b := strings.Fields(current), strings.Fields(snapshot)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// logUserMembershipsRestored records the restore of the memberships of a user.
+func (r *Remover) logUserMembershipsRestored(user *model.User, requesterID string, report *RestoreReport) {
+	rec := audit.Record{
+		Event:   audit.EventUserMembershipsRestored,
+		Status:  model.AuditStatusSuccess,
+		ActorID: requesterID,
+		Data: map[string]any{
+			"user_id":       user.Id,
+			"username":      user.Username,
+			"team_ids":      report.TeamsRestored,
+			"channel_count": len(report.ChannelsRestored),
+			"skipped_count": len(report.Skipped),
+			"failure_count": len(report.Failures),
+		},
+	}
+	if err := report.Err(); err != nil {
+		rec.Status = model.AuditStatusFail
+		rec.Error = err.Error()
+	}
+	r.audit.Log(rec)
+}
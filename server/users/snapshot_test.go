@@ -0,0 +1,182 @@
+package users
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+)
+
+// mockKVStore backs the KV store API calls with a map, honoring atomic writes.
+func mockKVStore(api *plugintest.API) {
+	var mux sync.Mutex
+	values := map[string][]byte{}
+	api.On("KVSetWithOptions", mock.Anything, mock.Anything, mock.Anything).Return(func(key string, value []byte, opts model.PluginKVSetOptions) (bool, *model.AppError) {
+		mux.Lock()
+		defer mux.Unlock()
+		if opts.Atomic && !bytes.Equal(values[key], opts.OldValue) {
+			return false, nil
+		}
+		if value == nil {
+			delete(values, key)
+		} else {
+			values[key] = value
+		}
+		return true, nil
+	})
+	api.On("KVGet", mock.Anything).Return(func(key string) ([]byte, *model.AppError) {
+		mux.Lock()
+		defer mux.Unlock()
+		return values[key], nil
+	})
+}
+
+func TestSaveMembershipSnapshot(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	require.NoError(t, remover.saveMembershipSnapshot(user, []TeamSnapshot{
+		{TeamID: "team1", Roles: "team_user", Channels: []ChannelSnapshot{{ChannelID: "channel1", Roles: "channel_user"}}},
+	}))
+
+	// a retried removal adds to the memberships removed before
+	require.NoError(t, remover.saveMembershipSnapshot(user, []TeamSnapshot{
+		{TeamID: "team1", Roles: "team_user team_admin", Channels: []ChannelSnapshot{{ChannelID: "channel2", Roles: "channel_user"}}},
+		{TeamID: "team2", Roles: "team_user", Channels: []ChannelSnapshot{}},
+	}))
+
+	snapshot, err := remover.GetMembershipSnapshot(user.Id)
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, "alice", snapshot.Username)
+	require.Len(t, snapshot.Teams, 2)
+	assert.Equal(t, "team_user team_admin", snapshot.Teams[0].Roles)
+	assert.Equal(t, []ChannelSnapshot{
+		{ChannelID: "channel1", Roles: "channel_user"},
+		{ChannelID: "channel2", Roles: "channel_user"},
+	}, snapshot.Teams[0].Channels)
+	assert.Equal(t, "team2", snapshot.Teams[1].TeamID)
+
+	snapshot, err = remover.GetMembershipSnapshot("other_user")
+	require.NoError(t, err)
+	assert.Nil(t, snapshot)
+}
+
+func TestRestoreMemberships(t *testing.T) {
+	user := &model.User{Id: "user_id", Username: "alice"}
+	notFound := &model.AppError{StatusCode: http.StatusNotFound, DetailedError: "not found"}
+
+	setup := func(t *testing.T) (*plugintest.API, *Remover) {
+		api := &plugintest.API{}
+		mockKVStore(api)
+		api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+		remover := NewRemover(api, nil, nil)
+
+		require.NoError(t, remover.saveMembershipSnapshot(user, []TeamSnapshot{
+			{TeamID: "team1", Roles: "team_user team_admin", Channels: []ChannelSnapshot{
+				{ChannelID: "channel1", Roles: "channel_user channel_admin"},
+				{ChannelID: "channel2", Roles: "channel_user"},
+				{ChannelID: "archived", Roles: "channel_user"},
+				{ChannelID: "deleted", Roles: "channel_user"},
+			}},
+			{TeamID: "archived_team", Roles: "team_user"},
+		}))
+
+		api.On("GetTeam", "team1").Return(&model.Team{Id: "team1"}, nil)
+		api.On("GetTeam", "archived_team").Return(&model.Team{Id: "archived_team", DeleteAt: 1000}, nil)
+		api.On("CreateTeamMember", "team1", user.Id).Return(&model.TeamMember{TeamId: "team1", Roles: "team_user"}, nil)
+		api.On("UpdateTeamMemberRoles", "team1", user.Id, "team_user team_admin").Return(&model.TeamMember{}, nil)
+		api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1"}, nil)
+		api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2"}, nil)
+		api.On("GetChannel", "archived").Return(&model.Channel{Id: "archived", DeleteAt: 1000}, nil)
+		api.On("GetChannel", "deleted").Return(nil, notFound)
+		api.On("AddChannelMember", "channel1", user.Id).Return(&model.ChannelMember{Roles: "channel_user"}, nil)
+		api.On("UpdateChannelMemberRoles", "channel1", user.Id, "channel_user channel_admin").Return(&model.ChannelMember{}, nil)
+		return api, remover
+	}
+
+	t.Run("memberships are restored with their roles", func(t *testing.T) {
+		api, remover := setup(t)
+		api.On("AddChannelMember", "channel2", user.Id).Return(&model.ChannelMember{Roles: "channel_user"}, nil)
+
+		report, err := remover.RestoreMemberships(user, "requester")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team1"}, report.TeamsRestored)
+		assert.Equal(t, []string{"channel1", "channel2"}, report.ChannelsRestored)
+		assert.Equal(t, []RestoreSkip{
+			{TeamID: "team1", ChannelID: "archived", Reason: SkipReasonArchived},
+			{TeamID: "team1", ChannelID: "deleted", Reason: SkipReasonDeleted},
+			{TeamID: "archived_team", Reason: SkipReasonArchived},
+		}, report.Skipped)
+		api.AssertNotCalled(t, "UpdateChannelMemberRoles", "channel2", user.Id, mock.Anything)
+
+		// the snapshot is gone once restored
+		_, err = remover.RestoreMemberships(user, "requester")
+		require.ErrorIs(t, err, ErrNoMembershipSnapshot)
+	})
+
+	t.Run("snapshot is kept when memberships fail", func(t *testing.T) {
+		api, remover := setup(t)
+		api.On("AddChannelMember", "channel2", user.Id).Return(nil, &model.AppError{DetailedError: "some database error"})
+
+		report, err := remover.RestoreMemberships(user, "requester")
+		require.ErrorContains(t, err, "failed to restore channel member. channel=channel2")
+		assert.Equal(t, []string{"channel1"}, report.ChannelsRestored)
+		require.Len(t, report.Failures, 1)
+		assert.Equal(t, "channel2", report.Failures[0].ChannelID)
+
+		snapshot, err := remover.GetMembershipSnapshot(user.Id)
+		require.NoError(t, err)
+		assert.NotNil(t, snapshot)
+	})
+
+	t.Run("deactivated users are not restored", func(t *testing.T) {
+		_, remover := setup(t)
+		_, err := remover.RestoreMemberships(&model.User{Id: user.Id, Username: "alice", DeleteAt: 1000}, "requester")
+		require.ErrorIs(t, err, ErrUserDeactivated)
+	})
+}
+
+func TestRemoveAndRestoreMemberships(t *testing.T) {
+	api := &plugintest.API{}
+	mockKVStore(api)
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Maybe()
+	api.On("LogDebug", mock.Anything, mock.Anything, mock.Anything).Maybe()
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	api.On("GetTeamMembersForUser", user.Id, 0, membersPerPage).Return([]*model.TeamMember{{TeamId: "team1", Roles: "team_user"}}, nil)
+	mockChannelMembers(api, user.Id, map[string][]*model.ChannelMember{
+		"team1": {{ChannelId: "channel1", Roles: "channel_user channel_admin"}, {ChannelId: "channel2", Roles: "channel_user"}},
+	})
+	api.On("DeleteChannelMember", "channel1", user.Id).Return(nil)
+	api.On("DeleteChannelMember", "channel2", user.Id).Return(nil)
+	api.On("DeleteTeamMember", "team1", user.Id, "requester").Return(nil)
+
+	_, err := remover.RemoveFromAllTeams(user, RemoveOpts{RequesterID: "requester"})
+	require.NoError(t, err)
+
+	// the channels are recorded along with the team, and come back on restore
+	api.On("GetTeam", "team1").Return(&model.Team{Id: "team1"}, nil)
+	api.On("CreateTeamMember", "team1", user.Id).Return(&model.TeamMember{TeamId: "team1", Roles: "team_user"}, nil)
+	api.On("GetChannel", "channel1").Return(&model.Channel{Id: "channel1"}, nil)
+	api.On("GetChannel", "channel2").Return(&model.Channel{Id: "channel2"}, nil)
+	api.On("AddChannelMember", "channel1", user.Id).Return(&model.ChannelMember{Roles: "channel_user"}, nil)
+	api.On("AddChannelMember", "channel2", user.Id).Return(&model.ChannelMember{Roles: "channel_user"}, nil)
+	api.On("UpdateChannelMemberRoles", "channel1", user.Id, "channel_user channel_admin").Return(&model.ChannelMember{}, nil)
+
+	report, err := remover.RestoreMemberships(user, "requester")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"team1"}, report.TeamsRestored)
+	assert.Equal(t, []string{"channel1", "channel2"}, report.ChannelsRestored)
+	api.AssertNotCalled(t, "AddChannelMember", "dm_channel", user.Id)
+}
@@ -0,0 +1,149 @@
+package users
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/plugin"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/bot"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/i18n"
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+type SweepOpts struct {
+	store.DeactivatedUserOpts
+	BatchSize    int           // number of users fetched at a time
+	Pause        time.Duration // pause after each user, so the sweep doesn't overload the server
+	DryRun       bool          // don't remove users, just list them
+	AdminChannel string        // optional channel receiving the report
+	Keep         KeepList      // teams and channels removed users stay in
+
+	Handover     *HandoverOpts    // optional, hands over the private channels removed users are the only admins of
+	Integrations *IntegrationOpts // optional, revokes the access tokens and handles the bots and integrations of removed users
+	CleanupData  bool             // hides the direct messages of removed users, and deletes their preferences, sidebar and threads
+
+	Bot    *bot.Bot      // bot posting the report, and recorded as the actor of removals
+	Audit  *audit.Logger // optional audit logger
+	I18n   *i18n.Bundle  // optional translations; the report is in English without it
+	Locale string        // locale of the admin channel report, the server locale if empty
+}
+
+type SweepResults struct {
+	Removed   []string // users removed from their teams and channels, or that would be in dry run mode
+	Failed    []string // users that could not be removed
+	Cancelled bool
+	Duration  time.Duration
+}
+
+// SweepDeactivatedUsers removes the deactivated users who still have team or channel memberships
+// from all teams and channels, except the excluded teams, a batch at a time.
+func SweepDeactivatedUsers(ctx context.Context, sqlstore *store.SQLStore, papi plugin.API, client *pluginapi.Client, opts SweepOpts) (*SweepResults, error) {
+	start := time.Now()
+	results := &SweepResults{
+		Removed: make([]string, 0),
+		Failed:  make([]string, 0),
+	}
+	defer func() {
+		results.Duration = time.Since(start)
+	}()
+
+	loc := opts.I18n.LocaleLocalizer(opts.Locale)
+	remover := NewRemover(papi, sqlstore, opts.Audit)
+	removeOpts := RemoveOpts{
+		RequesterID:    opts.Bot.UserID(),
+		ExcludeTeamIDs: opts.ExcludeTeamIDs,
+		Keep:           opts.Keep,
+		Handover:       opts.Handover,
+		Integrations:   opts.Integrations,
+		CleanupData:    opts.CleanupData,
+	}
+
+	var buffer bytes.Buffer
+	afterID := ""
+	for {
+		// users are paged by ID as removed users drop out of the results, while failed ones don't
+		batch, err := sqlstore.GetDeactivatedUsersWithMemberships(opts.DeactivatedUserOpts, afterID, opts.BatchSize)
+		if err != nil {
+			return results, fmt.Errorf("cannot fetch deactivated users: %w", err)
+		}
+
+		for _, user := range batch {
+			afterID = user.Id
+
+			if opts.DryRun {
+				results.Removed = append(results.Removed, user.Username)
+				buffer.WriteString(fmt.Sprintf("%s (%s)\n", user.Username, user.Id))
+				continue
+			}
+
+			report, err := remover.RemoveFromAllTeams(user, removeOpts)
+			if err == nil && !report.RemovedAny() && len(report.Preserved) > 0 {
+				// users only left in kept teams and channels are found again by every sweep
+				continue
+			}
+			if err != nil {
+				client.Log.Error("Cannot remove deactivated user from all teams", "user_id", user.Id, "err", err)
+				results.Failed = append(results.Failed, user.Username)
+				buffer.WriteString(loc.T(&i18n.Message{ID: "sweep.report.failed", Other: "{{.Username}} ({{.UserID}}): {{.Error}}"}, map[string]any{
+					"Username": user.Username,
+					"UserID":   user.Id,
+					"Error":    err.Error(),
+				}) + "\n")
+			} else {
+				results.Removed = append(results.Removed, user.Username)
+				buffer.WriteString(loc.T(&i18n.Message{ID: "sweep.report.removed", Other: "{{.Username}} ({{.UserID}}) removed from {{.TeamCount}} teams"}, map[string]any{
+					"Username":  user.Username,
+					"UserID":    user.Id,
+					"TeamCount": len(report.TeamsRemoved),
+				}) + "\n")
+			}
+
+			select {
+			case <-time.After(opts.Pause):
+			case <-ctx.Done():
+				results.Cancelled = true
+				return results, postSweepReport(opts, loc, &buffer, results)
+			}
+		}
+
+		if len(batch) < opts.BatchSize {
+			break
+		}
+	}
+
+	return results, postSweepReport(opts, loc, &buffer, results)
+}
+
+func postSweepReport(opts SweepOpts, loc *i18n.Localizer, buffer *bytes.Buffer, results *SweepResults) error {
+	if opts.AdminChannel == "" || buffer.Len() == 0 {
+		return nil
+	}
+
+	var msg string
+	if opts.DryRun {
+		msg = loc.T(&i18n.Message{
+			ID:    "sweep.report.dry_run",
+			Other: "{{.Count}} deactivated users would be removed from their teams and channels.",
+		}, map[string]any{"Count": len(results.Removed)})
+	} else {
+		msg = loc.T(&i18n.Message{
+			ID:    "sweep.report.header",
+			Other: "Deactivated users still in teams or channels were removed ({{.Removed}} removed, {{.Failed}} failed).",
+		}, map[string]any{"Removed": len(results.Removed), "Failed": len(results.Failed)})
+	}
+
+	fileName := fmt.Sprintf("%d_deactivated-users.txt", time.Now().UnixMilli())
+	fileInfo, err := opts.Bot.UploadFile(buffer, fileName, opts.AdminChannel)
+	if err != nil {
+		return fmt.Errorf("failed to upload file: %w", err)
+	}
+	if err := opts.Bot.SendPostWithAttachment(opts.AdminChannel, msg, fileInfo); err != nil {
+		return fmt.Errorf("failed to create post: %w", err)
+	}
+	return nil
+}
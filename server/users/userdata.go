@@ -0,0 +1,71 @@
+package users
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+// cleanupUserData hides the direct and group messages of a removed user for the other participants,
+// and deletes the user's preferences, sidebar categories and thread memberships. It is skipped
+// without the SQL store.
+func (r *Remover) cleanupUserData(user *model.User, report *RemovalReport) {
+	if r.sqlstore == nil {
+		return
+	}
+	wrapErr := func(err error) error {
+		return errors.Wrapf(err, "failed to clean up user data. user=%s", user.Username)
+	}
+
+	participants, err := r.sqlstore.GetDirectChannelParticipants(user.Id)
+	if err != nil {
+		report.addFailure("", "", wrapErr(errors.Wrap(err, "failed to get direct channel participants")))
+		return
+	}
+	hidden := r.hideDirectChannels(user, participants, report)
+
+	counts, err := r.sqlstore.CleanupUserData(user.Id)
+	if err != nil {
+		report.addFailure("", "", wrapErr(err))
+		counts = &store.UserDataCounts{}
+	}
+	counts.HiddenChannels = hidden
+	report.DataCleanup = counts
+}
+
+// hideDirectChannels hides the direct and group messages of a user for the other participants. The
+// preferences are saved through the plugin API, so the server caches are updated and the clients of
+// the participants are told right away. It returns the number of preferences saved.
+func (r *Remover) hideDirectChannels(user *model.User, participants []store.DirectChannelParticipant, report *RemovalReport) int64 {
+	prefsByUser := make(map[string][]model.Preference)
+	order := make([]string, 0)
+	for _, participant := range participants {
+		pref := model.Preference{
+			UserId:   participant.UserID,
+			Category: model.PreferenceCategoryDirectChannelShow,
+			Name:     user.Id,
+			Value:    "false",
+		}
+		if participant.ChannelType == model.ChannelTypeGroup {
+			pref.Category = model.PreferenceCategoryGroupChannelShow
+			pref.Name = participant.ChannelID
+		}
+		if _, ok := prefsByUser[participant.UserID]; !ok {
+			order = append(order, participant.UserID)
+		}
+		prefsByUser[participant.UserID] = append(prefsByUser[participant.UserID], pref)
+	}
+
+	var hidden int64
+	for _, userID := range order {
+		prefs := prefsByUser[userID]
+		if appErr := r.papi.UpdatePreferencesForUser(userID, prefs); appErr != nil {
+			report.addFailure("", "", errors.Wrapf(appErr, "failed to hide direct messages of %s for user %s", user.Username, userID))
+			continue
+		}
+		hidden += int64(len(prefs))
+	}
+	return hidden
+}
@@ -0,0 +1,39 @@
+package users
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/store"
+)
+
+func TestHideDirectChannels(t *testing.T) {
+	api := &plugintest.API{}
+	remover := NewRemover(api, nil, nil)
+	user := &model.User{Id: "user_id", Username: "alice"}
+
+	api.On("UpdatePreferencesForUser", "bob", []model.Preference{
+		{UserId: "bob", Category: model.PreferenceCategoryDirectChannelShow, Name: "user_id", Value: "false"},
+		{UserId: "bob", Category: model.PreferenceCategoryGroupChannelShow, Name: "group_id", Value: "false"},
+	}).Return(nil)
+	api.On("UpdatePreferencesForUser", "carol", []model.Preference{
+		{UserId: "carol", Category: model.PreferenceCategoryGroupChannelShow, Name: "group_id", Value: "false"},
+	}).Return(&model.AppError{DetailedError: "some database error"})
+
+	report := newRemovalReport(user)
+	hidden := remover.hideDirectChannels(user, []store.DirectChannelParticipant{
+		{UserID: "bob", ChannelID: "direct_id", ChannelType: model.ChannelTypeDirect},
+		{UserID: "bob", ChannelID: "group_id", ChannelType: model.ChannelTypeGroup},
+		{UserID: "carol", ChannelID: "group_id", ChannelType: model.ChannelTypeGroup},
+	}, report)
+
+	api.AssertExpectations(t)
+	assert.Equal(t, int64(2), hidden)
+	if assert.Len(t, report.Failures, 1) {
+		assert.Contains(t, report.Failures[0].Error, "failed to hide direct messages of alice for user carol")
+	}
+}
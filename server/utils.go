@@ -1,13 +1,22 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/pluginapi/cluster"
 )
 
+// clusterLockTimeout bounds the wait for a cluster lock held by another server.
+const clusterLockTimeout = 2 * time.Second
+
 func (p *Plugin) ensureSystemAdmin(userID string) (bool, error) {
 	user, appErr := p.API.GetUser(userID)
 	if appErr != nil {
@@ -21,6 +30,63 @@ func (p *Plugin) ensureSystemAdmin(userID string) (bool, error) {
 	return true, nil
 }
 
+// requireSystemAdmin verifies the request comes from an authenticated system admin, returning the
+// requester's user ID. If not, an error response is written and false is returned.
+func (p *Plugin) requireSystemAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	requesterID := r.Header.Get("Mattermost-User-Id")
+	if requesterID == "" {
+		writeError(w, "request is not from an authenticated user", http.StatusUnauthorized)
+		return "", false
+	}
+
+	isAdmin, err := p.ensureSystemAdmin(requesterID)
+	if err != nil {
+		writeError(w, fmt.Sprintf("error verifying whether user %s is a system admin: %s", requesterID, err.Error()), http.StatusUnauthorized)
+		return "", false
+	}
+
+	if !isAdmin {
+		writeError(w, fmt.Sprintf("user %s is not a system admin", requesterID), http.StatusUnauthorized)
+		return "", false
+	}
+
+	return requesterID, true
+}
+
+// checkMethod writes an error response and returns false if the request method is not the expected one.
+func checkMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		writeError(w, fmt.Sprintf("unexpected HTTP method %s. Should be %s", r.Method, method), http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func writeError(w http.ResponseWriter, errorString string, statusCode int) {
+	writeJSON(w, statusCode, ErrorResponse{errorString})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// tryClusterLock locks the cluster mutex of key, returning false if another server, or this one,
+// still holds it after clusterLockTimeout.
+func (p *Plugin) tryClusterLock(key string) (*cluster.Mutex, bool, error) {
+	mutex, err := cluster.NewMutex(p.API, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), clusterLockTimeout)
+	defer cancel()
+	if err := mutex.LockWithContext(ctx); err != nil {
+		return nil, false, nil
+	}
+	return mutex, true, nil
+}
+
 // CutPrefix returns s without the provided leading prefix string
 // and reports whether it found the prefix.
 // If s doesn't start with prefix, CutPrefix returns s, false.
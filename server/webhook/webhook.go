@@ -0,0 +1,300 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+)
+
+const (
+	HeaderEvent     = "X-Retention-Event"
+	HeaderDelivery  = "X-Retention-Delivery"
+	HeaderSignature = "X-Retention-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Event is the JSON body posted to webhook targets. It carries the same data as the audit record.
+type Event struct {
+	ID        string         `json:"id"` // unique per event, the same for all delivery attempts
+	Event     string         `json:"event"`
+	Timestamp int64          `json:"timestamp"`
+	Status    string         `json:"status"`
+	ActorID   string         `json:"actor_id,omitempty"`
+	Data      map[string]any `json:"data,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// Options controls the delivery of events. Zero values are replaced with the defaults.
+type Options struct {
+	MaxAttempts    int           // attempts per event and target before giving up
+	InitialBackoff time.Duration // wait before the first retry, doubled for each following retry
+	MaxBackoff     time.Duration
+	Timeout        time.Duration // timeout of each HTTP request
+	QueueSize      int           // events queued per target; further events are dropped
+}
+
+func (o Options) withDefaults() Options {
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = 5
+	}
+	if o.InitialBackoff <= 0 {
+		o.InitialBackoff = time.Second
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = time.Minute
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = 10 * time.Second
+	}
+	if o.QueueSize <= 0 {
+		o.QueueSize = 1000
+	}
+	return o
+}
+
+// Dispatcher is an audit.Sink posting audit records as signed JSON events to the configured webhook
+// targets. Each target has its own queue, so a slow or failing target doesn't delay the others.
+type Dispatcher struct {
+	mux     sync.Mutex
+	targets []*target
+	urls    []string
+	secret  string
+
+	opts   Options
+	client *http.Client
+	log    *pluginapi.LogService
+}
+
+var _ audit.Sink = (*Dispatcher)(nil)
+
+func NewDispatcher(log *pluginapi.LogService, opts Options) *Dispatcher {
+	opts = opts.withDefaults()
+	return &Dispatcher{
+		opts:   opts,
+		client: &http.Client{Timeout: opts.Timeout},
+		log:    log,
+	}
+}
+
+// ValidateConfig returns an error if any of the webhook URLs is not an absolute http or https URL,
+// or if there are URLs but no secret to sign events with.
+func ValidateConfig(urls []string, secret string) error {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q: must be an http or https URL", u)
+		}
+	}
+	if len(urls) > 0 && secret == "" {
+		return errors.New("a webhook secret is required to sign webhook events")
+	}
+	return nil
+}
+
+// Configure replaces the webhook targets. Events queued for removed targets are dropped.
+func (d *Dispatcher) Configure(urls []string, secret string) error {
+	if err := ValidateConfig(urls, secret); err != nil {
+		return err
+	}
+
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if slices.Equal(urls, d.urls) && secret == d.secret {
+		return nil
+	}
+
+	for _, t := range d.targets {
+		t.stop()
+	}
+
+	d.urls = slices.Clone(urls)
+	d.secret = secret
+	d.targets = make([]*target, 0, len(urls))
+	for _, u := range urls {
+		d.targets = append(d.targets, d.startTarget(u, secret))
+	}
+	return nil
+}
+
+// Send queues an audit record for delivery to all targets without blocking.
+func (d *Dispatcher) Send(rec audit.Record) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	if len(d.targets) == 0 {
+		return
+	}
+
+	event := &Event{
+		ID:        model.NewId(),
+		Event:     rec.Event,
+		Timestamp: model.GetMillis(),
+		Status:    rec.Status,
+		ActorID:   rec.ActorID,
+		Data:      rec.Data,
+		Error:     rec.Error,
+	}
+
+	for _, t := range d.targets {
+		select {
+		case t.queue <- event:
+		default:
+			d.log.Warn("Webhook queue is full, dropping event", "url", t.url, "event", event.Event, "event_id", event.ID)
+		}
+	}
+}
+
+// Close stops all targets, waiting up to timeout for queued events to be delivered.
+func (d *Dispatcher) Close(timeout time.Duration) error {
+	d.mux.Lock()
+	targets := d.targets
+	d.targets = nil
+	d.urls = nil
+	d.mux.Unlock()
+
+	deadline := time.After(timeout)
+	for _, t := range targets {
+		close(t.queue)
+	}
+	for _, t := range targets {
+		select {
+		case <-t.done:
+			t.cancel()
+		case <-deadline:
+			for _, t := range targets {
+				t.cancel()
+			}
+			return fmt.Errorf("timed out delivering webhook events after %s", timeout.String())
+		}
+	}
+	return nil
+}
+
+// target delivers queued events to a single URL in order.
+type target struct {
+	url    string
+	secret string
+	queue  chan *Event
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (d *Dispatcher) startTarget(u, secret string) *target {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &target{
+		url:    u,
+		secret: secret,
+		queue:  make(chan *Event, d.opts.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(t.done)
+		for event := range t.queue {
+			if t.ctx.Err() != nil {
+				return
+			}
+			if err := d.deliver(t, event); err != nil {
+				d.log.Error("Cannot deliver webhook event", "url", t.url, "event", event.Event, "event_id", event.ID, "err", err)
+			}
+		}
+	}()
+
+	return t
+}
+
+// stop drops the queued events and aborts the delivery in progress.
+func (t *target) stop() {
+	t.cancel()
+	close(t.queue)
+}
+
+// deliver posts an event, retrying with exponential backoff on network errors, 5xx and 429 responses.
+func (d *Dispatcher) deliver(t *target, event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event: %w", err)
+	}
+	signature := Sign(t.secret, body)
+
+	backoff := d.opts.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		retry, err := d.post(t, event, body, signature)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= d.opts.MaxAttempts {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+
+		d.log.Debug("Webhook delivery failed, retrying", "url", t.url, "event_id", event.ID, "attempt", attempt, "backoff", backoff.String(), "err", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-t.ctx.Done():
+			return fmt.Errorf("canceled after %d attempts: %w", attempt, err)
+		}
+		backoff = min(backoff*2, d.opts.MaxBackoff)
+	}
+}
+
+// post makes a single delivery attempt, returning whether a failed attempt may be retried.
+func (d *Dispatcher) post(t *target, event *Event, body []byte, signature string) (bool, error) {
+	req, err := http.NewRequestWithContext(t.ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, event.Event)
+	req.Header.Set(HeaderDelivery, event.ID)
+	req.Header.Set(HeaderSignature, signature)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return t.ctx.Err() == nil, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %s", resp.Status)
+	default:
+		return false, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// Sign returns the signature header value for a request body: the hex encoded HMAC-SHA256 of the
+// body with the webhook secret as key, prefixed with "sha256=".
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify returns true if signature is a valid signature of body. Receivers written in Go can use it
+// to check events.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
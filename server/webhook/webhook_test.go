@@ -0,0 +1,231 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest"
+	pluginapi "github.com/mattermost/mattermost/server/public/pluginapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost-plugin-retention-tooling/server/audit"
+)
+
+const testSecret = "s3cr3t"
+
+type delivery struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver is a webhook target recording the requests it receives. The first failures requests
+// are answered with failStatus.
+type receiver struct {
+	mux        sync.Mutex
+	deliveries []delivery
+	failures   int
+	failStatus int
+	received   chan struct{}
+}
+
+func newReceiver(t *testing.T, failures int, failStatus int) (*receiver, *httptest.Server) {
+	rcv := &receiver{failures: failures, failStatus: failStatus, received: make(chan struct{}, 100)}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		rcv.mux.Lock()
+		rcv.deliveries = append(rcv.deliveries, delivery{header: r.Header.Clone(), body: body})
+		fail := len(rcv.deliveries) <= rcv.failures
+		rcv.mux.Unlock()
+
+		if fail {
+			w.WriteHeader(rcv.failStatus)
+		}
+		rcv.received <- struct{}{}
+	}))
+	t.Cleanup(server.Close)
+	return rcv, server
+}
+
+func (rcv *receiver) wait(t *testing.T, count int) []delivery {
+	for i := 0; i < count; i++ {
+		select {
+		case <-rcv.received:
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timed out waiting for webhook deliveries")
+		}
+	}
+	rcv.mux.Lock()
+	defer rcv.mux.Unlock()
+	return append([]delivery{}, rcv.deliveries...)
+}
+
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	mockAPI := &plugintest.API{}
+	for _, method := range []string{"LogDebug", "LogWarn", "LogError"} {
+		for args := 1; args <= 13; args += 2 {
+			mockAPI.On(method, repeat(mock.Anything, args)...).Maybe()
+		}
+	}
+
+	d := NewDispatcher(&pluginapi.NewClient(mockAPI, nil).Log, Options{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     20 * time.Millisecond,
+	})
+	t.Cleanup(func() { _ = d.Close(time.Second) })
+	return d
+}
+
+func repeat(v any, n int) []any {
+	s := make([]any, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+var testRecord = audit.Record{
+	Event:   audit.EventChannelArchived,
+	Status:  model.AuditStatusSuccess,
+	ActorID: "actor_id",
+	Data:    map[string]any{"channel_id": "channel_id", "channel_name": "town-square"},
+}
+
+func TestDispatcherDeliversSignedEvents(t *testing.T) {
+	rcv, server := newReceiver(t, 0, 0)
+	d := newTestDispatcher(t)
+	require.NoError(t, d.Configure([]string{server.URL}, testSecret))
+
+	d.Send(testRecord)
+
+	deliveries := rcv.wait(t, 1)
+	require.Len(t, deliveries, 1)
+	got := deliveries[0]
+
+	assert.Equal(t, "application/json", got.header.Get("Content-Type"))
+	assert.Equal(t, audit.EventChannelArchived, got.header.Get(HeaderEvent))
+	assert.True(t, Verify(testSecret, got.body, got.header.Get(HeaderSignature)))
+	assert.False(t, Verify("other", got.body, got.header.Get(HeaderSignature)))
+
+	var event Event
+	require.NoError(t, json.Unmarshal(got.body, &event))
+	assert.Equal(t, got.header.Get(HeaderDelivery), event.ID)
+	assert.Equal(t, audit.EventChannelArchived, event.Event)
+	assert.Equal(t, model.AuditStatusSuccess, event.Status)
+	assert.Equal(t, "actor_id", event.ActorID)
+	assert.Equal(t, testRecord.Data, event.Data)
+	assert.NotZero(t, event.Timestamp)
+}
+
+func TestDispatcherRetries(t *testing.T) {
+	t.Run("server errors are retried with the same delivery id", func(t *testing.T) {
+		rcv, server := newReceiver(t, 2, http.StatusServiceUnavailable)
+		d := newTestDispatcher(t)
+		require.NoError(t, d.Configure([]string{server.URL}, testSecret))
+
+		d.Send(testRecord)
+
+		deliveries := rcv.wait(t, 3)
+		require.Len(t, deliveries, 3)
+		for _, got := range deliveries {
+			assert.Equal(t, deliveries[0].header.Get(HeaderDelivery), got.header.Get(HeaderDelivery))
+			assert.Equal(t, deliveries[0].body, got.body)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		rcv, server := newReceiver(t, 100, http.StatusInternalServerError)
+		d := newTestDispatcher(t)
+		require.NoError(t, d.Configure([]string{server.URL}, testSecret))
+
+		d.Send(testRecord)
+		rcv.wait(t, 3)
+		require.NoError(t, d.Close(time.Second))
+
+		rcv.mux.Lock()
+		defer rcv.mux.Unlock()
+		assert.Len(t, rcv.deliveries, 3)
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		rcv, server := newReceiver(t, 100, http.StatusBadRequest)
+		d := newTestDispatcher(t)
+		require.NoError(t, d.Configure([]string{server.URL}, testSecret))
+
+		d.Send(testRecord)
+		rcv.wait(t, 1)
+		require.NoError(t, d.Close(time.Second))
+
+		rcv.mux.Lock()
+		defer rcv.mux.Unlock()
+		assert.Len(t, rcv.deliveries, 1)
+	})
+}
+
+func TestDispatcherFailingTargetDoesNotBlockOthers(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+	rcv, server := newReceiver(t, 0, 0)
+
+	d := newTestDispatcher(t)
+	require.NoError(t, d.Configure([]string{failing.URL, server.URL}, testSecret))
+
+	for i := 0; i < 3; i++ {
+		d.Send(testRecord)
+	}
+
+	assert.Len(t, rcv.wait(t, 3), 3)
+}
+
+func TestDispatcherWithoutTargets(t *testing.T) {
+	d := newTestDispatcher(t)
+	d.Send(testRecord)
+
+	rcv, server := newReceiver(t, 0, 0)
+	require.NoError(t, d.Configure([]string{server.URL}, testSecret))
+	require.NoError(t, d.Configure(nil, ""))
+	d.Send(testRecord)
+	require.NoError(t, d.Close(time.Second))
+
+	rcv.mux.Lock()
+	defer rcv.mux.Unlock()
+	assert.Empty(t, rcv.deliveries)
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		urls    []string
+		secret  string
+		wantErr bool
+	}{
+		{name: "no webhooks", urls: nil, secret: ""},
+		{name: "valid", urls: []string{"https://tickets.example.com/hook", "http://localhost:8080/cmdb"}, secret: testSecret},
+		{name: "missing secret", urls: []string{"https://tickets.example.com/hook"}, secret: "", wantErr: true},
+		{name: "relative url", urls: []string{"/hook"}, secret: testSecret, wantErr: true},
+		{name: "unsupported scheme", urls: []string{"ftp://example.com/hook"}, secret: testSecret, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateConfig(tt.urls, tt.secret)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}